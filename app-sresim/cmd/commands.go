@@ -0,0 +1,90 @@
+package main
+
+import (
+	"encoding/json"
+	"flag"
+	"fmt"
+	"os"
+
+	"github.com/localstack/sresim/app-sresim/pkg/config"
+	"github.com/localstack/sresim/app-sresim/pkg/rules"
+	"github.com/localstack/sresim/app-sresim/pkg/version"
+)
+
+const usage = `Usage:
+  sresim                       start the server
+  sresim rules generate [-config FILE] [-o FILE]
+                               print the PrometheusRule for the configured SLOs and scenarios
+  sresim version [-json]       print the version, commit, build date and Go version
+`
+
+// runCommand executes a one-shot subcommand and returns the exit code.
+func runCommand(args []string) int {
+	if len(args) >= 2 && args[0] == "rules" && args[1] == "generate" {
+		return generateRules(args[2:])
+	}
+	if args[0] == "version" {
+		return printVersion(args[1:])
+	}
+	fmt.Fprint(os.Stderr, usage)
+	return 2
+}
+
+func generateRules(args []string) int {
+	fs := flag.NewFlagSet("rules generate", flag.ContinueOnError)
+	configFile := fs.String("config", os.Getenv("CONFIG_FILE"), "configuration file with SLO definitions")
+	output := fs.String("o", "", "write to FILE instead of stdout")
+	if err := fs.Parse(args); err != nil {
+		return 2
+	}
+
+	cfg := config.Default()
+	if *configFile != "" {
+		var err error
+		if cfg, err = config.Load(*configFile); err != nil {
+			fmt.Fprintf(os.Stderr, "Failed to load configuration: %v\n", err)
+			return 1
+		}
+	}
+
+	pr, err := rules.Generate(cfg.SLOs)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "Failed to generate rules: %v\n", err)
+		return 1
+	}
+	out, err := pr.Marshal()
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "Failed to render rules: %v\n", err)
+		return 1
+	}
+
+	if *output == "" {
+		os.Stdout.Write(out)
+		return 0
+	}
+	if err := os.WriteFile(*output, out, 0o644); err != nil {
+		fmt.Fprintf(os.Stderr, "Failed to write %s: %v\n", *output, err)
+		return 1
+	}
+	return 0
+}
+
+func printVersion(args []string) int {
+	fs := flag.NewFlagSet("version", flag.ContinueOnError)
+	asJSON := fs.Bool("json", false, "print JSON")
+	if err := fs.Parse(args); err != nil {
+		return 2
+	}
+
+	info := version.Get()
+	if *asJSON {
+		out, _ := json.MarshalIndent(info, "", "  ")
+		fmt.Println(string(out))
+		return 0
+	}
+	fmt.Printf("sresim %s\ncommit:     %s\nbuilt:      %s\ngo version: %s\n", info.Version, info.Commit, info.BuildDate, info.GoVersion)
+	if info.Modified {
+		fmt.Println("modified:   true")
+	}
+	return 0
+}
@@ -1,40 +1,337 @@
 package main
 
 import (
-	"log"
+	"context"
+	"errors"
+	"log/slog"
 	"net/http"
+	"os"
+	"os/signal"
+	"syscall"
+	"time"
 
+	"github.com/localstack/sresim/app-sresim/pkg/auth"
+	"github.com/localstack/sresim/app-sresim/pkg/chaos"
+	"github.com/localstack/sresim/app-sresim/pkg/config"
+	"github.com/localstack/sresim/app-sresim/pkg/events"
+	"github.com/localstack/sresim/app-sresim/pkg/experiment"
 	"github.com/localstack/sresim/app-sresim/pkg/handlers"
+	"github.com/localstack/sresim/app-sresim/pkg/health"
+	"github.com/localstack/sresim/app-sresim/pkg/loadgen"
+	"github.com/localstack/sresim/app-sresim/pkg/logging"
 	"github.com/localstack/sresim/app-sresim/pkg/metrics"
 	"github.com/localstack/sresim/app-sresim/pkg/middleware"
+	"github.com/localstack/sresim/app-sresim/pkg/notify"
+	"github.com/localstack/sresim/app-sresim/pkg/rules"
+	"github.com/localstack/sresim/app-sresim/pkg/schedule"
 	"github.com/localstack/sresim/app-sresim/pkg/simulator"
+	"github.com/localstack/sresim/app-sresim/pkg/slo"
+	"github.com/localstack/sresim/app-sresim/pkg/store"
+	"github.com/localstack/sresim/app-sresim/pkg/tracing"
+	"github.com/localstack/sresim/app-sresim/pkg/version"
 )
 
 func main() {
-	// Initialize metrics
-	if err := metrics.InitMetrics(); err != nil {
-		log.Fatalf("Failed to initialize metrics: %v", err)
+	// Run a one-shot subcommand instead of the server if one is given
+	if len(os.Args) > 1 {
+		os.Exit(runCommand(os.Args[1:]))
 	}
 
-	// Create a new HTTP multiplexer
+	// All metrics are registered with and served from one registry
+	reg := metrics.NewRegistry()
+
+	// Log JSON lines at LOG_LEVEL
+	level, levelErr := logging.ParseLevel(os.Getenv("LOG_LEVEL"))
+	slog.SetDefault(logging.New(os.Stdout, level, reg))
+	if levelErr != nil {
+		slog.Warn("Logging at info level", "error", levelErr)
+	}
+
+	// Load configuration
+	cfg, err := config.FromEnv()
+	if err != nil {
+		fatal("Failed to load configuration", err)
+	}
+
+	// Background loops run until shutdown
+	ctx, stopBackground := context.WithCancel(context.Background())
+	defer stopBackground()
+
+	// Serve OpenTelemetry metrics from the registry too
+	if err := reg.BridgeOTel(); err != nil {
+		fatal("Failed to initialize metrics", err)
+	}
+	build := version.Get()
+	reg.SetBuildInfo(build.Version, build.Commit, build.BuildDate, build.GoVersion)
+	slog.Info("Starting sresim", "version", build.Version, "commit", build.Commit, "build_date", build.BuildDate, "go_version", build.GoVersion)
+
+	// Export traces of requests and scenario runs
+	shutdownTracing, err := tracing.Init(ctx, cfg.Tracing)
+	if err != nil {
+		fatal("Failed to initialize tracing", err)
+	}
+
+	// Track SLOs from the traffic seen by the metrics middleware
+	sloTracker, err := slo.NewTracker(cfg.SLOs)
+	if err != nil {
+		fatal("Invalid SLO configuration", err)
+	}
+	reg.AddRequestObserver(sloTracker.Observe)
+	go sloTracker.Run(ctx, reg, 15*time.Second)
+
+	// Inject panics only when the configuration asks for them
+	if err := chaos.SetPanicProbability(cfg.Chaos.PanicProbability); err != nil {
+		fatal("Invalid chaos configuration", err)
+	}
+	if err := loadgen.SetLimits(cfg.LoadGen); err != nil {
+		fatal("Invalid loadgen configuration", err)
+	}
+
+	// Abort runaway scenarios at the configured guardrails
+	scenarioManager := simulator.NewManager(reg)
+	scenarioManager.SetGuardrails(*cfg.Guardrails)
+	scenarioManager.SetSLOTracker(sloTracker)
+
+	// Record runs, experiments and events so the history survives restarts
+	history, err := store.Open(cfg.History.Path, cfg.History.MaxAuditRecords)
+	if err != nil {
+		fatal("Failed to open history", err)
+	}
+	if err := scenarioManager.SetStore(history); err != nil {
+		fatal("Failed to restore scenario runs", err)
+	}
+	experimentManager := experiment.NewManager(scenarioManager, loadgen.GetManager(), reg.Gatherer())
+	experimentManager.SetSLOTracker(sloTracker)
+	if err := experimentManager.SetStore(history); err != nil {
+		fatal("Failed to restore experiments", err)
+	}
+	auditEvents, _ := events.GetBus().Subscribe(256)
+	recorded := make(chan struct{})
+	go func() {
+		history.RecordEvents(auditEvents)
+		close(recorded)
+	}()
+
+	// Let other teams know when scenarios start, stop and conclude
+	notifier, err := notify.New(cfg.Webhooks)
+	if err != nil {
+		fatal("Invalid webhook configuration", err)
+	}
+	notified := make(chan struct{})
+	if len(cfg.Webhooks) > 0 {
+		webhookEvents, _ := events.GetBus().Subscribe(256)
+		go func() {
+			notifier.Run(context.Background(), webhookEvents)
+			close(notified)
+		}()
+	} else {
+		close(notified)
+	}
+
+	// Run scheduled scenarios and experiments outside the blackouts
+	scheduler := schedule.New(schedule.RealClock{}, schedule.NewRunner(scenarioManager, experimentManager))
+	if err := scheduler.SetBlackouts(cfg.Schedules.Blackouts); err != nil {
+		fatal("Invalid blackout configuration", err)
+	}
+	if err := scheduler.SetStore(history); err != nil {
+		fatal("Failed to restore schedules", err)
+	}
+	go scheduler.Run(ctx)
+
+	// Fail readiness when the history or metrics break
+	checker := health.GetChecker()
+	checker.AddCheck("history", history.Check)
+	checker.AddCheck("metrics", reg.Check)
+
+	// Require credentials for the control endpoints
+	authenticator, err := auth.New(cfg.Auth)
+	if err != nil {
+		fatal("Invalid auth configuration", err)
+	}
+	if !authenticator.Enabled() {
+		slog.Warn("Control endpoints are open to anyone; configure auth.principals to restrict them")
+	}
+
+	// One router serves every endpoint. Routes are method-aware, so the
+	// router answers other methods with 405 and an Allow header.
 	mux := http.NewServeMux()
 
 	// Define endpoints
 	mux.HandleFunc("/simulate", handlers.SimulateHandler)
-	mux.HandleFunc("/health", handlers.HealthCheckHandler)
-	mux.Handle("/metrics", metrics.MetricsHandler())
+	mux.HandleFunc("GET /health", handlers.HealthCheckHandler)
+	mux.HandleFunc("GET /health/detailed", handlers.DetailedHealthHandler(scenarioManager))
+	mux.HandleFunc("GET /version", version.Handler)
+	mux.HandleFunc("GET /livez", checker.LivezHandler)
+	mux.HandleFunc("GET /readyz", checker.ReadyzHandler)
+	mux.HandleFunc("GET /startupz", checker.StartupzHandler)
+	mux.Handle("GET /metrics", reg.Handler())
+	mux.HandleFunc("GET /slo", sloTracker.StatusHandler)
+	mux.HandleFunc("GET /rules", rules.Handler(cfg.SLOs))
+
+	// Simulation endpoints. The query-string routes predate the path
+	// parameters and are kept for existing clients, which may use any
+	// method.
+	mux.HandleFunc("GET /scenarios", simulator.ListScenarios)
+	mux.HandleFunc("POST /scenarios/{name}/run", scenarioManager.RunHandler)
+	mux.HandleFunc("POST /scenarios/{name}/stop", scenarioManager.StopHandler)
+	mux.HandleFunc("/scenarios/run", scenarioManager.RunHandler)
+	mux.HandleFunc("/scenarios/stop", scenarioManager.StopHandler)
+	mux.HandleFunc("GET /scenarios/runs", scenarioManager.RunsHandler)
+	mux.HandleFunc("POST /alerts", scenarioManager.AlertsHandler)
+
+	// Load generation endpoints
+	mux.HandleFunc("GET /loadgen", loadgen.LoadgenHandler)
+	mux.HandleFunc("POST /loadgen", loadgen.LoadgenHandler)
+	mux.HandleFunc("POST /loadgen/stop", loadgen.StopHandler)
+
+	// Experiment endpoints
+	mux.HandleFunc("GET /experiments", experimentManager.ExperimentsHandler)
+	mux.HandleFunc("POST /experiments", experimentManager.ExperimentsHandler)
+	mux.HandleFunc("POST /experiments/stop", experimentManager.StopHandler)
+	mux.HandleFunc("GET /experiments/{id}/report", experimentManager.ReportHandler)
+	mux.HandleFunc("GET /history", history.HistoryHandler)
+	mux.HandleFunc("GET /events", events.Handler)
+	mux.HandleFunc("GET /schedules", scheduler.Handler)
+	mux.HandleFunc("POST /schedules", scheduler.Handler)
+	mux.HandleFunc("PUT /schedules", scheduler.Handler)
+	mux.HandleFunc("DELETE /schedules", scheduler.Handler)
 
-	// Simulation endpoints
-	mux.HandleFunc("/scenarios", simulator.ListScenarios)
-	mux.HandleFunc("/scenarios/run", simulator.RunScenario)
-	mux.HandleFunc("/scenarios/stop", simulator.StopScenario)
+	// Admin endpoints
+	mux.HandleFunc("POST /admin/kill", handlers.KillHandler(scenarioManager))
+	mux.HandleFunc("GET /admin/chaos", handlers.ChaosHandler)
+	mux.HandleFunc("POST /admin/chaos", handlers.ChaosHandler)
 
-	// Wrap the multiplexer with our middlewares
-	handler := middleware.ChaosMiddleware(metrics.MetricsMiddleware(mux))
+	// Wrap the router with our middlewares, outermost first: recovery, so
+	// that a panic anywhere below is answered with 500; tracing, so that the
+	// request span covers everything below; request logging, which assigns
+	// the request ID and logs the trace ID, the principal and the faults;
+	// metrics, so that rejected requests and injected faults are counted;
+	// auth; and chaos, closest to the handlers, so that unauthenticated
+	// requests do not get faults. Chaos spares the probes, /health, /metrics
+	// and /admin.
+	handler := middleware.Chain(mux,
+		middleware.Recovery(reg),
+		tracing.Middleware(mux),
+		logging.Middleware,
+		reg.Middleware(mux),
+		authenticator.Middleware,
+		middleware.ChaosMiddleware(reg, scenarioManager),
+	)
 
-	// Start the HTTP server
-	log.Println("Starting server on :8081")
-	if err := http.ListenAndServe(":8081", handler); err != nil {
-		log.Fatalf("Server failed: %v", err)
+	// Start the HTTP server. Shutting it down ends the event streams too,
+	// which would otherwise keep it from draining.
+	server := &http.Server{Addr: ":8081", Handler: handler}
+	server.RegisterOnShutdown(events.GetBus().EndStreams)
+	tlsCfg := cfg.Server.TLS
+	if tlsCfg != nil {
+		server.TLSConfig, err = auth.ServerTLSConfig(*tlsCfg)
+		if err != nil {
+			fatal("Invalid TLS configuration", err)
+		}
 	}
+	go func() {
+		slog.Info("Starting server", "addr", server.Addr, "tls", tlsCfg != nil)
+		var err error
+		if tlsCfg != nil {
+			err = server.ListenAndServeTLS(tlsCfg.CertFile, tlsCfg.KeyFile)
+		} else {
+			err = server.ListenAndServe()
+		}
+		if !errors.Is(err, http.ErrServerClosed) {
+			fatal("Server failed", err)
+		}
+	}()
+	checker.SetStarted()
+
+	// Reload the configuration on SIGHUP until asked to terminate
+	signals := make(chan os.Signal, 1)
+	signal.Notify(signals, syscall.SIGHUP, syscall.SIGTERM, syscall.SIGINT)
+	for sig := range signals {
+		if sig != syscall.SIGHUP {
+			slog.Info("Shutting down", "signal", sig.String())
+			break
+		}
+		reload(scenarioManager, scheduler, authenticator)
+	}
+	signal.Stop(signals)
+
+	// Stop taking traffic and stop everything that injects faults, so that
+	// none of it outlives the process
+	checker.SetShuttingDown()
+	grace := cfg.Server.ShutdownGracePeriod.Std()
+	shutdownCtx, cancel := context.WithTimeout(context.Background(), grace)
+	defer cancel()
+	stopBackground()
+	stoppedExperiments := experimentManager.StopAll(shutdownCtx)
+	abortedRuns := scenarioManager.KillAll("shutdown")
+	stoppedLoads := loadgen.GetManager().StopAll()
+	slog.Info("Stopped fault injection", "experiments", stoppedExperiments, "scenario_runs", len(abortedRuns), "load_runs", stoppedLoads)
+
+	// Drain in-flight requests, then abort runs that they started meanwhile
+	if err := server.Shutdown(shutdownCtx); err != nil {
+		slog.Warn("Requests still in flight after the grace period", "grace_period", grace.String(), "error", err)
+	}
+	scenarioManager.KillAll("shutdown")
+
+	// Flush the history and metrics. The bus is closed only now, so that the
+	// events of the runs aborted above are still recorded and sent.
+	events.GetBus().Close()
+	for _, done := range []chan struct{}{recorded, notified} {
+		select {
+		case <-done:
+		case <-shutdownCtx.Done():
+		}
+	}
+	sloTracker.Export(reg)
+	if err := history.Close(); err != nil {
+		slog.Error("Failed to flush history", "error", err)
+	}
+	if err := shutdownTracing(shutdownCtx); err != nil {
+		slog.Error("Failed to flush traces", "error", err)
+	}
+	slog.Info("Shutdown complete")
+}
+
+// reload reloads the configuration and applies the parts that can change at
+// runtime. SLOs still need a restart.
+func reload(sm *simulator.ScenarioManager, scheduler *schedule.Scheduler, authenticator *auth.Authenticator) {
+	cfg, err := config.FromEnv()
+	if err != nil {
+		slog.Error("Failed to reload configuration", "error", err)
+		return
+	}
+	if err := chaos.SetPanicProbability(cfg.Chaos.PanicProbability); err != nil {
+		slog.Error("Failed to reload configuration", "error", err)
+		return
+	}
+	if err := loadgen.SetLimits(cfg.LoadGen); err != nil {
+		slog.Error("Failed to reload configuration", "error", err)
+		return
+	}
+	if err := scheduler.SetBlackouts(cfg.Schedules.Blackouts); err != nil {
+		slog.Error("Failed to reload configuration", "error", err)
+		return
+	}
+	if err := authenticator.Set(cfg.Auth); err != nil {
+		slog.Error("Failed to reload configuration", "error", err)
+		return
+	}
+	sm.SetGuardrails(*cfg.Guardrails)
+	slog.Info("Configuration reloaded")
+	events.Publish(events.Event{
+		Type:    events.ConfigReloaded,
+		Message: "guardrails, blackouts, principals, chaos settings and loadgen limits reloaded",
+		Data: map[string]interface{}{
+			"guardrails": cfg.Guardrails,
+			"blackouts":  cfg.Schedules.Blackouts,
+			"chaos":      cfg.Chaos,
+			"loadgen":    cfg.LoadGen,
+		},
+	})
+}
+
+// fatal logs err and exits.
+func fatal(msg string, err error) {
+	slog.Error(msg, "error", err)
+	os.Exit(1)
 }
@@ -0,0 +1,72 @@
+package main
+
+import (
+	"flag"
+	"fmt"
+	"net/url"
+	"strings"
+	"text/tabwriter"
+)
+
+func (c *cli) chaosRules(args []string) error {
+	if _, err := c.parseArgs(flag.NewFlagSet("chaos rules", flag.ContinueOnError), args, 0, 0); err != nil {
+		return err
+	}
+	var status chaosStatus
+	raw, err := c.client.getJSON("/admin/chaos", nil, &status)
+	if err != nil {
+		return err
+	}
+	return c.printChaos(raw, status)
+}
+
+func (c *cli) chaosEnable(args []string) error {
+	return c.setChaos("chaos enable", args, true)
+}
+
+func (c *cli) chaosDisable(args []string) error {
+	return c.setChaos("chaos disable", args, false)
+}
+
+func (c *cli) setChaos(name string, args []string, enabled bool) error {
+	if _, err := c.parseArgs(flag.NewFlagSet(name, flag.ContinueOnError), args, 0, 0); err != nil {
+		return err
+	}
+	var status chaosStatus
+	query := url.Values{"enabled": {fmt.Sprint(enabled)}}
+	raw, err := c.client.postJSON("/admin/chaos", query, nil, &status)
+	if err != nil {
+		return err
+	}
+	return c.printChaos(raw, status)
+}
+
+// printChaos prints v as JSON, or status as a table.
+func (c *cli) printChaos(v interface{}, status chaosStatus) error {
+	return c.print(v, func(w *tabwriter.Writer) {
+		state := "disabled"
+		if status.Enabled {
+			state = "enabled"
+		}
+		fmt.Fprintf(w, "Chaos middleware %s\n\n", state)
+		row(w, "RULE", "PROBABILITY", "EFFECT")
+		for _, rule := range status.Rules {
+			row(w, rule.Name, fmt.Sprintf("%.0f%%", rule.Probability*100), rule.Effect)
+		}
+	})
+}
+
+func (c *cli) kill(args []string) error {
+	if _, err := c.parseArgs(flag.NewFlagSet("kill", flag.ContinueOnError), args, 0, 0); err != nil {
+		return err
+	}
+	var resp killResponse
+	raw, err := c.client.postJSON("/admin/kill", nil, nil, &resp)
+	if err != nil {
+		return err
+	}
+	return c.print(raw, func(w *tabwriter.Writer) {
+		row(w, "STATUS", "ABORTED RUNS", "STOPPED LOADS", "CHAOS")
+		row(w, resp.Status, strings.Join(resp.AbortedRuns, ","), resp.StoppedLoads, resp.ChaosEnabled)
+	})
+}
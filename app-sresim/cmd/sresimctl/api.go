@@ -0,0 +1,147 @@
+package main
+
+import "time"
+
+// The types below mirror the parts of the sresim API responses the tables
+// show. They are declared here rather than imported from the server
+// packages, which would link the server, its metrics and its package state
+// into the client. With -o json the responses are printed as received, so
+// fields left out here are not lost.
+
+// Experiment execution states.
+const (
+	statusPending = "pending"
+	statusRunning = "running"
+	statusPassed  = "passed"
+)
+
+// formatMarkdown is the default experiment report format.
+const formatMarkdown = "md"
+
+type scenario struct {
+	Name       string                 `json:"name"`
+	Parameters map[string]interface{} `json:"parameters"`
+}
+
+// runRequest is the body of POST /scenarios/{name}/run. Durations are
+// sent in Go syntax, e.g. "1m0s".
+type runRequest struct {
+	Parameters map[string]interface{} `json:"parameters,omitempty"`
+	Guardrails *guardrails            `json:"guardrails,omitempty"`
+	Load       *loadConfig            `json:"load,omitempty"`
+}
+
+type guardrails struct {
+	MaxDuration string `json:"max_duration,omitempty"`
+	MaxRSSMB    int64  `json:"max_rss_mb,omitempty"`
+}
+
+type scenarioResponse struct {
+	Status     string                 `json:"status"`
+	Parameters map[string]interface{} `json:"parameters"`
+	RunID      string                 `json:"run_id,omitempty"`
+	LoadRunID  string                 `json:"load_run_id,omitempty"`
+}
+
+type scenarioRun struct {
+	ID          string     `json:"id"`
+	Scenario    string     `json:"scenario"`
+	Status      string     `json:"status"`
+	StartedAt   *time.Time `json:"started_at,omitempty"`
+	EndedAt     *time.Time `json:"ended_at,omitempty"`
+	AbortReason string     `json:"abort_reason,omitempty"`
+	Verdict     *struct {
+		Result string `json:"result"`
+	} `json:"verdict,omitempty"`
+	Detection *struct {
+		Alert        string  `json:"alert"`
+		TimeToDetect float64 `json:"time_to_detect_seconds"`
+	} `json:"detection,omitempty"`
+}
+
+// loadConfig is the body of POST /loadgen; unset fields take the server
+// defaults.
+type loadConfig struct {
+	Target   string  `json:"target,omitempty"`
+	Method   string  `json:"method,omitempty"`
+	Model    string  `json:"model,omitempty"`
+	Rate     float64 `json:"rate,omitempty"`
+	Workers  int     `json:"workers,omitempty"`
+	Duration string  `json:"duration,omitempty"`
+	Seed     int64   `json:"seed,omitempty"`
+}
+
+type loadRun struct {
+	ID     string `json:"id"`
+	Status string `json:"status"`
+	Config struct {
+		Target string `json:"target"`
+	} `json:"config"`
+	Result struct {
+		Requests   int64   `json:"requests"`
+		Errors     int64   `json:"errors"`
+		Throughput float64 `json:"throughput_rps"`
+		Latency    struct {
+			P99 float64 `json:"p99_ms"`
+		} `json:"latency"`
+	} `json:"result"`
+}
+
+type execution struct {
+	ID        string        `json:"id"`
+	Name      string        `json:"name"`
+	Status    string        `json:"status"`
+	StartedAt time.Time     `json:"started_at"`
+	EndedAt   *time.Time    `json:"ended_at,omitempty"`
+	Error     string        `json:"error,omitempty"`
+	Steps     []*stepResult `json:"steps"`
+	Rollback  []*stepResult `json:"rollback,omitempty"`
+}
+
+type stepResult struct {
+	Name   string        `json:"name"`
+	Status string        `json:"status"`
+	Error  string        `json:"error,omitempty"`
+	Steps  []*stepResult `json:"steps,omitempty"`
+}
+
+type scheduleStatus struct {
+	ID         string `json:"id"`
+	Name       string `json:"name"`
+	Cron       string `json:"cron"`
+	Scenario   string `json:"scenario,omitempty"`
+	Experiment *struct {
+		Name string `json:"name"`
+	} `json:"experiment,omitempty"`
+	Paused  bool       `json:"paused,omitempty"`
+	NextRun *time.Time `json:"next_run,omitempty"`
+	LastRun *struct {
+		ScheduledAt time.Time `json:"scheduled_at"`
+		Status      string    `json:"status"`
+		Reason      string    `json:"reason,omitempty"`
+	} `json:"last_run,omitempty"`
+}
+
+type chaosStatus struct {
+	Enabled bool `json:"enabled"`
+	Rules   []struct {
+		Name        string  `json:"name"`
+		Probability float64 `json:"probability"`
+		Effect      string  `json:"effect"`
+	} `json:"rules"`
+}
+
+type killResponse struct {
+	Status       string   `json:"status"`
+	AbortedRuns  []string `json:"aborted_runs"`
+	StoppedLoads int      `json:"stopped_loads"`
+	ChaosEnabled bool     `json:"chaos_enabled"`
+}
+
+type event struct {
+	Type     string    `json:"type"`
+	Time     time.Time `json:"time"`
+	RunID    string    `json:"run_id,omitempty"`
+	Scenario string    `json:"scenario,omitempty"`
+	Message  string    `json:"message,omitempty"`
+}
@@ -0,0 +1,153 @@
+package main
+
+import (
+	"crypto/tls"
+	"crypto/x509"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"os"
+	"strings"
+	"text/tabwriter"
+)
+
+// client calls the sresim HTTP API.
+type client struct {
+	endpoint string
+	// token is sent as bearer token if set.
+	token string
+	http  *http.Client
+}
+
+// newTransport returns the transport for a client certificate and a CA to
+// verify the server with, or nil for the default transport if neither is
+// given.
+func newTransport(certFile, keyFile, caFile string) (http.RoundTripper, error) {
+	if certFile == "" && keyFile == "" && caFile == "" {
+		return nil, nil
+	}
+	tlsConfig := &tls.Config{MinVersion: tls.VersionTLS12}
+	if certFile != "" || keyFile != "" {
+		cert, err := tls.LoadX509KeyPair(certFile, keyFile)
+		if err != nil {
+			return nil, fmt.Errorf("client certificate: %w", err)
+		}
+		tlsConfig.Certificates = []tls.Certificate{cert}
+	}
+	if caFile != "" {
+		pem, err := os.ReadFile(caFile)
+		if err != nil {
+			return nil, err
+		}
+		tlsConfig.RootCAs = x509.NewCertPool()
+		if !tlsConfig.RootCAs.AppendCertsFromPEM(pem) {
+			return nil, fmt.Errorf("no certificates in %s", caFile)
+		}
+	}
+	transport := http.DefaultTransport.(*http.Transport).Clone()
+	transport.TLSClientConfig = tlsConfig
+	return transport, nil
+}
+
+// authorize adds the credentials of c to req.
+func (c *client) authorize(req *http.Request) {
+	if c.token != "" {
+		req.Header.Set("Authorization", "Bearer "+c.token)
+	}
+}
+
+// apiError is a non-2xx response from sresim.
+type apiError struct {
+	status  int
+	message string
+}
+
+func (e *apiError) Error() string {
+	return fmt.Sprintf("%s (HTTP %d)", e.message, e.status)
+}
+
+// do sends a request and returns the response body.
+func (c *client) do(method, path string, query url.Values, body io.Reader, contentType string) ([]byte, error) {
+	u := c.endpoint + path
+	if len(query) > 0 {
+		u += "?" + query.Encode()
+	}
+	req, err := http.NewRequest(method, u, body)
+	if err != nil {
+		return nil, err
+	}
+	if contentType != "" {
+		req.Header.Set("Content-Type", contentType)
+	}
+	c.authorize(req)
+	resp, err := c.http.Do(req)
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+	data, err := io.ReadAll(resp.Body)
+	if err != nil {
+		return nil, err
+	}
+	if resp.StatusCode/100 != 2 {
+		return nil, &apiError{status: resp.StatusCode, message: strings.TrimSpace(string(data))}
+	}
+	return data, nil
+}
+
+// getJSON decodes the response of a GET request into v and returns it as
+// received.
+func (c *client) getJSON(path string, query url.Values, v interface{}) (json.RawMessage, error) {
+	data, err := c.do(http.MethodGet, path, query, nil, "")
+	if err != nil {
+		return nil, err
+	}
+	return data, json.Unmarshal(data, v)
+}
+
+// postJSON sends body as JSON, if it is not nil, decodes the response into
+// v and returns it as received.
+func (c *client) postJSON(path string, query url.Values, body, v interface{}) (json.RawMessage, error) {
+	var reader io.Reader
+	contentType := ""
+	if body != nil {
+		data, err := json.Marshal(body)
+		if err != nil {
+			return nil, err
+		}
+		reader = strings.NewReader(string(data))
+		contentType = "application/json"
+	}
+	data, err := c.do(http.MethodPost, path, query, reader, contentType)
+	if err != nil {
+		return nil, err
+	}
+	return data, json.Unmarshal(data, v)
+}
+
+// print writes v as indented JSON with -o json, and otherwise calls table.
+func (c *cli) print(v interface{}, table func(w *tabwriter.Writer)) error {
+	if c.output == "json" {
+		enc := json.NewEncoder(c.out)
+		enc.SetIndent("", "  ")
+		return enc.Encode(v)
+	}
+	w := tabwriter.NewWriter(c.out, 0, 0, 2, ' ', 0)
+	table(w)
+	return w.Flush()
+}
+
+// row writes tab-separated cells, showing empty ones as "-".
+func row(w io.Writer, cells ...interface{}) {
+	parts := make([]string, len(cells))
+	for i, cell := range cells {
+		s := fmt.Sprint(cell)
+		if s == "" {
+			s = "-"
+		}
+		parts[i] = s
+	}
+	fmt.Fprintln(w, strings.Join(parts, "\t"))
+}
@@ -0,0 +1,79 @@
+package main
+
+import (
+	"bufio"
+	"encoding/json"
+	"flag"
+	"fmt"
+	"net/http"
+	"net/url"
+	"strings"
+)
+
+// watchEvents prints the live event stream until the server closes it.
+func (c *cli) watchEvents(args []string) error {
+	flags := flag.NewFlagSet("watch", flag.ContinueOnError)
+	types := flags.String("type", "", "comma-separated event types, e.g. scenario.*,breaker.state_changed")
+	runID := flags.String("run", "", "only events of this scenario run")
+	if _, err := c.parseArgs(flags, args, 0, 0); err != nil {
+		return err
+	}
+	query := url.Values{}
+	if *types != "" {
+		query.Set("type", *types)
+	}
+	if *runID != "" {
+		query.Set("run_id", *runID)
+	}
+
+	// The stream stays open indefinitely, so it must not use the request
+	// timeout of the other commands.
+	req, err := http.NewRequest(http.MethodGet, c.client.endpoint+"/events?"+query.Encode(), nil)
+	if err != nil {
+		return err
+	}
+	req.Header.Set("Accept", "text/event-stream")
+	c.client.authorize(req)
+	resp, err := (&http.Client{Transport: c.client.http.Transport}).Do(req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		return &apiError{status: resp.StatusCode, message: resp.Status}
+	}
+
+	lines := bufio.NewScanner(resp.Body)
+	lines.Buffer(make([]byte, 64*1024), 1024*1024)
+	for lines.Scan() {
+		data, ok := strings.CutPrefix(lines.Text(), "data: ")
+		if !ok {
+			continue
+		}
+		if c.output == "json" {
+			fmt.Fprintln(c.out, data)
+			continue
+		}
+		var e event
+		if err := json.Unmarshal([]byte(data), &e); err != nil {
+			return err
+		}
+		c.printEvent(e)
+	}
+	return lines.Err()
+}
+
+// printEvent writes one line of the event timeline.
+func (c *cli) printEvent(e event) {
+	line := fmt.Sprintf("%s  %-22s", e.Time.Local().Format("15:04:05"), e.Type)
+	if e.Scenario != "" {
+		line += "  " + e.Scenario
+	}
+	if e.RunID != "" {
+		line += "  run=" + e.RunID
+	}
+	if e.Message != "" {
+		line += "  " + e.Message
+	}
+	fmt.Fprintln(c.out, line)
+}
@@ -0,0 +1,144 @@
+package main
+
+import (
+	"bytes"
+	"encoding/json"
+	"flag"
+	"fmt"
+	"net/http"
+	"net/url"
+	"os"
+	"text/tabwriter"
+	"time"
+)
+
+func (c *cli) experimentsApply(args []string) error {
+	flags := flag.NewFlagSet("experiments apply", flag.ContinueOnError)
+	watch := flags.Bool("watch", false, "follow the experiment until it finishes")
+	interval := flags.Duration("interval", time.Second, "poll interval of -watch")
+	files, err := c.parseArgs(flags, args, 1, 1)
+	if err != nil {
+		return err
+	}
+	data, err := os.ReadFile(files[0])
+	if err != nil {
+		return err
+	}
+	body, err := c.client.do(http.MethodPost, "/experiments", nil, bytes.NewReader(data), "application/yaml")
+	if err != nil {
+		return err
+	}
+	var status execution
+	if err := json.Unmarshal(body, &status); err != nil {
+		return err
+	}
+	if *watch {
+		return c.watch(status.ID, *interval)
+	}
+	return c.printExecutions(json.RawMessage(body), []execution{status})
+}
+
+func (c *cli) experimentsList(args []string) error {
+	if _, err := c.parseArgs(flag.NewFlagSet("experiments list", flag.ContinueOnError), args, 0, 0); err != nil {
+		return err
+	}
+	var executions []execution
+	raw, err := c.client.getJSON("/experiments", nil, &executions)
+	if err != nil {
+		return err
+	}
+	return c.printExecutions(raw, executions)
+}
+
+func (c *cli) experimentsWatch(args []string) error {
+	flags := flag.NewFlagSet("experiments watch", flag.ContinueOnError)
+	interval := flags.Duration("interval", time.Second, "poll interval")
+	ids, err := c.parseArgs(flags, args, 1, 1)
+	if err != nil {
+		return err
+	}
+	return c.watch(ids[0], *interval)
+}
+
+func (c *cli) experimentsReport(args []string) error {
+	flags := flag.NewFlagSet("experiments report", flag.ContinueOnError)
+	format := flags.String("format", formatMarkdown, "report format: junit, json or md")
+	ids, err := c.parseArgs(flags, args, 1, 1)
+	if err != nil {
+		return err
+	}
+	report, err := c.client.do(http.MethodGet, "/experiments/"+url.PathEscape(ids[0])+"/report", url.Values{"format": {*format}}, nil, "")
+	if err != nil {
+		return err
+	}
+	_, err = c.out.Write(report)
+	return err
+}
+
+func (c *cli) experimentsStop(args []string) error {
+	ids, err := c.parseArgs(flag.NewFlagSet("experiments stop", flag.ContinueOnError), args, 1, 1)
+	if err != nil {
+		return err
+	}
+	var status execution
+	raw, err := c.client.postJSON("/experiments/stop", url.Values{"id": ids}, nil, &status)
+	if err != nil {
+		return err
+	}
+	return c.printExecutions(raw, []execution{status})
+}
+
+// watch polls an execution and prints every step transition until it has
+// finished. It fails unless the experiment passed.
+func (c *cli) watch(id string, interval time.Duration) error {
+	seen := map[string]string{}
+	for {
+		var status execution
+		raw, err := c.client.getJSON("/experiments", url.Values{"id": {id}}, &status)
+		if err != nil {
+			return err
+		}
+		if c.output == "table" {
+			c.printTransitions(seen, "", status.Steps)
+			c.printTransitions(seen, "rollback / ", status.Rollback)
+		}
+		if status.Status != statusPending && status.Status != statusRunning {
+			if err := c.printExecutions(raw, []execution{status}); err != nil {
+				return err
+			}
+			if status.Status != statusPassed {
+				return exitError(1)
+			}
+			return nil
+		}
+		time.Sleep(interval)
+	}
+}
+
+// printTransitions prints the steps whose status changed since the last
+// poll, including the branches of parallel steps.
+func (c *cli) printTransitions(seen map[string]string, prefix string, steps []*stepResult) {
+	for i, step := range steps {
+		name := fmt.Sprintf("%s%d. %s", prefix, i+1, step.Name)
+		if seen[name] != step.Status {
+			seen[name] = step.Status
+			line := fmt.Sprintf("%s  %-40s %s", time.Now().Format("15:04:05"), name, step.Status)
+			if step.Error != "" {
+				line += ": " + step.Error
+			}
+			fmt.Fprintln(c.out, line)
+		}
+		c.printTransitions(seen, name+" / ", step.Steps)
+	}
+}
+
+// printExecutions prints v as JSON, or executions as a table.
+func (c *cli) printExecutions(v interface{}, executions []execution) error {
+	return c.print(v, func(w *tabwriter.Writer) {
+		row(w, "EXPERIMENT", "NAME", "STATUS", "STARTED", "DURATION", "ERROR")
+		for _, e := range executions {
+			started := e.StartedAt
+			row(w, e.ID, e.Name, e.Status, formatTime(&started), formatDuration(&started, e.EndedAt), e.Error)
+		}
+	})
+}
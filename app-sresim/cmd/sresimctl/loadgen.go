@@ -0,0 +1,82 @@
+package main
+
+import (
+	"flag"
+	"fmt"
+	"net/url"
+	"text/tabwriter"
+	"time"
+)
+
+func (c *cli) loadgenStart(args []string) error {
+	flags := flag.NewFlagSet("loadgen start", flag.ContinueOnError)
+	var cfg loadConfig
+	flags.StringVar(&cfg.Target, "target", "", "URL to send requests to (server default when empty)")
+	flags.StringVar(&cfg.Method, "method", "", "HTTP method")
+	flags.StringVar(&cfg.Model, "model", "", "load model: open or closed")
+	flags.Float64Var(&cfg.Rate, "rate", 0, "requests per second of an open model")
+	flags.IntVar(&cfg.Workers, "workers", 0, "concurrent workers of a closed model")
+	flags.Int64Var(&cfg.Seed, "seed", 0, "seed of the request template random values")
+	duration := flags.Duration("duration", time.Minute, "how long to generate load")
+	if _, err := c.parseArgs(flags, args, 0, 0); err != nil {
+		return err
+	}
+	cfg.Duration = duration.String()
+
+	var status loadRun
+	raw, err := c.client.postJSON("/loadgen", nil, cfg, &status)
+	if err != nil {
+		return err
+	}
+	return c.printLoads(raw, []loadRun{status})
+}
+
+func (c *cli) loadgenList(args []string) error {
+	if _, err := c.parseArgs(flag.NewFlagSet("loadgen list", flag.ContinueOnError), args, 0, 0); err != nil {
+		return err
+	}
+	var runs []loadRun
+	raw, err := c.client.getJSON("/loadgen", nil, &runs)
+	if err != nil {
+		return err
+	}
+	return c.printLoads(raw, runs)
+}
+
+func (c *cli) loadgenStatus(args []string) error {
+	ids, err := c.parseArgs(flag.NewFlagSet("loadgen status", flag.ContinueOnError), args, 1, 1)
+	if err != nil {
+		return err
+	}
+	var status loadRun
+	raw, err := c.client.getJSON("/loadgen", url.Values{"id": ids}, &status)
+	if err != nil {
+		return err
+	}
+	return c.printLoads(raw, []loadRun{status})
+}
+
+func (c *cli) loadgenStop(args []string) error {
+	ids, err := c.parseArgs(flag.NewFlagSet("loadgen stop", flag.ContinueOnError), args, 1, 1)
+	if err != nil {
+		return err
+	}
+	var status loadRun
+	raw, err := c.client.postJSON("/loadgen/stop", url.Values{"id": ids}, nil, &status)
+	if err != nil {
+		return err
+	}
+	return c.printLoads(raw, []loadRun{status})
+}
+
+// printLoads prints v as JSON, or runs as a table.
+func (c *cli) printLoads(v interface{}, runs []loadRun) error {
+	return c.print(v, func(w *tabwriter.Writer) {
+		row(w, "LOAD RUN", "STATUS", "TARGET", "REQUESTS", "ERRORS", "RPS", "P99")
+		for _, run := range runs {
+			res := run.Result
+			row(w, run.ID, run.Status, run.Config.Target, res.Requests, res.Errors,
+				fmt.Sprintf("%.1f", res.Throughput), fmt.Sprintf("%.1fms", res.Latency.P99))
+		}
+	})
+}
@@ -0,0 +1,211 @@
+// Command sresimctl is the command-line client of the sresim control API.
+package main
+
+import (
+	"errors"
+	"flag"
+	"fmt"
+	"io"
+	"net/http"
+	"os"
+	"sort"
+	"strings"
+	"time"
+)
+
+// defaultEndpoint is the sresim address used when neither -endpoint nor
+// SRESIM_ENDPOINT is set.
+const defaultEndpoint = "http://localhost:8081"
+
+// errUsage is returned for invalid command lines; the usage has already
+// been printed.
+var errUsage = errors.New("usage")
+
+// exitError ends the program with the given status without printing an
+// error, e.g. when a watched experiment fails.
+type exitError int
+
+func (e exitError) Error() string {
+	return fmt.Sprintf("exit status %d", int(e))
+}
+
+// cli carries the global options to the commands.
+type cli struct {
+	client *client
+	out    io.Writer
+	errOut io.Writer
+	output string
+}
+
+type command struct {
+	usage string
+	run   func(c *cli, args []string) error
+}
+
+var commands = map[string]map[string]command{
+	"scenarios": {
+		"list":   {"", (*cli).scenariosList},
+		"run":    {"NAME [-p key=value]... [flags]", (*cli).scenariosRun},
+		"stop":   {"NAME", (*cli).scenariosStop},
+		"status": {"[RUN_ID]", (*cli).scenariosStatus},
+	},
+	"experiments": {
+		"apply":  {"FILE [-watch]", (*cli).experimentsApply},
+		"list":   {"", (*cli).experimentsList},
+		"watch":  {"ID [-interval DURATION]", (*cli).experimentsWatch},
+		"report": {"ID [-format junit|json|md]", (*cli).experimentsReport},
+		"stop":   {"ID", (*cli).experimentsStop},
+	},
+	"schedules": {
+		"apply":  {"FILE [-id ID]", (*cli).schedulesApply},
+		"list":   {"", (*cli).schedulesList},
+		"delete": {"ID", (*cli).schedulesDelete},
+	},
+	"chaos": {
+		"rules":   {"", (*cli).chaosRules},
+		"enable":  {"", (*cli).chaosEnable},
+		"disable": {"", (*cli).chaosDisable},
+	},
+	"loadgen": {
+		"start":  {"[-target URL] [-rate N] [-duration D] [flags]", (*cli).loadgenStart},
+		"list":   {"", (*cli).loadgenList},
+		"status": {"ID", (*cli).loadgenStatus},
+		"stop":   {"ID", (*cli).loadgenStop},
+	},
+}
+
+func main() {
+	os.Exit(run(os.Args[1:], os.Stdout, os.Stderr))
+}
+
+func run(args []string, stdout, stderr io.Writer) int {
+	flags := flag.NewFlagSet("sresimctl", flag.ContinueOnError)
+	flags.SetOutput(stderr)
+	endpoint := flags.String("endpoint", envOr("SRESIM_ENDPOINT", defaultEndpoint), "sresim base URL (env SRESIM_ENDPOINT)")
+	output := flags.String("o", "table", "output format: table or json")
+	timeout := flags.Duration("timeout", 30*time.Second, "request timeout")
+	token := flags.String("token", os.Getenv("SRESIM_TOKEN"), "bearer token (env SRESIM_TOKEN)")
+	cert := flags.String("cert", "", "client certificate file, for client certificate authentication")
+	key := flags.String("key", "", "private key file of -cert")
+	caCert := flags.String("cacert", "", "CA certificate file to verify an HTTPS endpoint with")
+	flags.Usage = func() { usage(stderr, flags) }
+	if err := flags.Parse(args); err != nil {
+		return 2
+	}
+	if *output != "table" && *output != "json" {
+		fmt.Fprintf(stderr, "sresimctl: unknown output format %q\n", *output)
+		return 2
+	}
+	if flags.NArg() == 0 {
+		flags.Usage()
+		return 2
+	}
+
+	transport, err := newTransport(*cert, *key, *caCert)
+	if err != nil {
+		fmt.Fprintf(stderr, "sresimctl: %v\n", err)
+		return 2
+	}
+	c := &cli{
+		client: &client{
+			endpoint: strings.TrimRight(*endpoint, "/"),
+			token:    *token,
+			http:     &http.Client{Timeout: *timeout, Transport: transport},
+		},
+		out:    stdout,
+		errOut: stderr,
+		output: *output,
+	}
+	err = c.dispatch(flags.Args())
+	var exit exitError
+	switch {
+	case err == nil:
+		return 0
+	case errors.Is(err, errUsage):
+		return 2
+	case errors.As(err, &exit):
+		return int(exit)
+	default:
+		fmt.Fprintf(stderr, "sresimctl: %v\n", err)
+		return 1
+	}
+}
+
+func (c *cli) dispatch(args []string) error {
+	switch args[0] {
+	case "kill":
+		return c.kill(args[1:])
+	case "watch":
+		return c.watchEvents(args[1:])
+	}
+	group, ok := commands[args[0]]
+	if !ok {
+		fmt.Fprintf(c.errOut, "sresimctl: unknown command %q\n", args[0])
+		return errUsage
+	}
+	if len(args) < 2 {
+		fmt.Fprintf(c.errOut, "sresimctl: %s needs a subcommand: %s\n", args[0], strings.Join(subcommands(group), ", "))
+		return errUsage
+	}
+	cmd, ok := group[args[1]]
+	if !ok {
+		fmt.Fprintf(c.errOut, "sresimctl: unknown command %q %q\n", args[0], args[1])
+		return errUsage
+	}
+	return cmd.run(c, args[2:])
+}
+
+func usage(w io.Writer, flags *flag.FlagSet) {
+	fmt.Fprintf(w, "Usage: sresimctl [flags] COMMAND\n\nCommands:\n")
+	groups := make([]string, 0, len(commands))
+	for name := range commands {
+		groups = append(groups, name)
+	}
+	sort.Strings(groups)
+	for _, name := range groups {
+		for _, sub := range subcommands(commands[name]) {
+			fmt.Fprintln(w, strings.TrimRight(fmt.Sprintf("  %s %s %s", name, sub, commands[name][sub].usage), " "))
+		}
+	}
+	fmt.Fprintf(w, "  watch [-type TYPES] [-run RUN_ID]\n  kill\n\nFlags:\n")
+	flags.PrintDefaults()
+}
+
+func subcommands(group map[string]command) []string {
+	names := make([]string, 0, len(group))
+	for name := range group {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names
+}
+
+// parseArgs parses flags that may appear before or after the positional
+// arguments and checks the number of positional arguments.
+func (c *cli) parseArgs(flags *flag.FlagSet, args []string, min, max int) ([]string, error) {
+	flags.SetOutput(c.errOut)
+	var positional []string
+	for {
+		if err := flags.Parse(args); err != nil {
+			return nil, errUsage
+		}
+		args = flags.Args()
+		if len(args) == 0 {
+			break
+		}
+		positional = append(positional, args[0])
+		args = args[1:]
+	}
+	if len(positional) < min || len(positional) > max {
+		fmt.Fprintf(c.errOut, "sresimctl: %s: wrong number of arguments\n", flags.Name())
+		return nil, errUsage
+	}
+	return positional, nil
+}
+
+func envOr(key, fallback string) string {
+	if v := os.Getenv(key); v != "" {
+		return v
+	}
+	return fallback
+}
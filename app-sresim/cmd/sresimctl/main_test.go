@@ -0,0 +1,188 @@
+package main
+
+import (
+	"bytes"
+	"encoding/json"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/localstack/sresim/app-sresim/pkg/experiment"
+	"github.com/localstack/sresim/app-sresim/pkg/schedule"
+	"github.com/localstack/sresim/app-sresim/pkg/simulator"
+)
+
+// fakeServer records the requests it receives and answers them from
+// responses, keyed by method and path.
+type fakeServer struct {
+	*httptest.Server
+	requests       []string
+	bodies         []string
+	authorizations []string
+}
+
+func newFakeServer(t *testing.T, responses map[string]interface{}) *fakeServer {
+	f := &fakeServer{}
+	f.Server = httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		body, _ := io.ReadAll(r.Body)
+		f.requests = append(f.requests, r.Method+" "+r.URL.RequestURI())
+		f.bodies = append(f.bodies, string(body))
+		f.authorizations = append(f.authorizations, r.Header.Get("Authorization"))
+		resp, ok := responses[r.Method+" "+r.URL.Path]
+		if !ok {
+			http.Error(w, "Scenario not found", http.StatusNotFound)
+			return
+		}
+		if s, ok := resp.(string); ok {
+			io.WriteString(w, s)
+			return
+		}
+		json.NewEncoder(w).Encode(resp)
+	}))
+	t.Cleanup(f.Close)
+	return f
+}
+
+func runCLI(f *fakeServer, args ...string) (int, string, string) {
+	var stdout, stderr bytes.Buffer
+	code := run(append([]string{"-endpoint", f.URL}, args...), &stdout, &stderr)
+	return code, stdout.String(), stderr.String()
+}
+
+func TestScenariosRun(t *testing.T) {
+	f := newFakeServer(t, map[string]interface{}{
+		"POST /scenarios/latency/run": simulator.ScenarioResponse{Status: "running", RunID: "run-1", Parameters: map[string]interface{}{"delay_ms": 50}},
+	})
+
+	code, out, errOut := runCLI(f, "scenarios", "run", "latency", "-p", "delay_ms=50", "-p", "mode=slow", "-max-duration", "1m")
+	require.Equal(t, 0, code, errOut)
+	assert.Equal(t, []string{"POST /scenarios/latency/run"}, f.requests)
+	assert.JSONEq(t, `{"parameters":{"delay_ms":50,"mode":"slow"},"guardrails":{"max_duration":"1m0s"}}`, f.bodies[0])
+	assert.Contains(t, out, "RUN ID")
+	assert.Contains(t, out, "run-1")
+	assert.Contains(t, out, "delay_ms=50")
+}
+
+func TestToken(t *testing.T) {
+	f := newFakeServer(t, map[string]interface{}{
+		"GET /scenarios": map[string]simulator.Scenario{},
+	})
+
+	code, _, errOut := runCLI(f, "scenarios", "list")
+	require.Equal(t, 0, code, errOut)
+	t.Setenv("SRESIM_TOKEN", "from-env")
+	code, _, errOut = runCLI(f, "scenarios", "list")
+	require.Equal(t, 0, code, errOut)
+	code, _, errOut = runCLI(f, "-token", "from-flag", "scenarios", "list")
+	require.Equal(t, 0, code, errOut)
+
+	assert.Equal(t, []string{"", "Bearer from-env", "Bearer from-flag"}, f.authorizations)
+}
+
+func TestJSONOutput(t *testing.T) {
+	f := newFakeServer(t, map[string]interface{}{
+		"GET /scenarios/runs": []simulator.Run{{ID: "run-1", Scenario: "latency", Status: simulator.RunAborted, AbortReason: "max duration",
+			Parameters: map[string]interface{}{"delay_ms": 50}}},
+	})
+
+	code, out, _ := runCLI(f, "-o", "json", "scenarios", "status")
+	require.Equal(t, 0, code)
+	var runs []simulator.Run
+	require.NoError(t, json.Unmarshal([]byte(out), &runs))
+	require.Len(t, runs, 1)
+	assert.Equal(t, "max duration", runs[0].AbortReason)
+	assert.Equal(t, map[string]interface{}{"delay_ms": float64(50)}, runs[0].Parameters, "fields the table leaves out are printed too")
+}
+
+func TestExperimentsApplyWatch(t *testing.T) {
+	status := experiment.ExecutionStatus{
+		ID:     "exp-1",
+		Name:   "gameday",
+		Status: experiment.StatusFailed,
+		Steps:  []*experiment.StepResult{{Name: "check", Kind: "assert", Status: experiment.StatusFailed, Error: "p99 too high"}},
+	}
+	f := newFakeServer(t, map[string]interface{}{
+		"POST /experiments": status,
+		"GET /experiments":  status,
+	})
+	file := filepath.Join(t.TempDir(), "exp.yaml")
+	require.NoError(t, os.WriteFile(file, []byte("name: gameday\n"), 0o644))
+
+	code, out, errOut := runCLI(f, "experiments", "apply", file, "-watch")
+	assert.Equal(t, 1, code, "a failed experiment exits non-zero")
+	assert.Empty(t, errOut)
+	assert.Equal(t, []string{"POST /experiments", "GET /experiments?id=exp-1"}, f.requests)
+	assert.Equal(t, "name: gameday\n", f.bodies[0])
+	assert.Contains(t, out, "1. check")
+	assert.Contains(t, out, "p99 too high")
+}
+
+func TestExperimentsReport(t *testing.T) {
+	f := newFakeServer(t, map[string]interface{}{
+		"GET /experiments/exp-1/report": "<testsuites/>",
+	})
+
+	code, out, _ := runCLI(f, "experiments", "report", "exp-1", "-format", "junit")
+	require.Equal(t, 0, code)
+	assert.Equal(t, "<testsuites/>", out)
+	assert.Equal(t, []string{"GET /experiments/exp-1/report?format=junit"}, f.requests)
+}
+
+func TestErrors(t *testing.T) {
+	f := newFakeServer(t, nil)
+
+	code, _, errOut := runCLI(f, "scenarios", "stop", "nope")
+	assert.Equal(t, 1, code)
+	assert.Contains(t, errOut, "Scenario not found (HTTP 404)")
+
+	code, _, _ = runCLI(f, "scenarios", "stop")
+	assert.Equal(t, 2, code, "missing argument")
+
+	code, _, _ = runCLI(f, "bogus")
+	assert.Equal(t, 2, code)
+
+	code, _, _ = runCLI(f, "-o", "yaml", "kill")
+	assert.Equal(t, 2, code)
+	assert.Len(t, f.requests, 1, "usage errors send no request")
+}
+
+func TestWatch(t *testing.T) {
+	f := newFakeServer(t, map[string]interface{}{
+		"GET /events": ": connected\n\n" +
+			"id: 1\nevent: scenario.aborted\n" +
+			`data: {"id":"1","type":"scenario.aborted","time":"2026-01-02T03:04:05Z","run_id":"run-1","scenario":"latency","message":"max duration"}` + "\n\n",
+	})
+
+	code, out, errOut := runCLI(f, "watch", "-type", "scenario.*", "-run", "run-1")
+	require.Equal(t, 0, code, errOut)
+	assert.Equal(t, []string{"GET /events?run_id=run-1&type=scenario.%2A"}, f.requests)
+	assert.Contains(t, out, "scenario.aborted")
+	assert.Contains(t, out, "latency  run=run-1  max duration")
+}
+
+func TestSchedules(t *testing.T) {
+	def := filepath.Join(t.TempDir(), "schedule.yaml")
+	require.NoError(t, os.WriteFile(def, []byte("name: weekday latency\ncron: '0 10 * * 1-5'\nscenario: latency\nduration: 15m\n"), 0o644))
+	f := newFakeServer(t, map[string]interface{}{
+		"PUT /schedules": schedule.Schedule{ID: "sched-1", Name: "weekday latency", Cron: "0 10 * * 1-5", Scenario: "latency",
+			LastRun: &schedule.Occurrence{Status: schedule.OccurrenceSkipped, Reason: "blackout release freeze"}},
+		"DELETE /schedules": "",
+	})
+
+	code, out, errOut := runCLI(f, "schedules", "apply", def, "-id", "sched-1")
+	require.Equal(t, 0, code, errOut)
+	assert.Equal(t, "PUT /schedules?id=sched-1", f.requests[0])
+	assert.Contains(t, f.bodies[0], "scenario: latency")
+	assert.Contains(t, out, "skipped: blackout release freeze")
+
+	code, out, _ = runCLI(f, "schedules", "delete", "sched-1")
+	require.Equal(t, 0, code)
+	assert.Equal(t, "DELETE /schedules?id=sched-1", f.requests[1])
+	assert.Contains(t, out, "Schedule sched-1 deleted")
+}
@@ -0,0 +1,182 @@
+package main
+
+import (
+	"encoding/json"
+	"flag"
+	"fmt"
+	"net/url"
+	"os"
+	"sort"
+	"strings"
+	"text/tabwriter"
+	"time"
+)
+
+// paramFlag collects repeated -p key=value flags.
+type paramFlag map[string]interface{}
+
+func (p paramFlag) String() string {
+	return fmt.Sprint(map[string]interface{}(p))
+}
+
+// Set stores the value as a JSON number, boolean or object when it parses
+// as one and as a string otherwise.
+func (p paramFlag) Set(s string) error {
+	key, value, ok := strings.Cut(s, "=")
+	if !ok || key == "" {
+		return fmt.Errorf("expected key=value, got %q", s)
+	}
+	var v interface{}
+	if err := json.Unmarshal([]byte(value), &v); err != nil {
+		v = value
+	}
+	p[key] = v
+	return nil
+}
+
+func (c *cli) scenariosList(args []string) error {
+	if _, err := c.parseArgs(flag.NewFlagSet("scenarios list", flag.ContinueOnError), args, 0, 0); err != nil {
+		return err
+	}
+	var scenarios map[string]scenario
+	raw, err := c.client.getJSON("/scenarios", nil, &scenarios)
+	if err != nil {
+		return err
+	}
+	names := make([]string, 0, len(scenarios))
+	for name := range scenarios {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return c.print(raw, func(w *tabwriter.Writer) {
+		row(w, "SCENARIO", "NAME", "PARAMETERS")
+		for _, name := range names {
+			s := scenarios[name]
+			row(w, name, s.Name, formatParams(s.Parameters))
+		}
+	})
+}
+
+func (c *cli) scenariosRun(args []string) error {
+	flags := flag.NewFlagSet("scenarios run", flag.ContinueOnError)
+	params := paramFlag{}
+	flags.Var(params, "p", "scenario parameter as key=value (repeatable)")
+	file := flags.String("f", "", "JSON run request to send instead of building one from flags")
+	maxDuration := flags.Duration("max-duration", 0, "guardrail: abort the run after this long")
+	maxRSS := flags.Int64("max-rss-mb", 0, "guardrail: abort the run above this resident memory")
+	loadRate := flags.Float64("load-rate", 0, "start a load run at this many requests per second")
+	loadDuration := flags.Duration("load-duration", time.Minute, "duration of the load run")
+	loadTarget := flags.String("load-target", "", "URL the load run sends requests to")
+	names, err := c.parseArgs(flags, args, 1, 1)
+	if err != nil {
+		return err
+	}
+
+	var body interface{}
+	if *file != "" {
+		data, err := os.ReadFile(*file)
+		if err != nil {
+			return err
+		}
+		body = json.RawMessage(data)
+	} else {
+		req := runRequest{Parameters: params}
+		if *maxDuration > 0 || *maxRSS > 0 {
+			req.Guardrails = &guardrails{MaxRSSMB: *maxRSS}
+			if *maxDuration > 0 {
+				req.Guardrails.MaxDuration = maxDuration.String()
+			}
+		}
+		if *loadRate > 0 {
+			req.Load = &loadConfig{Target: *loadTarget, Rate: *loadRate, Duration: loadDuration.String()}
+		}
+		body = req
+	}
+
+	var resp scenarioResponse
+	raw, err := c.client.postJSON("/scenarios/"+url.PathEscape(names[0])+"/run", nil, body, &resp)
+	if err != nil {
+		return err
+	}
+	return c.print(raw, func(w *tabwriter.Writer) {
+		row(w, "RUN ID", "SCENARIO", "STATUS", "LOAD RUN", "PARAMETERS")
+		row(w, resp.RunID, names[0], resp.Status, resp.LoadRunID, formatParams(resp.Parameters))
+	})
+}
+
+func (c *cli) scenariosStop(args []string) error {
+	names, err := c.parseArgs(flag.NewFlagSet("scenarios stop", flag.ContinueOnError), args, 1, 1)
+	if err != nil {
+		return err
+	}
+	var resp scenarioResponse
+	raw, err := c.client.postJSON("/scenarios/"+url.PathEscape(names[0])+"/stop", nil, nil, &resp)
+	if err != nil {
+		return err
+	}
+	return c.print(raw, func(w *tabwriter.Writer) {
+		row(w, "SCENARIO", "STATUS")
+		row(w, names[0], resp.Status)
+	})
+}
+
+func (c *cli) scenariosStatus(args []string) error {
+	ids, err := c.parseArgs(flag.NewFlagSet("scenarios status", flag.ContinueOnError), args, 0, 1)
+	if err != nil {
+		return err
+	}
+	var runs []scenarioRun
+	var raw json.RawMessage
+	if len(ids) == 1 {
+		var run scenarioRun
+		raw, err = c.client.getJSON("/scenarios/runs", url.Values{"id": ids}, &run)
+		runs = append(runs, run)
+	} else {
+		raw, err = c.client.getJSON("/scenarios/runs", nil, &runs)
+	}
+	if err != nil {
+		return err
+	}
+	return c.print(raw, func(w *tabwriter.Writer) {
+		row(w, "RUN ID", "SCENARIO", "STATUS", "STARTED", "DURATION", "VERDICT", "DETECTED", "REASON")
+		for _, run := range runs {
+			verdict, detected := "", ""
+			if run.Verdict != nil {
+				verdict = run.Verdict.Result
+			}
+			if run.Detection != nil {
+				detected = fmt.Sprintf("%s after %s", run.Detection.Alert, time.Duration(run.Detection.TimeToDetect*float64(time.Second)).Round(time.Second))
+			}
+			row(w, run.ID, run.Scenario, run.Status, formatTime(run.StartedAt), formatDuration(run.StartedAt, run.EndedAt), verdict, detected, run.AbortReason)
+		}
+	})
+}
+
+// formatParams renders parameters as sorted key=value pairs.
+func formatParams(params map[string]interface{}) string {
+	pairs := make([]string, 0, len(params))
+	for k, v := range params {
+		pairs = append(pairs, fmt.Sprintf("%s=%v", k, v))
+	}
+	sort.Strings(pairs)
+	return strings.Join(pairs, ",")
+}
+
+func formatTime(t *time.Time) string {
+	if t == nil {
+		return ""
+	}
+	return t.Local().Format(time.RFC3339)
+}
+
+// formatDuration is the time from start to end, or to now while running.
+func formatDuration(start, end *time.Time) string {
+	if start == nil {
+		return ""
+	}
+	stop := time.Now()
+	if end != nil {
+		stop = *end
+	}
+	return stop.Sub(*start).Round(time.Second).String()
+}
@@ -0,0 +1,90 @@
+package main
+
+import (
+	"bytes"
+	"encoding/json"
+	"flag"
+	"fmt"
+	"net/http"
+	"net/url"
+	"os"
+	"text/tabwriter"
+)
+
+func (c *cli) schedulesApply(args []string) error {
+	flags := flag.NewFlagSet("schedules apply", flag.ContinueOnError)
+	id := flags.String("id", "", "replace the schedule with this ID instead of creating one")
+	files, err := c.parseArgs(flags, args, 1, 1)
+	if err != nil {
+		return err
+	}
+	data, err := os.ReadFile(files[0])
+	if err != nil {
+		return err
+	}
+	method, query := http.MethodPost, url.Values(nil)
+	if *id != "" {
+		method, query = http.MethodPut, url.Values{"id": {*id}}
+	}
+	body, err := c.client.do(method, "/schedules", query, bytes.NewReader(data), "application/yaml")
+	if err != nil {
+		return err
+	}
+	var sched scheduleStatus
+	if err := json.Unmarshal(body, &sched); err != nil {
+		return err
+	}
+	return c.printSchedules(json.RawMessage(body), []scheduleStatus{sched})
+}
+
+func (c *cli) schedulesList(args []string) error {
+	if _, err := c.parseArgs(flag.NewFlagSet("schedules list", flag.ContinueOnError), args, 0, 0); err != nil {
+		return err
+	}
+	var schedules []scheduleStatus
+	raw, err := c.client.getJSON("/schedules", nil, &schedules)
+	if err != nil {
+		return err
+	}
+	return c.printSchedules(raw, schedules)
+}
+
+func (c *cli) schedulesDelete(args []string) error {
+	ids, err := c.parseArgs(flag.NewFlagSet("schedules delete", flag.ContinueOnError), args, 1, 1)
+	if err != nil {
+		return err
+	}
+	if _, err := c.client.do(http.MethodDelete, "/schedules", url.Values{"id": ids}, nil, ""); err != nil {
+		return err
+	}
+	if c.output == "table" {
+		fmt.Fprintf(c.out, "Schedule %s deleted\n", ids[0])
+	}
+	return nil
+}
+
+// printSchedules prints v as JSON, or schedules as a table.
+func (c *cli) printSchedules(v interface{}, schedules []scheduleStatus) error {
+	return c.print(v, func(w *tabwriter.Writer) {
+		row(w, "SCHEDULE", "NAME", "CRON", "RUNS", "NEXT", "LAST", "RESULT")
+		for _, s := range schedules {
+			runs := s.Scenario
+			if s.Experiment != nil {
+				runs = "experiment " + s.Experiment.Name
+			}
+			next := formatTime(s.NextRun)
+			if s.Paused {
+				next = "paused"
+			}
+			lastAt, result := "", ""
+			if last := s.LastRun; last != nil {
+				lastAt = formatTime(&last.ScheduledAt)
+				result = last.Status
+				if last.Reason != "" {
+					result += ": " + last.Reason
+				}
+			}
+			row(w, s.ID, s.Name, s.Cron, runs, next, lastAt, result)
+		}
+	})
+}
@@ -0,0 +1,277 @@
+// Package auth authenticates requests to sresim's control endpoints with
+// bearer tokens or client certificates and authorizes them by role and
+// scenario.
+package auth
+
+import (
+	"context"
+	"crypto/subtle"
+	"errors"
+	"fmt"
+	"log/slog"
+	"net/http"
+	"os"
+	"path"
+	"strings"
+	"sync"
+
+	"github.com/localstack/sresim/app-sresim/pkg/config"
+	"github.com/localstack/sresim/app-sresim/pkg/logging"
+)
+
+// Roles, from least to most privileged.
+const (
+	// RoleViewer may read the state of the control endpoints.
+	RoleViewer = "viewer"
+	// RoleOperator may also start and stop scenarios, experiments,
+	// schedules and load generation, and report alerts.
+	RoleOperator = "operator"
+	// RoleAdmin may also use /admin, i.e. the kill switch and the chaos
+	// toggle.
+	RoleAdmin = "admin"
+)
+
+// roleRank orders the roles; a role is granted everything a lower one is.
+var roleRank = map[string]int{RoleViewer: 1, RoleOperator: 2, RoleAdmin: 3}
+
+// controlPrefixes are the endpoints that need authentication: the control
+// endpoints, the Alertmanager receiver, which records detections, and the
+// history and event stream, which show who ran what. Everything else, e.g.
+// /simulate, the probes and /metrics, stays open.
+var controlPrefixes = []string{"/scenarios", "/experiments", "/schedules", "/loadgen", "/chaos", "/admin", "/alerts", "/history", "/events"}
+
+// legacyControlPaths start and stop scenarios with any method, GET
+// included, for clients that predate the method-aware routes.
+var legacyControlPaths = map[string]bool{"/scenarios/run": true, "/scenarios/stop": true}
+
+// Principal is an authenticated caller.
+type Principal struct {
+	Name string
+	Role string
+
+	token      []byte
+	commonName string
+	// scenarios are the scenarios the principal may use, or nil for all.
+	scenarios map[string]bool
+}
+
+// Authenticator checks the credentials of requests to the control
+// endpoints against the configured principals.
+type Authenticator struct {
+	mu         sync.RWMutex
+	principals []*Principal
+}
+
+// New returns an authenticator for the principals of cfg.
+func New(cfg config.Auth) (*Authenticator, error) {
+	a := &Authenticator{}
+	if err := a.Set(cfg); err != nil {
+		return nil, err
+	}
+	return a, nil
+}
+
+// Set replaces the principals, e.g. after the configuration or a mounted
+// token file changed. The old ones are kept if cfg is invalid.
+func (a *Authenticator) Set(cfg config.Auth) error {
+	principals := make([]*Principal, 0, len(cfg.Principals))
+	tokens := make(map[string]string)
+	commonNames := make(map[string]string)
+	for i, pc := range cfg.Principals {
+		where := fmt.Sprintf("auth.principals[%d]", i)
+		if pc.Name == "" {
+			return fmt.Errorf("%s: name is required", where)
+		}
+		where += " (" + pc.Name + ")"
+		if roleRank[pc.Role] == 0 {
+			return fmt.Errorf("%s: role must be %s, %s or %s, got %q", where, RoleViewer, RoleOperator, RoleAdmin, pc.Role)
+		}
+		token, err := readToken(pc)
+		if err != nil {
+			return fmt.Errorf("%s: %w", where, err)
+		}
+		if token == "" && pc.CommonName == "" {
+			return fmt.Errorf("%s: needs a token, token_file or common_name", where)
+		}
+		if other, ok := tokens[token]; ok && token != "" {
+			return fmt.Errorf("%s: token is also used by %s", where, other)
+		}
+		if other, ok := commonNames[pc.CommonName]; ok && pc.CommonName != "" {
+			return fmt.Errorf("%s: common_name is also used by %s", where, other)
+		}
+		tokens[token], commonNames[pc.CommonName] = pc.Name, pc.Name
+
+		p := &Principal{Name: pc.Name, Role: pc.Role, token: []byte(token), commonName: pc.CommonName}
+		if len(pc.Scenarios) > 0 {
+			p.scenarios = make(map[string]bool, len(pc.Scenarios))
+			for _, name := range pc.Scenarios {
+				p.scenarios[name] = true
+			}
+		}
+		principals = append(principals, p)
+	}
+
+	a.mu.Lock()
+	a.principals = principals
+	a.mu.Unlock()
+	return nil
+}
+
+// readToken returns the token of pc, reading it from its token file if it
+// has one.
+func readToken(pc config.Principal) (string, error) {
+	if pc.TokenFile == "" {
+		return pc.Token, nil
+	}
+	if pc.Token != "" {
+		return "", errors.New("token and token_file are mutually exclusive")
+	}
+	data, err := os.ReadFile(pc.TokenFile)
+	if err != nil {
+		return "", err
+	}
+	token := strings.TrimSpace(string(data))
+	if token == "" {
+		return "", fmt.Errorf("token file %s is empty", pc.TokenFile)
+	}
+	return token, nil
+}
+
+// Enabled reports whether any principals are configured. Without them the
+// control endpoints are open.
+func (a *Authenticator) Enabled() bool {
+	a.mu.RLock()
+	defer a.mu.RUnlock()
+	return len(a.principals) > 0
+}
+
+// Middleware rejects requests to the control endpoints without valid
+// credentials with 401, and requests the principal's role does not allow
+// with 403. Reads need the viewer role, changes the operator role and
+// changes under /admin the admin role. The principal is added to the
+// request context and to the request log.
+func (a *Authenticator) Middleware(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		role := requiredRole(r)
+		if role == "" || !a.Enabled() {
+			next.ServeHTTP(w, r)
+			return
+		}
+
+		p := a.authenticate(r)
+		if p == nil {
+			w.Header().Set("WWW-Authenticate", `Bearer realm="sresim"`)
+			http.Error(w, "Unauthorized", http.StatusUnauthorized)
+			return
+		}
+		logging.Annotate(r.Context(), slog.String("principal", p.Name))
+		if roleRank[p.Role] < roleRank[role] {
+			http.Error(w, "Forbidden: "+role+" role required", http.StatusForbidden)
+			return
+		}
+		next.ServeHTTP(w, r.WithContext(context.WithValue(r.Context(), principalKey{}, p)))
+	})
+}
+
+// requiredRole returns the role a request needs, or "" if it is not for a
+// control endpoint.
+func requiredRole(r *http.Request) string {
+	p := path.Clean("/" + r.URL.Path)
+	control := false
+	for _, prefix := range controlPrefixes {
+		if p == prefix || strings.HasPrefix(p, prefix+"/") {
+			control = true
+			break
+		}
+	}
+	switch {
+	case !control:
+		return ""
+	case legacyControlPaths[p]:
+		return RoleOperator
+	case r.Method == http.MethodGet || r.Method == http.MethodHead:
+		return RoleViewer
+	case p == "/admin" || strings.HasPrefix(p, "/admin/"):
+		return RoleAdmin
+	}
+	return RoleOperator
+}
+
+// authenticate returns the principal of the bearer token of r or, if it has
+// none, of its verified client certificate. It returns nil if neither
+// identifies a principal; an invalid token is not made up for by a
+// certificate.
+func (a *Authenticator) authenticate(r *http.Request) *Principal {
+	a.mu.RLock()
+	defer a.mu.RUnlock()
+
+	if header := r.Header.Get("Authorization"); header != "" {
+		scheme, token, ok := strings.Cut(header, " ")
+		if !ok || !strings.EqualFold(scheme, "Bearer") || token == "" {
+			return nil
+		}
+		for _, p := range a.principals {
+			if len(p.token) > 0 && subtle.ConstantTimeCompare(p.token, []byte(token)) == 1 {
+				return p
+			}
+		}
+		return nil
+	}
+
+	if r.TLS == nil || len(r.TLS.VerifiedChains) == 0 || len(r.TLS.VerifiedChains[0]) == 0 {
+		return nil
+	}
+	cn := r.TLS.VerifiedChains[0][0].Subject.CommonName
+	for _, p := range a.principals {
+		if p.commonName != "" && p.commonName == cn {
+			return p
+		}
+	}
+	return nil
+}
+
+type principalKey struct{}
+
+// FromContext returns the principal a request was authenticated as.
+func FromContext(ctx context.Context) (*Principal, bool) {
+	p, ok := ctx.Value(principalKey{}).(*Principal)
+	return p, ok
+}
+
+// AllowsScenarios reports whether the principal of ctx may run, stop or
+// schedule all of scenarios. Requests that were not authenticated, e.g.
+// because no principals are configured, may use every scenario.
+func AllowsScenarios(ctx context.Context, scenarios ...string) bool {
+	p, ok := FromContext(ctx)
+	if !ok || p.scenarios == nil {
+		return true
+	}
+	for _, name := range scenarios {
+		if !p.scenarios[name] {
+			return false
+		}
+	}
+	return true
+}
+
+// CheckUnrestricted answers with 403 and returns false if the principal of
+// r is restricted to some scenarios. It guards what cannot be tied to a
+// scenario, such as load sent to an arbitrary target.
+func CheckUnrestricted(w http.ResponseWriter, r *http.Request) bool {
+	p, ok := FromContext(r.Context())
+	if !ok || p.scenarios == nil {
+		return true
+	}
+	http.Error(w, "Forbidden: principals restricted to scenarios may only start load together with a scenario run or experiment", http.StatusForbidden)
+	return false
+}
+
+// CheckScenarios answers with 403 and returns false unless the principal of
+// r may use all of scenarios.
+func CheckScenarios(w http.ResponseWriter, r *http.Request, scenarios ...string) bool {
+	if AllowsScenarios(r.Context(), scenarios...) {
+		return true
+	}
+	http.Error(w, "Forbidden: not allowed to use scenario "+strings.Join(scenarios, ", "), http.StatusForbidden)
+	return false
+}
@@ -0,0 +1,171 @@
+package auth
+
+import (
+	"crypto/tls"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/localstack/sresim/app-sresim/pkg/config"
+)
+
+// testAuthenticator returns an authenticator with a principal per role and
+// a payments operator restricted to the latency scenario.
+func testAuthenticator(t *testing.T) *Authenticator {
+	t.Helper()
+	a, err := New(config.Auth{Principals: []config.Principal{
+		{Name: "dashboard", Role: RoleViewer, Token: "viewer-token"},
+		{Name: "ci", Role: RoleOperator, Token: "operator-token", CommonName: "ci.example.com"},
+		{Name: "payments", Role: RoleOperator, Token: "payments-token", Scenarios: []string{"latency"}},
+		{Name: "sre", Role: RoleAdmin, Token: "admin-token"},
+	}})
+	require.NoError(t, err)
+	return a
+}
+
+func TestMiddleware(t *testing.T) {
+	handler := testAuthenticator(t).Middleware(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {}))
+
+	for _, tc := range []struct {
+		method, path, token string
+		want                int
+	}{
+		{"GET", "/simulate", "", http.StatusOK},
+		{"GET", "/metrics", "", http.StatusOK},
+		{"POST", "/alerts", "", http.StatusUnauthorized},
+		{"POST", "/alerts", "viewer-token", http.StatusForbidden},
+		{"POST", "/alerts", "operator-token", http.StatusOK},
+		{"GET", "/history", "", http.StatusUnauthorized},
+		{"GET", "/history", "viewer-token", http.StatusOK},
+		{"GET", "/events", "", http.StatusUnauthorized},
+		{"GET", "/events", "viewer-token", http.StatusOK},
+		{"GET", "/scenarios", "", http.StatusUnauthorized},
+		{"GET", "/scenarios", "wrong-token", http.StatusUnauthorized},
+		{"GET", "/scenarios", "viewer-token", http.StatusOK},
+		{"GET", "/experiments/1/report", "viewer-token", http.StatusOK},
+		{"POST", "/scenarios/latency/run", "viewer-token", http.StatusForbidden},
+		{"POST", "/scenarios/latency/run", "operator-token", http.StatusOK},
+		{"POST", "/scenarios/run", "operator-token", http.StatusOK},
+		{"GET", "/scenarios/run", "viewer-token", http.StatusForbidden},
+		{"GET", "/scenarios/stop", "operator-token", http.StatusOK},
+		{"DELETE", "/schedules", "operator-token", http.StatusOK},
+		{"POST", "/loadgen", "", http.StatusUnauthorized},
+		{"GET", "/admin/chaos", "viewer-token", http.StatusOK},
+		{"POST", "/admin/kill", "operator-token", http.StatusForbidden},
+		{"POST", "/admin/kill", "admin-token", http.StatusOK},
+		{"POST", "/simulate/../admin/kill", "operator-token", http.StatusForbidden},
+	} {
+		req := httptest.NewRequest(tc.method, "/", nil)
+		req.URL.Path = tc.path
+		if tc.token != "" {
+			req.Header.Set("Authorization", "Bearer "+tc.token)
+		}
+		rec := httptest.NewRecorder()
+		handler.ServeHTTP(rec, req)
+		assert.Equal(t, tc.want, rec.Code, "%s %s with %q", tc.method, tc.path, tc.token)
+		if tc.want == http.StatusUnauthorized {
+			assert.Equal(t, `Bearer realm="sresim"`, rec.Header().Get("WWW-Authenticate"))
+		}
+	}
+}
+
+func TestMiddlewareWithoutPrincipals(t *testing.T) {
+	a, err := New(config.Auth{})
+	require.NoError(t, err)
+	assert.False(t, a.Enabled())
+
+	rec := httptest.NewRecorder()
+	a.Middleware(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {})).
+		ServeHTTP(rec, httptest.NewRequest(http.MethodPost, "/admin/kill", nil))
+	assert.Equal(t, http.StatusOK, rec.Code, "control endpoints are open until principals are configured")
+}
+
+func TestClientCertificate(t *testing.T) {
+	var got *Principal
+	handler := testAuthenticator(t).Middleware(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		got, _ = FromContext(r.Context())
+	}))
+
+	request := func(cn string) *http.Request {
+		req := httptest.NewRequest(http.MethodPost, "/scenarios/latency/run", nil)
+		req.TLS = &tls.ConnectionState{VerifiedChains: [][]*x509.Certificate{{{Subject: pkix.Name{CommonName: cn}}}}}
+		return req
+	}
+
+	rec := httptest.NewRecorder()
+	handler.ServeHTTP(rec, request("ci.example.com"))
+	assert.Equal(t, http.StatusOK, rec.Code)
+	require.NotNil(t, got)
+	assert.Equal(t, "ci", got.Name)
+
+	rec = httptest.NewRecorder()
+	handler.ServeHTTP(rec, request("unknown.example.com"))
+	assert.Equal(t, http.StatusUnauthorized, rec.Code)
+
+	req := request("ci.example.com")
+	req.Header.Set("Authorization", "Bearer wrong-token")
+	rec = httptest.NewRecorder()
+	handler.ServeHTTP(rec, req)
+	assert.Equal(t, http.StatusUnauthorized, rec.Code, "an invalid token is not made up for by a certificate")
+}
+
+func TestCheckScenarios(t *testing.T) {
+	handler := testAuthenticator(t).Middleware(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		CheckScenarios(w, r, r.PathValue("name"))
+	}))
+	mux := http.NewServeMux()
+	mux.Handle("POST /scenarios/{name}/run", handler)
+
+	for _, tc := range []struct {
+		scenario, token string
+		want            int
+	}{
+		{"latency", "payments-token", http.StatusOK},
+		{"cpu_spike", "payments-token", http.StatusForbidden},
+		{"cpu_spike", "operator-token", http.StatusOK},
+	} {
+		req := httptest.NewRequest(http.MethodPost, "/scenarios/"+tc.scenario+"/run", nil)
+		req.Header.Set("Authorization", "Bearer "+tc.token)
+		rec := httptest.NewRecorder()
+		mux.ServeHTTP(rec, req)
+		assert.Equal(t, tc.want, rec.Code, "%s with %s", tc.scenario, tc.token)
+	}
+
+	assert.True(t, AllowsScenarios(httptest.NewRequest(http.MethodGet, "/", nil).Context(), "cpu_spike"),
+		"requests that were not authenticated are not restricted")
+}
+
+func TestSet(t *testing.T) {
+	dir := t.TempDir()
+	tokenFile := filepath.Join(dir, "token")
+	require.NoError(t, os.WriteFile(tokenFile, []byte("secret-token\n"), 0o600))
+	emptyFile := filepath.Join(dir, "empty")
+	require.NoError(t, os.WriteFile(emptyFile, nil, 0o600))
+
+	a, err := New(config.Auth{Principals: []config.Principal{{Name: "ci", Role: RoleOperator, TokenFile: tokenFile}}})
+	require.NoError(t, err)
+	req := httptest.NewRequest(http.MethodGet, "/scenarios", nil)
+	req.Header.Set("Authorization", "Bearer secret-token")
+	require.NotNil(t, a.authenticate(req), "tokens are read from files without trailing newlines")
+
+	for _, principals := range [][]config.Principal{
+		{{Role: RoleViewer, Token: "t"}},
+		{{Name: "ci", Role: "superuser", Token: "t"}},
+		{{Name: "ci", Role: RoleViewer}},
+		{{Name: "ci", Role: RoleViewer, Token: "t", TokenFile: tokenFile}},
+		{{Name: "ci", Role: RoleViewer, TokenFile: emptyFile}},
+		{{Name: "ci", Role: RoleViewer, TokenFile: filepath.Join(dir, "missing")}},
+		{{Name: "ci", Role: RoleViewer, Token: "t"}, {Name: "cd", Role: RoleAdmin, Token: "t"}},
+		{{Name: "ci", Role: RoleViewer, CommonName: "ci"}, {Name: "cd", Role: RoleAdmin, CommonName: "ci"}},
+	} {
+		assert.Error(t, a.Set(config.Auth{Principals: principals}), "%+v", principals)
+	}
+	assert.NotNil(t, a.authenticate(req), "invalid configurations leave the principals unchanged")
+}
@@ -1,26 +1,99 @@
 package chaos
 
 import (
+	"errors"
+	"fmt"
+	"math"
 	"math/rand"
+	"sync/atomic"
 	"time"
 )
 
+// enabled is whether the chaos middleware injects faults.
+var enabled atomic.Bool
+
 // init seeds the random number generator.
 func init() {
 	rand.Seed(time.Now().UnixNano())
+	enabled.Store(true)
+}
+
+// Enabled reports whether random faults are being injected.
+func Enabled() bool {
+	return enabled.Load()
+}
+
+// SetEnabled turns random fault injection on or off.
+func SetEnabled(on bool) {
+	enabled.Store(on)
+}
+
+// Fault injection probabilities and delay range of the chaos middleware.
+const (
+	failProbability  = 0.2
+	delayProbability = 0.3
+	minDelay         = 100 * time.Millisecond
+	maxDelay         = time.Second
+)
+
+// panicProbability holds the bits of the share of requests the chaos
+// middleware panics while serving. It is zero unless configured: a panic
+// pages whoever alerts on sresim_panics_total.
+var panicProbability atomic.Uint64
+
+// PanicProbability returns the share of requests the chaos middleware
+// panics while serving.
+func PanicProbability() float64 {
+	return math.Float64frombits(panicProbability.Load())
+}
+
+// SetPanicProbability sets the share of requests the chaos middleware
+// panics while serving; 0 turns injected panics off.
+func SetPanicProbability(p float64) error {
+	if !(p >= 0 && p <= 1) {
+		return fmt.Errorf("panic probability must be between 0 and 1, got %v", p)
+	}
+	panicProbability.Store(math.Float64bits(p))
+	return nil
+}
+
+// ErrSimulatedPanic is the value the chaos middleware panics with.
+var ErrSimulatedPanic = errors.New("simulated panic")
+
+// Rule describes one fault the chaos middleware injects.
+type Rule struct {
+	Name        string  `json:"name"`
+	Probability float64 `json:"probability"`
+	Effect      string  `json:"effect"`
+}
+
+// Rules returns the faults injected into every request while chaos is
+// enabled. Rules with a probability of 0 are off.
+func Rules() []Rule {
+	return []Rule{
+		{Name: "fail", Probability: failProbability, Effect: "respond with 500 Simulated failure"},
+		{Name: "panic", Probability: PanicProbability(), Effect: "panic while serving the request, answered with 500 by the recovery middleware; off unless panic_probability is set"},
+		{Name: "delay", Probability: delayProbability, Effect: "delay the response by " + minDelay.String() + " to " + maxDelay.String()},
+	}
 }
 
 // ShouldFail randomly returns true based on a set probability (e.g., 20%).
 func ShouldFail() bool {
-	return rand.Float32() < 0.2
+	return rand.Float32() < failProbability
+}
+
+// ShouldPanic randomly decides to panic while serving the request, with
+// the probability set by SetPanicProbability.
+func ShouldPanic() bool {
+	return rand.Float64() < PanicProbability()
 }
 
 // ShouldDelay randomly decides to delay the response (e.g., 30% chance).
 func ShouldDelay() bool {
-	return rand.Float32() < 0.3
+	return rand.Float32() < delayProbability
 }
 
 // RandomDelay returns a random delay duration between 100ms and 1s.
 func RandomDelay() time.Duration {
-	return time.Duration(100+rand.Intn(900)) * time.Millisecond
+	return minDelay + time.Duration(rand.Int63n(int64(maxDelay-minDelay)))
 }
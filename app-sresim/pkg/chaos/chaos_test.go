@@ -0,0 +1,23 @@
+package chaos
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestPanicsAreOptIn(t *testing.T) {
+	for i := 0; i < 1000; i++ {
+		require.False(t, ShouldPanic(), "no panics are injected by default")
+	}
+
+	require.NoError(t, SetPanicProbability(1))
+	t.Cleanup(func() { SetPanicProbability(0) })
+	assert.True(t, ShouldPanic())
+	assert.Equal(t, 1.0, Rules()[1].Probability, "the rules report the probability")
+
+	assert.Error(t, SetPanicProbability(1.5))
+	assert.Error(t, SetPanicProbability(-0.1))
+	assert.Equal(t, 1.0, PanicProbability(), "invalid probabilities are rejected")
+}
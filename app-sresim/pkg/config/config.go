@@ -0,0 +1,292 @@
+package config
+
+import (
+	"errors"
+	"fmt"
+	"io/fs"
+	"os"
+	"time"
+
+	"gopkg.in/yaml.v3"
+)
+
+// Config is the sresim configuration file. Only the sections sresim reads
+// are modelled; unknown keys are ignored so the file can be shared with
+// other tooling.
+type Config struct {
+	Server Server `yaml:"server" json:"server"`
+	SLOs   []SLO  `yaml:"slos" json:"slos"`
+	// Guardrails apply to every scenario run unless the run overrides them.
+	Guardrails *Guardrails `yaml:"guardrails" json:"guardrails"`
+	History    History     `yaml:"history" json:"history"`
+	Webhooks   []Webhook   `yaml:"webhooks" json:"webhooks,omitempty"`
+	Schedules  Schedules   `yaml:"schedules" json:"schedules"`
+	Tracing    Tracing     `yaml:"tracing" json:"tracing"`
+	Auth       Auth        `yaml:"auth" json:"auth"`
+	Chaos      Chaos       `yaml:"chaos" json:"chaos"`
+	LoadGen    LoadGen     `yaml:"loadgen" json:"loadgen"`
+}
+
+// DefaultShutdownGracePeriod leaves a margin within the 30 seconds
+// Kubernetes waits between SIGTERM and SIGKILL by default.
+const DefaultShutdownGracePeriod = Duration(25 * time.Second)
+
+// Server configures the HTTP server.
+type Server struct {
+	// ShutdownGracePeriod bounds how long a shutdown waits for experiment
+	// rollbacks and in-flight requests before exiting anyway.
+	ShutdownGracePeriod Duration `yaml:"shutdown_grace_period" json:"shutdown_grace_period,omitempty"`
+	// TLS serves HTTPS instead of HTTP when set.
+	TLS *TLS `yaml:"tls" json:"tls,omitempty"`
+}
+
+// TLS configures HTTPS and client certificate authentication.
+type TLS struct {
+	CertFile string `yaml:"cert_file" json:"cert_file"`
+	KeyFile  string `yaml:"key_file" json:"key_file"`
+	// ClientCAFile is a PEM bundle of the CAs client certificates are
+	// verified against. Clients without a certificate can still connect,
+	// e.g. to /simulate, or authenticate with a token.
+	ClientCAFile string `yaml:"client_ca_file" json:"client_ca_file,omitempty"`
+}
+
+// Auth configures who may use the control endpoints: /scenarios,
+// /experiments, /schedules, /loadgen, /chaos and /admin, as well as /alerts,
+// /history and /events. Without principals they are open to anyone who can
+// reach sresim.
+type Auth struct {
+	Principals []Principal `yaml:"principals" json:"principals,omitempty"`
+}
+
+// Principal is a user or service allowed to use the control endpoints. It
+// authenticates with a bearer token, a client certificate or either.
+type Principal struct {
+	Name string `yaml:"name" json:"name"`
+	// Role is viewer (read only), operator (also start and stop scenarios,
+	// experiments, schedules and load, and report alerts) or admin (also the
+	// kill switch and the chaos toggle).
+	Role string `yaml:"role" json:"role"`
+	// Token is accepted as "Authorization: Bearer <token>". TokenFile names
+	// a file to read the token from instead, e.g. a key of a mounted
+	// Secret; it is read again when the configuration is reloaded.
+	Token     string `yaml:"token" json:"-"`
+	TokenFile string `yaml:"token_file" json:"token_file,omitempty"`
+	// CommonName is the subject common name of a client certificate
+	// verified against server.tls.client_ca_file.
+	CommonName string `yaml:"common_name" json:"common_name,omitempty"`
+	// Scenarios restricts the scenarios the principal may run, stop and
+	// schedule, directly or in experiments. Empty allows all.
+	Scenarios []string `yaml:"scenarios" json:"scenarios,omitempty"`
+}
+
+// Chaos configures the chaos middleware.
+type Chaos struct {
+	// PanicProbability is the share of requests the chaos middleware panics
+	// while serving, between 0 and 1. Injected panics are off by default
+	// because they trip panic alerts.
+	PanicProbability float64 `yaml:"panic_probability" json:"panic_probability,omitempty"`
+}
+
+// LoadGen limits the load a single load generation run may produce, so a
+// mistyped rate cannot flood the target or exhaust sresim itself.
+type LoadGen struct {
+	// MaxRate is the highest arrival rate of the open model, in requests
+	// per second (default 1000).
+	MaxRate float64 `yaml:"max_rate" json:"max_rate,omitempty"`
+	// MaxWorkers is the highest worker count of the closed model (default
+	// 200).
+	MaxWorkers int `yaml:"max_workers" json:"max_workers,omitempty"`
+}
+
+// Tracing configures the export of OpenTelemetry traces.
+type Tracing struct {
+	// Enabled exports traces over OTLP/HTTP. ENABLE_TRACING=true enables
+	// tracing as well.
+	Enabled bool `yaml:"enabled" json:"enabled"`
+	// Endpoint is the OTLP/HTTP endpoint of the collector, e.g.
+	// http://jaeger:4318. Empty uses the OTEL_EXPORTER_OTLP_ENDPOINT
+	// environment variables.
+	Endpoint string `yaml:"endpoint" json:"endpoint,omitempty"`
+	// SampleRatio is the share of traces started by sresim that are
+	// sampled (default all). Requests that carry trace context follow the
+	// sampling decision of the caller.
+	SampleRatio float64 `yaml:"sample_ratio" json:"sample_ratio,omitempty"`
+}
+
+// Schedules configures the scheduler of recurring scenarios.
+type Schedules struct {
+	// Blackouts apply to every schedule in addition to its own.
+	Blackouts []Blackout `yaml:"blackouts" json:"blackouts,omitempty"`
+}
+
+// Blackout is a period in which scheduled scenarios do not run. It is
+// either a one-off window from Start to End or a recurring one that opens
+// at every match of Cron and lasts Duration.
+type Blackout struct {
+	Name     string     `yaml:"name" json:"name,omitempty"`
+	Start    *time.Time `yaml:"start" json:"start,omitempty"`
+	End      *time.Time `yaml:"end" json:"end,omitempty"`
+	Cron     string     `yaml:"cron" json:"cron,omitempty"`
+	Duration Duration   `yaml:"duration" json:"duration,omitempty"`
+	// Timezone is the IANA time zone Cron is evaluated in (default UTC).
+	Timezone string `yaml:"timezone" json:"timezone,omitempty"`
+}
+
+// Webhook is an HTTP endpoint notified of sresim events.
+type Webhook struct {
+	Name string `yaml:"name" json:"name"`
+	URL  string `yaml:"url" json:"url"`
+	// Format is the payload format: json (the default), slack or
+	// cloudevents.
+	Format string `yaml:"format" json:"format,omitempty"`
+	// Events lists the event types sent to the webhook; a trailing "*"
+	// matches a prefix. By default scenario lifecycle events and verdicts
+	// are sent.
+	Events []string `yaml:"events" json:"events,omitempty"`
+	// Secret signs every payload with HMAC-SHA256. SecretEnv names an
+	// environment variable to read the secret from instead.
+	Secret    string            `yaml:"secret" json:"-"`
+	SecretEnv string            `yaml:"secret_env" json:"secret_env,omitempty"`
+	Headers   map[string]string `yaml:"headers" json:"headers,omitempty"`
+	// Timeout bounds each delivery attempt (default 5s) and MaxRetries the
+	// attempts after the first failed one (default 3).
+	Timeout    Duration `yaml:"timeout" json:"timeout,omitempty"`
+	MaxRetries int      `yaml:"max_retries" json:"max_retries,omitempty"`
+}
+
+// History configures where scenario runs, experiments and audit events are
+// recorded.
+type History struct {
+	// Path is the JSONL file the history is appended to. Put it on a
+	// persistent volume to keep the history across restarts. Empty keeps the
+	// history in memory only.
+	Path string `yaml:"path" json:"path,omitempty"`
+	// MaxAuditRecords is how many audit events are kept; older ones are
+	// dropped. Zero keeps the 10000 most recent.
+	MaxAuditRecords int `yaml:"max_audit_records" json:"max_audit_records,omitempty"`
+}
+
+// SLO defines the service level objectives for one handler.
+type SLO struct {
+	Name    string `yaml:"name" json:"name"`
+	Handler string `yaml:"handler" json:"handler"`
+	// Availability is the target share of requests that must not fail with
+	// a 5xx status, e.g. 0.999. Zero disables the availability objective.
+	Availability float64     `yaml:"availability" json:"availability,omitempty"`
+	Latency      *LatencySLO `yaml:"latency" json:"latency,omitempty"`
+}
+
+// LatencySLO requires Percentile (e.g. 0.99) of requests to complete within
+// Threshold.
+type LatencySLO struct {
+	Threshold  Duration `yaml:"threshold" json:"threshold"`
+	Percentile float64  `yaml:"percentile" json:"percentile"`
+}
+
+// Guardrails are the limits at which a scenario run is aborted
+// automatically. A zero value disables the corresponding limit.
+type Guardrails struct {
+	MaxDuration Duration `yaml:"max_duration" json:"max_duration,omitempty"`
+	// MaxRSSMB is the resident memory of the sresim process, in MiB.
+	MaxRSSMB int64 `yaml:"max_rss_mb" json:"max_rss_mb,omitempty"`
+	// MaxErrorRate is the share of 5xx responses served while the run is
+	// active, e.g. 0.5.
+	MaxErrorRate float64 `yaml:"max_error_rate" json:"max_error_rate,omitempty"`
+	// MaxBurnRate aborts the run once an SLO burns its error budget faster
+	// than this over BurnRateWindow (default 5m). SLO restricts the check to
+	// one SLO; by default every SLO is checked.
+	MaxBurnRate    float64 `yaml:"max_burn_rate" json:"max_burn_rate,omitempty"`
+	BurnRateWindow string  `yaml:"burn_rate_window" json:"burn_rate_window,omitempty"`
+	SLO            string  `yaml:"slo" json:"slo,omitempty"`
+}
+
+// Merge returns the guardrails with every unset field taken from defaults.
+func (g Guardrails) Merge(defaults Guardrails) Guardrails {
+	if g.MaxDuration == 0 {
+		g.MaxDuration = defaults.MaxDuration
+	}
+	if g.MaxRSSMB == 0 {
+		g.MaxRSSMB = defaults.MaxRSSMB
+	}
+	if g.MaxErrorRate == 0 {
+		g.MaxErrorRate = defaults.MaxErrorRate
+	}
+	if g.MaxBurnRate == 0 {
+		g.MaxBurnRate = defaults.MaxBurnRate
+		g.BurnRateWindow = defaults.BurnRateWindow
+		g.SLO = defaults.SLO
+	}
+	return g
+}
+
+// IsZero reports whether no guardrail is set.
+func (g Guardrails) IsZero() bool {
+	return g == Guardrails{}
+}
+
+// Default returns the configuration used when no file is provided.
+func Default() *Config {
+	return &Config{
+		Server: Server{ShutdownGracePeriod: DefaultShutdownGracePeriod},
+		SLOs: []SLO{
+			{
+				Name:         "simulate",
+				Handler:      "/simulate",
+				Availability: 0.99,
+				Latency: &LatencySLO{
+					Threshold:  Duration(300 * time.Millisecond),
+					Percentile: 0.99,
+				},
+			},
+		},
+		Guardrails: DefaultGuardrails(),
+	}
+}
+
+// DefaultGuardrails returns the guardrails applied when the configuration
+// file does not define any. They keep runs with default parameters, such as
+// a memory leak of several gigabytes, from taking the host down. The RSS
+// limit stays below the 512Mi memory limit of the Kubernetes deployment, so
+// that runs are aborted before the pod is OOM-killed.
+func DefaultGuardrails() *Guardrails {
+	return &Guardrails{
+		MaxDuration: Duration(30 * time.Minute),
+		MaxRSSMB:    384,
+	}
+}
+
+// Load reads the configuration file at path.
+func Load(path string) (*Config, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+	cfg := &Config{}
+	if err := yaml.Unmarshal(data, cfg); err != nil {
+		return nil, fmt.Errorf("parse %s: %w", path, err)
+	}
+	if cfg.SLOs == nil {
+		cfg.SLOs = Default().SLOs
+	}
+	if cfg.Guardrails == nil {
+		cfg.Guardrails = DefaultGuardrails()
+	}
+	if cfg.Server.ShutdownGracePeriod == 0 {
+		cfg.Server.ShutdownGracePeriod = DefaultShutdownGracePeriod
+	}
+	return cfg, nil
+}
+
+// FromEnv loads the file named by CONFIG_FILE, falling back to the defaults
+// when the variable is unset or the file does not exist.
+func FromEnv() (*Config, error) {
+	path := os.Getenv("CONFIG_FILE")
+	if path == "" {
+		return Default(), nil
+	}
+	cfg, err := Load(path)
+	if errors.Is(err, fs.ErrNotExist) {
+		return Default(), nil
+	}
+	return cfg, err
+}
@@ -0,0 +1,135 @@
+package events
+
+import (
+	"sync"
+	"time"
+
+	"github.com/google/uuid"
+)
+
+// Event types.
+const (
+	ScenarioStarted = "scenario.started"
+	ScenarioStopped = "scenario.stopped"
+	ScenarioAborted = "scenario.aborted"
+	// ScenarioVerdict is published when a verified run concludes.
+	ScenarioVerdict = "scenario.verdict"
+	// ScenarioDetected is published when an alert correlated with a run
+	// fires for the first time.
+	ScenarioDetected   = "scenario.detected"
+	ExperimentFinished = "experiment.finished"
+	Kill               = "admin.kill"
+	// FaultInjected is published for a sample of the faults injected by the
+	// chaos middleware.
+	FaultInjected       = "fault.injected"
+	BreakerStateChanged = "breaker.state_changed"
+	RateLimitBurst      = "rate_limit.burst"
+	// ReadinessChanged is published when the readiness_flap scenario makes
+	// sresim ready or unready.
+	ReadinessChanged = "readiness.changed"
+	ConfigReloaded   = "config.reloaded"
+)
+
+// Event is something that happened to a scenario run or to sresim itself.
+type Event struct {
+	ID       string                 `json:"id"`
+	Type     string                 `json:"type"`
+	Time     time.Time              `json:"time"`
+	RunID    string                 `json:"run_id,omitempty"`
+	Scenario string                 `json:"scenario,omitempty"`
+	Message  string                 `json:"message,omitempty"`
+	Data     map[string]interface{} `json:"data,omitempty"`
+}
+
+// Bus fans events out to subscribers. Publishing never blocks: a subscriber
+// that does not keep up misses events rather than stalling the publisher.
+type Bus struct {
+	mu     sync.Mutex
+	subs   map[chan Event]struct{}
+	closed bool
+	// streamsEnded is closed by EndStreams.
+	streamsEnded chan struct{}
+	endStreams   sync.Once
+}
+
+// NewBus returns a bus without subscribers.
+func NewBus() *Bus {
+	return &Bus{subs: make(map[chan Event]struct{}), streamsEnded: make(chan struct{})}
+}
+
+var bus = NewBus()
+
+// GetBus returns the process-wide event bus.
+func GetBus() *Bus {
+	return bus
+}
+
+// Publish fills in the ID and time of the event if unset and delivers it to
+// every subscriber. It returns the event as delivered.
+func (b *Bus) Publish(e Event) Event {
+	if e.ID == "" {
+		e.ID = uuid.NewString()
+	}
+	if e.Time.IsZero() {
+		e.Time = time.Now()
+	}
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	for ch := range b.subs {
+		select {
+		case ch <- e:
+		default:
+		}
+	}
+	return e
+}
+
+// Subscribe returns a channel receiving every event published from now on,
+// buffering up to buffer events, and a function that cancels the
+// subscription and closes the channel. On a closed bus the channel is
+// closed right away.
+func (b *Bus) Subscribe(buffer int) (<-chan Event, func()) {
+	ch := make(chan Event, buffer)
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	if b.closed {
+		close(ch)
+	} else {
+		b.subs[ch] = struct{}{}
+	}
+	return ch, func() { b.unsubscribe(ch) }
+}
+
+func (b *Bus) unsubscribe(ch chan Event) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	if _, ok := b.subs[ch]; ok {
+		delete(b.subs, ch)
+		close(ch)
+	}
+}
+
+// EndStreams ends the event streams served by Handler, so that they do not
+// keep the HTTP server from draining at shutdown. Other subscriptions keep
+// receiving events until Close.
+func (b *Bus) EndStreams() {
+	b.endStreams.Do(func() { close(b.streamsEnded) })
+}
+
+// Close ends every subscription at shutdown. Subscribers still receive the
+// events buffered so far before their channel reports it is closed, and
+// later events are dropped.
+func (b *Bus) Close() {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	b.closed = true
+	for ch := range b.subs {
+		delete(b.subs, ch)
+		close(ch)
+	}
+}
+
+// Publish publishes the event on the process-wide bus.
+func Publish(e Event) Event {
+	return bus.Publish(e)
+}
@@ -0,0 +1,66 @@
+package events
+
+import (
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestPublishSubscribe(t *testing.T) {
+	b := NewBus()
+	ch, cancel := b.Subscribe(1)
+
+	sent := b.Publish(Event{Type: ScenarioAborted, RunID: "run-1", Message: "too much memory"})
+	assert.NotEmpty(t, sent.ID)
+	assert.False(t, sent.Time.IsZero())
+
+	got := <-ch
+	assert.Equal(t, sent, got)
+
+	// A full subscriber must not block publishing.
+	b.Publish(Event{Type: ScenarioStarted})
+	b.Publish(Event{Type: ScenarioStopped})
+	assert.Equal(t, ScenarioStarted, (<-ch).Type)
+
+	cancel()
+	cancel()
+	_, open := <-ch
+	require.False(t, open)
+	b.Publish(Event{Type: Kill})
+}
+
+func TestClose(t *testing.T) {
+	b := NewBus()
+	ch, cancel := b.Subscribe(2)
+	b.Publish(Event{Type: ScenarioAborted})
+
+	b.Close()
+	b.Publish(Event{Type: ScenarioStopped})
+	assert.Equal(t, ScenarioAborted, (<-ch).Type, "buffered events are still delivered")
+	_, open := <-ch
+	require.False(t, open)
+	cancel()
+
+	late, _ := b.Subscribe(1)
+	_, open = <-late
+	assert.False(t, open)
+}
+
+func TestSampler(t *testing.T) {
+	s := NewSampler(time.Hour)
+	ok, skipped := s.Allow("fail")
+	assert.True(t, ok)
+	assert.Zero(t, skipped)
+
+	ok, _ = s.Allow("fail")
+	assert.False(t, ok)
+	ok, _ = s.Allow("delay")
+	assert.True(t, ok, "keys are sampled independently")
+
+	s.interval = 0
+	ok, skipped = s.Allow("fail")
+	assert.True(t, ok)
+	assert.Equal(t, 1, skipped)
+}
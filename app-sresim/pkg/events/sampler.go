@@ -0,0 +1,41 @@
+package events
+
+import (
+	"sync"
+	"time"
+)
+
+// Sampler limits high-frequency events to at most one per key and interval.
+type Sampler struct {
+	interval time.Duration
+
+	mu      sync.Mutex
+	last    map[string]time.Time
+	skipped map[string]int
+}
+
+// NewSampler returns a sampler letting one event per key through every
+// interval.
+func NewSampler(interval time.Duration) *Sampler {
+	return &Sampler{
+		interval: interval,
+		last:     make(map[string]time.Time),
+		skipped:  make(map[string]int),
+	}
+}
+
+// Allow reports whether an event for key should be published now and, if
+// so, how many were dropped since the last one.
+func (s *Sampler) Allow(key string) (bool, int) {
+	now := time.Now()
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if last, ok := s.last[key]; ok && now.Sub(last) < s.interval {
+		s.skipped[key]++
+		return false, 0
+	}
+	skipped := s.skipped[key]
+	s.last[key] = now
+	s.skipped[key] = 0
+	return true, skipped
+}
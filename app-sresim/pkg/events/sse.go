@@ -0,0 +1,107 @@
+package events
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"strings"
+	"time"
+)
+
+// heartbeatInterval is how often an idle stream sends a comment so that
+// proxies do not close the connection.
+var heartbeatInterval = 15 * time.Second
+
+// streamBuffer is how many events a slow stream client may fall behind
+// before it starts missing events.
+const streamBuffer = 256
+
+// Filter selects events by type and run. Types ending in "*" match every
+// type with that prefix, e.g. "scenario.*". Empty fields match everything.
+type Filter struct {
+	Types []string
+	RunID string
+}
+
+// Match reports whether e passes the filter.
+func (f Filter) Match(e Event) bool {
+	if f.RunID != "" && e.RunID != f.RunID {
+		return false
+	}
+	if len(f.Types) == 0 {
+		return true
+	}
+	for _, t := range f.Types {
+		if prefix, ok := strings.CutSuffix(t, "*"); ok {
+			if strings.HasPrefix(e.Type, prefix) {
+				return true
+			}
+		} else if e.Type == t {
+			return true
+		}
+	}
+	return false
+}
+
+// Handler serves GET /events, streaming the bus as Server-Sent Events.
+// ?type= takes a comma-separated list of event types and ?run_id= limits
+// the stream to one scenario run.
+func (b *Bus) Handler(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet {
+		w.Header().Set("Allow", "GET")
+		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+	filter := Filter{RunID: r.URL.Query().Get("run_id")}
+	for _, t := range strings.Split(r.URL.Query().Get("type"), ",") {
+		if t = strings.TrimSpace(t); t != "" {
+			filter.Types = append(filter.Types, t)
+		}
+	}
+
+	ch, cancel := b.Subscribe(streamBuffer)
+	defer cancel()
+
+	rc := http.NewResponseController(w)
+	w.Header().Set("Content-Type", "text/event-stream")
+	w.Header().Set("Cache-Control", "no-cache")
+	w.Header().Set("X-Accel-Buffering", "no")
+	w.WriteHeader(http.StatusOK)
+	fmt.Fprint(w, ": connected\n\n")
+	if err := rc.Flush(); err != nil {
+		return
+	}
+
+	heartbeat := time.NewTicker(heartbeatInterval)
+	defer heartbeat.Stop()
+	for {
+		select {
+		case <-r.Context().Done():
+			return
+		case <-b.streamsEnded:
+			return
+		case <-heartbeat.C:
+			fmt.Fprint(w, ": heartbeat\n\n")
+		case e, ok := <-ch:
+			if !ok {
+				return
+			}
+			if !filter.Match(e) {
+				continue
+			}
+			data, err := json.Marshal(e)
+			if err != nil {
+				continue
+			}
+			fmt.Fprintf(w, "id: %s\nevent: %s\ndata: %s\n\n", e.ID, e.Type, data)
+		}
+		if err := rc.Flush(); err != nil {
+			return
+		}
+	}
+}
+
+// Handler serves the process-wide bus as Server-Sent Events.
+func Handler(w http.ResponseWriter, r *http.Request) {
+	bus.Handler(w, r)
+}
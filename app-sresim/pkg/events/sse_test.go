@@ -0,0 +1,86 @@
+package events
+
+import (
+	"bufio"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestFilter(t *testing.T) {
+	e := Event{Type: ScenarioAborted, RunID: "run-1"}
+	assert.True(t, Filter{}.Match(e))
+	assert.True(t, Filter{Types: []string{ScenarioStarted, ScenarioAborted}}.Match(e))
+	assert.True(t, Filter{Types: []string{"scenario.*"}, RunID: "run-1"}.Match(e))
+	assert.False(t, Filter{Types: []string{"breaker.*"}}.Match(e))
+	assert.False(t, Filter{RunID: "run-2"}.Match(e))
+}
+
+func TestHandlerStreamsFilteredEvents(t *testing.T) {
+	b := NewBus()
+	srv := httptest.NewServer(http.HandlerFunc(b.Handler))
+	defer srv.Close()
+
+	resp, err := http.Get(srv.URL + "?type=scenario.*,admin.kill&run_id=run-1")
+	require.NoError(t, err)
+	defer resp.Body.Close()
+	assert.Equal(t, "text/event-stream", resp.Header.Get("Content-Type"))
+
+	lines := bufio.NewScanner(resp.Body)
+	require.True(t, lines.Scan())
+	require.Equal(t, ": connected", lines.Text())
+
+	b.Publish(Event{Type: ScenarioStarted, RunID: "run-2"})
+	b.Publish(Event{Type: BreakerStateChanged, RunID: "run-1"})
+	sent := b.Publish(Event{Type: ScenarioAborted, RunID: "run-1", Message: "too slow"})
+
+	var frame []string
+	for lines.Scan() {
+		if lines.Text() == "" {
+			if len(frame) > 0 {
+				break
+			}
+			continue
+		}
+		frame = append(frame, lines.Text())
+	}
+	require.Len(t, frame, 3)
+	assert.Equal(t, "id: "+sent.ID, frame[0])
+	assert.Equal(t, "event: "+ScenarioAborted, frame[1])
+	var got Event
+	require.NoError(t, json.Unmarshal([]byte(strings.TrimPrefix(frame[2], "data: ")), &got))
+	assert.Equal(t, "too slow", got.Message)
+}
+
+func TestHandlerRejectsPost(t *testing.T) {
+	rec := httptest.NewRecorder()
+	NewBus().Handler(rec, httptest.NewRequest(http.MethodPost, "/events", nil))
+	assert.Equal(t, http.StatusMethodNotAllowed, rec.Code)
+}
+
+func TestEndStreams(t *testing.T) {
+	b := NewBus()
+	srv := httptest.NewServer(http.HandlerFunc(b.Handler))
+	defer srv.Close()
+	ch, cancel := b.Subscribe(1)
+	defer cancel()
+
+	resp, err := http.Get(srv.URL)
+	require.NoError(t, err)
+	defer resp.Body.Close()
+	lines := bufio.NewScanner(resp.Body)
+	require.True(t, lines.Scan())
+
+	b.EndStreams()
+	for lines.Scan() {
+	}
+	require.NoError(t, lines.Err(), "the stream ends cleanly")
+
+	b.Publish(Event{Type: ScenarioAborted})
+	assert.Equal(t, ScenarioAborted, (<-ch).Type, "other subscribers still receive events")
+}
@@ -0,0 +1,104 @@
+package experiment
+
+import (
+	"encoding/json"
+	"io"
+	"net/http"
+
+	"github.com/localstack/sresim/app-sresim/pkg/auth"
+)
+
+// maxDefinitionSize bounds the experiment definitions accepted over HTTP.
+const maxDefinitionSize = 1 << 20
+
+// ExperimentsHandler serves /experiments. POST starts an experiment from a
+// YAML or JSON definition in the request body; GET lists executions, or
+// returns a single execution when ?id= is set.
+func (m *Manager) ExperimentsHandler(w http.ResponseWriter, r *http.Request) {
+	switch r.Method {
+	case http.MethodPost:
+		m.startExecution(w, r)
+	case http.MethodGet:
+		m.getExecutions(w, r)
+	default:
+		w.Header().Set("Allow", "GET, POST")
+		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+	}
+}
+
+// StopHandler stops the execution named by ?id= and runs its rollback.
+func (m *Manager) StopHandler(w http.ResponseWriter, r *http.Request) {
+	e, ok := m.Get(r.URL.Query().Get("id"))
+	if !ok {
+		http.Error(w, "Experiment not found", http.StatusNotFound)
+		return
+	}
+	if !auth.CheckScenarios(w, r, e.Experiment.Scenarios()...) {
+		return
+	}
+	writeJSON(w, http.StatusOK, e.Stop())
+}
+
+func (m *Manager) startExecution(w http.ResponseWriter, r *http.Request) {
+	data, err := io.ReadAll(http.MaxBytesReader(w, r.Body, maxDefinitionSize))
+	if err != nil {
+		http.Error(w, "Invalid experiment: "+err.Error(), http.StatusBadRequest)
+		return
+	}
+	exp, err := Parse(data)
+	if err != nil {
+		http.Error(w, "Invalid experiment: "+err.Error(), http.StatusBadRequest)
+		return
+	}
+	if !auth.CheckScenarios(w, r, exp.Scenarios()...) {
+		return
+	}
+	e, err := m.Start(*exp)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+	writeJSON(w, http.StatusAccepted, e.Status())
+}
+
+func (m *Manager) getExecutions(w http.ResponseWriter, r *http.Request) {
+	if id := r.URL.Query().Get("id"); id != "" {
+		e, ok := m.Get(id)
+		if !ok {
+			http.Error(w, "Experiment not found", http.StatusNotFound)
+			return
+		}
+		writeJSON(w, http.StatusOK, e.Status())
+		return
+	}
+
+	executions := m.List()
+	statuses := make([]ExecutionStatus, 0, len(executions))
+	for _, e := range executions {
+		statuses = append(statuses, e.Status())
+	}
+	writeJSON(w, http.StatusOK, statuses)
+}
+
+func writeJSON(w http.ResponseWriter, status int, v interface{}) {
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(status)
+	json.NewEncoder(w).Encode(v)
+}
+
+// ReportHandler serves GET /experiments/{id}/report. ?format= selects junit,
+// json (the default) or md.
+func (m *Manager) ReportHandler(w http.ResponseWriter, r *http.Request) {
+	e, ok := m.Get(r.PathValue("id"))
+	if !ok {
+		http.Error(w, "Experiment not found", http.StatusNotFound)
+		return
+	}
+	out, contentType, err := m.Report(e).Render(r.URL.Query().Get("format"))
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+	w.Header().Set("Content-Type", contentType)
+	w.Write(out)
+}
@@ -0,0 +1,242 @@
+package experiment
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"strings"
+
+	"github.com/localstack/sresim/app-sresim/pkg/config"
+	"github.com/localstack/sresim/app-sresim/pkg/loadgen"
+	"github.com/localstack/sresim/app-sresim/pkg/simulator"
+	"github.com/localstack/sresim/app-sresim/pkg/steadystate"
+	"gopkg.in/yaml.v3"
+)
+
+// Step kinds.
+const (
+	KindScenario = "scenario"
+	KindStop     = "stop"
+	KindWait     = "wait"
+	KindLoad     = "load"
+	KindAssert   = "assert"
+	KindParallel = "parallel"
+)
+
+// Experiment is a declarative sequence of steps, e.g. a game day. Rollback
+// steps run once the steps have finished, whether they passed or not.
+// Scenarios and background load the experiment started are stopped after
+// the rollback in any case.
+type Experiment struct {
+	Name        string `json:"name"`
+	Description string `json:"description,omitempty"`
+	// Seed is used for the random values of every load step that does not
+	// set its own. A random seed is chosen when it is zero, and recorded in
+	// the report so the run can be repeated.
+	Seed     int64  `json:"seed,omitempty"`
+	Steps    []Step `json:"steps"`
+	Rollback []Step `json:"rollback,omitempty"`
+}
+
+// Step is one action of an experiment. Exactly one of Scenario, Stop, Wait,
+// Load, Assert or Parallel must be set.
+type Step struct {
+	Name string `json:"name,omitempty"`
+
+	// Scenario starts the named scenario with Parameters and Guardrails. With
+	// Duration set the step keeps the scenario running for that long and then
+	// stops it; otherwise the scenario runs until a stop step or the end of
+	// the experiment.
+	Scenario   string                 `json:"scenario,omitempty"`
+	Parameters map[string]interface{} `json:"parameters,omitempty"`
+	Guardrails *config.Guardrails     `json:"guardrails,omitempty"`
+	Duration   config.Duration        `json:"duration,omitempty"`
+
+	// Stop stops a scenario started by an earlier step.
+	Stop string `json:"stop,omitempty"`
+
+	// Wait pauses the experiment.
+	Wait config.Duration `json:"wait,omitempty"`
+
+	// Load generates traffic and waits for it to finish, unless Background
+	// is set, in which case the following steps run alongside it.
+	Load       *loadgen.Config `json:"load,omitempty"`
+	Background bool            `json:"background,omitempty"`
+
+	// Assert checks a steady-state hypothesis; the experiment fails if it
+	// does not hold.
+	Assert *steadystate.Hypothesis `json:"assert,omitempty"`
+
+	// Parallel runs the nested steps concurrently and waits for all of them.
+	Parallel []Step `json:"parallel,omitempty"`
+}
+
+// Kind returns which action the step performs, or "" if none is set.
+func (s Step) Kind() string {
+	switch {
+	case s.Scenario != "":
+		return KindScenario
+	case s.Stop != "":
+		return KindStop
+	case s.Wait != 0:
+		return KindWait
+	case s.Load != nil:
+		return KindLoad
+	case s.Assert != nil:
+		return KindAssert
+	case len(s.Parallel) > 0:
+		return KindParallel
+	}
+	return ""
+}
+
+// Scenarios returns the scenarios the steps and rollback of the experiment
+// start or stop.
+func (e Experiment) Scenarios() []string {
+	seen := make(map[string]bool)
+	var names []string
+	var collect func(steps []Step)
+	collect = func(steps []Step) {
+		for _, step := range steps {
+			for _, name := range []string{step.Scenario, step.Stop} {
+				if name != "" && !seen[name] {
+					seen[name] = true
+					names = append(names, name)
+				}
+			}
+			collect(step.Parallel)
+		}
+	}
+	collect(e.Steps)
+	collect(e.Rollback)
+	return names
+}
+
+// actions returns how many actions are set on the step.
+func (s Step) actions() int {
+	n := 0
+	for _, set := range []bool{s.Scenario != "", s.Stop != "", s.Wait != 0, s.Load != nil, s.Assert != nil, len(s.Parallel) > 0} {
+		if set {
+			n++
+		}
+	}
+	return n
+}
+
+// title is the step name, or a description of the step if it has none.
+func (s Step) title() string {
+	if s.Name != "" {
+		return s.Name
+	}
+	switch s.Kind() {
+	case KindScenario:
+		return "scenario " + s.Scenario
+	case KindStop:
+		return "stop " + s.Stop
+	case KindWait:
+		return "wait " + s.Wait.String()
+	case KindLoad:
+		return "load"
+	case KindAssert:
+		if s.Assert.Title != "" {
+			return "assert " + s.Assert.Title
+		}
+		return "assert"
+	case KindParallel:
+		return "parallel"
+	}
+	return ""
+}
+
+// Parse reads an experiment from YAML or JSON and validates it. Unknown
+// fields are rejected so that typos do not silently change the experiment.
+func Parse(data []byte) (*Experiment, error) {
+	// Decode the YAML generically and re-encode it as JSON, so the JSON field
+	// names and duration parsing of the embedded types apply to both formats.
+	var doc interface{}
+	if err := yaml.Unmarshal(data, &doc); err != nil {
+		return nil, err
+	}
+	raw, err := json.Marshal(doc)
+	if err != nil {
+		return nil, err
+	}
+	dec := json.NewDecoder(bytes.NewReader(raw))
+	dec.DisallowUnknownFields()
+	var exp Experiment
+	if err := dec.Decode(&exp); err != nil {
+		return nil, err
+	}
+	if err := exp.Validate(); err != nil {
+		return nil, err
+	}
+	return &exp, nil
+}
+
+// Validate checks the experiment for mistakes that can be caught before it
+// runs.
+func (e Experiment) Validate() error {
+	if e.Name == "" {
+		return errors.New("experiment name is required")
+	}
+	if len(e.Steps) == 0 {
+		return errors.New("experiment needs at least one step")
+	}
+	if err := validateSteps(e.Steps, "steps"); err != nil {
+		return err
+	}
+	return validateSteps(e.Rollback, "rollback")
+}
+
+func validateSteps(steps []Step, path string) error {
+	known := make(map[string]bool)
+	for _, name := range simulator.ScenarioNames() {
+		known[name] = true
+	}
+	for i, step := range steps {
+		where := fmt.Sprintf("%s[%d]", path, i)
+		if step.Name != "" {
+			where += " (" + step.Name + ")"
+		}
+		if n := step.actions(); n != 1 {
+			return fmt.Errorf("%s: a step needs exactly one of scenario, stop, wait, load, assert or parallel, got %d", where, n)
+		}
+		switch step.Kind() {
+		case KindScenario:
+			if !known[step.Scenario] {
+				return fmt.Errorf("%s: unknown scenario %q (available: %s)", where, step.Scenario, strings.Join(simulator.ScenarioNames(), ", "))
+			}
+			if step.Duration < 0 {
+				return fmt.Errorf("%s: duration must not be negative", where)
+			}
+		case KindStop:
+			if !known[step.Stop] {
+				return fmt.Errorf("%s: unknown scenario %q", where, step.Stop)
+			}
+		case KindWait:
+			if step.Wait < 0 {
+				return fmt.Errorf("%s: wait must not be negative", where)
+			}
+		case KindLoad:
+			if err := step.Load.WithDefaults().Validate(); err != nil {
+				return fmt.Errorf("%s: load: %w", where, err)
+			}
+		case KindAssert:
+			if err := step.Assert.Validate(); err != nil {
+				return fmt.Errorf("%s: %w", where, err)
+			}
+		case KindParallel:
+			if err := validateSteps(step.Parallel, where+".parallel"); err != nil {
+				return err
+			}
+		}
+		if step.Kind() != KindScenario && (step.Parameters != nil || step.Guardrails != nil || step.Duration != 0) {
+			return fmt.Errorf("%s: parameters, guardrails and duration only apply to scenario steps", where)
+		}
+		if step.Kind() != KindLoad && step.Background {
+			return fmt.Errorf("%s: background only applies to load steps", where)
+		}
+	}
+	return nil
+}
@@ -0,0 +1,273 @@
+package experiment
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/localstack/sresim/app-sresim/pkg/loadgen"
+	"github.com/localstack/sresim/app-sresim/pkg/metrics"
+	"github.com/localstack/sresim/app-sresim/pkg/simulator"
+	"github.com/localstack/sresim/app-sresim/pkg/store"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestParseExample(t *testing.T) {
+	data, err := os.ReadFile("../../experiments/latency-gameday.yaml")
+	require.NoError(t, err)
+
+	exp, err := Parse(data)
+	require.NoError(t, err)
+	assert.Equal(t, "latency-gameday", exp.Name)
+	require.Len(t, exp.Steps, 8)
+	assert.Equal(t, KindLoad, exp.Steps[0].Kind())
+	assert.True(t, exp.Steps[0].Background)
+	assert.Equal(t, KindAssert, exp.Steps[1].Kind())
+	assert.Equal(t, 300*time.Millisecond, exp.Steps[1].Assert.Probes[0].MaxLatency.Std())
+	assert.Equal(t, KindScenario, exp.Steps[2].Kind())
+	assert.Equal(t, float64(500), exp.Steps[2].Parameters["delay_ms"])
+	assert.Equal(t, KindWait, exp.Steps[3].Kind())
+	assert.Equal(t, KindParallel, exp.Steps[4].Kind())
+	assert.Len(t, exp.Steps[4].Parallel, 2)
+	assert.Equal(t, KindStop, exp.Rollback[0].Kind())
+	assert.Equal(t, []string{"latency", "cpu_spike"}, exp.Scenarios(), "nested and rollback steps count too")
+}
+
+func TestParseJSON(t *testing.T) {
+	exp, err := Parse([]byte(`{"name": "json", "steps": [{"wait": "1s"}, {"wait": 2}]}`))
+	require.NoError(t, err)
+	assert.Equal(t, time.Second, exp.Steps[0].Wait.Std())
+	assert.Equal(t, 2*time.Second, exp.Steps[1].Wait.Std())
+}
+
+func TestParseErrors(t *testing.T) {
+	for name, def := range map[string]string{
+		"missing name":     "steps: [{wait: 1s}]",
+		"no steps":         "name: x",
+		"unknown field":    "name: x\nsteps: [{wiat: 1s}]",
+		"two actions":      "name: x\nsteps: [{wait: 1s, stop: latency}]",
+		"no action":        "name: x\nsteps: [{name: nothing}]",
+		"unknown scenario": "name: x\nsteps: [{scenario: meteor_strike}]",
+		"nested":           "name: x\nsteps: [{parallel: [{scenario: meteor_strike}]}]",
+		"misplaced field":  "name: x\nsteps: [{wait: 1s, duration: 1s}]",
+		"bad hypothesis":   "name: x\nsteps: [{assert: {probes: []}}]",
+		"bad load":         "name: x\nsteps: [{load: {model: poisson}}]",
+		"excessive load":   "name: x\nsteps: [{load: {rate: 1000000}}]",
+		"bad assert load":  "name: x\nsteps: [{assert: {load: {rate: -1}, probes: [{type: error_rate, handler: /simulate}]}}]",
+		"bad rollback":     "name: x\nsteps: [{wait: 1s}]\nrollback: [{stop: meteor_strike}]",
+	} {
+		_, err := Parse([]byte(def))
+		assert.Error(t, err, name)
+	}
+}
+
+func testManager(t *testing.T) *Manager {
+	t.Helper()
+	reg := metrics.NewRegistry()
+	scenarios := simulator.NewManager(reg)
+	t.Cleanup(func() {
+		scenarios.KillAll("test cleanup")
+		loadgen.GetManager().StopAll()
+	})
+	return NewManager(scenarios, loadgen.GetManager(), reg.Gatherer())
+}
+
+func mustParse(t *testing.T, def string) Experiment {
+	t.Helper()
+	exp, err := Parse([]byte(def))
+	require.NoError(t, err)
+	return *exp
+}
+
+func TestRunPassing(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {}))
+	defer server.Close()
+
+	m := testManager(t)
+	e, err := m.Start(mustParse(t, strings.ReplaceAll(`
+name: passing
+steps:
+  - scenario: latency
+    parameters: {delay_ms: 10}
+  - parallel:
+      - wait: 20ms
+      - load: {target: TARGET, rate: 50, duration: 100ms}
+  - stop: latency
+  - assert:
+      window: 50ms
+      load: {target: TARGET, rate: 100}
+      probes:
+        - {type: error_rate, source: loadgen, max_error_rate: 0}
+`, "TARGET", server.URL)))
+	require.NoError(t, err)
+
+	status := e.Wait()
+	assert.Equal(t, StatusPassed, status.Status, status.Error)
+	assert.NotNil(t, status.EndedAt)
+	require.Len(t, status.Steps, 4)
+	for _, step := range status.Steps {
+		assert.Equal(t, StatusPassed, step.Status, step.Name)
+	}
+
+	run, ok := m.scenarios.GetRun(status.Steps[0].RunID)
+	require.True(t, ok)
+	assert.Equal(t, simulator.RunStopped, run.Status)
+
+	load := status.Steps[1].Steps[1]
+	require.NotNil(t, load.Load)
+	assert.Greater(t, load.Load.Requests, int64(0))
+
+	require.NotNil(t, status.Steps[3].Check)
+	assert.True(t, status.Steps[3].Check.Passed)
+}
+
+func TestRunFailingAssertSkipsRestAndRollsBack(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusServiceUnavailable)
+	}))
+	defer server.Close()
+
+	m := testManager(t)
+	e, err := m.Start(mustParse(t, strings.ReplaceAll(`
+name: failing
+steps:
+  - scenario: latency
+  - name: healthy
+    assert:
+      window: 50ms
+      load: {target: TARGET, rate: 100}
+      probes:
+        - {type: error_rate, source: loadgen, max_error_rate: 0.01}
+  - wait: 1h
+rollback:
+  - wait: 1ms
+`, "TARGET", server.URL)))
+	require.NoError(t, err)
+
+	status := e.Wait()
+	assert.Equal(t, StatusFailed, status.Status)
+	assert.Contains(t, status.Error, "healthy: steady state was not met")
+	assert.Equal(t, StatusFailed, status.Steps[1].Status)
+	assert.Equal(t, StatusSkipped, status.Steps[2].Status)
+	assert.Equal(t, StatusPassed, status.Rollback[0].Status)
+
+	// Scenarios left running are stopped once the experiment ends.
+	assert.False(t, m.scenarios.IsScenarioActive("latency"))
+}
+
+func TestStopExecution(t *testing.T) {
+	m := testManager(t)
+	e, err := m.Start(mustParse(t, `
+name: stopped
+steps:
+  - scenario: latency
+  - load: {target: http://127.0.0.1:1, rate: 1, duration: 1h}
+    background: true
+  - wait: 1h
+`))
+	require.NoError(t, err)
+
+	require.Eventually(t, func() bool {
+		return e.Status().Steps[2].Status == StatusRunning
+	}, 5*time.Second, 10*time.Millisecond)
+
+	status := e.Stop()
+	assert.Equal(t, StatusStopped, status.Status)
+	assert.Equal(t, StatusStopped, status.Steps[2].Status)
+	assert.False(t, m.scenarios.IsScenarioActive("latency"))
+
+	load, ok := loadgen.GetManager().Get(status.Steps[1].LoadRunID)
+	require.True(t, ok)
+	assert.Equal(t, loadgen.StatusStopped, load.Status().Status)
+
+	got, ok := m.Get(e.ID)
+	require.True(t, ok)
+	assert.Equal(t, e, got)
+	assert.Len(t, m.List(), 1)
+}
+
+func TestStopAll(t *testing.T) {
+	m := testManager(t)
+	running, err := m.Start(mustParse(t, "name: running\nsteps: [{wait: 1h}]\nrollback: [{wait: 10ms}]"))
+	require.NoError(t, err)
+	finished, err := m.Start(mustParse(t, "name: finished\nsteps: [{wait: 1ms}]"))
+	require.NoError(t, err)
+	finished.Wait()
+
+	assert.Equal(t, 1, m.StopAll(context.Background()))
+	status := running.Status()
+	assert.Equal(t, StatusStopped, status.Status)
+	assert.Equal(t, StatusPassed, status.Rollback[0].Status, "rollback ran before StopAll returned")
+	assert.Equal(t, StatusPassed, finished.Status().Status)
+
+	// Rollbacks that outlast ctx are left running.
+	slow, err := m.Start(mustParse(t, "name: slow\nsteps: [{wait: 1h}]\nrollback: [{wait: 1h}]"))
+	require.NoError(t, err)
+	ctx, cancel := context.WithTimeout(context.Background(), 50*time.Millisecond)
+	defer cancel()
+	assert.Equal(t, 1, m.StopAll(ctx))
+	assert.Equal(t, StatusRunning, slow.Status().Status)
+}
+
+func TestExecutionHistory(t *testing.T) {
+	st, err := store.Open("", 0)
+	require.NoError(t, err)
+
+	m := testManager(t)
+	require.NoError(t, m.SetStore(st))
+	e, err := m.Start(mustParse(t, "name: history\nsteps: [{wait: 1h}, {wait: 1ms}]"))
+	require.NoError(t, err)
+	require.Eventually(t, func() bool {
+		record, ok := st.Get(store.KindExperiment, e.ID)
+		return ok && strings.Contains(string(record.Data), `"kind":"wait","status":"running"`)
+	}, 5*time.Second, 10*time.Millisecond)
+
+	// A new process finds the execution still running in the history.
+	restarted := testManager(t)
+	require.NoError(t, restarted.SetStore(st))
+	e.Stop()
+
+	got, ok := restarted.Get(e.ID)
+	require.True(t, ok)
+	status := got.Wait()
+	assert.Equal(t, StatusInterrupted, status.Status)
+	assert.Equal(t, "history", status.Name)
+	assert.Equal(t, StatusInterrupted, status.Steps[0].Status)
+	assert.Equal(t, StatusInterrupted, status.Steps[1].Status)
+}
+
+func TestRestoredExecution(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "history.jsonl")
+	st, err := store.Open(path, 0)
+	require.NoError(t, err)
+	m := testManager(t)
+	require.NoError(t, m.SetStore(st))
+	e, err := m.Start(mustParse(t, "name: restored\nsteps: [{wait: 1ms}, {scenario: latency, duration: 10ms}]\nrollback: [{stop: latency}]"))
+	require.NoError(t, err)
+	e.Wait()
+	require.NoError(t, st.Close())
+
+	// After a restart the execution is reported on as before.
+	st, err = store.Open(path, 0)
+	require.NoError(t, err)
+	defer st.Close()
+	restarted := testManager(t)
+	require.NoError(t, restarted.SetStore(st))
+	got, ok := restarted.Get(e.ID)
+	require.True(t, ok)
+	assert.Equal(t, e.Experiment, got.Experiment, "the steps are restored")
+	assert.Equal(t, []string{"latency"}, got.Experiment.Scenarios())
+	cases := restarted.Report(got).Cases
+	require.Len(t, cases, 3)
+	for i, want := range m.Report(e).Cases {
+		assert.Equal(t, want.Name, cases[i].Name)
+		assert.Equal(t, want.Status, cases[i].Status)
+		assert.Equal(t, want.RunID, cases[i].RunID)
+	}
+}
@@ -0,0 +1,371 @@
+package experiment
+
+import (
+	"bytes"
+	"encoding/json"
+	"encoding/xml"
+	"fmt"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/localstack/sresim/app-sresim/pkg/loadgen"
+	"github.com/localstack/sresim/app-sresim/pkg/steadystate"
+	"github.com/localstack/sresim/app-sresim/pkg/version"
+)
+
+// Report formats.
+const (
+	FormatJSON     = "json"
+	FormatJUnit    = "junit"
+	FormatMarkdown = "md"
+)
+
+// Report is the result of an execution in a form suited to CI test
+// reporting: every step, and every probe of an assertion, is a test case.
+type Report struct {
+	ID          string     `json:"id"`
+	Name        string     `json:"name"`
+	Description string     `json:"description,omitempty"`
+	Status      string     `json:"status"`
+	Error       string     `json:"error,omitempty"`
+	Seed        int64      `json:"seed"`
+	StartedAt   time.Time  `json:"started_at"`
+	EndedAt     *time.Time `json:"ended_at,omitempty"`
+	Duration    float64    `json:"duration_seconds"`
+	SLOBurn     []SLOBurn  `json:"slo_burn,omitempty"`
+	Cases       []Case     `json:"cases"`
+	// Build identifies the sresim that ran the experiment, so that results
+	// can be compared across releases.
+	Build version.Info `json:"build"`
+}
+
+// Case is one test case of a report.
+type Case struct {
+	Name     string  `json:"name"`
+	Kind     string  `json:"kind"`
+	Status   string  `json:"status"`
+	Duration float64 `json:"duration_seconds"`
+	Error    string  `json:"error,omitempty"`
+
+	Scenario    string                 `json:"scenario,omitempty"`
+	Parameters  map[string]interface{} `json:"parameters,omitempty"`
+	RunID       string                 `json:"run_id,omitempty"`
+	RunStatus   string                 `json:"run_status,omitempty"`
+	AbortReason string                 `json:"abort_reason,omitempty"`
+
+	Load  *LoadStats               `json:"load,omitempty"`
+	Probe *steadystate.ProbeResult `json:"probe,omitempty"`
+}
+
+// LoadStats summarises the traffic of a load step.
+type LoadStats struct {
+	Requests   int64                  `json:"requests"`
+	ErrorRate  float64                `json:"error_rate"`
+	Throughput float64                `json:"throughput"`
+	Latency    loadgen.LatencySummary `json:"latency"`
+}
+
+// Report builds the report of an execution. Scenario parameters, run
+// states and abort reasons are looked up from the scenario runs, so they
+// reflect what happened after the step that started a scenario finished.
+func (m *Manager) Report(e *Execution) Report {
+	status := e.Status()
+	report := Report{
+		ID:          status.ID,
+		Name:        status.Name,
+		Description: status.Description,
+		Status:      status.Status,
+		Error:       status.Error,
+		Seed:        status.Seed,
+		StartedAt:   status.StartedAt,
+		EndedAt:     status.EndedAt,
+		Duration:    seconds(&status.StartedAt, status.EndedAt),
+		SLOBurn:     status.SLOBurn,
+		Cases:       []Case{},
+		Build:       e.Build,
+	}
+	m.addCases(&report, "", status.Steps)
+	m.addCases(&report, "rollback / ", status.Rollback)
+	return report
+}
+
+func (m *Manager) addCases(report *Report, prefix string, results []*StepResult) {
+	for _, r := range results {
+		name := prefix + r.Name
+		if r.Kind == KindParallel {
+			m.addCases(report, name+" / ", r.Steps)
+			continue
+		}
+
+		c := Case{
+			Name:     name,
+			Kind:     r.Kind,
+			Status:   r.Status,
+			Duration: seconds(r.StartedAt, r.EndedAt),
+			Error:    r.Error,
+			Scenario: r.Scenario,
+			RunID:    r.RunID,
+		}
+		if r.RunID != "" {
+			if run, ok := m.scenarios.GetRun(r.RunID); ok {
+				c.Parameters = run.Parameters
+				c.RunStatus = run.Status
+				c.AbortReason = run.AbortReason
+			}
+		}
+		if r.Load != nil {
+			c.Load = &LoadStats{
+				Requests:   r.Load.Requests,
+				ErrorRate:  r.Load.ErrorRate(),
+				Throughput: r.Load.Throughput,
+				Latency:    r.Load.Latency,
+			}
+		}
+
+		if r.Kind == KindAssert && r.Check != nil && len(r.Check.Probes) > 0 {
+			for _, probe := range r.Check.Probes {
+				probe := probe
+				pc := c
+				pc.Name = name + " / " + probe.Name
+				pc.Probe = &probe
+				pc.Error = ""
+				if !probe.Passed {
+					pc.Status = StatusFailed
+					pc.Error = probeFailure(probe)
+				} else if c.Status == StatusFailed {
+					// The step failed because of another probe.
+					pc.Status = StatusPassed
+				}
+				report.Cases = append(report.Cases, pc)
+			}
+			continue
+		}
+		report.Cases = append(report.Cases, c)
+	}
+}
+
+func probeFailure(p steadystate.ProbeResult) string {
+	if p.Error != "" {
+		return p.Error
+	}
+	return fmt.Sprintf("%s %g %s exceeds %g %s", p.Type, p.Value, p.Unit, p.Limit, p.Unit)
+}
+
+func seconds(start, end *time.Time) float64 {
+	if start == nil || end == nil {
+		return 0
+	}
+	return end.Sub(*start).Seconds()
+}
+
+// Render encodes the report in the given format.
+func (r Report) Render(format string) ([]byte, string, error) {
+	switch format {
+	case FormatJSON, "":
+		out, err := json.MarshalIndent(r, "", "  ")
+		return append(out, '\n'), "application/json", err
+	case FormatJUnit:
+		out, err := r.junit()
+		return out, "application/xml", err
+	case FormatMarkdown:
+		return r.markdown(), "text/markdown; charset=utf-8", nil
+	}
+	return nil, "", fmt.Errorf("unknown format %q (want %s, %s or %s)", format, FormatJUnit, FormatJSON, FormatMarkdown)
+}
+
+type junitSuites struct {
+	XMLName xml.Name     `xml:"testsuites"`
+	Suites  []junitSuite `xml:"testsuite"`
+}
+
+type junitSuite struct {
+	Name       string          `xml:"name,attr"`
+	Tests      int             `xml:"tests,attr"`
+	Failures   int             `xml:"failures,attr"`
+	Errors     int             `xml:"errors,attr"`
+	Skipped    int             `xml:"skipped,attr"`
+	Time       string          `xml:"time,attr"`
+	Timestamp  string          `xml:"timestamp,attr"`
+	Properties []junitProperty `xml:"properties>property"`
+	Cases      []junitCase     `xml:"testcase"`
+}
+
+type junitProperty struct {
+	Name  string `xml:"name,attr"`
+	Value string `xml:"value,attr"`
+}
+
+type junitCase struct {
+	Name      string        `xml:"name,attr"`
+	Classname string        `xml:"classname,attr"`
+	Time      string        `xml:"time,attr"`
+	Failure   *junitMessage `xml:"failure,omitempty"`
+	Error     *junitMessage `xml:"error,omitempty"`
+	Skipped   *junitMessage `xml:"skipped,omitempty"`
+	SystemOut string        `xml:"system-out,omitempty"`
+}
+
+type junitMessage struct {
+	Message string `xml:"message,attr,omitempty"`
+}
+
+func (r Report) junit() ([]byte, error) {
+	suite := junitSuite{
+		Name:      r.Name,
+		Tests:     len(r.Cases),
+		Time:      formatSeconds(r.Duration),
+		Timestamp: r.StartedAt.UTC().Format(time.RFC3339),
+		Properties: []junitProperty{
+			{Name: "id", Value: r.ID},
+			{Name: "status", Value: r.Status},
+			{Name: "seed", Value: fmt.Sprint(r.Seed)},
+			{Name: "sresim.version", Value: r.Build.Version},
+			{Name: "sresim.commit", Value: r.Build.Commit},
+			{Name: "sresim.build_date", Value: r.Build.BuildDate},
+			{Name: "sresim.go_version", Value: r.Build.GoVersion},
+		},
+	}
+	if r.Error != "" {
+		suite.Properties = append(suite.Properties, junitProperty{Name: "error", Value: r.Error})
+	}
+	for _, burn := range r.SLOBurn {
+		suite.Properties = append(suite.Properties, junitProperty{
+			Name:  fmt.Sprintf("slo.%s.%s.budget_burned", burn.SLO, burn.SLI),
+			Value: formatRatio(burn.BudgetBurned),
+		})
+	}
+
+	for _, c := range r.Cases {
+		jc := junitCase{
+			Name:      c.Name,
+			Classname: r.Name + "." + c.Kind,
+			Time:      formatSeconds(c.Duration),
+			SystemOut: strings.Join(c.details(), "\n"),
+		}
+		switch c.Status {
+		case StatusFailed:
+			jc.Failure = &junitMessage{Message: c.Error}
+			suite.Failures++
+		case StatusStopped, StatusInterrupted, StatusRunning:
+			jc.Error = &junitMessage{Message: "step " + c.Status}
+			suite.Errors++
+		case StatusSkipped, StatusPending:
+			jc.Skipped = &junitMessage{Message: "step " + c.Status}
+			suite.Skipped++
+		}
+		suite.Cases = append(suite.Cases, jc)
+	}
+
+	var buf bytes.Buffer
+	buf.WriteString(xml.Header)
+	enc := xml.NewEncoder(&buf)
+	enc.Indent("", "  ")
+	if err := enc.Encode(junitSuites{Suites: []junitSuite{suite}}); err != nil {
+		return nil, err
+	}
+	buf.WriteByte('\n')
+	return buf.Bytes(), nil
+}
+
+// details returns the facts of a case as "key: value" lines.
+func (c Case) details() []string {
+	var lines []string
+	if c.Scenario != "" {
+		lines = append(lines, "scenario: "+c.Scenario)
+	}
+	if len(c.Parameters) > 0 {
+		lines = append(lines, "parameters: "+formatParameters(c.Parameters))
+	}
+	if c.RunID != "" {
+		lines = append(lines, fmt.Sprintf("run: %s (%s)", c.RunID, c.RunStatus))
+	}
+	if c.AbortReason != "" {
+		lines = append(lines, "abort reason: "+c.AbortReason)
+	}
+	if c.Load != nil {
+		l := c.Load.Latency
+		lines = append(lines,
+			fmt.Sprintf("load: %d requests, %.1f req/s, error rate %s", c.Load.Requests, c.Load.Throughput, formatRatio(c.Load.ErrorRate)),
+			fmt.Sprintf("latency: p50 %.1fms, p90 %.1fms, p95 %.1fms, p99 %.1fms, p99.9 %.1fms, max %.1fms", l.P50, l.P90, l.P95, l.P99, l.P999, l.Max),
+		)
+	}
+	if c.Probe != nil {
+		lines = append(lines, fmt.Sprintf("probe: %s %g %s (limit %g %s)", c.Probe.Type, c.Probe.Value, c.Probe.Unit, c.Probe.Limit, c.Probe.Unit))
+	}
+	if c.Error != "" {
+		lines = append(lines, "error: "+c.Error)
+	}
+	return lines
+}
+
+func (r Report) markdown() []byte {
+	var b strings.Builder
+	fmt.Fprintf(&b, "# Experiment report: %s\n\n", r.Name)
+	if r.Description != "" {
+		fmt.Fprintf(&b, "%s\n\n", r.Description)
+	}
+	fmt.Fprintf(&b, "| | |\n|---|---|\n")
+	fmt.Fprintf(&b, "| Result | **%s** |\n", r.Status)
+	if r.Error != "" {
+		fmt.Fprintf(&b, "| Error | %s |\n", escapeCell(r.Error))
+	}
+	fmt.Fprintf(&b, "| Execution | `%s` |\n", r.ID)
+	fmt.Fprintf(&b, "| Started | %s |\n", r.StartedAt.UTC().Format(time.RFC3339))
+	fmt.Fprintf(&b, "| Duration | %s |\n", time.Duration(r.Duration*float64(time.Second)).Round(time.Millisecond))
+	fmt.Fprintf(&b, "| Seed | `%d` |\n", r.Seed)
+	fmt.Fprintf(&b, "| sresim | %s (`%s`, built %s with %s) |\n", r.Build.Version, r.Build.Commit, r.Build.BuildDate, r.Build.GoVersion)
+
+	if len(r.SLOBurn) > 0 {
+		fmt.Fprintf(&b, "\n## SLO error budget\n\n| SLO | SLI | Budget burned |\n|---|---|---|\n")
+		for _, burn := range r.SLOBurn {
+			fmt.Fprintf(&b, "| %s | %s | %s |\n", burn.SLO, burn.SLI, formatRatio(burn.BudgetBurned))
+		}
+	}
+
+	fmt.Fprintf(&b, "\n## Steps\n\n| Step | Kind | Status | Duration |\n|---|---|---|---|\n")
+	for _, c := range r.Cases {
+		fmt.Fprintf(&b, "| %s | %s | %s | %.3fs |\n", escapeCell(c.Name), c.Kind, c.Status, c.Duration)
+	}
+
+	var details strings.Builder
+	for _, c := range r.Cases {
+		lines := c.details()
+		if len(lines) == 0 {
+			continue
+		}
+		fmt.Fprintf(&details, "\n### %s\n\n", c.Name)
+		for _, line := range lines {
+			fmt.Fprintf(&details, "- %s\n", line)
+		}
+	}
+	if details.Len() > 0 {
+		fmt.Fprintf(&b, "\n## Details\n%s", details.String())
+	}
+	return []byte(b.String())
+}
+
+func formatSeconds(s float64) string {
+	return fmt.Sprintf("%.3f", s)
+}
+
+func formatRatio(r float64) string {
+	return fmt.Sprintf("%.2f%%", r*100)
+}
+
+func formatParameters(params map[string]interface{}) string {
+	keys := make([]string, 0, len(params))
+	for k := range params {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+	pairs := make([]string, len(keys))
+	for i, k := range keys {
+		pairs[i] = fmt.Sprintf("%s=%v", k, params[k])
+	}
+	return strings.Join(pairs, ", ")
+}
+
+func escapeCell(s string) string {
+	return strings.ReplaceAll(s, "|", `\|`)
+}
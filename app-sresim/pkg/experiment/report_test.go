@@ -0,0 +1,185 @@
+package experiment
+
+import (
+	"encoding/json"
+	"encoding/xml"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/localstack/sresim/app-sresim/pkg/config"
+	"github.com/localstack/sresim/app-sresim/pkg/slo"
+	"github.com/localstack/sresim/app-sresim/pkg/store"
+	"github.com/localstack/sresim/app-sresim/pkg/version"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func runReportExperiment(t *testing.T) (*Manager, *Execution) {
+	t.Helper()
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		time.Sleep(2 * time.Millisecond)
+	}))
+	t.Cleanup(server.Close)
+
+	tracker, err := slo.NewTracker([]config.SLO{{Name: "api", Handler: "/api", Availability: 0.9}})
+	require.NoError(t, err)
+
+	m := testManager(t)
+	m.SetSLOTracker(tracker)
+	e, err := m.Start(mustParse(t, strings.ReplaceAll(`
+name: report
+seed: 7
+steps:
+  - name: slow down
+    scenario: latency
+    parameters: {delay_ms: 5}
+    duration: 20ms
+  - name: traffic
+    load: {target: TARGET, rate: 100, duration: 100ms}
+  - name: healthy
+    assert:
+      window: 50ms
+      load: {target: TARGET, rate: 100}
+      probes:
+        - {name: errors, type: error_rate, source: loadgen, max_error_rate: 0.01}
+        - {name: p99, type: latency, source: loadgen, max_latency: 1ms}
+  - wait: 1ms
+rollback:
+  - stop: latency
+`, "TARGET", server.URL)))
+	require.NoError(t, err)
+
+	// Burn some of the error budget while the experiment runs.
+	for i := 0; i < 10; i++ {
+		tracker.Observe("/api", 500, time.Millisecond)
+	}
+	e.Wait()
+	return m, e
+}
+
+func TestReport(t *testing.T) {
+	m, e := runReportExperiment(t)
+	report := m.Report(e)
+
+	assert.Equal(t, StatusFailed, report.Status)
+	assert.Equal(t, int64(7), report.Seed)
+	assert.Equal(t, version.Get(), report.Build)
+	assert.Greater(t, report.Duration, 0.0)
+	require.Len(t, report.SLOBurn, 1)
+	assert.Equal(t, "api", report.SLOBurn[0].SLO)
+	assert.Equal(t, slo.SLIAvailability, report.SLOBurn[0].SLI)
+	assert.InDelta(t, 10, report.SLOBurn[0].BudgetBurned, 1e-9, "all requests failed against a 10% budget")
+
+	names := make([]string, len(report.Cases))
+	for i, c := range report.Cases {
+		names[i] = c.Name
+	}
+	assert.Equal(t, []string{"slow down", "traffic", "healthy / errors", "healthy / p99", "wait 1ms", "rollback / stop latency"}, names)
+
+	scenario := report.Cases[0]
+	assert.Equal(t, StatusPassed, scenario.Status)
+	assert.Equal(t, "latency", scenario.Scenario)
+	assert.EqualValues(t, 5, scenario.Parameters["delay_ms"])
+	assert.Equal(t, "stopped", scenario.RunStatus)
+
+	load := report.Cases[1]
+	require.NotNil(t, load.Load)
+	assert.Greater(t, load.Load.Requests, int64(0))
+	assert.Greater(t, load.Load.Latency.P99, 0.0)
+
+	assert.Equal(t, StatusPassed, report.Cases[2].Status)
+	assert.Equal(t, StatusFailed, report.Cases[3].Status)
+	assert.Contains(t, report.Cases[3].Error, "exceeds 1 ms")
+	assert.Equal(t, StatusSkipped, report.Cases[4].Status)
+	assert.Equal(t, StatusPassed, report.Cases[5].Status)
+}
+
+func TestRenderReport(t *testing.T) {
+	m, e := runReportExperiment(t)
+	report := m.Report(e)
+	report.Build = version.Info{Version: "v1.4.0", Commit: "0a1b2c3", BuildDate: "2024-03-25T12:00:00Z", GoVersion: "go1.22.1"}
+
+	out, contentType, err := report.Render(FormatJUnit)
+	require.NoError(t, err)
+	assert.Equal(t, "application/xml", contentType)
+	var suites junitSuites
+	require.NoError(t, xml.Unmarshal(out, &suites))
+	require.Len(t, suites.Suites, 1)
+	suite := suites.Suites[0]
+	assert.Equal(t, 6, suite.Tests)
+	assert.Equal(t, 1, suite.Failures)
+	assert.Equal(t, 1, suite.Skipped)
+	assert.Contains(t, suite.Properties, junitProperty{Name: "seed", Value: "7"})
+	assert.Contains(t, suite.Properties, junitProperty{Name: "sresim.version", Value: "v1.4.0"})
+	assert.Contains(t, suite.Properties, junitProperty{Name: "sresim.commit", Value: "0a1b2c3"})
+	assert.Contains(t, suite.Properties, junitProperty{Name: "slo.api.availability.budget_burned", Value: "1000.00%"})
+	assert.Contains(t, suite.Cases[0].SystemOut, "parameters: delay_ms=5")
+	assert.Contains(t, suite.Cases[1].SystemOut, "latency: p50")
+
+	out, _, err = report.Render(FormatMarkdown)
+	require.NoError(t, err)
+	md := string(out)
+	assert.Contains(t, md, "# Experiment report: report")
+	assert.Contains(t, md, "| Seed | `7` |")
+	assert.Contains(t, md, "| sresim | v1.4.0 (`0a1b2c3`, built 2024-03-25T12:00:00Z with go1.22.1) |")
+	assert.Contains(t, md, "| healthy / p99 | assert | failed |")
+	assert.Contains(t, md, "| api | availability | 1000.00% |")
+
+	out, contentType, err = report.Render("")
+	require.NoError(t, err)
+	assert.Equal(t, "application/json", contentType)
+	var decoded Report
+	require.NoError(t, json.Unmarshal(out, &decoded))
+	assert.Equal(t, report.ID, decoded.ID)
+	assert.Equal(t, report.Build, decoded.Build)
+
+	_, _, err = report.Render("pdf")
+	assert.Error(t, err)
+}
+
+func TestReportHandler(t *testing.T) {
+	m := testManager(t)
+	e, err := m.Start(mustParse(t, "name: handler\nsteps: [{wait: 1ms}]"))
+	require.NoError(t, err)
+	e.Wait()
+
+	mux := http.NewServeMux()
+	mux.HandleFunc("GET /experiments/{id}/report", m.ReportHandler)
+
+	w := httptest.NewRecorder()
+	mux.ServeHTTP(w, httptest.NewRequest(http.MethodGet, "/experiments/"+e.ID+"/report?format=md", nil))
+	assert.Equal(t, http.StatusOK, w.Code)
+	assert.Contains(t, w.Body.String(), "# Experiment report: handler")
+	assert.NotZero(t, e.Status().Seed, "a seed is chosen when none is given")
+
+	w = httptest.NewRecorder()
+	mux.ServeHTTP(w, httptest.NewRequest(http.MethodGet, "/experiments/"+e.ID+"/report?format=pdf", nil))
+	assert.Equal(t, http.StatusBadRequest, w.Code)
+
+	w = httptest.NewRecorder()
+	mux.ServeHTTP(w, httptest.NewRequest(http.MethodGet, "/experiments/unknown/report", nil))
+	assert.Equal(t, http.StatusNotFound, w.Code)
+}
+
+func TestReportBuildOfRestoredExecution(t *testing.T) {
+	st, err := store.Open("", 0)
+	require.NoError(t, err)
+	old := version.Info{Version: "v1.4.0", Commit: "0a1b2c3", BuildDate: "2024-03-25T12:00:00Z", GoVersion: "go1.22.1"}
+	for id, build := range map[string]*version.Info{"upgraded": &old, "legacy": nil} {
+		status := ExecutionStatus{ID: id, Name: id, Status: StatusPassed, StartedAt: time.Now()}
+		require.NoError(t, st.Put(store.Record{Kind: store.KindExperiment, ID: id, Time: status.StartedAt, Status: status.Status},
+			executionRecord{ExecutionStatus: status, Build: build}))
+	}
+
+	m := testManager(t)
+	require.NoError(t, m.SetStore(st))
+	e, ok := m.Get("upgraded")
+	require.True(t, ok)
+	assert.Equal(t, old, m.Report(e).Build, "the report names the build that ran the experiment")
+	e, ok = m.Get("legacy")
+	require.True(t, ok)
+	assert.Equal(t, "unknown", m.Report(e).Build.Version)
+}
@@ -0,0 +1,661 @@
+package experiment
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log/slog"
+	"math/rand"
+	"sort"
+	"sync"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/localstack/sresim/app-sresim/pkg/events"
+	"github.com/localstack/sresim/app-sresim/pkg/loadgen"
+	"github.com/localstack/sresim/app-sresim/pkg/simulator"
+	"github.com/localstack/sresim/app-sresim/pkg/slo"
+	"github.com/localstack/sresim/app-sresim/pkg/steadystate"
+	"github.com/localstack/sresim/app-sresim/pkg/store"
+	"github.com/localstack/sresim/app-sresim/pkg/version"
+	"github.com/prometheus/client_golang/prometheus"
+)
+
+// Execution and step states.
+const (
+	StatusPending = "pending"
+	StatusRunning = "running"
+	StatusPassed  = "passed"
+	StatusFailed  = "failed"
+	StatusStopped = "stopped"
+	StatusSkipped = "skipped"
+	// StatusInterrupted is an execution that was still running when sresim
+	// restarted.
+	StatusInterrupted = "interrupted"
+)
+
+// StepResult is the progress of one step.
+type StepResult struct {
+	Name      string             `json:"name"`
+	Kind      string             `json:"kind"`
+	Scenario  string             `json:"scenario,omitempty"`
+	Status    string             `json:"status"`
+	StartedAt *time.Time         `json:"started_at,omitempty"`
+	EndedAt   *time.Time         `json:"ended_at,omitempty"`
+	RunID     string             `json:"run_id,omitempty"`
+	LoadRunID string             `json:"load_run_id,omitempty"`
+	Load      *loadgen.Result    `json:"load,omitempty"`
+	Check     *steadystate.Check `json:"check,omitempty"`
+	Error     string             `json:"error,omitempty"`
+	Steps     []*StepResult      `json:"steps,omitempty"`
+}
+
+func newResults(steps []Step) []*StepResult {
+	results := make([]*StepResult, len(steps))
+	for i, step := range steps {
+		results[i] = &StepResult{
+			Name:     step.title(),
+			Kind:     step.Kind(),
+			Scenario: step.Scenario,
+			Status:   StatusPending,
+			Steps:    newResults(step.Parallel),
+		}
+		if step.Stop != "" {
+			results[i].Scenario = step.Stop
+		}
+	}
+	return results
+}
+
+func copyResults(results []*StepResult) []*StepResult {
+	if results == nil {
+		return nil
+	}
+	copied := make([]*StepResult, len(results))
+	for i, r := range results {
+		c := *r
+		c.Steps = copyResults(r.Steps)
+		copied[i] = &c
+	}
+	return copied
+}
+
+// Execution is a run of an experiment started through the Manager.
+type Execution struct {
+	ID         string
+	Experiment Experiment
+	StartedAt  time.Time
+	// Build is the sresim that ran the execution, which need not be the
+	// one reporting on it.
+	Build version.Info
+
+	cancel context.CancelFunc
+	done   chan struct{}
+
+	mu       sync.Mutex
+	status   string
+	endedAt  time.Time
+	err      string
+	steps    []*StepResult
+	rollback []*StepResult
+	// runs maps each scenario the execution started to its current run.
+	runs  map[string]string
+	loads []backgroundLoad
+	// slos is the SLO status when the execution started.
+	slos    []slo.Status
+	sloBurn []SLOBurn
+}
+
+// backgroundLoad is a load run that outlives the step that started it.
+type backgroundLoad struct {
+	run    *loadgen.Run
+	result *StepResult
+}
+
+// SLOBurn is the share of an SLI's error budget consumed during an
+// execution.
+type SLOBurn struct {
+	SLO          string  `json:"slo"`
+	SLI          string  `json:"sli"`
+	BudgetBurned float64 `json:"budget_burned"`
+}
+
+// ExecutionStatus is the externally visible state of an execution.
+type ExecutionStatus struct {
+	ID          string        `json:"id"`
+	Name        string        `json:"name"`
+	Description string        `json:"description,omitempty"`
+	Seed        int64         `json:"seed"`
+	Status      string        `json:"status"`
+	StartedAt   time.Time     `json:"started_at"`
+	EndedAt     *time.Time    `json:"ended_at,omitempty"`
+	Error       string        `json:"error,omitempty"`
+	Steps       []*StepResult `json:"steps"`
+	Rollback    []*StepResult `json:"rollback,omitempty"`
+	SLOBurn     []SLOBurn     `json:"slo_burn,omitempty"`
+}
+
+// Stop cancels the remaining steps, waits for the rollback to finish and
+// returns the final state.
+func (e *Execution) Stop() ExecutionStatus {
+	e.cancel()
+	return e.Wait()
+}
+
+// Wait blocks until the execution has finished and returns its final state.
+func (e *Execution) Wait() ExecutionStatus {
+	<-e.done
+	return e.Status()
+}
+
+// Done is closed when the execution has finished.
+func (e *Execution) Done() <-chan struct{} {
+	return e.done
+}
+
+// Status returns a snapshot of the execution.
+func (e *Execution) Status() ExecutionStatus {
+	e.mu.Lock()
+	defer e.mu.Unlock()
+	status := ExecutionStatus{
+		ID:          e.ID,
+		Name:        e.Experiment.Name,
+		Description: e.Experiment.Description,
+		Seed:        e.Experiment.Seed,
+		Status:      e.status,
+		StartedAt:   e.StartedAt,
+		Error:       e.err,
+		Steps:       copyResults(e.steps),
+		Rollback:    copyResults(e.rollback),
+		SLOBurn:     e.sloBurn,
+	}
+	if !e.endedAt.IsZero() {
+		endedAt := e.endedAt
+		status.EndedAt = &endedAt
+	}
+	return status
+}
+
+func (e *Execution) update(fn func()) {
+	e.mu.Lock()
+	defer e.mu.Unlock()
+	fn()
+}
+
+// Manager runs experiments against the scenario and load generation
+// managers.
+type Manager struct {
+	scenarios *simulator.ScenarioManager
+	loads     *loadgen.Manager
+	gatherer  prometheus.Gatherer
+
+	mu         sync.RWMutex
+	executions map[string]*Execution
+	store      *store.Store
+	sloTracker *slo.Tracker
+}
+
+// NewManager returns a manager driving the given scenario and load
+// generation managers. Assert steps read the request metrics from g.
+func NewManager(scenarios *simulator.ScenarioManager, loads *loadgen.Manager, g prometheus.Gatherer) *Manager {
+	return &Manager{
+		scenarios:  scenarios,
+		loads:      loads,
+		gatherer:   g,
+		executions: make(map[string]*Execution),
+	}
+}
+
+// SetSLOTracker sets the tracker the error budget burned by each execution
+// is measured with.
+func (m *Manager) SetSLOTracker(t *slo.Tracker) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	m.sloTracker = t
+}
+
+func (m *Manager) sloStatus() []slo.Status {
+	m.mu.RLock()
+	tracker := m.sloTracker
+	m.mu.RUnlock()
+	if tracker == nil {
+		return nil
+	}
+	return tracker.Status()
+}
+
+// Start validates the experiment and runs it in the background.
+func (m *Manager) Start(exp Experiment) (*Execution, error) {
+	if err := exp.Validate(); err != nil {
+		return nil, err
+	}
+	for exp.Seed == 0 {
+		exp.Seed = rand.Int63()
+	}
+	ctx, cancel := context.WithCancel(context.Background())
+	e := &Execution{
+		ID:         uuid.NewString(),
+		Experiment: exp,
+		StartedAt:  time.Now(),
+		Build:      version.Get(),
+		cancel:     cancel,
+		done:       make(chan struct{}),
+		status:     StatusRunning,
+		steps:      newResults(exp.Steps),
+		rollback:   newResults(exp.Rollback),
+		runs:       make(map[string]string),
+		slos:       m.sloStatus(),
+	}
+
+	m.mu.Lock()
+	m.executions[e.ID] = e
+	m.mu.Unlock()
+	m.save(e)
+
+	go func() {
+		defer cancel()
+		m.execute(ctx, e)
+	}()
+	return e, nil
+}
+
+// Get returns the execution with the given ID.
+func (m *Manager) Get(id string) (*Execution, bool) {
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+	e, ok := m.executions[id]
+	return e, ok
+}
+
+// List returns every known execution, most recent first.
+func (m *Manager) List() []*Execution {
+	m.mu.RLock()
+	executions := make([]*Execution, 0, len(m.executions))
+	for _, e := range m.executions {
+		executions = append(executions, e)
+	}
+	m.mu.RUnlock()
+	sort.Slice(executions, func(i, j int) bool {
+		return executions[i].StartedAt.After(executions[j].StartedAt)
+	})
+	return executions
+}
+
+// StopAll stops every running execution and waits for their rollbacks until
+// ctx is done. It returns how many executions it stopped.
+func (m *Manager) StopAll(ctx context.Context) int {
+	var running []*Execution
+	for _, e := range m.List() {
+		if e.Status().Status == StatusRunning {
+			e.cancel()
+			running = append(running, e)
+		}
+	}
+	for _, e := range running {
+		select {
+		case <-e.Done():
+		case <-ctx.Done():
+			return len(running)
+		}
+	}
+	return len(running)
+}
+
+func (m *Manager) execute(ctx context.Context, e *Execution) {
+	err := m.runSequence(ctx, e, e.Experiment.Steps, e.steps, false)
+
+	// Rollback must run to completion even when the experiment was stopped,
+	// and attempts every step even if an earlier one fails.
+	rollbackErr := m.runSequence(context.Background(), e, e.Experiment.Rollback, e.rollback, true)
+	m.cleanup(e)
+	burn := budgetBurned(e.slos, m.sloStatus())
+
+	e.update(func() {
+		e.endedAt = time.Now()
+		e.sloBurn = burn
+		switch {
+		case ctx.Err() != nil:
+			e.status = StatusStopped
+			e.err = "experiment was stopped"
+		case err != nil:
+			e.status = StatusFailed
+			e.err = err.Error()
+		case rollbackErr != nil:
+			e.status = StatusFailed
+			e.err = "rollback: " + rollbackErr.Error()
+		default:
+			e.status = StatusPassed
+		}
+	})
+	m.save(e)
+	status := e.Status()
+	events.Publish(events.Event{
+		Type:    events.ExperimentFinished,
+		Message: status.Error,
+		Data: map[string]interface{}{
+			"experiment_id": status.ID,
+			"name":          status.Name,
+			"status":        status.Status,
+		},
+	})
+	close(e.done)
+}
+
+// runSequence runs steps in order. Unless keepGoing is set, the steps after
+// a failed one are skipped.
+func (m *Manager) runSequence(ctx context.Context, e *Execution, steps []Step, results []*StepResult, keepGoing bool) error {
+	var first error
+	for i, step := range steps {
+		if first != nil && !keepGoing {
+			e.update(func() { skip(results[i]) })
+			continue
+		}
+		if err := m.runStep(ctx, e, step, results[i]); err != nil && first == nil {
+			first = fmt.Errorf("%s: %w", results[i].Name, err)
+		}
+	}
+	return first
+}
+
+func skip(result *StepResult) {
+	result.Status = StatusSkipped
+	for _, child := range result.Steps {
+		skip(child)
+	}
+}
+
+func (m *Manager) runStep(ctx context.Context, e *Execution, step Step, result *StepResult) error {
+	e.update(func() {
+		now := time.Now()
+		result.StartedAt = &now
+		result.Status = StatusRunning
+	})
+	m.save(e)
+
+	err := ctx.Err()
+	if err == nil {
+		switch step.Kind() {
+		case KindScenario:
+			err = m.startScenario(ctx, e, step, result)
+		case KindStop:
+			err = m.stopScenario(e, step.Stop)
+		case KindWait:
+			err = sleep(ctx, step.Wait.Std())
+		case KindLoad:
+			err = m.generateLoad(ctx, e, step, result)
+		case KindAssert:
+			hypothesis := *step.Assert
+			if hypothesis.Load != nil {
+				hypothesis.Load = e.seeded(*hypothesis.Load)
+			}
+			check := hypothesis.Check(ctx, m.gatherer, result.Name)
+			e.update(func() { result.Check = &check })
+			if ctx.Err() != nil {
+				err = ctx.Err()
+			} else if !check.Passed {
+				err = errors.New("steady state was not met")
+			}
+		case KindParallel:
+			err = m.runParallel(ctx, e, step.Parallel, result.Steps)
+		}
+	}
+
+	e.update(func() {
+		now := time.Now()
+		result.EndedAt = &now
+		switch {
+		case err == nil:
+			result.Status = StatusPassed
+		case errors.Is(err, context.Canceled):
+			result.Status = StatusStopped
+		default:
+			result.Status = StatusFailed
+			result.Error = err.Error()
+		}
+	})
+	m.save(e)
+	return err
+}
+
+func (m *Manager) runParallel(ctx context.Context, e *Execution, steps []Step, results []*StepResult) error {
+	errs := make([]error, len(steps))
+	var wg sync.WaitGroup
+	for i, step := range steps {
+		wg.Add(1)
+		go func(i int, step Step) {
+			defer wg.Done()
+			if err := m.runStep(ctx, e, step, results[i]); err != nil {
+				errs[i] = fmt.Errorf("%s: %w", results[i].Name, err)
+			}
+		}(i, step)
+	}
+	wg.Wait()
+	for _, err := range errs {
+		if err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func (m *Manager) startScenario(ctx context.Context, e *Execution, step Step, result *StepResult) error {
+	run, err := m.scenarios.Start(step.Scenario, step.Parameters, step.Guardrails)
+	if err != nil {
+		return err
+	}
+	e.update(func() {
+		result.RunID = run.ID
+		e.runs[step.Scenario] = run.ID
+	})
+	if step.Duration == 0 {
+		return nil
+	}
+
+	err = sleep(ctx, step.Duration.Std())
+	m.scenarios.StopRun(run.ID)
+	if err != nil {
+		return err
+	}
+	if final, ok := m.scenarios.GetRun(run.ID); ok && final.Status == simulator.RunAborted {
+		return fmt.Errorf("run was aborted: %s", final.AbortReason)
+	}
+	return nil
+}
+
+// stopScenario stops the run of the scenario started by this execution.
+// Stopping a scenario that is not running is a no-op, so rollback steps can
+// stop scenarios regardless of how far the experiment got.
+func (m *Manager) stopScenario(e *Execution, scenario string) error {
+	var runID string
+	e.update(func() { runID = e.runs[scenario] })
+	if runID != "" {
+		m.scenarios.StopRun(runID)
+	}
+	return nil
+}
+
+func (m *Manager) generateLoad(ctx context.Context, e *Execution, step Step, result *StepResult) error {
+	run, err := m.loads.Start(*e.seeded(*step.Load))
+	if err != nil {
+		return err
+	}
+	run.SetScenarios(e.Experiment.Scenarios()...)
+	e.update(func() { result.LoadRunID = run.ID })
+	if step.Background {
+		e.update(func() { e.loads = append(e.loads, backgroundLoad{run: run, result: result}) })
+		return nil
+	}
+
+	select {
+	case <-run.Done():
+	case <-ctx.Done():
+		run.Stop()
+	}
+	res := run.Wait()
+	e.update(func() { result.Load = &res })
+	return ctx.Err()
+}
+
+// cleanup stops every scenario and background load the execution started
+// that is still running.
+func (m *Manager) cleanup(e *Execution) {
+	var runIDs []string
+	var loads []backgroundLoad
+	e.update(func() {
+		for _, runID := range e.runs {
+			runIDs = append(runIDs, runID)
+		}
+		loads = e.loads
+	})
+	for _, runID := range runIDs {
+		m.scenarios.StopRun(runID)
+	}
+	for _, load := range loads {
+		load.run.Stop()
+		res := load.run.Wait()
+		e.update(func() { load.result.Load = &res })
+	}
+}
+
+// seeded returns cfg with the experiment seed unless it sets its own.
+func (e *Execution) seeded(cfg loadgen.Config) *loadgen.Config {
+	if cfg.Seed == 0 {
+		cfg.Seed = e.Experiment.Seed
+	}
+	return &cfg
+}
+
+// budgetBurned returns the error budget each SLI lost between two SLO
+// status snapshots.
+func budgetBurned(before, after []slo.Status) []SLOBurn {
+	var burn []SLOBurn
+	for _, end := range after {
+		for _, start := range before {
+			if start.Name != end.Name {
+				continue
+			}
+			for _, ind := range end.Indicators {
+				if earlier, ok := start.Indicator(ind.SLI); ok {
+					burn = append(burn, SLOBurn{SLO: end.Name, SLI: ind.SLI, BudgetBurned: ind.BudgetBurnedSince(earlier)})
+				}
+			}
+		}
+	}
+	return burn
+}
+
+// save writes the execution to the store, if one is set.
+func (m *Manager) save(e *Execution) {
+	m.mu.RLock()
+	st := m.store
+	m.mu.RUnlock()
+	if st == nil {
+		return
+	}
+	status := e.Status()
+	record := store.Record{Kind: store.KindExperiment, ID: status.ID, Time: status.StartedAt, Status: status.Status}
+	if err := st.Put(record, executionRecord{ExecutionStatus: status, Experiment: &e.Experiment, Build: &e.Build}); err != nil {
+		slog.Error("Failed to save experiment", "experiment_id", status.ID, "error", err)
+	}
+}
+
+// SetStore persists executions to st from now on and loads the executions
+// recorded there. Executions that were still running are marked as
+// interrupted; their scenarios did not survive the restart either.
+func (m *Manager) SetStore(st *store.Store) error {
+	m.mu.Lock()
+	m.store = st
+	var restored []*Execution
+	for _, record := range st.Query(store.Filter{Kind: store.KindExperiment}) {
+		var saved executionRecord
+		if err := json.Unmarshal(record.Data, &saved); err != nil {
+			m.mu.Unlock()
+			return fmt.Errorf("experiment %s: %w", record.ID, err)
+		}
+		e := restore(saved)
+		m.executions[e.ID] = e
+		if saved.Status == StatusRunning {
+			restored = append(restored, e)
+		}
+	}
+	m.mu.Unlock()
+
+	for _, e := range restored {
+		e.update(func() {
+			e.status = StatusInterrupted
+			e.err = "experiment was interrupted by a restart"
+			e.endedAt = time.Now()
+			interrupt(e.steps)
+			interrupt(e.rollback)
+		})
+		m.save(e)
+	}
+	return nil
+}
+
+// executionRecord is what the store keeps of an execution: its state and
+// the experiment it ran.
+type executionRecord struct {
+	ExecutionStatus
+	// Experiment and Build are missing from records written before they
+	// were kept.
+	Experiment *Experiment   `json:"experiment,omitempty"`
+	Build      *version.Info `json:"build,omitempty"`
+}
+
+// unknownBuild stands in for the build of executions recorded without one.
+var unknownBuild = version.Info{Version: "unknown", Commit: "unknown", BuildDate: "unknown", GoVersion: "unknown"}
+
+// restore rebuilds a finished execution from its recorded state.
+func restore(saved executionRecord) *Execution {
+	status := saved.ExecutionStatus
+	exp := Experiment{Name: status.Name, Description: status.Description, Seed: status.Seed}
+	if saved.Experiment != nil {
+		exp = *saved.Experiment
+	}
+	build := unknownBuild
+	if saved.Build != nil {
+		build = *saved.Build
+	}
+	e := &Execution{
+		ID:         status.ID,
+		Experiment: exp,
+		StartedAt:  status.StartedAt,
+		Build:      build,
+		cancel:     func() {},
+		done:       make(chan struct{}),
+		status:     status.Status,
+		err:        status.Error,
+		steps:      status.Steps,
+		rollback:   status.Rollback,
+		runs:       make(map[string]string),
+		sloBurn:    status.SLOBurn,
+	}
+	if status.EndedAt != nil {
+		e.endedAt = *status.EndedAt
+	}
+	close(e.done)
+	return e
+}
+
+// interrupt marks the steps that had not finished as interrupted.
+func interrupt(results []*StepResult) {
+	for _, r := range results {
+		if r.Status == StatusPending || r.Status == StatusRunning {
+			r.Status = StatusInterrupted
+		}
+		interrupt(r.Steps)
+	}
+}
+
+func sleep(ctx context.Context, d time.Duration) error {
+	timer := time.NewTimer(d)
+	defer timer.Stop()
+	select {
+	case <-timer.C:
+		return nil
+	case <-ctx.Done():
+		return ctx.Err()
+	}
+}
@@ -0,0 +1,102 @@
+package handlers
+
+import (
+	"encoding/json"
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/localstack/sresim/app-sresim/pkg/chaos"
+	"github.com/localstack/sresim/app-sresim/pkg/events"
+	"github.com/localstack/sresim/app-sresim/pkg/loadgen"
+	"github.com/localstack/sresim/app-sresim/pkg/simulator"
+)
+
+// KillResponse reports what the kill switch stopped.
+type KillResponse struct {
+	Status       string    `json:"status"`
+	AbortedRuns  []string  `json:"aborted_runs"`
+	StoppedLoads int       `json:"stopped_loads"`
+	ChaosEnabled bool      `json:"chaos_enabled"`
+	Timestamp    time.Time `json:"timestamp"`
+}
+
+// KillHandler returns the kill switch: it aborts every scenario of
+// scenarios, stops all load generation and turns off the chaos middleware.
+func KillHandler(scenarios *simulator.ScenarioManager) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		if r.Method != http.MethodPost {
+			http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+			return
+		}
+
+		chaos.SetEnabled(false)
+		response := KillResponse{
+			Status:       "killed",
+			AbortedRuns:  scenarios.KillAll("kill switch"),
+			StoppedLoads: loadgen.GetManager().StopAll(),
+			ChaosEnabled: chaos.Enabled(),
+			Timestamp:    time.Now(),
+		}
+		if response.AbortedRuns == nil {
+			response.AbortedRuns = []string{}
+		}
+		events.Publish(events.Event{
+			Type:    events.Kill,
+			Message: "kill switch engaged",
+			Data: map[string]interface{}{
+				"aborted_runs":  response.AbortedRuns,
+				"stopped_loads": response.StoppedLoads,
+			},
+		})
+
+		w.Header().Set("Content-Type", "application/json")
+		json.NewEncoder(w).Encode(response)
+	}
+}
+
+// ChaosStatus is the state of the chaos middleware.
+type ChaosStatus struct {
+	Enabled bool         `json:"enabled"`
+	Rules   []chaos.Rule `json:"rules"`
+}
+
+// ChaosHandler reports whether the chaos middleware is enabled and which
+// faults it injects. POST ?enabled=true|false turns it on or off and
+// ?panic_probability= sets the share of requests it panics while serving.
+func ChaosHandler(w http.ResponseWriter, r *http.Request) {
+	switch r.Method {
+	case http.MethodGet:
+	case http.MethodPost:
+		query := r.URL.Query()
+		if !query.Has("enabled") && !query.Has("panic_probability") {
+			http.Error(w, "enabled or panic_probability is required", http.StatusBadRequest)
+			return
+		}
+		on := chaos.Enabled()
+		if query.Has("enabled") {
+			var err error
+			if on, err = strconv.ParseBool(query.Get("enabled")); err != nil {
+				http.Error(w, "enabled must be true or false", http.StatusBadRequest)
+				return
+			}
+		}
+		if query.Has("panic_probability") {
+			p, err := strconv.ParseFloat(query.Get("panic_probability"), 64)
+			if err == nil {
+				err = chaos.SetPanicProbability(p)
+			}
+			if err != nil {
+				http.Error(w, "panic_probability must be a number between 0 and 1", http.StatusBadRequest)
+				return
+			}
+		}
+		chaos.SetEnabled(on)
+	default:
+		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(ChaosStatus{Enabled: chaos.Enabled(), Rules: chaos.Rules()})
+}
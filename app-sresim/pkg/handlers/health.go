@@ -3,7 +3,12 @@ package handlers
 import (
 	"encoding/json"
 	"net/http"
+	"runtime"
 	"time"
+
+	"github.com/localstack/sresim/app-sresim/pkg/health"
+	"github.com/localstack/sresim/app-sresim/pkg/simulator"
+	"github.com/localstack/sresim/app-sresim/pkg/version"
 )
 
 type HealthResponse struct {
@@ -12,14 +17,74 @@ type HealthResponse struct {
 	Version   string    `json:"version"`
 }
 
-// HealthCheckHandler provides basic health check information
+// DetailedHealthResponse is the state of sresim served by /health/detailed.
+type DetailedHealthResponse struct {
+	// Status is healthy, degraded while sresim is not ready, or shutting
+	// down.
+	Status          string        `json:"status"`
+	Timestamp       time.Time     `json:"timestamp"`
+	Version         string        `json:"version"`
+	ActiveScenarios []string      `json:"active_scenarios"`
+	ResourceUsage   ResourceUsage `json:"resource_usage"`
+	Readiness       health.Status `json:"readiness"`
+}
+
+// ResourceUsage is what the sresim process consumes, including what the
+// scenarios make it consume.
+type ResourceUsage struct {
+	MemoryRSSBytes uint64 `json:"memory_rss_bytes"`
+	HeapBytes      uint64 `json:"heap_bytes"`
+	Goroutines     int    `json:"goroutines"`
+	CPUs           int    `json:"cpus"`
+}
+
+// HealthCheckHandler provides basic health check information. It fails with
+// 503 once a shutdown has begun.
 func HealthCheckHandler(w http.ResponseWriter, r *http.Request) {
 	response := HealthResponse{
 		Status:    "healthy",
 		Timestamp: time.Now(),
-		Version:   "1.0.0",
+		Version:   version.Get().Version,
+	}
+	status := http.StatusOK
+	if health.GetChecker().ShuttingDown() {
+		response.Status = "shutting down"
+		status = http.StatusServiceUnavailable
 	}
 
 	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(status)
 	json.NewEncoder(w).Encode(response)
 }
+
+// DetailedHealthHandler returns a handler reporting the active scenarios of
+// scenarios, resource usage, readiness and dependency checks. Unlike the
+// probes it always answers 200.
+func DetailedHealthHandler(scenarios *simulator.ScenarioManager) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		var mem runtime.MemStats
+		runtime.ReadMemStats(&mem)
+		response := DetailedHealthResponse{
+			Status:          "healthy",
+			Timestamp:       time.Now(),
+			Version:         version.Get().Version,
+			ActiveScenarios: scenarios.ActiveScenarios(),
+			ResourceUsage: ResourceUsage{
+				MemoryRSSBytes: simulator.ResidentMemory(),
+				HeapBytes:      mem.HeapAlloc,
+				Goroutines:     runtime.NumGoroutine(),
+				CPUs:           runtime.NumCPU(),
+			},
+			Readiness: health.GetChecker().Status(r.Context()),
+		}
+		switch {
+		case response.Readiness.ShuttingDown:
+			response.Status = "shutting down"
+		case !response.Readiness.Ready:
+			response.Status = "degraded"
+		}
+
+		w.Header().Set("Content-Type", "application/json")
+		json.NewEncoder(w).Encode(response)
+	}
+}
@@ -0,0 +1,194 @@
+// Package health tracks whether sresim has started, is shutting down or has
+// been made unready on purpose, runs dependency checks and serves the
+// Kubernetes probe endpoints.
+package health
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"sort"
+	"sync"
+	"time"
+)
+
+// checkTimeout bounds each dependency check.
+var checkTimeout = 2 * time.Second
+
+// Check probes a dependency. It returns an error when the dependency is
+// unusable.
+type Check func(ctx context.Context) error
+
+// CheckResult is the outcome of one dependency check.
+type CheckResult struct {
+	Name     string  `json:"name"`
+	Healthy  bool    `json:"healthy"`
+	Error    string  `json:"error,omitempty"`
+	Duration float64 `json:"duration_seconds"`
+}
+
+// Status is the readiness of sresim. Reasons lists why it is not ready.
+type Status struct {
+	Started      bool          `json:"started"`
+	Ready        bool          `json:"ready"`
+	ShuttingDown bool          `json:"shutting_down"`
+	Reasons      []string      `json:"reasons,omitempty"`
+	Checks       []CheckResult `json:"checks,omitempty"`
+}
+
+type namedCheck struct {
+	name  string
+	check Check
+}
+
+// Checker holds the health state of the process.
+type Checker struct {
+	mu           sync.Mutex
+	started      bool
+	shuttingDown bool
+	checks       []namedCheck
+	faults       map[int]string
+	nextFault    int
+}
+
+// New returns a checker that has not started yet.
+func New() *Checker {
+	return &Checker{faults: make(map[int]string)}
+}
+
+var checker = New()
+
+// GetChecker returns the process-wide checker.
+func GetChecker() *Checker {
+	return checker
+}
+
+// AddCheck registers a dependency check. Failing checks make sresim
+// unready.
+func (c *Checker) AddCheck(name string, check Check) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.checks = append(c.checks, namedCheck{name, check})
+}
+
+// SetStarted marks startup as complete.
+func (c *Checker) SetStarted() {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.started = true
+}
+
+// SetShuttingDown makes sresim unready for good, so that Kubernetes stops
+// routing traffic to it while in-flight requests drain.
+func (c *Checker) SetShuttingDown() {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.shuttingDown = true
+}
+
+// ShuttingDown reports whether a shutdown has begun.
+func (c *Checker) ShuttingDown() bool {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	return c.shuttingDown
+}
+
+// FailReadiness makes sresim unready until the returned function is called.
+// Scenarios use it to fail the readiness probe on purpose.
+func (c *Checker) FailReadiness(reason string) (restore func()) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	id := c.nextFault
+	c.nextFault++
+	c.faults[id] = reason
+	return func() {
+		c.mu.Lock()
+		defer c.mu.Unlock()
+		delete(c.faults, id)
+	}
+}
+
+// Status runs the dependency checks and reports whether sresim is ready.
+func (c *Checker) Status(ctx context.Context) Status {
+	c.mu.Lock()
+	s := Status{Started: c.started, ShuttingDown: c.shuttingDown}
+	checks := c.checks
+	for _, reason := range c.faults {
+		s.Reasons = append(s.Reasons, reason)
+	}
+	c.mu.Unlock()
+	sort.Strings(s.Reasons)
+
+	if !s.Started {
+		s.Reasons = append([]string{"starting"}, s.Reasons...)
+	}
+	if s.ShuttingDown {
+		s.Reasons = append([]string{"shutting down"}, s.Reasons...)
+	}
+	s.Checks = runChecks(ctx, checks)
+	for _, result := range s.Checks {
+		if !result.Healthy {
+			s.Reasons = append(s.Reasons, "check "+result.Name+" failed: "+result.Error)
+		}
+	}
+	s.Ready = len(s.Reasons) == 0
+	return s
+}
+
+// runChecks runs the checks concurrently.
+func runChecks(ctx context.Context, checks []namedCheck) []CheckResult {
+	results := make([]CheckResult, len(checks))
+	var wg sync.WaitGroup
+	for i, nc := range checks {
+		wg.Add(1)
+		go func(i int, nc namedCheck) {
+			defer wg.Done()
+			ctx, cancel := context.WithTimeout(ctx, checkTimeout)
+			defer cancel()
+			start := time.Now()
+			err := nc.check(ctx)
+			results[i] = CheckResult{Name: nc.name, Healthy: err == nil, Duration: time.Since(start).Seconds()}
+			if err != nil {
+				results[i].Error = err.Error()
+			}
+		}(i, nc)
+	}
+	wg.Wait()
+	return results
+}
+
+// LivezHandler serves GET /livez. sresim is alive as long as it answers:
+// failing dependencies and scenarios never make Kubernetes restart it.
+func (c *Checker) LivezHandler(w http.ResponseWriter, r *http.Request) {
+	writeJSON(w, http.StatusOK, map[string]string{"status": "alive"})
+}
+
+// StartupzHandler serves GET /startupz, which succeeds once startup is
+// complete.
+func (c *Checker) StartupzHandler(w http.ResponseWriter, r *http.Request) {
+	c.mu.Lock()
+	started := c.started
+	c.mu.Unlock()
+	if !started {
+		writeJSON(w, http.StatusServiceUnavailable, map[string]string{"status": "starting"})
+		return
+	}
+	writeJSON(w, http.StatusOK, map[string]string{"status": "started"})
+}
+
+// ReadyzHandler serves GET /readyz, which fails with 503 and the reasons
+// while sresim is not ready to take traffic.
+func (c *Checker) ReadyzHandler(w http.ResponseWriter, r *http.Request) {
+	s := c.Status(r.Context())
+	code := http.StatusOK
+	if !s.Ready {
+		code = http.StatusServiceUnavailable
+	}
+	writeJSON(w, code, s)
+}
+
+func writeJSON(w http.ResponseWriter, status int, v interface{}) {
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(status)
+	json.NewEncoder(w).Encode(v)
+}
@@ -0,0 +1,84 @@
+package health
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func probe(t *testing.T, handler http.HandlerFunc) (int, Status) {
+	t.Helper()
+	rec := httptest.NewRecorder()
+	handler(rec, httptest.NewRequest(http.MethodGet, "/", nil))
+	var s Status
+	require.NoError(t, json.Unmarshal(rec.Body.Bytes(), &s))
+	return rec.Code, s
+}
+
+func TestProbes(t *testing.T) {
+	c := New()
+	var historyErr error
+	c.AddCheck("history", func(context.Context) error { return historyErr })
+
+	code, s := probe(t, c.ReadyzHandler)
+	assert.Equal(t, http.StatusServiceUnavailable, code)
+	assert.Equal(t, []string{"starting"}, s.Reasons)
+	code, _ = probe(t, c.StartupzHandler)
+	assert.Equal(t, http.StatusServiceUnavailable, code)
+	code, _ = probe(t, c.LivezHandler)
+	assert.Equal(t, http.StatusOK, code)
+
+	c.SetStarted()
+	code, s = probe(t, c.ReadyzHandler)
+	assert.Equal(t, http.StatusOK, code)
+	assert.True(t, s.Ready)
+	require.Len(t, s.Checks, 1)
+	assert.True(t, s.Checks[0].Healthy)
+	code, _ = probe(t, c.StartupzHandler)
+	assert.Equal(t, http.StatusOK, code)
+
+	historyErr = errors.New("disk full")
+	restore := c.FailReadiness("scenario readiness_flap is failing readiness")
+	code, s = probe(t, c.ReadyzHandler)
+	assert.Equal(t, http.StatusServiceUnavailable, code)
+	assert.Equal(t, []string{"scenario readiness_flap is failing readiness", "check history failed: disk full"}, s.Reasons)
+	assert.Equal(t, "disk full", s.Checks[0].Error)
+	// Liveness does not depend on checks or scenarios.
+	code, _ = probe(t, c.LivezHandler)
+	assert.Equal(t, http.StatusOK, code)
+
+	historyErr = nil
+	restore()
+	restore()
+	code, _ = probe(t, c.ReadyzHandler)
+	assert.Equal(t, http.StatusOK, code)
+
+	c.SetShuttingDown()
+	code, s = probe(t, c.ReadyzHandler)
+	assert.Equal(t, http.StatusServiceUnavailable, code)
+	assert.True(t, s.ShuttingDown)
+	assert.Equal(t, []string{"shutting down"}, s.Reasons)
+}
+
+func TestCheckTimeout(t *testing.T) {
+	timeout := checkTimeout
+	checkTimeout = 10 * time.Millisecond
+	t.Cleanup(func() { checkTimeout = timeout })
+
+	c := New()
+	c.SetStarted()
+	c.AddCheck("hanging", func(ctx context.Context) error {
+		<-ctx.Done()
+		return ctx.Err()
+	})
+	s := c.Status(context.Background())
+	assert.False(t, s.Ready)
+	assert.Equal(t, []string{"check hanging failed: context deadline exceeded"}, s.Reasons)
+}
@@ -0,0 +1,86 @@
+package loadgen
+
+import (
+	"encoding/json"
+	"net/http"
+
+	"github.com/localstack/sresim/app-sresim/pkg/auth"
+)
+
+// LoadgenHandler serves /loadgen. POST starts a run from a Config in the
+// request body; GET lists runs, or returns a single run when ?id= is set.
+// Runs driving scenarios the principal may not use are left out, and
+// principals restricted to some scenarios may not start runs here.
+func LoadgenHandler(w http.ResponseWriter, r *http.Request) {
+	switch r.Method {
+	case http.MethodPost:
+		startRun(w, r)
+	case http.MethodGet:
+		getRuns(w, r)
+	default:
+		w.Header().Set("Allow", "GET, POST")
+		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+	}
+}
+
+// StopHandler stops the run named by ?id=.
+func StopHandler(w http.ResponseWriter, r *http.Request) {
+	run, ok := GetManager().Get(r.URL.Query().Get("id"))
+	if !ok {
+		http.Error(w, "Load run not found", http.StatusNotFound)
+		return
+	}
+	if !auth.CheckScenarios(w, r, run.Scenarios()...) {
+		return
+	}
+	run.Stop()
+	writeJSON(w, http.StatusOK, run.Status())
+}
+
+func startRun(w http.ResponseWriter, r *http.Request) {
+	// A standalone run drives no scenario, so nothing limits its target.
+	if !auth.CheckUnrestricted(w, r) {
+		return
+	}
+	var cfg Config
+	if err := json.NewDecoder(r.Body).Decode(&cfg); err != nil {
+		http.Error(w, "Invalid load configuration: "+err.Error(), http.StatusBadRequest)
+		return
+	}
+	run, err := GetManager().Start(cfg)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+	writeJSON(w, http.StatusAccepted, run.Status())
+}
+
+func getRuns(w http.ResponseWriter, r *http.Request) {
+	if id := r.URL.Query().Get("id"); id != "" {
+		run, ok := GetManager().Get(id)
+		if !ok {
+			http.Error(w, "Load run not found", http.StatusNotFound)
+			return
+		}
+		if !auth.CheckScenarios(w, r, run.Scenarios()...) {
+			return
+		}
+		writeJSON(w, http.StatusOK, run.Status())
+		return
+	}
+
+	runs := GetManager().List()
+	statuses := make([]RunStatus, 0, len(runs))
+	for _, run := range runs {
+		if auth.AllowsScenarios(r.Context(), run.Scenarios()...) {
+			statuses = append(statuses, run.Status())
+		}
+	}
+	writeJSON(w, http.StatusOK, statuses)
+}
+
+func writeJSON(w http.ResponseWriter, status int, v interface{}) {
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(status)
+	json.NewEncoder(w).Encode(v)
+}
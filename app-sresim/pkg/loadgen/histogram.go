@@ -0,0 +1,173 @@
+package loadgen
+
+import (
+	"math"
+	"math/bits"
+	"time"
+)
+
+// subBucketHalf is half the number of linear sub-buckets per power of two.
+// 64 gives two significant decimal digits, i.e. under 1% relative error,
+// which is the usual HdrHistogram setting for latency recording.
+const subBucketHalf = 64
+
+// Histogram records latencies in microseconds using HdrHistogram-style
+// log-linear buckets: values below 128µs are exact and every power-of-two
+// range above that is split into 64 equal sub-buckets.
+type Histogram struct {
+	counts []int64
+	total  int64
+	min    int64
+	max    int64
+	sum    int64
+}
+
+// NewHistogram creates an empty histogram.
+func NewHistogram() *Histogram {
+	return &Histogram{min: math.MaxInt64}
+}
+
+func bucketIndex(v int64) int {
+	shift := bits.Len64(uint64(v)) - 7
+	if shift < 0 {
+		shift = 0
+	}
+	return shift*subBucketHalf + int(v>>uint(shift))
+}
+
+// highestEquivalentValue returns the largest value that maps to idx.
+func highestEquivalentValue(idx int) int64 {
+	if idx < 2*subBucketHalf {
+		return int64(idx)
+	}
+	shift := idx/subBucketHalf - 1
+	sub := int64(idx - shift*subBucketHalf)
+	return (sub << uint(shift)) + (1 << uint(shift)) - 1
+}
+
+// Record adds a single latency observation.
+func (h *Histogram) Record(d time.Duration) {
+	v := d.Microseconds()
+	if v < 0 {
+		v = 0
+	}
+	idx := bucketIndex(v)
+	if idx >= len(h.counts) {
+		grown := make([]int64, idx+1)
+		copy(grown, h.counts)
+		h.counts = grown
+	}
+	h.counts[idx]++
+	h.total++
+	h.sum += v
+	if v < h.min {
+		h.min = v
+	}
+	if v > h.max {
+		h.max = v
+	}
+}
+
+// Merge adds all observations from other into h.
+func (h *Histogram) Merge(other *Histogram) {
+	if other.total == 0 {
+		return
+	}
+	if len(other.counts) > len(h.counts) {
+		grown := make([]int64, len(other.counts))
+		copy(grown, h.counts)
+		h.counts = grown
+	}
+	for i, c := range other.counts {
+		h.counts[i] += c
+	}
+	h.total += other.total
+	h.sum += other.sum
+	if other.min < h.min {
+		h.min = other.min
+	}
+	if other.max > h.max {
+		h.max = other.max
+	}
+}
+
+// Count returns the number of recorded observations.
+func (h *Histogram) Count() int64 {
+	return h.total
+}
+
+// Percentile returns the latency at or below which q (0-1) of the
+// observations fall.
+func (h *Histogram) Percentile(q float64) time.Duration {
+	if h.total == 0 {
+		return 0
+	}
+	target := int64(math.Ceil(q * float64(h.total)))
+	if target < 1 {
+		target = 1
+	}
+	var seen int64
+	for i, c := range h.counts {
+		seen += c
+		if seen >= target {
+			v := highestEquivalentValue(i)
+			if v > h.max {
+				v = h.max
+			}
+			return time.Duration(v) * time.Microsecond
+		}
+	}
+	return time.Duration(h.max) * time.Microsecond
+}
+
+// Min returns the smallest recorded latency.
+func (h *Histogram) Min() time.Duration {
+	if h.total == 0 {
+		return 0
+	}
+	return time.Duration(h.min) * time.Microsecond
+}
+
+// Max returns the largest recorded latency.
+func (h *Histogram) Max() time.Duration {
+	return time.Duration(h.max) * time.Microsecond
+}
+
+// Mean returns the arithmetic mean of the recorded latencies.
+func (h *Histogram) Mean() time.Duration {
+	if h.total == 0 {
+		return 0
+	}
+	return time.Duration(h.sum/h.total) * time.Microsecond
+}
+
+// LatencySummary is the percentile breakdown reported for a run. All values
+// are in milliseconds.
+type LatencySummary struct {
+	Min  float64 `json:"min_ms"`
+	Mean float64 `json:"mean_ms"`
+	P50  float64 `json:"p50_ms"`
+	P90  float64 `json:"p90_ms"`
+	P95  float64 `json:"p95_ms"`
+	P99  float64 `json:"p99_ms"`
+	P999 float64 `json:"p999_ms"`
+	Max  float64 `json:"max_ms"`
+}
+
+// Summary returns the standard percentile breakdown of the histogram.
+func (h *Histogram) Summary() LatencySummary {
+	return LatencySummary{
+		Min:  millis(h.Min()),
+		Mean: millis(h.Mean()),
+		P50:  millis(h.Percentile(0.50)),
+		P90:  millis(h.Percentile(0.90)),
+		P95:  millis(h.Percentile(0.95)),
+		P99:  millis(h.Percentile(0.99)),
+		P999: millis(h.Percentile(0.999)),
+		Max:  millis(h.Max()),
+	}
+}
+
+func millis(d time.Duration) float64 {
+	return float64(d) / float64(time.Millisecond)
+}
@@ -0,0 +1,477 @@
+package loadgen
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"strconv"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"github.com/localstack/sresim/app-sresim/pkg/config"
+	"github.com/localstack/sresim/app-sresim/pkg/tracing"
+)
+
+// Traffic models supported by the generator.
+const (
+	// ModelOpen sends requests at a constant arrival rate regardless of how
+	// quickly the target responds.
+	ModelOpen = "open"
+	// ModelClosed runs a fixed number of workers that each wait for a
+	// response before sending the next request.
+	ModelClosed = "closed"
+)
+
+// DefaultTarget is the sresim data-plane endpoint used when no target is set.
+const DefaultTarget = "http://localhost:8081/simulate"
+
+// statusError is the status key used for requests that got no HTTP response.
+const statusError = "error"
+
+// Stage is one segment of a ramp profile. Over Duration the rate (open model)
+// or worker count (closed model) moves linearly to Target.
+type Stage struct {
+	Duration config.Duration `json:"duration"`
+	Target   float64         `json:"target"`
+}
+
+// Config describes a load generation run.
+type Config struct {
+	Target      string            `json:"target"`
+	Method      string            `json:"method"`
+	Headers     map[string]string `json:"headers,omitempty"`
+	Body        string            `json:"body,omitempty"`
+	Model       string            `json:"model"`
+	Rate        float64           `json:"rate,omitempty"`
+	Workers     int               `json:"workers,omitempty"`
+	Duration    config.Duration   `json:"duration,omitempty"`
+	Stages      []Stage           `json:"stages,omitempty"`
+	Timeout     config.Duration   `json:"timeout,omitempty"`
+	MaxInFlight int               `json:"max_in_flight,omitempty"`
+	// Seed makes the random values of request templates reproducible.
+	Seed int64 `json:"seed,omitempty"`
+}
+
+// WithDefaults returns a copy of cfg with unset fields filled in.
+func (cfg Config) WithDefaults() Config {
+	if cfg.Target == "" {
+		cfg.Target = DefaultTarget
+	}
+	if cfg.Method == "" {
+		cfg.Method = http.MethodGet
+	}
+	cfg.Method = strings.ToUpper(cfg.Method)
+	if cfg.Model == "" {
+		cfg.Model = ModelOpen
+	}
+	if cfg.Timeout == 0 {
+		cfg.Timeout = config.Duration(10 * time.Second)
+	}
+	if cfg.MaxInFlight == 0 {
+		cfg.MaxInFlight = 1000
+	}
+	if cfg.Model == ModelOpen && cfg.Rate == 0 && len(cfg.Stages) == 0 {
+		cfg.Rate = 10
+	}
+	if cfg.Model == ModelClosed && cfg.Workers == 0 && len(cfg.Stages) == 0 {
+		cfg.Workers = 1
+	}
+	if cfg.Duration == 0 && len(cfg.Stages) == 0 {
+		cfg.Duration = config.Duration(30 * time.Second)
+	}
+	return cfg
+}
+
+// Default limits on the load of a single run, used unless configured
+// otherwise.
+const (
+	DefaultMaxRate    = 1000
+	DefaultMaxWorkers = 200
+)
+
+var limits = struct {
+	sync.RWMutex
+	config.LoadGen
+}{LoadGen: config.LoadGen{MaxRate: DefaultMaxRate, MaxWorkers: DefaultMaxWorkers}}
+
+// SetLimits sets the highest rate and worker count a run may use. Unset
+// limits fall back to the defaults.
+func SetLimits(l config.LoadGen) error {
+	if l.MaxRate < 0 || l.MaxWorkers < 0 {
+		return errors.New("loadgen limits must not be negative")
+	}
+	if l.MaxRate == 0 {
+		l.MaxRate = DefaultMaxRate
+	}
+	if l.MaxWorkers == 0 {
+		l.MaxWorkers = DefaultMaxWorkers
+	}
+	limits.Lock()
+	defer limits.Unlock()
+	limits.LoadGen = l
+	return nil
+}
+
+// Limits returns the highest rate and worker count a run may use.
+func Limits() config.LoadGen {
+	limits.RLock()
+	defer limits.RUnlock()
+	return limits.LoadGen
+}
+
+// Validate checks that the configuration describes a runnable load profile
+// within the configured limits. Call it on a configuration returned by
+// WithDefaults.
+func (cfg Config) Validate() error {
+	switch cfg.Model {
+	case ModelOpen, ModelClosed:
+	default:
+		return fmt.Errorf("unknown model %q (want %q or %q)", cfg.Model, ModelOpen, ModelClosed)
+	}
+	if cfg.Rate < 0 || cfg.Workers < 0 {
+		return errors.New("rate and workers must not be negative")
+	}
+	if cfg.Duration < 0 {
+		return errors.New("duration must not be negative")
+	}
+	l := Limits()
+	if cfg.Rate > l.MaxRate {
+		return fmt.Errorf("rate must not exceed %v requests per second", l.MaxRate)
+	}
+	if cfg.Workers > l.MaxWorkers {
+		return fmt.Errorf("workers must not exceed %d", l.MaxWorkers)
+	}
+	maxTarget := l.MaxRate
+	if cfg.Model == ModelClosed {
+		maxTarget = float64(l.MaxWorkers)
+	}
+	for i, stage := range cfg.Stages {
+		if stage.Duration <= 0 {
+			return fmt.Errorf("stage %d: duration must be positive", i)
+		}
+		if stage.Target < 0 {
+			return fmt.Errorf("stage %d: target must not be negative", i)
+		}
+		if stage.Target > maxTarget {
+			return fmt.Errorf("stage %d: target must not exceed %v", i, maxTarget)
+		}
+	}
+	if cfg.MaxInFlight < 0 {
+		return errors.New("max_in_flight must not be negative")
+	}
+	// A request template that does not parse would only fail once the run
+	// starts.
+	_, err := newRequestTemplate(cfg)
+	return err
+}
+
+// profile computes the target rate or worker count at a point in the run.
+type profile struct {
+	start  float64
+	hold   time.Duration
+	stages []Stage
+}
+
+func newProfile(cfg Config) profile {
+	start := cfg.Rate
+	if cfg.Model == ModelClosed {
+		start = float64(cfg.Workers)
+	}
+	return profile{start: start, hold: cfg.Duration.Std(), stages: cfg.Stages}
+}
+
+// total returns the length of the run: the constant-load hold followed by
+// every ramp stage.
+func (p profile) total() time.Duration {
+	total := p.hold
+	for _, stage := range p.stages {
+		total += stage.Duration.Std()
+	}
+	return total
+}
+
+func (p profile) at(elapsed time.Duration) float64 {
+	if elapsed < p.hold {
+		return p.start
+	}
+	elapsed -= p.hold
+	from := p.start
+	for _, stage := range p.stages {
+		d := stage.Duration.Std()
+		if elapsed < d {
+			return from + (stage.Target-from)*float64(elapsed)/float64(d)
+		}
+		elapsed -= d
+		from = stage.Target
+	}
+	return from
+}
+
+func (p profile) peak() float64 {
+	peak := p.start
+	for _, stage := range p.stages {
+		if stage.Target > peak {
+			peak = stage.Target
+		}
+	}
+	return peak
+}
+
+// Result is the outcome of a load generation run.
+type Result struct {
+	Requests   int64            `json:"requests"`
+	Errors     int64            `json:"errors"`
+	Dropped    int64            `json:"dropped"`
+	Status     map[string]int64 `json:"status"`
+	Latency    LatencySummary   `json:"latency"`
+	Elapsed    float64          `json:"elapsed_seconds"`
+	Throughput float64          `json:"throughput_rps"`
+
+	hist *Histogram
+}
+
+// Percentile returns an arbitrary latency percentile of the run. It is only
+// available on results produced in this process, not on decoded ones.
+func (r Result) Percentile(q float64) time.Duration {
+	if r.hist == nil {
+		return 0
+	}
+	return r.hist.Percentile(q)
+}
+
+// ErrorRate returns the share of requests that failed, counting transport
+// errors and 5xx responses.
+func (r Result) ErrorRate() float64 {
+	if r.Requests == 0 {
+		return 0
+	}
+	failed := r.Errors
+	for status, count := range r.Status {
+		if code, err := strconv.Atoi(status); err == nil && code >= 500 {
+			failed += count
+		}
+	}
+	return float64(failed) / float64(r.Requests)
+}
+
+// recorder accumulates request outcomes from concurrent workers.
+type recorder struct {
+	mu      sync.Mutex
+	hist    *Histogram
+	status  map[string]int64
+	errors  int64
+	dropped atomic.Int64
+	start   time.Time
+}
+
+func newRecorder() *recorder {
+	return &recorder{hist: NewHistogram(), status: make(map[string]int64), start: time.Now()}
+}
+
+func (rec *recorder) record(status string, latency time.Duration) {
+	rec.mu.Lock()
+	defer rec.mu.Unlock()
+	rec.hist.Record(latency)
+	rec.status[status]++
+	if status == statusError {
+		rec.errors++
+	}
+}
+
+func (rec *recorder) result(end time.Time) Result {
+	rec.mu.Lock()
+	defer rec.mu.Unlock()
+	status := make(map[string]int64, len(rec.status))
+	for k, v := range rec.status {
+		status[k] = v
+	}
+	hist := NewHistogram()
+	hist.Merge(rec.hist)
+	elapsed := end.Sub(rec.start).Seconds()
+	result := Result{
+		Requests: rec.hist.Count(),
+		Errors:   rec.errors,
+		Dropped:  rec.dropped.Load(),
+		Status:   status,
+		Latency:  hist.Summary(),
+		Elapsed:  elapsed,
+		hist:     hist,
+	}
+	if elapsed > 0 {
+		result.Throughput = float64(result.Requests) / elapsed
+	}
+	return result
+}
+
+// generator drives a single run.
+type generator struct {
+	cfg     Config
+	profile profile
+	tmpl    *requestTemplate
+	client  *http.Client
+	rec     *recorder
+	seq     atomic.Int64
+}
+
+func newGenerator(cfg Config) (*generator, error) {
+	cfg = cfg.WithDefaults()
+	if err := cfg.Validate(); err != nil {
+		return nil, err
+	}
+	tmpl, err := newRequestTemplate(cfg)
+	if err != nil {
+		return nil, err
+	}
+	return &generator{
+		cfg:     cfg,
+		profile: newProfile(cfg),
+		tmpl:    tmpl,
+		client:  &http.Client{Timeout: cfg.Timeout.Std()},
+		rec:     newRecorder(),
+	}, nil
+}
+
+func (g *generator) run(ctx context.Context) {
+	ctx, cancel := context.WithTimeout(ctx, g.profile.total())
+	defer cancel()
+	if g.cfg.Model == ModelClosed {
+		g.runClosed(ctx)
+	} else {
+		g.runOpen(ctx)
+	}
+}
+
+// runOpen schedules arrivals independently of responses. Arrivals that would
+// exceed MaxInFlight are counted as dropped instead of queueing, so a slow
+// target cannot silently turn the open model into a closed one.
+func (g *generator) runOpen(ctx context.Context) {
+	var wg sync.WaitGroup
+	inFlight := make(chan struct{}, g.cfg.MaxInFlight)
+	total := g.profile.total()
+	var next time.Duration
+
+	for next < total {
+		rate := g.profile.at(next)
+		if rate <= 0 {
+			next += 10 * time.Millisecond
+			continue
+		}
+		if wait := time.Until(g.rec.start.Add(next)); wait > 0 {
+			select {
+			case <-ctx.Done():
+				wg.Wait()
+				return
+			case <-time.After(wait):
+			}
+		} else if ctx.Err() != nil {
+			break
+		}
+		next += time.Duration(float64(time.Second) / rate)
+
+		select {
+		case inFlight <- struct{}{}:
+		default:
+			g.rec.dropped.Add(1)
+			continue
+		}
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			defer func() { <-inFlight }()
+			g.do(ctx, 0)
+		}()
+	}
+	wg.Wait()
+}
+
+// runClosed starts enough workers for the profile's peak and lets each one
+// idle while the profile's current worker count is below its index.
+func (g *generator) runClosed(ctx context.Context) {
+	var wg sync.WaitGroup
+	workers := int(g.profile.peak() + 0.5)
+	for i := 0; i < workers; i++ {
+		wg.Add(1)
+		go func(worker int) {
+			defer wg.Done()
+			for ctx.Err() == nil {
+				if float64(worker) >= g.profile.at(time.Since(g.rec.start)) {
+					select {
+					case <-ctx.Done():
+					case <-time.After(50 * time.Millisecond):
+					}
+					continue
+				}
+				g.do(ctx, worker)
+			}
+		}(i)
+	}
+	wg.Wait()
+}
+
+func (g *generator) do(ctx context.Context, worker int) {
+	data := TemplateData{Seq: g.seq.Add(1), Worker: worker, Time: time.Now()}
+	req, err := g.buildRequest(ctx, data)
+	if err != nil {
+		g.rec.record(statusError, 0)
+		return
+	}
+
+	req, span := tracing.StartClient(req, "loadgen "+req.Method)
+	start := time.Now()
+	resp, err := g.client.Do(req)
+	tracing.EndClient(span, resp, err)
+	if err != nil {
+		// Requests cut off by the end of the run are not failures of the target.
+		if ctx.Err() != nil {
+			return
+		}
+		g.rec.record(statusError, time.Since(start))
+		return
+	}
+	io.Copy(io.Discard, resp.Body)
+	resp.Body.Close()
+	g.rec.record(strconv.Itoa(resp.StatusCode), time.Since(start))
+}
+
+func (g *generator) buildRequest(ctx context.Context, data TemplateData) (*http.Request, error) {
+	target, err := g.tmpl.target.render(data)
+	if err != nil {
+		return nil, err
+	}
+	body, err := g.tmpl.body.render(data)
+	if err != nil {
+		return nil, err
+	}
+	var reader io.Reader
+	if body != "" {
+		reader = strings.NewReader(body)
+	}
+	req, err := http.NewRequestWithContext(ctx, g.tmpl.method, target, reader)
+	if err != nil {
+		return nil, err
+	}
+	for name, f := range g.tmpl.headers {
+		value, err := f.render(data)
+		if err != nil {
+			return nil, err
+		}
+		req.Header.Set(name, value)
+	}
+	return req, nil
+}
+
+// Execute runs a load profile to completion and returns its result. It is
+// the synchronous counterpart of Manager.Start for callers that need the
+// numbers inline.
+func Execute(ctx context.Context, cfg Config) (Result, error) {
+	g, err := newGenerator(cfg)
+	if err != nil {
+		return Result{}, err
+	}
+	g.run(ctx)
+	return g.rec.result(time.Now()), nil
+}
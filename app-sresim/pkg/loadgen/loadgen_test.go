@@ -0,0 +1,251 @@
+package loadgen
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/localstack/sresim/app-sresim/pkg/auth"
+	"github.com/localstack/sresim/app-sresim/pkg/config"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"go.opentelemetry.io/otel"
+	"go.opentelemetry.io/otel/propagation"
+	sdktrace "go.opentelemetry.io/otel/sdk/trace"
+)
+
+func TestHistogramPercentiles(t *testing.T) {
+	h := NewHistogram()
+	for i := 1; i <= 1000; i++ {
+		h.Record(time.Duration(i) * time.Millisecond)
+	}
+
+	assert.Equal(t, int64(1000), h.Count())
+	assert.Equal(t, time.Millisecond, h.Min())
+	assert.Equal(t, time.Second, h.Max())
+
+	// Log-linear buckets keep the relative error under 1%.
+	assert.InEpsilon(t, float64(500*time.Millisecond), float64(h.Percentile(0.50)), 0.01)
+	assert.InEpsilon(t, float64(990*time.Millisecond), float64(h.Percentile(0.99)), 0.01)
+	assert.Equal(t, time.Second, h.Percentile(1))
+}
+
+func TestHistogramMerge(t *testing.T) {
+	a, b := NewHistogram(), NewHistogram()
+	a.Record(10 * time.Millisecond)
+	b.Record(2 * time.Second)
+	a.Merge(b)
+
+	assert.Equal(t, int64(2), a.Count())
+	assert.Equal(t, 10*time.Millisecond, a.Min())
+	assert.Equal(t, 2*time.Second, a.Max())
+}
+
+func TestProfileRamp(t *testing.T) {
+	p := newProfile(Config{
+		Model:    ModelOpen,
+		Rate:     10,
+		Duration: config.Duration(time.Second),
+		Stages: []Stage{
+			{Duration: config.Duration(2 * time.Second), Target: 30},
+			{Duration: config.Duration(time.Second), Target: 0},
+		},
+	})
+
+	assert.Equal(t, 4*time.Second, p.total())
+	assert.Equal(t, float64(10), p.at(500*time.Millisecond))
+	assert.Equal(t, float64(20), p.at(2*time.Second))
+	assert.Equal(t, float64(15), p.at(3500*time.Millisecond))
+	assert.Equal(t, float64(30), p.peak())
+}
+
+func TestValidate(t *testing.T) {
+	assert.Error(t, Config{Model: "poisson"}.WithDefaults().Validate())
+	assert.Error(t, Config{Stages: []Stage{{Target: 5}}}.WithDefaults().Validate())
+	assert.NoError(t, Config{}.WithDefaults().Validate())
+	assert.Error(t, Config{Target: "{{.Nope"}.WithDefaults().Validate())
+}
+
+func TestLimits(t *testing.T) {
+	t.Cleanup(func() { require.NoError(t, SetLimits(config.LoadGen{})) })
+	assert.Equal(t, config.LoadGen{MaxRate: DefaultMaxRate, MaxWorkers: DefaultMaxWorkers}, Limits())
+	assert.Error(t, Config{Rate: DefaultMaxRate + 1}.WithDefaults().Validate())
+	assert.Error(t, Config{Model: ModelClosed, Workers: DefaultMaxWorkers + 1}.WithDefaults().Validate())
+
+	require.NoError(t, SetLimits(config.LoadGen{MaxRate: 50, MaxWorkers: 5}))
+	assert.NoError(t, Config{Rate: 50}.WithDefaults().Validate())
+	assert.Error(t, Config{Rate: 51}.WithDefaults().Validate())
+	assert.Error(t, Config{Stages: []Stage{{Duration: config.Duration(time.Second), Target: 60}}}.WithDefaults().Validate())
+	assert.NoError(t, Config{Model: ModelClosed, Stages: []Stage{{Duration: config.Duration(time.Second), Target: 5}}}.WithDefaults().Validate())
+	assert.Error(t, Config{Model: ModelClosed, Stages: []Stage{{Duration: config.Duration(time.Second), Target: 6}}}.WithDefaults().Validate())
+	assert.Error(t, SetLimits(config.LoadGen{MaxRate: -1}))
+}
+
+func TestExecuteClosedModel(t *testing.T) {
+	var mu sync.Mutex
+	paths := make(map[string]bool)
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		mu.Lock()
+		paths[r.URL.Query().Get("worker")] = true
+		mu.Unlock()
+		if r.Header.Get("X-Fail") == "yes" {
+			w.WriteHeader(http.StatusInternalServerError)
+		}
+	}))
+	defer server.Close()
+
+	result, err := Execute(context.Background(), Config{
+		Target:   server.URL + "/?worker={{.Worker}}",
+		Model:    ModelClosed,
+		Workers:  2,
+		Duration: config.Duration(200 * time.Millisecond),
+		Headers:  map[string]string{"X-Fail": "{{if eq .Worker 1}}yes{{else}}no{{end}}"},
+	})
+	require.NoError(t, err)
+
+	assert.Greater(t, result.Requests, int64(0))
+	assert.Equal(t, result.Requests, result.Status["200"]+result.Status["500"])
+	assert.Greater(t, result.Status["500"], int64(0))
+	assert.Greater(t, result.ErrorRate(), 0.0)
+	mu.Lock()
+	defer mu.Unlock()
+	assert.True(t, paths["0"] && paths["1"], "both workers should have sent requests")
+}
+
+func TestExecuteOpenModel(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {}))
+	defer server.Close()
+
+	result, err := Execute(context.Background(), Config{
+		Target:   server.URL,
+		Model:    ModelOpen,
+		Rate:     100,
+		Duration: config.Duration(300 * time.Millisecond),
+	})
+	require.NoError(t, err)
+
+	// 100 req/s for 300ms schedules about 30 arrivals.
+	assert.InDelta(t, 30, result.Requests, 5)
+	assert.Equal(t, result.Requests, result.Status["200"])
+	assert.Zero(t, result.ErrorRate())
+}
+
+func TestExecutePropagatesTraceContext(t *testing.T) {
+	prevProvider, prevPropagator := otel.GetTracerProvider(), otel.GetTextMapPropagator()
+	otel.SetTracerProvider(sdktrace.NewTracerProvider())
+	otel.SetTextMapPropagator(propagation.TraceContext{})
+	t.Cleanup(func() {
+		otel.SetTracerProvider(prevProvider)
+		otel.SetTextMapPropagator(prevPropagator)
+	})
+
+	var mu sync.Mutex
+	traces := make(map[string]bool)
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		mu.Lock()
+		defer mu.Unlock()
+		traces[r.Header.Get("traceparent")] = true
+	}))
+	defer server.Close()
+
+	result, err := Execute(context.Background(), Config{Target: server.URL, Rate: 50, Duration: config.Duration(100 * time.Millisecond)})
+	require.NoError(t, err)
+	require.NotZero(t, result.Requests)
+	assert.NotContains(t, traces, "", "every request carries trace context")
+	assert.Len(t, traces, int(result.Requests), "every request is a trace of its own")
+}
+
+func TestManagerStop(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {}))
+	defer server.Close()
+
+	run, err := GetManager().Start(Config{Target: server.URL, Rate: 50, Duration: config.Duration(time.Minute)})
+	require.NoError(t, err)
+
+	run.Stop()
+	status := run.Status()
+	assert.Equal(t, StatusStopped, status.Status)
+	assert.NotNil(t, status.EndedAt)
+}
+
+func TestManagerTrimsFinishedRuns(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {}))
+	defer server.Close()
+	m := &Manager{runs: make(map[string]*Run)}
+
+	running, err := m.Start(Config{Target: server.URL, Rate: 1, Duration: config.Duration(time.Minute)})
+	require.NoError(t, err)
+	defer running.Stop()
+	for i := 0; i < maxRuns+5; i++ {
+		run, err := m.Start(Config{Target: server.URL, Rate: 1, Duration: config.Duration(time.Millisecond)})
+		require.NoError(t, err)
+		run.Wait()
+	}
+
+	assert.Len(t, m.List(), maxRuns)
+	_, ok := m.Get(running.ID)
+	assert.True(t, ok, "runs still generating load are kept")
+}
+
+func TestHandlersRestrictScenarios(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {}))
+	defer server.Close()
+	authenticator, err := auth.New(config.Auth{Principals: []config.Principal{
+		{Name: "payments", Role: auth.RoleOperator, Token: "payments-token", Scenarios: []string{"latency"}},
+	}})
+	require.NoError(t, err)
+	mux := http.NewServeMux()
+	mux.HandleFunc("GET /loadgen", LoadgenHandler)
+	mux.HandleFunc("POST /loadgen", LoadgenHandler)
+	mux.HandleFunc("POST /loadgen/stop", StopHandler)
+	handler := authenticator.Middleware(mux)
+	do := func(method, target string) *httptest.ResponseRecorder {
+		req := httptest.NewRequest(method, target, nil)
+		req.Header.Set("Authorization", "Bearer payments-token")
+		rec := httptest.NewRecorder()
+		handler.ServeHTTP(rec, req)
+		return rec
+	}
+
+	run, err := GetManager().Start(Config{Target: server.URL, Rate: 10, Duration: config.Duration(time.Minute)})
+	require.NoError(t, err)
+	defer run.Stop()
+	run.SetScenarios("cpu_spike")
+
+	rec := do(http.MethodGet, "/loadgen")
+	require.Equal(t, http.StatusOK, rec.Code)
+	var statuses []RunStatus
+	require.NoError(t, json.NewDecoder(rec.Body).Decode(&statuses))
+	for _, status := range statuses {
+		assert.NotEqual(t, run.ID, status.ID, "runs of other scenarios are not listed")
+	}
+	assert.Equal(t, http.StatusForbidden, do(http.MethodGet, "/loadgen?id="+run.ID).Code)
+	assert.Equal(t, http.StatusForbidden, do(http.MethodPost, "/loadgen/stop?id="+run.ID).Code)
+	assert.Equal(t, StatusRunning, run.Status().Status)
+
+	run.SetScenarios("latency")
+	assert.Equal(t, http.StatusOK, do(http.MethodPost, "/loadgen/stop?id="+run.ID).Code)
+	assert.Equal(t, StatusStopped, run.Status().Status)
+
+	assert.Equal(t, http.StatusForbidden, do(http.MethodPost, "/loadgen").Code, "standalone runs drive no scenario")
+}
+
+func TestTemplateSeed(t *testing.T) {
+	render := func(seed int64) []string {
+		rt, err := newRequestTemplate(Config{Target: "/simulate?user={{randInt 1 1000000}}&{{randChoice \"a\" \"b\" \"c\"}}", Seed: seed})
+		require.NoError(t, err)
+		var targets []string
+		for i := 0; i < 5; i++ {
+			target, err := rt.target.render(TemplateData{Seq: int64(i)})
+			require.NoError(t, err)
+			targets = append(targets, target)
+		}
+		return targets
+	}
+	assert.Equal(t, render(42), render(42))
+	assert.NotEqual(t, render(42), render(43))
+}
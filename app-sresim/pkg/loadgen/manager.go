@@ -0,0 +1,236 @@
+package loadgen
+
+import (
+	"context"
+	"sort"
+	"sync"
+	"time"
+
+	"github.com/google/uuid"
+)
+
+// Run states.
+const (
+	StatusRunning   = "running"
+	StatusCompleted = "completed"
+	StatusStopped   = "stopped"
+)
+
+// Run is a load generation run started through the Manager.
+type Run struct {
+	ID        string
+	Config    Config
+	StartedAt time.Time
+
+	gen    *generator
+	cancel context.CancelFunc
+	done   chan struct{}
+
+	mu        sync.Mutex
+	status    string
+	endedAt   time.Time
+	result    *Result
+	scenarios []string
+}
+
+// RunStatus is the externally visible state of a run. While the run is in
+// progress Result holds the numbers recorded so far.
+type RunStatus struct {
+	ID        string     `json:"id"`
+	Status    string     `json:"status"`
+	Config    Config     `json:"config"`
+	StartedAt time.Time  `json:"started_at"`
+	EndedAt   *time.Time `json:"ended_at,omitempty"`
+	Result    Result     `json:"result"`
+	// Scenarios are the scenarios the run drives, if it was started for a
+	// scenario run or an experiment.
+	Scenarios []string `json:"scenarios,omitempty"`
+}
+
+// SetScenarios ties the run to the scenarios it drives. Principals that may
+// not use all of them can neither see nor stop the run.
+func (r *Run) SetScenarios(scenarios ...string) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.scenarios = scenarios
+}
+
+// Scenarios returns the scenarios the run drives.
+func (r *Run) Scenarios() []string {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	return r.scenarios
+}
+
+// Stop cancels the run and waits for in-flight requests to finish.
+func (r *Run) Stop() {
+	r.mu.Lock()
+	if r.status == StatusRunning {
+		r.status = StatusStopped
+	}
+	r.mu.Unlock()
+	r.cancel()
+	<-r.done
+}
+
+// Wait blocks until the run has finished and returns its result.
+func (r *Run) Wait() Result {
+	<-r.done
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	return *r.result
+}
+
+// Done returns a channel that is closed when the run finishes.
+func (r *Run) Done() <-chan struct{} {
+	return r.done
+}
+
+// Status returns a snapshot of the run.
+func (r *Run) Status() RunStatus {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	status := RunStatus{
+		ID:        r.ID,
+		Status:    r.status,
+		Config:    r.Config,
+		StartedAt: r.StartedAt,
+		Scenarios: r.scenarios,
+	}
+	if r.result != nil {
+		status.Result = *r.result
+		endedAt := r.endedAt
+		status.EndedAt = &endedAt
+	} else {
+		status.Result = r.gen.rec.result(time.Now())
+	}
+	return status
+}
+
+// finished reports whether the run has stopped generating load.
+func (r *Run) finished() bool {
+	select {
+	case <-r.done:
+		return true
+	default:
+		return false
+	}
+}
+
+func (r *Run) finish() {
+	result := r.gen.rec.result(time.Now())
+	r.mu.Lock()
+	r.result = &result
+	r.endedAt = time.Now()
+	if r.status == StatusRunning {
+		r.status = StatusCompleted
+	}
+	r.mu.Unlock()
+	close(r.done)
+}
+
+// maxRuns is how many runs the Manager keeps. The oldest finished runs
+// beyond it are forgotten; runs still generating load are always kept.
+const maxRuns = 100
+
+// Manager tracks load generation runs.
+type Manager struct {
+	runs map[string]*Run
+	// order holds the IDs of the runs, oldest first.
+	order []string
+	mu    sync.RWMutex
+}
+
+var manager = &Manager{
+	runs: make(map[string]*Run),
+}
+
+// GetManager returns the singleton load generation manager
+func GetManager() *Manager {
+	return manager
+}
+
+// Start validates cfg and begins generating load in the background.
+func (m *Manager) Start(cfg Config) (*Run, error) {
+	g, err := newGenerator(cfg)
+	if err != nil {
+		return nil, err
+	}
+	ctx, cancel := context.WithCancel(context.Background())
+	run := &Run{
+		ID:        uuid.NewString(),
+		Config:    g.cfg,
+		StartedAt: time.Now(),
+		gen:       g,
+		cancel:    cancel,
+		done:      make(chan struct{}),
+		status:    StatusRunning,
+	}
+
+	m.mu.Lock()
+	m.runs[run.ID] = run
+	m.order = append(m.order, run.ID)
+	m.trim()
+	m.mu.Unlock()
+
+	go func() {
+		defer cancel()
+		g.run(ctx)
+		run.finish()
+	}()
+	return run, nil
+}
+
+// trim forgets the oldest finished runs beyond maxRuns. The caller must
+// hold m.mu.
+func (m *Manager) trim() {
+	excess := len(m.order) - maxRuns
+	if excess <= 0 {
+		return
+	}
+	kept := m.order[:0]
+	for _, id := range m.order {
+		if excess > 0 && m.runs[id].finished() {
+			delete(m.runs, id)
+			excess--
+			continue
+		}
+		kept = append(kept, id)
+	}
+	m.order = kept
+}
+
+// Get returns the run with the given ID.
+func (m *Manager) Get(id string) (*Run, bool) {
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+	run, ok := m.runs[id]
+	return run, ok
+}
+
+// List returns every known run, most recent first.
+func (m *Manager) List() []*Run {
+	m.mu.RLock()
+	runs := make([]*Run, 0, len(m.runs))
+	for _, run := range m.runs {
+		runs = append(runs, run)
+	}
+	m.mu.RUnlock()
+	sort.Slice(runs, func(i, j int) bool {
+		return runs[i].StartedAt.After(runs[j].StartedAt)
+	})
+	return runs
+}
+
+// StopAll stops every run that is still generating load and returns how
+// many it stopped.
+func (m *Manager) StopAll() int {
+	stopped := 0
+	for _, run := range m.List() {
+		if run.Status().Status == StatusRunning {
+			stopped++
+		}
+		run.Stop()
+	}
+	return stopped
+}
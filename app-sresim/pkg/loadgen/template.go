@@ -0,0 +1,114 @@
+package loadgen
+
+import (
+	"bytes"
+	"fmt"
+	"math/rand"
+	"strings"
+	"sync"
+	"text/template"
+	"time"
+
+	"github.com/google/uuid"
+)
+
+// TemplateData is the data available to request templates. Target, body and
+// header values may all use Go template syntax, e.g.
+// "/simulate?user={{randInt 1 1000}}&seq={{.Seq}}".
+type TemplateData struct {
+	Seq    int64
+	Worker int
+	Time   time.Time
+}
+
+// lockedRand is a random source shared by the workers of a run.
+type lockedRand struct {
+	mu  sync.Mutex
+	rng *rand.Rand
+}
+
+func (r *lockedRand) Intn(n int) int {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	return r.rng.Intn(n)
+}
+
+// templateFuncs returns the template functions of a run. A non-zero seed
+// makes the sequence of random values reproducible.
+func templateFuncs(seed int64) template.FuncMap {
+	if seed == 0 {
+		seed = time.Now().UnixNano()
+	}
+	rng := &lockedRand{rng: rand.New(rand.NewSource(seed))}
+	return template.FuncMap{
+		"randInt": func(min, max int) int {
+			if max <= min {
+				return min
+			}
+			return min + rng.Intn(max-min+1)
+		},
+		"randChoice": func(choices ...string) string {
+			if len(choices) == 0 {
+				return ""
+			}
+			return choices[rng.Intn(len(choices))]
+		},
+		"uuid": func() string {
+			return uuid.NewString()
+		},
+	}
+}
+
+// field is a request attribute that is either a literal or a template.
+type field struct {
+	literal string
+	tmpl    *template.Template
+}
+
+func parseField(name, value string, funcs template.FuncMap) (field, error) {
+	if !strings.Contains(value, "{{") {
+		return field{literal: value}, nil
+	}
+	tmpl, err := template.New(name).Funcs(funcs).Option("missingkey=error").Parse(value)
+	if err != nil {
+		return field{}, fmt.Errorf("invalid %s template: %w", name, err)
+	}
+	return field{tmpl: tmpl}, nil
+}
+
+func (f field) render(data TemplateData) (string, error) {
+	if f.tmpl == nil {
+		return f.literal, nil
+	}
+	var buf bytes.Buffer
+	if err := f.tmpl.Execute(&buf, data); err != nil {
+		return "", err
+	}
+	return buf.String(), nil
+}
+
+// requestTemplate is the parsed form of a Config's request attributes.
+type requestTemplate struct {
+	method  string
+	target  field
+	body    field
+	headers map[string]field
+}
+
+func newRequestTemplate(cfg Config) (*requestTemplate, error) {
+	rt := &requestTemplate{method: cfg.Method, headers: make(map[string]field)}
+	funcs := templateFuncs(cfg.Seed)
+	var err error
+	if rt.target, err = parseField("target", cfg.Target, funcs); err != nil {
+		return nil, err
+	}
+	if rt.body, err = parseField("body", cfg.Body, funcs); err != nil {
+		return nil, err
+	}
+	for name, value := range cfg.Headers {
+		if rt.headers[name], err = parseField("header "+name, value, funcs); err != nil {
+			return nil, err
+		}
+	}
+	return rt, nil
+}
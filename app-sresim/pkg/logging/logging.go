@@ -0,0 +1,150 @@
+// Package logging sets up structured JSON logging with log/slog and logs
+// every request with the faults injected into it.
+package logging
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"log/slog"
+	"net/http"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/google/uuid"
+	"go.opentelemetry.io/otel/trace"
+
+	"github.com/localstack/sresim/app-sresim/pkg/metrics"
+	"github.com/localstack/sresim/app-sresim/pkg/response"
+)
+
+// RequestIDHeader carries the ID of a request. An incoming ID is kept,
+// otherwise one is generated; either way it is echoed in the response.
+const RequestIDHeader = "X-Request-ID"
+
+// ParseLevel parses a LOG_LEVEL value: debug, info, warn or error. Empty
+// means info.
+func ParseLevel(s string) (slog.Level, error) {
+	var level slog.Level
+	if s == "" {
+		return slog.LevelInfo, nil
+	}
+	if err := level.UnmarshalText([]byte(s)); err != nil {
+		return slog.LevelInfo, fmt.Errorf("invalid log level %q (want debug, info, warn or error)", s)
+	}
+	return level, nil
+}
+
+// New returns a logger that writes JSON lines at or above level to w and
+// counts them by level in sresim_log_records_total of m.
+func New(w io.Writer, level slog.Leveler, m *metrics.Registry) *slog.Logger {
+	return slog.New(countingHandler{slog.NewJSONHandler(w, &slog.HandlerOptions{Level: level}), m})
+}
+
+// countingHandler counts the records its handler writes.
+type countingHandler struct {
+	slog.Handler
+	m *metrics.Registry
+}
+
+func (h countingHandler) Handle(ctx context.Context, r slog.Record) error {
+	h.m.CountLogRecord(strings.ToLower(r.Level.String()))
+	return h.Handler.Handle(ctx, r)
+}
+
+func (h countingHandler) WithAttrs(attrs []slog.Attr) slog.Handler {
+	return countingHandler{h.Handler.WithAttrs(attrs), h.m}
+}
+
+func (h countingHandler) WithGroup(name string) slog.Handler {
+	return countingHandler{h.Handler.WithGroup(name), h.m}
+}
+
+// quietPaths are polled by Kubernetes and Prometheus; their requests are
+// logged at debug level.
+var quietPaths = map[string]bool{
+	"/health":   true,
+	"/livez":    true,
+	"/readyz":   true,
+	"/startupz": true,
+	"/metrics":  true,
+}
+
+// annotations collects the attributes inner handlers add to the log line
+// of a request.
+type annotations struct {
+	mu    sync.Mutex
+	attrs []slog.Attr
+}
+
+type annotationsKey struct{}
+
+// Annotate adds attributes to the log line of the request served with ctx,
+// e.g. the fault injected into it. It does nothing outside Middleware.
+func Annotate(ctx context.Context, attrs ...slog.Attr) {
+	a, ok := ctx.Value(annotationsKey{}).(*annotations)
+	if !ok {
+		return
+	}
+	a.mu.Lock()
+	defer a.mu.Unlock()
+	a.attrs = append(a.attrs, attrs...)
+}
+
+// Middleware logs every request once it has been served, with its request
+// ID, trace ID and the attributes added with Annotate. It must run inside
+// the tracing middleware to see the trace ID, and outside the middlewares
+// that inject faults.
+func Middleware(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		start := time.Now()
+		requestID := r.Header.Get(RequestIDHeader)
+		if requestID == "" {
+			requestID = uuid.NewString()
+		}
+		w.Header().Set(RequestIDHeader, requestID)
+
+		a := &annotations{}
+		rw := response.Wrap(w)
+		defer func() {
+			// Log a request whose handler panicked with the 500 it is
+			// answered with further out, and let the panic go on.
+			if p := recover(); p != nil {
+				status := rw.Status()
+				if !rw.WroteHeader() {
+					status = http.StatusInternalServerError
+				}
+				logRequest(r, requestID, status, start, a)
+				panic(p)
+			}
+		}()
+		next.ServeHTTP(rw, r.WithContext(context.WithValue(r.Context(), annotationsKey{}, a)))
+		logRequest(r, requestID, rw.Status(), start, a)
+	})
+}
+
+// logRequest writes the log line of a served request.
+func logRequest(r *http.Request, requestID string, status int, start time.Time, a *annotations) {
+	level := slog.LevelInfo
+	switch {
+	case status >= 500:
+		level = slog.LevelWarn
+	case quietPaths[r.URL.Path]:
+		level = slog.LevelDebug
+	}
+	attrs := []slog.Attr{
+		slog.String("request_id", requestID),
+		slog.String("method", r.Method),
+		slog.String("path", r.URL.Path),
+		slog.Int("status", status),
+		slog.Float64("duration_seconds", time.Since(start).Seconds()),
+	}
+	if sc := trace.SpanContextFromContext(r.Context()); sc.IsValid() {
+		attrs = append(attrs, slog.String("trace_id", sc.TraceID().String()), slog.String("span_id", sc.SpanID().String()))
+	}
+	a.mu.Lock()
+	attrs = append(attrs, a.attrs...)
+	a.mu.Unlock()
+	slog.LogAttrs(r.Context(), level, "request", attrs...)
+}
@@ -0,0 +1,168 @@
+package logging
+
+import (
+	"bytes"
+	"encoding/json"
+	"log/slog"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/prometheus/client_golang/prometheus/testutil"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"go.opentelemetry.io/otel/trace"
+
+	"github.com/localstack/sresim/app-sresim/pkg/metrics"
+)
+
+// captureLogs makes the default logger write JSON lines at level to the
+// returned buffer.
+func captureLogs(t *testing.T, level slog.Level) *bytes.Buffer {
+	t.Helper()
+	var buf bytes.Buffer
+	prev := slog.Default()
+	slog.SetDefault(New(&buf, level, metrics.NewRegistry()))
+	t.Cleanup(func() { slog.SetDefault(prev) })
+	return &buf
+}
+
+// lines decodes the JSON lines in buf.
+func lines(t *testing.T, buf *bytes.Buffer) []map[string]interface{} {
+	t.Helper()
+	var out []map[string]interface{}
+	for _, line := range strings.Split(strings.TrimSpace(buf.String()), "\n") {
+		if line == "" {
+			continue
+		}
+		var m map[string]interface{}
+		require.NoError(t, json.Unmarshal([]byte(line), &m), line)
+		out = append(out, m)
+	}
+	return out
+}
+
+func TestParseLevel(t *testing.T) {
+	for in, want := range map[string]slog.Level{
+		"":      slog.LevelInfo,
+		"debug": slog.LevelDebug,
+		"INFO":  slog.LevelInfo,
+		"warn":  slog.LevelWarn,
+		"error": slog.LevelError,
+	} {
+		level, err := ParseLevel(in)
+		require.NoError(t, err, in)
+		assert.Equal(t, want, level, in)
+	}
+
+	level, err := ParseLevel("verbose")
+	assert.Error(t, err)
+	assert.Equal(t, slog.LevelInfo, level)
+}
+
+func TestNewCountsRecords(t *testing.T) {
+	m := metrics.NewRegistry()
+	var buf bytes.Buffer
+	logger := New(&buf, slog.LevelInfo, m).With("component", "test")
+
+	logger.Error("failed")
+	logger.Info("started")
+	logger.Debug("not written")
+
+	assert.Len(t, lines(t, &buf), 2)
+	// Records below the level are not counted.
+	expected := `
+# HELP sresim_log_records_total Total number of log records written, by level
+# TYPE sresim_log_records_total counter
+sresim_log_records_total{level="error"} 1
+sresim_log_records_total{level="info"} 1
+`
+	assert.NoError(t, testutil.GatherAndCompare(m.Gatherer(), strings.NewReader(expected), metrics.LogRecordsName))
+}
+
+func TestMiddleware(t *testing.T) {
+	buf := captureLogs(t, slog.LevelInfo)
+	handler := Middleware(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		Annotate(r.Context(), slog.String("fault", "fail"), slog.Any("scenario_run_ids", []string{"run-1"}))
+		http.Error(w, "Simulated failure", http.StatusInternalServerError)
+	}))
+
+	traceID, _ := trace.TraceIDFromHex("4bf92f3577b34da6a3ce929d0e0e4736")
+	spanID, _ := trace.SpanIDFromHex("00f067aa0ba902b7")
+	ctx := trace.ContextWithSpanContext(httptest.NewRequest(http.MethodGet, "/", nil).Context(),
+		trace.NewSpanContext(trace.SpanContextConfig{TraceID: traceID, SpanID: spanID}))
+	req := httptest.NewRequest(http.MethodGet, "/simulate", nil).WithContext(ctx)
+	req.Header.Set(RequestIDHeader, "req-1")
+	rec := httptest.NewRecorder()
+	handler.ServeHTTP(rec, req)
+
+	assert.Equal(t, "req-1", rec.Header().Get(RequestIDHeader), "the request ID is echoed")
+	logged := lines(t, buf)
+	require.Len(t, logged, 1)
+	line := logged[0]
+	assert.Equal(t, "WARN", line["level"], "5xx responses are logged as warnings")
+	assert.Equal(t, "request", line["msg"])
+	assert.Equal(t, "req-1", line["request_id"])
+	assert.Equal(t, "GET", line["method"])
+	assert.Equal(t, "/simulate", line["path"])
+	assert.Equal(t, float64(500), line["status"])
+	assert.Equal(t, "4bf92f3577b34da6a3ce929d0e0e4736", line["trace_id"])
+	assert.Equal(t, "00f067aa0ba902b7", line["span_id"])
+	assert.Equal(t, "fail", line["fault"])
+	assert.Equal(t, []interface{}{"run-1"}, line["scenario_run_ids"])
+}
+
+func TestMiddlewareGeneratesRequestID(t *testing.T) {
+	buf := captureLogs(t, slog.LevelInfo)
+	handler := Middleware(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {}))
+
+	rec := httptest.NewRecorder()
+	handler.ServeHTTP(rec, httptest.NewRequest(http.MethodPost, "/scenarios/run", nil))
+
+	logged := lines(t, buf)
+	require.Len(t, logged, 1)
+	assert.Equal(t, "INFO", logged[0]["level"])
+	assert.NotEmpty(t, rec.Header().Get(RequestIDHeader))
+	assert.Equal(t, rec.Header().Get(RequestIDHeader), logged[0]["request_id"])
+	assert.NotContains(t, logged[0], "trace_id", "there is no trace to correlate with")
+}
+
+func TestMiddlewareQuietPaths(t *testing.T) {
+	buf := captureLogs(t, slog.LevelInfo)
+	handler := Middleware(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {}))
+
+	handler.ServeHTTP(httptest.NewRecorder(), httptest.NewRequest(http.MethodGet, "/metrics", nil))
+	handler.ServeHTTP(httptest.NewRecorder(), httptest.NewRequest(http.MethodGet, "/readyz", nil))
+	assert.Empty(t, buf.String(), "probes and scrapes are only logged at debug level")
+
+	buf = captureLogs(t, slog.LevelDebug)
+	handler.ServeHTTP(httptest.NewRecorder(), httptest.NewRequest(http.MethodGet, "/metrics", nil))
+	logged := lines(t, buf)
+	require.Len(t, logged, 1)
+	assert.Equal(t, "DEBUG", logged[0]["level"])
+}
+
+func TestMiddlewarePanic(t *testing.T) {
+	buf := captureLogs(t, slog.LevelInfo)
+	handler := Middleware(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		Annotate(r.Context(), slog.String("fault", "panic"))
+		panic("boom")
+	}))
+
+	assert.PanicsWithValue(t, "boom", func() {
+		handler.ServeHTTP(httptest.NewRecorder(), httptest.NewRequest(http.MethodGet, "/simulate", nil))
+	}, "the panic is left to the recovery middleware")
+
+	logged := lines(t, buf)
+	require.Len(t, logged, 1)
+	assert.Equal(t, "WARN", logged[0]["level"])
+	assert.Equal(t, float64(500), logged[0]["status"], "the request is logged with the status it is answered with")
+	assert.Equal(t, "panic", logged[0]["fault"])
+}
+
+func TestAnnotateOutsideMiddleware(t *testing.T) {
+	assert.NotPanics(t, func() {
+		Annotate(httptest.NewRequest(http.MethodGet, "/", nil).Context(), slog.String("fault", "fail"))
+	})
+}
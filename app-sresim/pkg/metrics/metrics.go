@@ -1,347 +1,595 @@
+// Package metrics defines sresim's Prometheus metrics and the HTTP
+// middleware that records requests.
 package metrics
 
 import (
 	"context"
 	"net/http"
 	"strconv"
+	"strings"
+	"sync"
 	"time"
 
-	"github.com/gin-gonic/gin"
 	prom "github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/collectors"
 	"github.com/prometheus/client_golang/prometheus/promhttp"
 	"go.opentelemetry.io/otel"
 	otelprom "go.opentelemetry.io/otel/exporters/prometheus"
 	"go.opentelemetry.io/otel/sdk/metric"
+	"go.opentelemetry.io/otel/trace"
+
+	"github.com/localstack/sresim/app-sresim/pkg/response"
 )
 
-var (
-	// HTTP metrics
-	requestDuration = prom.NewHistogramVec(
-		prom.HistogramOpts{
-			Name:    "http_request_duration_seconds",
-			Help:    "Duration of HTTP requests in seconds",
-			Buckets: prom.DefBuckets,
-		},
-		[]string{"handler", "method", "status"},
-	)
+// Metric names, exported so that generated alerting and recording rules
+// reference exactly what is registered here.
+const (
+	RequestDurationName        = "http_request_duration_seconds"
+	RequestsTotalName          = "http_requests_total"
+	ErrorsTotalName            = "http_errors_total"
+	ResponseSizeName           = "http_response_size_bytes"
+	RequestsInFlightName       = "http_requests_in_flight"
+	ActiveScenariosName        = "sresim_active_scenarios"
+	ScenarioDurationName       = "sresim_scenario_duration_seconds"
+	ScenarioErrorsName         = "sresim_scenario_errors_total"
+	CPUUsageName               = "sresim_cpu_usage_percent"
+	MemoryUsageName            = "sresim_memory_usage_bytes"
+	DiskIOName                 = "sresim_disk_io_bytes_total"
+	NetworkLatencyName         = "sresim_network_latency_seconds"
+	NetworkErrorsName          = "sresim_network_errors_total"
+	CircuitBreakerStateName    = "sresim_circuit_breaker_state"
+	CircuitBreakerFailuresName = "sresim_circuit_breaker_failures_total"
+	RateLimitHitsName          = "sresim_rate_limit_hits_total"
+	RateLimitCurrentName       = "sresim_rate_limit_current"
+	SLOObjectiveName           = "sresim_slo_objective_ratio"
+	SLORatioName               = "sresim_slo_sli_ratio"
+	SLOErrorBudgetName         = "sresim_slo_error_budget_remaining_ratio"
+	SLOBurnRateName            = "sresim_slo_burn_rate"
+	TimeToDetectName           = "sresim_time_to_detect_seconds"
+	BuildInfoName              = "sresim_build_info"
+	LogRecordsName             = "sresim_log_records_total"
+	PanicsName                 = "sresim_panics_total"
+)
 
-	requestTotal = prom.NewCounterVec(
-		prom.CounterOpts{
-			Name: "http_requests_total",
-			Help: "Total number of HTTP requests",
-		},
-		[]string{"handler", "method"},
-	)
+// ExemplarTraceIDLabel is the exemplar label latency observations carry the
+// trace ID of their request in. Grafana looks for trace_id by default.
+const ExemplarTraceIDLabel = "trace_id"
 
-	errorTotal = prom.NewCounterVec(
-		prom.CounterOpts{
-			Name: "http_errors_total",
-			Help: "Total number of HTTP errors",
-		},
-		[]string{"handler", "method"},
-	)
+// Other is the handler label of requests that match no route, and the
+// method label of requests with a nonstandard method, so that scanners
+// probing random paths do not create a time series per path.
+const Other = "other"
 
-	// Scenario metrics
-	activeScenarios = prom.NewGaugeVec(
-		prom.GaugeOpts{
-			Name: "sresim_active_scenarios",
-			Help: "Number of currently active simulation scenarios",
-		},
-		[]string{"scenario_type"},
-	)
+// Registry owns the Prometheus registry sresim's metrics are registered
+// with and served from. Every registry has collectors of its own, so tests
+// can use one each and run in parallel.
+type Registry struct {
+	reg *prom.Registry
 
-	scenarioDuration = prom.NewHistogramVec(
-		prom.HistogramOpts{
-			Name:    "sresim_scenario_duration_seconds",
-			Help:    "Duration of simulation scenarios",
-			Buckets: prom.DefBuckets,
-		},
-		[]string{"scenario_type"},
-	)
+	// HTTP metrics
+	requestDuration  *prom.HistogramVec
+	requestTotal     *prom.CounterVec
+	errorTotal       *prom.CounterVec
+	responseSize     *prom.HistogramVec
+	requestsInFlight prom.Gauge
 
-	scenarioErrors = prom.NewCounterVec(
-		prom.CounterOpts{
-			Name: "sresim_scenario_errors_total",
-			Help: "Total number of scenario errors",
-		},
-		[]string{"scenario_type", "error_type"},
-	)
+	// Scenario metrics
+	activeScenarios  *prom.GaugeVec
+	scenarioDuration *prom.HistogramVec
+	scenarioErrors   *prom.CounterVec
 
 	// Resource metrics
-	cpuUsage = prom.NewGaugeVec(
-		prom.GaugeOpts{
-			Name: "sresim_cpu_usage_percent",
-			Help: "CPU usage percentage",
-		},
-		[]string{"scenario_type"},
-	)
-
-	memoryUsage = prom.NewGaugeVec(
-		prom.GaugeOpts{
-			Name: "sresim_memory_usage_bytes",
-			Help: "Memory usage in bytes",
-		},
-		[]string{"scenario_type"},
-	)
-
-	diskIO = prom.NewCounterVec(
-		prom.CounterOpts{
-			Name: "sresim_disk_io_bytes_total",
-			Help: "Total disk I/O operations in bytes",
-		},
-		[]string{"scenario_type", "operation_type"},
-	)
+	cpuUsage    *prom.GaugeVec
+	memoryUsage *prom.GaugeVec
+	diskIO      *prom.CounterVec
 
 	// Network metrics
-	networkLatency = prom.NewHistogramVec(
-		prom.HistogramOpts{
-			Name:    "sresim_network_latency_seconds",
-			Help:    "Network latency in seconds",
-			Buckets: prom.DefBuckets,
-		},
-		[]string{"scenario_type"},
-	)
-
-	networkErrors = prom.NewCounterVec(
-		prom.CounterOpts{
-			Name: "sresim_network_errors_total",
-			Help: "Total number of network errors",
-		},
-		[]string{"scenario_type", "error_type"},
-	)
+	networkLatency *prom.HistogramVec
+	networkErrors  *prom.CounterVec
 
 	// Circuit breaker metrics
-	circuitBreakerState = prom.NewGaugeVec(
-		prom.GaugeOpts{
-			Name: "sresim_circuit_breaker_state",
-			Help: "Current state of circuit breaker (0: closed, 1: open, 2: half-open)",
-		},
-		[]string{"scenario_type"},
-	)
-
-	circuitBreakerFailures = prom.NewCounterVec(
-		prom.CounterOpts{
-			Name: "sresim_circuit_breaker_failures_total",
-			Help: "Total number of circuit breaker failures",
-		},
-		[]string{"scenario_type"},
-	)
+	circuitBreakerState    *prom.GaugeVec
+	circuitBreakerFailures *prom.CounterVec
 
 	// Rate limiting metrics
-	rateLimitHits = prom.NewCounterVec(
-		prom.CounterOpts{
-			Name: "sresim_rate_limit_hits_total",
-			Help: "Total number of rate limit hits",
-		},
-		[]string{"scenario_type"},
-	)
+	rateLimitHits    *prom.CounterVec
+	rateLimitCurrent *prom.GaugeVec
+
+	// SLO metrics
+	sloObjective   *prom.GaugeVec
+	sloRatio       *prom.GaugeVec
+	sloErrorBudget *prom.GaugeVec
+	sloBurnRate    *prom.GaugeVec
+
+	// Detection, build, logging and panic metrics
+	timeToDetect *prom.HistogramVec
+	buildInfo    *prom.GaugeVec
+	logRecords   *prom.CounterVec
+	panics       prom.Counter
+
+	observersMu sync.RWMutex
+	observers   []RequestObserver
+}
 
-	rateLimitCurrent = prom.NewGaugeVec(
-		prom.GaugeOpts{
-			Name: "sresim_rate_limit_current",
-			Help: "Current rate limit value",
-		},
-		[]string{"scenario_type"},
+// NewRegistry returns a registry with sresim's metrics and the Go runtime and
+// process collectors registered.
+func NewRegistry() *Registry {
+	m := &Registry{
+		reg: prom.NewRegistry(),
+
+		// HTTP metrics
+		requestDuration: prom.NewHistogramVec(
+			prom.HistogramOpts{
+				Name:    RequestDurationName,
+				Help:    "Duration of HTTP requests in seconds",
+				Buckets: prom.DefBuckets,
+			},
+			[]string{"handler", "method", "status"},
+		),
+
+		requestTotal: prom.NewCounterVec(
+			prom.CounterOpts{
+				Name: RequestsTotalName,
+				Help: "Total number of HTTP requests",
+			},
+			[]string{"handler", "method", "status"},
+		),
+
+		errorTotal: prom.NewCounterVec(
+			prom.CounterOpts{
+				Name: ErrorsTotalName,
+				Help: "Total number of HTTP errors",
+			},
+			[]string{"handler", "method"},
+		),
+
+		responseSize: prom.NewHistogramVec(
+			prom.HistogramOpts{
+				Name:    ResponseSizeName,
+				Help:    "Size of HTTP responses in bytes",
+				Buckets: prom.ExponentialBuckets(100, 10, 6),
+			},
+			[]string{"handler", "method"},
+		),
+
+		requestsInFlight: prom.NewGauge(
+			prom.GaugeOpts{
+				Name: RequestsInFlightName,
+				Help: "Number of HTTP requests being served",
+			},
+		),
+
+		// Scenario metrics
+		activeScenarios: prom.NewGaugeVec(
+			prom.GaugeOpts{
+				Name: ActiveScenariosName,
+				Help: "Number of currently active simulation scenarios",
+			},
+			[]string{"scenario_type"},
+		),
+
+		scenarioDuration: prom.NewHistogramVec(
+			prom.HistogramOpts{
+				Name:    ScenarioDurationName,
+				Help:    "Duration of simulation scenarios",
+				Buckets: prom.DefBuckets,
+			},
+			[]string{"scenario_type"},
+		),
+
+		scenarioErrors: prom.NewCounterVec(
+			prom.CounterOpts{
+				Name: ScenarioErrorsName,
+				Help: "Total number of scenario errors",
+			},
+			[]string{"scenario_type", "error_type"},
+		),
+
+		// Resource metrics
+		cpuUsage: prom.NewGaugeVec(
+			prom.GaugeOpts{
+				Name: CPUUsageName,
+				Help: "CPU usage percentage",
+			},
+			[]string{"scenario_type"},
+		),
+
+		memoryUsage: prom.NewGaugeVec(
+			prom.GaugeOpts{
+				Name: MemoryUsageName,
+				Help: "Memory usage in bytes",
+			},
+			[]string{"scenario_type"},
+		),
+
+		diskIO: prom.NewCounterVec(
+			prom.CounterOpts{
+				Name: DiskIOName,
+				Help: "Total disk I/O operations in bytes",
+			},
+			[]string{"scenario_type", "operation_type"},
+		),
+
+		// Network metrics
+		networkLatency: prom.NewHistogramVec(
+			prom.HistogramOpts{
+				Name:    NetworkLatencyName,
+				Help:    "Network latency in seconds",
+				Buckets: prom.DefBuckets,
+			},
+			[]string{"scenario_type"},
+		),
+
+		networkErrors: prom.NewCounterVec(
+			prom.CounterOpts{
+				Name: NetworkErrorsName,
+				Help: "Total number of network errors",
+			},
+			[]string{"scenario_type", "error_type"},
+		),
+
+		// Circuit breaker metrics
+		circuitBreakerState: prom.NewGaugeVec(
+			prom.GaugeOpts{
+				Name: CircuitBreakerStateName,
+				Help: "Current state of circuit breaker (0: closed, 1: open, 2: half-open)",
+			},
+			[]string{"scenario_type"},
+		),
+
+		circuitBreakerFailures: prom.NewCounterVec(
+			prom.CounterOpts{
+				Name: CircuitBreakerFailuresName,
+				Help: "Total number of circuit breaker failures",
+			},
+			[]string{"scenario_type"},
+		),
+
+		// Rate limiting metrics
+		rateLimitHits: prom.NewCounterVec(
+			prom.CounterOpts{
+				Name: RateLimitHitsName,
+				Help: "Total number of rate limit hits",
+			},
+			[]string{"scenario_type"},
+		),
+
+		rateLimitCurrent: prom.NewGaugeVec(
+			prom.GaugeOpts{
+				Name: RateLimitCurrentName,
+				Help: "Current rate limit value",
+			},
+			[]string{"scenario_type"},
+		),
+
+		// SLO metrics
+		sloObjective: prom.NewGaugeVec(
+			prom.GaugeOpts{
+				Name: SLOObjectiveName,
+				Help: "Target ratio of good events for the SLO",
+			},
+			[]string{"slo", "sli"},
+		),
+
+		sloRatio: prom.NewGaugeVec(
+			prom.GaugeOpts{
+				Name: SLORatioName,
+				Help: "Observed ratio of good events since the error budget period started",
+			},
+			[]string{"slo", "sli"},
+		),
+
+		sloErrorBudget: prom.NewGaugeVec(
+			prom.GaugeOpts{
+				Name: SLOErrorBudgetName,
+				Help: "Share of the error budget that is left (negative when overspent)",
+			},
+			[]string{"slo", "sli"},
+		),
+
+		sloBurnRate: prom.NewGaugeVec(
+			prom.GaugeOpts{
+				Name: SLOBurnRateName,
+				Help: "Rate at which the error budget is consumed over the window (1 = exactly on budget)",
+			},
+			[]string{"slo", "sli", "window"},
+		),
+
+		timeToDetect: prom.NewHistogramVec(
+			prom.HistogramOpts{
+				Name:    TimeToDetectName,
+				Help:    "Time from the start of a scenario run until the first alert correlated with it fired",
+				Buckets: []float64{15, 30, 60, 120, 300, 600, 900, 1800, 3600},
+			},
+			[]string{"scenario"},
+		),
+
+		buildInfo: prom.NewGaugeVec(
+			prom.GaugeOpts{
+				Name: BuildInfoName,
+				Help: "Build information of the running binary, always 1",
+			},
+			[]string{"version", "commit", "build_date", "go_version"},
+		),
+
+		logRecords: prom.NewCounterVec(
+			prom.CounterOpts{
+				Name: LogRecordsName,
+				Help: "Total number of log records written, by level",
+			},
+			[]string{"level"},
+		),
+
+		panics: prom.NewCounter(
+			prom.CounterOpts{
+				Name: PanicsName,
+				Help: "Total number of panics recovered from while serving HTTP requests",
+			},
+		),
+	}
+	m.reg.MustRegister(
+		collectors.NewGoCollector(),
+		collectors.NewProcessCollector(collectors.ProcessCollectorOpts{}),
+		m.requestDuration,
+		m.requestTotal,
+		m.errorTotal,
+		m.responseSize,
+		m.requestsInFlight,
+		m.activeScenarios,
+		m.scenarioDuration,
+		m.scenarioErrors,
+		m.cpuUsage,
+		m.memoryUsage,
+		m.diskIO,
+		m.networkLatency,
+		m.networkErrors,
+		m.circuitBreakerState,
+		m.circuitBreakerFailures,
+		m.rateLimitHits,
+		m.rateLimitCurrent,
+		m.sloObjective,
+		m.sloRatio,
+		m.sloErrorBudget,
+		m.sloBurnRate,
+		m.timeToDetect,
+		m.buildInfo,
+		m.logRecords,
+		m.panics,
 	)
-)
+	return m
+}
+
+// Registerer returns the registry to register further collectors with.
+func (m *Registry) Registerer() prom.Registerer {
+	return m.reg
+}
+
+// Gatherer returns the registry to read the metrics from.
+func (m *Registry) Gatherer() prom.Gatherer {
+	return m.reg
+}
 
-func init() {
-	// Register all metrics
-	prom.MustRegister(requestDuration)
-	prom.MustRegister(requestTotal)
-	prom.MustRegister(errorTotal)
-	prom.MustRegister(activeScenarios)
-	prom.MustRegister(scenarioDuration)
-	prom.MustRegister(scenarioErrors)
-	prom.MustRegister(cpuUsage)
-	prom.MustRegister(memoryUsage)
-	prom.MustRegister(diskIO)
-	prom.MustRegister(networkLatency)
-	prom.MustRegister(networkErrors)
-	prom.MustRegister(circuitBreakerState)
-	prom.MustRegister(circuitBreakerFailures)
-	prom.MustRegister(rateLimitHits)
-	prom.MustRegister(rateLimitCurrent)
-}
-
-// Init initializes all metrics
-func Init() error {
-	// Register all metrics
-	prom.MustRegister(requestDuration)
-	prom.MustRegister(requestTotal)
-	prom.MustRegister(errorTotal)
-	prom.MustRegister(activeScenarios)
-	prom.MustRegister(scenarioDuration)
-	prom.MustRegister(scenarioErrors)
-	prom.MustRegister(cpuUsage)
-	prom.MustRegister(memoryUsage)
-	prom.MustRegister(diskIO)
-	prom.MustRegister(networkLatency)
-	prom.MustRegister(networkErrors)
-	prom.MustRegister(circuitBreakerState)
-	prom.MustRegister(circuitBreakerFailures)
-	prom.MustRegister(rateLimitHits)
-	prom.MustRegister(rateLimitCurrent)
-
-	// Initialize OpenTelemetry metrics
-	return InitMetrics()
-}
-
-// InitMetrics initializes Prometheus and OpenTelemetry metrics
-func InitMetrics() error {
-	// Initialize Prometheus exporter
-	exporter, err := otelprom.New()
+// BridgeOTel installs a global OpenTelemetry meter provider that exports to
+// the registry, so that metrics recorded with OpenTelemetry are served on
+// /metrics too. Call it once per process.
+func (m *Registry) BridgeOTel() error {
+	exporter, err := otelprom.New(otelprom.WithRegisterer(m.reg))
 	if err != nil {
 		return err
 	}
+	otel.SetMeterProvider(metric.NewMeterProvider(metric.WithReader(exporter)))
+	return nil
+}
 
-	// Create metric provider
-	provider := metric.NewMeterProvider(
-		metric.WithReader(exporter),
-	)
-	otel.SetMeterProvider(provider)
+// Middleware returns middleware that wraps HTTP handlers with metrics
+// collection. Requests are labelled with the pattern of the route they
+// match in routes, e.g. /experiments/{id}/report, and with Other if they
+// match none, so the middleware can sit outside others that answer
+// requests themselves.
+func (m *Registry) Middleware(routes Router) func(http.Handler) http.Handler {
+	return func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			start := time.Now()
+			m.requestsInFlight.Inc()
+			defer m.requestsInFlight.Dec()
+
+			// Create a response writer that captures the status code
+			wrapped := response.Wrap(w)
+			defer func() {
+				// Count a request whose handler panicked as the 500 it is
+				// answered with further out, and let the panic go on.
+				if p := recover(); p != nil {
+					status := wrapped.Status()
+					if !wrapped.WroteHeader() {
+						status = http.StatusInternalServerError
+					}
+					m.record(routes, r, status, wrapped.Size(), start)
+					panic(p)
+				}
+			}()
+			next.ServeHTTP(wrapped, r)
+			m.record(routes, r, wrapped.Status(), wrapped.Size(), start)
+		})
+	}
+}
 
-	return nil
+// record records the metrics of a served request.
+func (m *Registry) record(routes Router, r *http.Request, statusCode, size int, start time.Time) {
+	handler, method, status := Route(routes, r), methodLabel(r.Method), strconv.Itoa(statusCode)
+	duration := time.Since(start).Seconds()
+	observe(r.Context(), m.requestDuration.WithLabelValues(handler, method, status), duration)
+	m.requestTotal.WithLabelValues(handler, method, status).Inc()
+	m.responseSize.WithLabelValues(handler, method).Observe(float64(size))
+
+	if statusCode >= 400 {
+		m.errorTotal.WithLabelValues(handler, method).Inc()
+	}
+	m.notifyObservers(handler, statusCode, time.Since(start))
 }
 
-// MetricsMiddleware wraps HTTP handlers with metrics collection
-func MetricsMiddleware(next http.Handler) http.Handler {
-	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
-		start := time.Now()
+// Router finds the handler of a request and the pattern it was registered
+// with, like *http.ServeMux does.
+type Router interface {
+	Handler(r *http.Request) (h http.Handler, pattern string)
+}
 
-		// Create a response writer that captures the status code
-		wrapped := wrapResponseWriter(w)
-		next.ServeHTTP(wrapped, r)
+// Route returns the path of the pattern r matches in routes, or Other if it
+// matches none.
+func Route(routes Router, r *http.Request) string {
+	_, pattern := routes.Handler(r)
+	if pattern == "" {
+		return Other
+	}
+	// Drop the method and host of patterns like "GET example.com/x".
+	if i := strings.Index(pattern, "/"); i > 0 {
+		pattern = pattern[i:]
+	}
+	return pattern
+}
 
-		// Record metrics
-		duration := time.Since(start).Seconds()
-		requestDuration.WithLabelValues(r.URL.Path, r.Method, wrapped.status).Observe(duration)
-		requestTotal.WithLabelValues(r.URL.Path, r.Method).Inc()
+// methodLabel returns method if it is a standard HTTP method, and Other
+// otherwise.
+func methodLabel(method string) string {
+	switch method {
+	case http.MethodGet, http.MethodHead, http.MethodPost, http.MethodPut, http.MethodPatch,
+		http.MethodDelete, http.MethodConnect, http.MethodOptions, http.MethodTrace:
+		return method
+	}
+	return Other
+}
 
-		statusCode, _ := strconv.Atoi(wrapped.status)
-		if statusCode >= 400 {
-			errorTotal.WithLabelValues(r.URL.Path, r.Method).Inc()
+// observe records v with the trace ID of ctx as exemplar, if ctx belongs to
+// a sampled trace. Unsampled traces are never exported, so there would be no
+// trace to jump to.
+func observe(ctx context.Context, o prom.Observer, v float64) {
+	if sc := trace.SpanContextFromContext(ctx); sc.IsSampled() {
+		if eo, ok := o.(prom.ExemplarObserver); ok {
+			eo.ObserveWithExemplar(v, prom.Labels{ExemplarTraceIDLabel: sc.TraceID().String()})
+			return
 		}
-	})
+	}
+	o.Observe(v)
 }
 
-// responseWriter is a minimal wrapper for http.ResponseWriter that allows us to track the status code
-type responseWriter struct {
-	http.ResponseWriter
-	status string
+// RequestObserver is called for every request recorded by the HTTP metrics
+// middleware.
+type RequestObserver func(handler string, status int, duration time.Duration)
+
+// AddRequestObserver registers fn to receive every request recorded by the
+// HTTP metrics middleware
+func (m *Registry) AddRequestObserver(fn RequestObserver) {
+	m.observersMu.Lock()
+	defer m.observersMu.Unlock()
+	m.observers = append(m.observers, fn)
 }
 
-func wrapResponseWriter(w http.ResponseWriter) *responseWriter {
-	return &responseWriter{ResponseWriter: w, status: "200"}
+func (m *Registry) notifyObservers(handler string, status int, duration time.Duration) {
+	m.observersMu.RLock()
+	defer m.observersMu.RUnlock()
+	for _, fn := range m.observers {
+		fn(handler, status, duration)
+	}
 }
 
-func (rw *responseWriter) WriteHeader(code int) {
-	rw.status = strconv.Itoa(code)
-	rw.ResponseWriter.WriteHeader(code)
+// Check gathers every registered metric, failing if a collector is broken
+// and /metrics would fail to render.
+func (m *Registry) Check(ctx context.Context) error {
+	_, err := m.reg.Gather()
+	return err
 }
 
-// MetricsHandler returns a handler for the /metrics endpoint
-func MetricsHandler() http.Handler {
-	return promhttp.Handler()
+// Handler returns a handler for the /metrics endpoint. It serves the
+// OpenMetrics format, which carries exemplars, to scrapers that ask for it.
+func (m *Registry) Handler() http.Handler {
+	return promhttp.InstrumentMetricHandler(m.reg,
+		promhttp.HandlerFor(m.reg, promhttp.HandlerOpts{EnableOpenMetrics: true}))
 }
 
 // ScenarioMetrics provides methods to update scenario-specific metrics
 type ScenarioMetrics struct {
+	m            *Registry
 	scenarioType string
 }
 
-// NewScenarioMetrics creates a new ScenarioMetrics instance
-func NewScenarioMetrics(scenarioType string) *ScenarioMetrics {
-	return &ScenarioMetrics{scenarioType: scenarioType}
+// Scenario returns the metrics of a scenario
+func (m *Registry) Scenario(scenarioType string) *ScenarioMetrics {
+	return &ScenarioMetrics{m: m, scenarioType: scenarioType}
 }
 
 // SetActive updates the active scenarios gauge
 func (sm *ScenarioMetrics) SetActive(active bool) {
 	if active {
-		activeScenarios.WithLabelValues(sm.scenarioType).Set(1)
+		sm.m.activeScenarios.WithLabelValues(sm.scenarioType).Set(1)
 	} else {
-		activeScenarios.WithLabelValues(sm.scenarioType).Set(0)
+		sm.m.activeScenarios.WithLabelValues(sm.scenarioType).Set(0)
 	}
 }
 
 // RecordDuration records the duration of a scenario
 func (sm *ScenarioMetrics) RecordDuration(duration time.Duration) {
-	scenarioDuration.WithLabelValues(sm.scenarioType).Observe(duration.Seconds())
+	sm.m.scenarioDuration.WithLabelValues(sm.scenarioType).Observe(duration.Seconds())
 }
 
 // RecordError records a scenario error
 func (sm *ScenarioMetrics) RecordError(errorType string) {
-	scenarioErrors.WithLabelValues(sm.scenarioType, errorType).Inc()
+	sm.m.scenarioErrors.WithLabelValues(sm.scenarioType, errorType).Inc()
 }
 
 // UpdateCPUUsage updates the CPU usage metric
 func (sm *ScenarioMetrics) UpdateCPUUsage(percentage float64) {
-	cpuUsage.WithLabelValues(sm.scenarioType).Set(percentage)
+	sm.m.cpuUsage.WithLabelValues(sm.scenarioType).Set(percentage)
 }
 
 // UpdateMemoryUsage updates the memory usage metric
 func (sm *ScenarioMetrics) UpdateMemoryUsage(bytes int64) {
-	memoryUsage.WithLabelValues(sm.scenarioType).Set(float64(bytes))
+	sm.m.memoryUsage.WithLabelValues(sm.scenarioType).Set(float64(bytes))
 }
 
 // RecordDiskIO records disk I/O operations
 func (sm *ScenarioMetrics) RecordDiskIO(bytes int64, operationType string) {
-	diskIO.WithLabelValues(sm.scenarioType, operationType).Add(float64(bytes))
+	sm.m.diskIO.WithLabelValues(sm.scenarioType, operationType).Add(float64(bytes))
 }
 
-// RecordNetworkLatency records network latency
-func (sm *ScenarioMetrics) RecordNetworkLatency(duration time.Duration) {
-	networkLatency.WithLabelValues(sm.scenarioType).Observe(duration.Seconds())
+// RecordNetworkLatency records network latency, with the trace ID of ctx as
+// exemplar
+func (sm *ScenarioMetrics) RecordNetworkLatency(ctx context.Context, duration time.Duration) {
+	observe(ctx, sm.m.networkLatency.WithLabelValues(sm.scenarioType), duration.Seconds())
 }
 
 // RecordNetworkError records a network error
 func (sm *ScenarioMetrics) RecordNetworkError(errorType string) {
-	networkErrors.WithLabelValues(sm.scenarioType, errorType).Inc()
+	sm.m.networkErrors.WithLabelValues(sm.scenarioType, errorType).Inc()
 }
 
 // UpdateCircuitBreakerState updates the circuit breaker state
 func (sm *ScenarioMetrics) UpdateCircuitBreakerState(state int) {
-	circuitBreakerState.WithLabelValues(sm.scenarioType).Set(float64(state))
+	sm.m.circuitBreakerState.WithLabelValues(sm.scenarioType).Set(float64(state))
 }
 
 // RecordCircuitBreakerFailure records a circuit breaker failure
 func (sm *ScenarioMetrics) RecordCircuitBreakerFailure() {
-	circuitBreakerFailures.WithLabelValues(sm.scenarioType).Inc()
+	sm.m.circuitBreakerFailures.WithLabelValues(sm.scenarioType).Inc()
 }
 
 // RecordRateLimitHit records a rate limit hit
 func (sm *ScenarioMetrics) RecordRateLimitHit() {
-	rateLimitHits.WithLabelValues(sm.scenarioType).Inc()
+	sm.m.rateLimitHits.WithLabelValues(sm.scenarioType).Inc()
 }
 
 // UpdateRateLimit updates the current rate limit
 func (sm *ScenarioMetrics) UpdateRateLimit(limit float64) {
-	rateLimitCurrent.WithLabelValues(sm.scenarioType).Set(limit)
-}
-
-// HTTPMetricsMiddleware returns a Gin middleware for HTTP metrics
-func HTTPMetricsMiddleware() gin.HandlerFunc {
-	return func(c *gin.Context) {
-		start := time.Now()
-		c.Next()
-		duration := time.Since(start).Seconds()
-		requestDuration.WithLabelValues(c.Request.URL.Path, c.Request.Method, strconv.Itoa(c.Writer.Status())).Observe(duration)
-		requestTotal.WithLabelValues(c.Request.URL.Path, c.Request.Method).Inc()
-		if c.Writer.Status() >= 400 {
-			errorTotal.WithLabelValues(c.Request.URL.Path, c.Request.Method).Inc()
-		}
-	}
+	sm.m.rateLimitCurrent.WithLabelValues(sm.scenarioType).Set(limit)
 }
 
 // UpdateResourceMetrics updates CPU, memory, and disk I/O metrics
-func UpdateResourceMetrics(cpuBytes, memoryBytes, diskBytes int64) {
-	cpuUsage.WithLabelValues("").Set(float64(cpuBytes))
-	memoryUsage.WithLabelValues("").Set(float64(memoryBytes))
-	diskIO.WithLabelValues("", "read").Add(float64(diskBytes))
+func (m *Registry) UpdateResourceMetrics(cpuBytes, memoryBytes, diskBytes int64) {
+	m.cpuUsage.WithLabelValues("").Set(float64(cpuBytes))
+	m.memoryUsage.WithLabelValues("").Set(float64(memoryBytes))
+	m.diskIO.WithLabelValues("", "read").Add(float64(diskBytes))
 }
 
 // UpdateCircuitBreakerState updates the circuit breaker state metric
-func UpdateCircuitBreakerState(state string) {
+func (m *Registry) UpdateCircuitBreakerState(state string) {
 	value := 0
 	switch state {
 	case "open":
@@ -349,18 +597,53 @@ func UpdateCircuitBreakerState(state string) {
 	case "half-open":
 		value = 2
 	}
-	circuitBreakerState.WithLabelValues("").Set(float64(value))
+	m.circuitBreakerState.WithLabelValues("").Set(float64(value))
 }
 
 // UpdateCircuitBreakerFailures increments the circuit breaker failures counter
-func UpdateCircuitBreakerFailures() {
-	circuitBreakerFailures.WithLabelValues("").Inc()
+func (m *Registry) UpdateCircuitBreakerFailures() {
+	m.circuitBreakerFailures.WithLabelValues("").Inc()
 }
 
 // UpdateRateLimitMetrics updates rate limit metrics
-func UpdateRateLimitMetrics(hits, current int64) {
-	rateLimitHits.WithLabelValues("").Add(float64(hits))
-	rateLimitCurrent.WithLabelValues("").Set(float64(current))
+func (m *Registry) UpdateRateLimitMetrics(hits, current int64) {
+	m.rateLimitHits.WithLabelValues("").Add(float64(hits))
+	m.rateLimitCurrent.WithLabelValues("").Set(float64(current))
+}
+
+// UpdateSLO sets the objective, SLI ratio and remaining error budget gauges
+// for one SLI of an SLO
+func (m *Registry) UpdateSLO(slo, sli string, objective, ratio, budgetRemaining float64) {
+	m.sloObjective.WithLabelValues(slo, sli).Set(objective)
+	m.sloRatio.WithLabelValues(slo, sli).Set(ratio)
+	m.sloErrorBudget.WithLabelValues(slo, sli).Set(budgetRemaining)
+}
+
+// UpdateSLOBurnRate sets the burn rate gauge for one SLI over a window
+func (m *Registry) UpdateSLOBurnRate(slo, sli, window string, rate float64) {
+	m.sloBurnRate.WithLabelValues(slo, sli, window).Set(rate)
+}
+
+// ObserveTimeToDetect records how long it took for a scenario run to be
+// detected by an alert
+func (m *Registry) ObserveTimeToDetect(scenario string, d time.Duration) {
+	m.timeToDetect.WithLabelValues(scenario).Observe(d.Seconds())
+}
+
+// CountLogRecord counts a log record written at level
+func (m *Registry) CountLogRecord(level string) {
+	m.logRecords.WithLabelValues(level).Inc()
+}
+
+// CountPanic counts a panic recovered from while serving a request
+func (m *Registry) CountPanic() {
+	m.panics.Inc()
+}
+
+// SetBuildInfo publishes the build of the running binary
+func (m *Registry) SetBuildInfo(version, commit, buildDate, goVersion string) {
+	m.buildInfo.Reset()
+	m.buildInfo.WithLabelValues(version, commit, buildDate, goVersion).Set(1)
 }
 
 // MetricsContextKey is the key used to store metrics context in context.Context
@@ -370,21 +653,22 @@ var metricsContextKey = MetricsContextKey{}
 
 // MetricsContext holds metrics-related data
 type MetricsContext struct {
+	m        *Registry
 	scenario *ScenarioMetrics
 }
 
 // WithMetricsContext adds metrics context to the given context
-func WithMetricsContext(ctx context.Context) context.Context {
-	return context.WithValue(ctx, metricsContextKey, &MetricsContext{})
+func (m *Registry) WithMetricsContext(ctx context.Context) context.Context {
+	return context.WithValue(ctx, metricsContextKey, &MetricsContext{m: m})
 }
 
 // TrackScenario creates a new scenario metrics tracker
 func (mc *MetricsContext) TrackScenario(name string) *ScenarioMetrics {
-	mc.scenario = NewScenarioMetrics(name)
+	mc.scenario = mc.m.Scenario(name)
 	return mc.scenario
 }
 
 // TrackResources updates resource metrics
 func (mc *MetricsContext) TrackResources(cpuBytes, memoryBytes, diskBytes int64) {
-	UpdateResourceMetrics(cpuBytes, memoryBytes, diskBytes)
+	mc.m.UpdateResourceMetrics(cpuBytes, memoryBytes, diskBytes)
 }
@@ -2,89 +2,159 @@ package metrics
 
 import (
 	"context"
+	"io"
+	"net/http"
 	"net/http/httptest"
+	"strings"
 	"testing"
 	"time"
 
-	"github.com/gin-gonic/gin"
 	"github.com/prometheus/client_golang/prometheus"
 	"github.com/prometheus/client_golang/prometheus/testutil"
+	dto "github.com/prometheus/client_model/go"
 	"github.com/stretchr/testify/assert"
 	"github.com/stretchr/testify/require"
+	"go.opentelemetry.io/otel/trace"
 )
 
-func resetMetrics() {
-	prometheus.DefaultRegisterer.Unregister(requestDuration)
-	prometheus.DefaultRegisterer.Unregister(requestTotal)
-	prometheus.DefaultRegisterer.Unregister(errorTotal)
-	prometheus.DefaultRegisterer.Unregister(activeScenarios)
-	prometheus.DefaultRegisterer.Unregister(scenarioDuration)
-	prometheus.DefaultRegisterer.Unregister(scenarioErrors)
-	prometheus.DefaultRegisterer.Unregister(cpuUsage)
-	prometheus.DefaultRegisterer.Unregister(memoryUsage)
-	prometheus.DefaultRegisterer.Unregister(diskIO)
-	prometheus.DefaultRegisterer.Unregister(networkLatency)
-	prometheus.DefaultRegisterer.Unregister(networkErrors)
-	prometheus.DefaultRegisterer.Unregister(circuitBreakerState)
-	prometheus.DefaultRegisterer.Unregister(circuitBreakerFailures)
-	prometheus.DefaultRegisterer.Unregister(rateLimitHits)
-	prometheus.DefaultRegisterer.Unregister(rateLimitCurrent)
-}
-
-func TestMetricsInitialization(t *testing.T) {
-	resetMetrics()
-
-	// Test initialization
-	err := Init()
-	require.NoError(t, err)
+func TestNewRegistry(t *testing.T) {
+	t.Parallel()
+	m := NewRegistry()
 
 	// Verify metrics are registered
 	metrics := []prometheus.Collector{
-		requestDuration,
-		requestTotal,
-		errorTotal,
-		activeScenarios,
-		scenarioDuration,
-		scenarioErrors,
-		cpuUsage,
-		memoryUsage,
-		diskIO,
-		networkLatency,
-		networkErrors,
-		circuitBreakerState,
-		circuitBreakerFailures,
-		rateLimitHits,
-		rateLimitCurrent,
+		m.requestDuration,
+		m.requestTotal,
+		m.errorTotal,
+		m.responseSize,
+		m.requestsInFlight,
+		m.activeScenarios,
+		m.scenarioDuration,
+		m.scenarioErrors,
+		m.cpuUsage,
+		m.memoryUsage,
+		m.diskIO,
+		m.networkLatency,
+		m.networkErrors,
+		m.circuitBreakerState,
+		m.circuitBreakerFailures,
+		m.rateLimitHits,
+		m.rateLimitCurrent,
+		m.sloObjective,
+		m.sloRatio,
+		m.sloErrorBudget,
+		m.sloBurnRate,
+		m.timeToDetect,
+		m.buildInfo,
+		m.logRecords,
 	}
 
-	for _, m := range metrics {
-		err := prometheus.Register(m)
+	for _, c := range metrics {
+		err := m.Registerer().Register(c)
 		assert.Error(t, err, "Metric should already be registered")
 	}
+
+	// The Go runtime and process collectors are registered too
+	families, err := m.Gatherer().Gather()
+	require.NoError(t, err)
+	names := make(map[string]bool)
+	for _, mf := range families {
+		names[mf.GetName()] = true
+	}
+	assert.True(t, names["go_goroutines"])
+	assert.NoError(t, m.Check(context.Background()))
+
+	// Registries do not share collectors
+	m.CountLogRecord("error")
+	assert.Equal(t, float64(0), testutil.ToFloat64(NewRegistry().logRecords.WithLabelValues("error")))
 }
 
 func TestHTTPMetricsMiddleware(t *testing.T) {
-	resetMetrics()
+	t.Parallel()
+	m := NewRegistry()
+
+	mux := http.NewServeMux()
+	mux.HandleFunc("GET /test/{id}", func(w http.ResponseWriter, r *http.Request) {})
+	// Requests answered before they reach the router are labelled with the
+	// route they would have matched.
+	failing := func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			if r.URL.Query().Has("fail") {
+				http.Error(w, "Simulated failure", http.StatusInternalServerError)
+				return
+			}
+			next.ServeHTTP(w, r)
+		})
+	}
+	handler := m.Middleware(mux)(failing(mux))
+
+	handler.ServeHTTP(httptest.NewRecorder(), httptest.NewRequest("GET", "/test/1", nil))
+	handler.ServeHTTP(httptest.NewRecorder(), httptest.NewRequest("GET", "/test/2?fail", nil))
+	handler.ServeHTTP(httptest.NewRecorder(), httptest.NewRequest("GET", "/unknown", nil))
+	handler.ServeHTTP(httptest.NewRecorder(), httptest.NewRequest("DELETE", "/test/1", nil))
 
-	// Setup test server
-	gin.SetMode(gin.TestMode)
-	w := httptest.NewRecorder()
-	c, _ := gin.CreateTestContext(w)
-	c.Request = httptest.NewRequest("GET", "/test", nil)
+	assert.Equal(t, float64(1), testutil.ToFloat64(m.requestTotal.WithLabelValues("/test/{id}", "GET", "200")))
+	assert.Equal(t, float64(1), testutil.ToFloat64(m.requestTotal.WithLabelValues("/test/{id}", "GET", "500")))
+	assert.Equal(t, float64(1), testutil.ToFloat64(m.requestTotal.WithLabelValues(Other, "GET", "404")))
+	assert.Equal(t, float64(1), testutil.ToFloat64(m.requestTotal.WithLabelValues(Other, "DELETE", "405")))
+}
 
-	// Test middleware
-	handler := HTTPMetricsMiddleware()
-	handler(c)
+func TestMetricsMiddlewareRoutes(t *testing.T) {
+	t.Parallel()
+	m := NewRegistry()
+
+	mux := http.NewServeMux()
+	mux.HandleFunc("/routes", func(w http.ResponseWriter, r *http.Request) {
+		assert.Equal(t, float64(1), testutil.ToFloat64(m.requestsInFlight), "the request is in flight")
+		w.Write([]byte("hello"))
+	})
+	mux.HandleFunc("GET /routes/{id}/report", func(w http.ResponseWriter, r *http.Request) {})
+	handler := m.Middleware(mux)(mux)
+
+	for _, req := range []*http.Request{
+		httptest.NewRequest("GET", "/routes", nil),
+		httptest.NewRequest("GET", "/routes/1/report", nil),
+		httptest.NewRequest("GET", "/routes/2/report", nil),
+		httptest.NewRequest("POST", "/wp-admin/setup.php", nil),
+		httptest.NewRequest("POST", "/.env", nil),
+		httptest.NewRequest("PROPFIND", "/routes", nil),
+	} {
+		handler.ServeHTTP(httptest.NewRecorder(), req)
+	}
 
-	// Verify metrics were recorded
-	assert.Equal(t, float64(1), testutil.ToFloat64(requestTotal.WithLabelValues("/test", "GET")))
+	assert.Equal(t, float64(1), testutil.ToFloat64(m.requestTotal.WithLabelValues("/routes", "GET", "200")))
+	assert.Equal(t, float64(2), testutil.ToFloat64(m.requestTotal.WithLabelValues("/routes/{id}/report", "GET", "200")),
+		"requests are labelled with the route pattern, not the path")
+	assert.Equal(t, float64(2), testutil.ToFloat64(m.requestTotal.WithLabelValues(Other, "POST", "404")),
+		"unknown paths share one series")
+	assert.Equal(t, float64(1), testutil.ToFloat64(m.requestTotal.WithLabelValues("/routes", Other, "200")),
+		"nonstandard methods share one series")
+	assert.Equal(t, float64(0), testutil.ToFloat64(m.requestsInFlight))
+
+	m.responseSize.Reset()
+	handler.ServeHTTP(httptest.NewRecorder(), httptest.NewRequest("GET", "/routes", nil))
+	expected := `
+# HELP http_response_size_bytes Size of HTTP responses in bytes
+# TYPE http_response_size_bytes histogram
+http_response_size_bytes_bucket{handler="/routes",method="GET",le="100"} 1
+http_response_size_bytes_bucket{handler="/routes",method="GET",le="1000"} 1
+http_response_size_bytes_bucket{handler="/routes",method="GET",le="10000"} 1
+http_response_size_bytes_bucket{handler="/routes",method="GET",le="100000"} 1
+http_response_size_bytes_bucket{handler="/routes",method="GET",le="1e+06"} 1
+http_response_size_bytes_bucket{handler="/routes",method="GET",le="1e+07"} 1
+http_response_size_bytes_bucket{handler="/routes",method="GET",le="+Inf"} 1
+http_response_size_bytes_sum{handler="/routes",method="GET"} 5
+http_response_size_bytes_count{handler="/routes",method="GET"} 1
+`
+	assert.NoError(t, testutil.CollectAndCompare(m.responseSize, strings.NewReader(expected)))
 }
 
 func TestScenarioMetrics(t *testing.T) {
-	resetMetrics()
+	t.Parallel()
+	m := NewRegistry()
 
 	// Create test scenario
-	scenario := NewScenarioMetrics("test-scenario")
+	scenario := m.Scenario("test-scenario")
 
 	// Test scenario lifecycle
 	scenario.SetActive(true)
@@ -93,60 +163,272 @@ func TestScenarioMetrics(t *testing.T) {
 	scenario.RecordDuration(100 * time.Millisecond)
 
 	// Verify metrics
-	assert.Equal(t, float64(0), testutil.ToFloat64(activeScenarios.WithLabelValues("test-scenario")))
+	assert.Equal(t, float64(0), testutil.ToFloat64(m.activeScenarios.WithLabelValues("test-scenario")))
 
-	// For histogram metrics, we just verify that attempting to register it again fails
-	err := prometheus.Register(scenarioDuration)
-	assert.NoError(t, err, "Histogram metric should not be registered yet")
+	assert.Equal(t, 1, testutil.CollectAndCount(m.scenarioDuration))
 }
 
 func TestResourceMetrics(t *testing.T) {
-	resetMetrics()
+	t.Parallel()
+	m := NewRegistry()
 
 	// Test resource updates
-	UpdateResourceMetrics(1000, 2000, 3000)
+	m.UpdateResourceMetrics(1000, 2000, 3000)
 
 	// Verify metrics
-	assert.Equal(t, float64(1000), testutil.ToFloat64(cpuUsage))
-	assert.Equal(t, float64(2000), testutil.ToFloat64(memoryUsage))
-	assert.Equal(t, float64(3000), testutil.ToFloat64(diskIO))
+	assert.Equal(t, float64(1000), testutil.ToFloat64(m.cpuUsage))
+	assert.Equal(t, float64(2000), testutil.ToFloat64(m.memoryUsage))
+	assert.Equal(t, float64(3000), testutil.ToFloat64(m.diskIO))
 }
 
 func TestCircuitBreakerMetrics(t *testing.T) {
-	resetMetrics()
+	t.Parallel()
+	m := NewRegistry()
 
 	// Test circuit breaker state changes
-	UpdateCircuitBreakerState("closed") // Should set to 0
-	assert.Equal(t, float64(0), testutil.ToFloat64(circuitBreakerState.WithLabelValues("")))
+	m.UpdateCircuitBreakerState("closed") // Should set to 0
+	assert.Equal(t, float64(0), testutil.ToFloat64(m.circuitBreakerState.WithLabelValues("")))
 
-	UpdateCircuitBreakerState("open") // Should set to 1
-	assert.Equal(t, float64(1), testutil.ToFloat64(circuitBreakerState.WithLabelValues("")))
+	m.UpdateCircuitBreakerState("open") // Should set to 1
+	assert.Equal(t, float64(1), testutil.ToFloat64(m.circuitBreakerState.WithLabelValues("")))
 
-	UpdateCircuitBreakerState("half-open") // Should set to 2
-	assert.Equal(t, float64(2), testutil.ToFloat64(circuitBreakerState.WithLabelValues("")))
+	m.UpdateCircuitBreakerState("half-open") // Should set to 2
+	assert.Equal(t, float64(2), testutil.ToFloat64(m.circuitBreakerState.WithLabelValues("")))
 
 	// Test failures
-	UpdateCircuitBreakerFailures()
-	assert.Equal(t, float64(1), testutil.ToFloat64(circuitBreakerFailures.WithLabelValues("")))
+	m.UpdateCircuitBreakerFailures()
+	assert.Equal(t, float64(1), testutil.ToFloat64(m.circuitBreakerFailures.WithLabelValues("")))
 }
 
 func TestRateLimitMetrics(t *testing.T) {
-	resetMetrics()
+	t.Parallel()
+	m := NewRegistry()
 
 	// Test rate limit updates
-	UpdateRateLimitMetrics(5, 10)
+	m.UpdateRateLimitMetrics(5, 10)
 
 	// Verify metrics
-	assert.Equal(t, float64(5), testutil.ToFloat64(rateLimitHits))
-	assert.Equal(t, float64(10), testutil.ToFloat64(rateLimitCurrent))
+	assert.Equal(t, float64(5), testutil.ToFloat64(m.rateLimitHits))
+	assert.Equal(t, float64(10), testutil.ToFloat64(m.rateLimitCurrent))
+}
+
+func TestSLOMetrics(t *testing.T) {
+	t.Parallel()
+	m := NewRegistry()
+
+	m.UpdateSLO("checkout", "availability", 0.99, 0.995, 0.5)
+	m.UpdateSLOBurnRate("checkout", "availability", "1h", 14.4)
+
+	assert.Equal(t, 0.99, testutil.ToFloat64(m.sloObjective.WithLabelValues("checkout", "availability")))
+	assert.Equal(t, 0.995, testutil.ToFloat64(m.sloRatio.WithLabelValues("checkout", "availability")))
+	assert.Equal(t, 0.5, testutil.ToFloat64(m.sloErrorBudget.WithLabelValues("checkout", "availability")))
+	assert.Equal(t, 14.4, testutil.ToFloat64(m.sloBurnRate.WithLabelValues("checkout", "availability", "1h")))
+}
+
+func TestTimeToDetect(t *testing.T) {
+	t.Parallel()
+	m := NewRegistry()
+
+	m.ObserveTimeToDetect("latency", 90*time.Second)
+
+	expected := `
+# HELP sresim_time_to_detect_seconds Time from the start of a scenario run until the first alert correlated with it fired
+# TYPE sresim_time_to_detect_seconds histogram
+sresim_time_to_detect_seconds_bucket{scenario="latency",le="15"} 0
+sresim_time_to_detect_seconds_bucket{scenario="latency",le="30"} 0
+sresim_time_to_detect_seconds_bucket{scenario="latency",le="60"} 0
+sresim_time_to_detect_seconds_bucket{scenario="latency",le="120"} 1
+sresim_time_to_detect_seconds_bucket{scenario="latency",le="300"} 1
+sresim_time_to_detect_seconds_bucket{scenario="latency",le="600"} 1
+sresim_time_to_detect_seconds_bucket{scenario="latency",le="900"} 1
+sresim_time_to_detect_seconds_bucket{scenario="latency",le="1800"} 1
+sresim_time_to_detect_seconds_bucket{scenario="latency",le="3600"} 1
+sresim_time_to_detect_seconds_bucket{scenario="latency",le="+Inf"} 1
+sresim_time_to_detect_seconds_sum{scenario="latency"} 90
+sresim_time_to_detect_seconds_count{scenario="latency"} 1
+`
+	assert.NoError(t, testutil.CollectAndCompare(m.timeToDetect, strings.NewReader(expected)))
+}
+
+func TestBuildInfo(t *testing.T) {
+	t.Parallel()
+	m := NewRegistry()
+
+	m.SetBuildInfo("v1.3.0", "0a1b2c3", "2024-03-25T12:00:00Z", "go1.22.1")
+	// Setting it again replaces the series rather than adding one.
+	m.SetBuildInfo("v1.4.0", "4d5e6f7", "2024-04-01T12:00:00Z", "go1.22.2")
+
+	expected := `
+# HELP sresim_build_info Build information of the running binary, always 1
+# TYPE sresim_build_info gauge
+sresim_build_info{build_date="2024-04-01T12:00:00Z",commit="4d5e6f7",go_version="go1.22.2",version="v1.4.0"} 1
+`
+	assert.NoError(t, testutil.CollectAndCompare(m.buildInfo, strings.NewReader(expected)))
+}
+
+func TestCountLogRecord(t *testing.T) {
+	t.Parallel()
+	m := NewRegistry()
+
+	m.CountLogRecord("error")
+	m.CountLogRecord("error")
+	m.CountLogRecord("info")
+
+	expected := `
+# HELP sresim_log_records_total Total number of log records written, by level
+# TYPE sresim_log_records_total counter
+sresim_log_records_total{level="error"} 2
+sresim_log_records_total{level="info"} 1
+`
+	assert.NoError(t, testutil.CollectAndCompare(m.logRecords, strings.NewReader(expected)))
+}
+
+func TestMetricsMiddlewarePanic(t *testing.T) {
+	t.Parallel()
+	m := NewRegistry()
+
+	mux := http.NewServeMux()
+	mux.HandleFunc("/panic", func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Query().Has("late") {
+			w.Write([]byte("partial"))
+		}
+		panic("boom")
+	})
+	handler := m.Middleware(mux)(mux)
+
+	assert.PanicsWithValue(t, "boom", func() {
+		handler.ServeHTTP(httptest.NewRecorder(), httptest.NewRequest("GET", "/panic", nil))
+	}, "the panic is left to the recovery middleware")
+	assert.Panics(t, func() {
+		handler.ServeHTTP(httptest.NewRecorder(), httptest.NewRequest("GET", "/panic?late", nil))
+	})
+
+	assert.Equal(t, float64(1), testutil.ToFloat64(m.requestTotal.WithLabelValues("/panic", "GET", "500")),
+		"a panic is counted as the 500 it is answered with")
+	assert.Equal(t, float64(1), testutil.ToFloat64(m.requestTotal.WithLabelValues("/panic", "GET", "200")),
+		"unless the response was already under way")
+	assert.Equal(t, float64(0), testutil.ToFloat64(m.requestsInFlight))
+}
+
+func TestRequestObserver(t *testing.T) {
+	t.Parallel()
+	m := NewRegistry()
+
+	var gotHandler string
+	var gotStatus int
+	m.AddRequestObserver(func(handler string, status int, duration time.Duration) {
+		gotHandler, gotStatus = handler, status
+	})
+
+	mux := http.NewServeMux()
+	mux.HandleFunc("GET /observed", func(w http.ResponseWriter, r *http.Request) {})
+	m.Middleware(mux)(mux).ServeHTTP(httptest.NewRecorder(), httptest.NewRequest("GET", "/observed", nil))
+
+	assert.Equal(t, "/observed", gotHandler)
+	assert.Equal(t, 200, gotStatus)
+}
+
+// sampledContext returns a context of a sampled span of trace traceID.
+func sampledContext(t *testing.T, traceID string) context.Context {
+	t.Helper()
+	tid, err := trace.TraceIDFromHex(traceID)
+	require.NoError(t, err)
+	sid, err := trace.SpanIDFromHex("00f067aa0ba902b7")
+	require.NoError(t, err)
+	return trace.ContextWithSpanContext(context.Background(), trace.NewSpanContext(trace.SpanContextConfig{
+		TraceID: tid, SpanID: sid, TraceFlags: trace.FlagsSampled,
+	}))
+}
+
+// exemplars returns the trace IDs of the exemplars of the histograms c
+// collects with label value.
+func exemplars(t *testing.T, c prometheus.Collector, value string) []string {
+	t.Helper()
+	reg := prometheus.NewPedanticRegistry()
+	require.NoError(t, reg.Register(c))
+	families, err := reg.Gather()
+	require.NoError(t, err)
+
+	var ids []string
+	for _, mf := range families {
+		for _, m := range mf.GetMetric() {
+			if !hasLabelValue(m, value) {
+				continue
+			}
+			for _, b := range m.GetHistogram().GetBucket() {
+				for _, l := range b.GetExemplar().GetLabel() {
+					if l.GetName() == ExemplarTraceIDLabel {
+						ids = append(ids, l.GetValue())
+					}
+				}
+			}
+		}
+	}
+	return ids
+}
+
+func hasLabelValue(m *dto.Metric, value string) bool {
+	for _, l := range m.GetLabel() {
+		if l.GetValue() == value {
+			return true
+		}
+	}
+	return false
+}
+
+func TestRequestDurationExemplar(t *testing.T) {
+	t.Parallel()
+	m := NewRegistry()
+
+	mux := http.NewServeMux()
+	mux.HandleFunc("/exemplar", func(w http.ResponseWriter, r *http.Request) {})
+	handler := m.Middleware(mux)(mux)
+	req := httptest.NewRequest("GET", "/exemplar", nil)
+	handler.ServeHTTP(httptest.NewRecorder(), req.WithContext(sampledContext(t, "4bf92f3577b34da6a3ce929d0e0e4736")))
+	// Requests without a sampled trace have nothing to link to.
+	handler.ServeHTTP(httptest.NewRecorder(), httptest.NewRequest("GET", "/exemplar", nil))
+
+	assert.Equal(t, []string{"4bf92f3577b34da6a3ce929d0e0e4736"}, exemplars(t, m.requestDuration, "/exemplar"))
+}
+
+func TestNetworkLatencyExemplar(t *testing.T) {
+	t.Parallel()
+	m := NewRegistry()
+
+	m.Scenario("exemplar-scenario").RecordNetworkLatency(sampledContext(t, "0af7651916cd43dd8448eb211c80319c"), 300*time.Millisecond)
+
+	assert.Equal(t, []string{"0af7651916cd43dd8448eb211c80319c"}, exemplars(t, m.networkLatency, "exemplar-scenario"))
+}
+
+func TestMetricsHandlerOpenMetrics(t *testing.T) {
+	t.Parallel()
+	m := NewRegistry()
+
+	m.Scenario("openmetrics-scenario").RecordNetworkLatency(sampledContext(t, "5b8efff798038103d269b633813fc60c"), 2*time.Second)
+
+	req := httptest.NewRequest("GET", "/metrics", nil)
+	req.Header.Set("Accept", "application/openmetrics-text; version=1.0.0")
+	rec := httptest.NewRecorder()
+	m.Handler().ServeHTTP(rec, req)
+
+	assert.Contains(t, rec.Header().Get("Content-Type"), "application/openmetrics-text")
+	body, err := io.ReadAll(rec.Body)
+	require.NoError(t, err)
+	assert.Contains(t, string(body), `# {trace_id="5b8efff798038103d269b633813fc60c"} 2`)
+
+	// Scrapers that do not ask for OpenMetrics get the text format.
+	rec = httptest.NewRecorder()
+	m.Handler().ServeHTTP(rec, httptest.NewRequest("GET", "/metrics", nil))
+	assert.Contains(t, rec.Header().Get("Content-Type"), "text/plain")
 }
 
 func TestMetricsContext(t *testing.T) {
-	resetMetrics()
+	t.Parallel()
+	m := NewRegistry()
 
 	// Create test context
 	ctx := context.Background()
-	ctx = WithMetricsContext(ctx)
+	ctx = m.WithMetricsContext(ctx)
 
 	// Verify metrics context
 	metricsCtx, ok := ctx.Value(metricsContextKey).(*MetricsContext)
@@ -162,5 +444,5 @@ func TestMetricsContext(t *testing.T) {
 	metricsCtx.TrackResources(1000, 2000, 3000)
 
 	// Verify metrics
-	assert.Equal(t, float64(1000), testutil.ToFloat64(cpuUsage))
+	assert.Equal(t, float64(1000), testutil.ToFloat64(m.cpuUsage))
 }
@@ -0,0 +1,15 @@
+package middleware
+
+import "net/http"
+
+// Middleware wraps a handler with behaviour of its own.
+type Middleware func(http.Handler) http.Handler
+
+// Chain wraps h with mws, the first of which is the outermost and sees
+// requests first.
+func Chain(h http.Handler, mws ...Middleware) http.Handler {
+	for i := len(mws) - 1; i >= 0; i-- {
+		h = mws[i](h)
+	}
+	return h
+}
@@ -1,28 +1,109 @@
 package middleware
 
 import (
+	"log/slog"
 	"net/http"
+	"path"
+	"strings"
 	"time"
 
 	"github.com/localstack/sresim/app-sresim/pkg/chaos"
+	"github.com/localstack/sresim/app-sresim/pkg/events"
+	"github.com/localstack/sresim/app-sresim/pkg/logging"
+	"github.com/localstack/sresim/app-sresim/pkg/metrics"
+	"github.com/localstack/sresim/app-sresim/pkg/simulator"
+	"github.com/localstack/sresim/app-sresim/pkg/tracing"
+	"go.opentelemetry.io/otel/trace"
 )
 
-// ChaosMiddleware intercepts HTTP requests and applies chaos
-// by randomly failing or delaying the request.
-func ChaosMiddleware(next http.Handler) http.Handler {
-	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
-		// If chaos decides to fail, send an error response.
-		if chaos.ShouldFail() {
-			http.Error(w, "Simulated failure", http.StatusInternalServerError)
-			return
-		}
+// faultEvents samples the fault.injected events, which would otherwise be
+// published for every affected request.
+var faultEvents = events.NewSampler(time.Second)
+
+// exemptPrefixes are the endpoints chaos never injects faults into: the
+// kill switch and the chaos toggle have to work while faults are injected,
+// and probes and metrics have to report the state of sresim rather than of
+// the dice. The readiness_flap scenario fails readiness on purpose.
+var exemptPrefixes = []string{"/admin", "/livez", "/readyz", "/startupz", "/health", "/metrics"}
+
+// ChaosMiddleware returns middleware that intercepts HTTP requests and
+// applies chaos by randomly failing, panicking or delaying the request.
+// Requests to exemptPrefixes are passed through. Injected delays are
+// recorded in m, and requests are tied to the active runs of scenarios.
+func ChaosMiddleware(m *metrics.Registry, scenarios *simulator.ScenarioManager) Middleware {
+	return func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			// Tie the request to the scenario runs active while it is served.
+			if runIDs := scenarios.ActiveRunIDs(); len(runIDs) > 0 {
+				trace.SpanFromContext(r.Context()).SetAttributes(tracing.RunIDsKey.StringSlice(runIDs))
+				logging.Annotate(r.Context(), slog.Any("scenario_run_ids", runIDs))
+			}
+
+			// Pass requests straight through while chaos is switched off and
+			// exempt requests always.
+			if !chaos.Enabled() || exempt(r) {
+				next.ServeHTTP(w, r)
+				return
+			}
+
+			// If chaos decides to fail, send an error response.
+			if chaos.ShouldFail() {
+				tracing.RecordFault(r.Context(), "fail")
+				logging.Annotate(r.Context(), slog.String("fault", "fail"))
+				publishFault(r, "fail", nil)
+				http.Error(w, "Simulated failure", http.StatusInternalServerError)
+				return
+			}
 
-		// If chaos decides to delay, pause the request processing.
-		if chaos.ShouldDelay() {
-			time.Sleep(chaos.RandomDelay())
+			// If chaos decides to panic, leave it to the recovery middleware.
+			if chaos.ShouldPanic() {
+				tracing.RecordFault(r.Context(), "panic")
+				logging.Annotate(r.Context(), slog.String("fault", "panic"))
+				publishFault(r, "panic", nil)
+				panic(chaos.ErrSimulatedPanic)
+			}
+
+			// If chaos decides to delay, pause the request processing.
+			if chaos.ShouldDelay() {
+				delay := chaos.RandomDelay()
+				tracing.RecordFault(r.Context(), "delay", tracing.FaultDelayKey.Int64(delay.Milliseconds()))
+				logging.Annotate(r.Context(), slog.String("fault", "delay"), slog.Int64("fault_delay_ms", delay.Milliseconds()))
+				publishFault(r, "delay", map[string]interface{}{"delay_ms": delay.Milliseconds()})
+				m.Scenario("chaos").RecordNetworkLatency(r.Context(), delay)
+				time.Sleep(delay)
+			}
+
+			// Proceed with the next handler.
+			next.ServeHTTP(w, r)
+		})
+	}
+}
+
+// exempt reports whether r is for one of exemptPrefixes.
+func exempt(r *http.Request) bool {
+	p := path.Clean("/" + r.URL.Path)
+	for _, prefix := range exemptPrefixes {
+		if p == prefix || strings.HasPrefix(p, prefix+"/") {
+			return true
 		}
+	}
+	return false
+}
 
-		// Proceed with the next handler.
-		next.ServeHTTP(w, r)
-	})
+// publishFault publishes a fault.injected event for the request unless one
+// for the same rule was published within the last second. The event counts
+// the faults left out since the previous one.
+func publishFault(r *http.Request, rule string, data map[string]interface{}) {
+	ok, skipped := faultEvents.Allow(rule)
+	if !ok {
+		return
+	}
+	if data == nil {
+		data = make(map[string]interface{})
+	}
+	data["rule"] = rule
+	data["method"] = r.Method
+	data["path"] = r.URL.Path
+	data["unsampled"] = skipped
+	events.Publish(events.Event{Type: events.FaultInjected, Message: rule + " " + r.URL.Path, Data: data})
 }
@@ -0,0 +1,49 @@
+package middleware
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/localstack/sresim/app-sresim/pkg/chaos"
+	"github.com/localstack/sresim/app-sresim/pkg/health"
+	"github.com/localstack/sresim/app-sresim/pkg/metrics"
+	"github.com/localstack/sresim/app-sresim/pkg/simulator"
+)
+
+func TestChaosMiddlewareExempt(t *testing.T) {
+	chaos.SetEnabled(true)
+	require.NoError(t, chaos.SetPanicProbability(1))
+	t.Cleanup(func() { chaos.SetPanicProbability(0) })
+	reg := metrics.NewRegistry()
+	handler := ChaosMiddleware(reg, simulator.NewManager(reg))(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {}))
+
+	for _, target := range []string{"/admin/kill", "/admin/chaos"} {
+		for i := 0; i < 100; i++ {
+			rec := httptest.NewRecorder()
+			assert.NotPanics(t, func() { handler.ServeHTTP(rec, httptest.NewRequest(http.MethodPost, target, nil)) })
+			if !assert.Equal(t, http.StatusOK, rec.Code, "no faults are injected into %s", target) {
+				break
+			}
+		}
+	}
+}
+
+func TestChaosMiddlewareSparesProbes(t *testing.T) {
+	chaos.SetEnabled(true)
+	checker := health.New()
+	checker.SetStarted()
+	mux := http.NewServeMux()
+	mux.HandleFunc("GET /readyz", checker.ReadyzHandler)
+	reg := metrics.NewRegistry()
+	handler := ChaosMiddleware(reg, simulator.NewManager(reg))(mux)
+
+	for i := 0; i < 100; i++ {
+		rec := httptest.NewRecorder()
+		handler.ServeHTTP(rec, httptest.NewRequest(http.MethodGet, "/readyz", nil))
+		require.Equal(t, http.StatusOK, rec.Code, "readiness does not flap with chaos")
+	}
+}
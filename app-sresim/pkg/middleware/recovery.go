@@ -0,0 +1,48 @@
+package middleware
+
+import (
+	"fmt"
+	"log/slog"
+	"net/http"
+	"runtime/debug"
+
+	"github.com/localstack/sresim/app-sresim/pkg/logging"
+	"github.com/localstack/sresim/app-sresim/pkg/metrics"
+	"github.com/localstack/sresim/app-sresim/pkg/response"
+)
+
+// Recovery returns middleware that recovers from panics while serving
+// requests. A panic is logged with its stack, counted in m and answered
+// with 500 and the request ID. If the response was already under way, the
+// connection is aborted instead, so that clients do not take a truncated
+// response for a whole one.
+func Recovery(m *metrics.Registry) Middleware {
+	return func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			rw := response.Wrap(w)
+			defer func() {
+				p := recover()
+				if p == nil {
+					return
+				}
+				// net/http aborts the response quietly on ErrAbortHandler.
+				if p == http.ErrAbortHandler {
+					panic(p)
+				}
+				m.CountPanic()
+				requestID := w.Header().Get(logging.RequestIDHeader)
+				slog.Error("Recovered from panic", "request_id", requestID, "method", r.Method, "path", r.URL.Path,
+					"panic", fmt.Sprint(p), "stack", string(debug.Stack()))
+				if rw.WroteHeader() {
+					panic(http.ErrAbortHandler)
+				}
+				msg := "Internal server error"
+				if requestID != "" {
+					msg += " (request ID " + requestID + ")"
+				}
+				http.Error(w, msg, http.StatusInternalServerError)
+			}()
+			next.ServeHTTP(rw, r)
+		})
+	}
+}
@@ -0,0 +1,79 @@
+package middleware
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/prometheus/client_golang/prometheus/testutil"
+	"github.com/stretchr/testify/assert"
+
+	"github.com/localstack/sresim/app-sresim/pkg/logging"
+	"github.com/localstack/sresim/app-sresim/pkg/metrics"
+)
+
+func TestRecovery(t *testing.T) {
+	m := metrics.NewRegistry()
+	handler := Chain(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Query().Has("late") {
+			w.Write([]byte("partial"))
+		}
+		panic("boom")
+	}), Recovery(m), logging.Middleware)
+
+	req := httptest.NewRequest(http.MethodGet, "/simulate", nil)
+	req.Header.Set(logging.RequestIDHeader, "req-1")
+	rec := httptest.NewRecorder()
+	assert.NotPanics(t, func() { handler.ServeHTTP(rec, req) })
+	assert.Equal(t, http.StatusInternalServerError, rec.Code)
+	assert.Equal(t, "req-1", rec.Header().Get(logging.RequestIDHeader))
+	assert.Contains(t, rec.Body.String(), "req-1", "the response names the request to look up in the logs")
+
+	// A response under way can only be cut off.
+	assert.PanicsWithValue(t, http.ErrAbortHandler, func() {
+		handler.ServeHTTP(httptest.NewRecorder(), httptest.NewRequest(http.MethodGet, "/simulate?late", nil))
+	})
+
+	expected := `
+# HELP sresim_panics_total Total number of panics recovered from while serving HTTP requests
+# TYPE sresim_panics_total counter
+sresim_panics_total 2
+`
+	assert.NoError(t, testutil.GatherAndCompare(m.Gatherer(), strings.NewReader(expected), metrics.PanicsName))
+}
+
+func TestRecoveryAbortHandler(t *testing.T) {
+	m := metrics.NewRegistry()
+	handler := Recovery(m)(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		panic(http.ErrAbortHandler)
+	}))
+
+	assert.PanicsWithValue(t, http.ErrAbortHandler, func() {
+		handler.ServeHTTP(httptest.NewRecorder(), httptest.NewRequest(http.MethodGet, "/events", nil))
+	}, "aborted responses are left to net/http")
+	expected := `
+# HELP sresim_panics_total Total number of panics recovered from while serving HTTP requests
+# TYPE sresim_panics_total counter
+sresim_panics_total 0
+`
+	assert.NoError(t, testutil.GatherAndCompare(m.Gatherer(), strings.NewReader(expected), metrics.PanicsName))
+}
+
+func TestChain(t *testing.T) {
+	var order []string
+	mw := func(name string) Middleware {
+		return func(next http.Handler) http.Handler {
+			return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				order = append(order, name)
+				next.ServeHTTP(w, r)
+			})
+		}
+	}
+	handler := Chain(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		order = append(order, "handler")
+	}), mw("outer"), mw("inner"))
+
+	handler.ServeHTTP(httptest.NewRecorder(), httptest.NewRequest(http.MethodGet, "/", nil))
+	assert.Equal(t, []string{"outer", "inner", "handler"}, order)
+}
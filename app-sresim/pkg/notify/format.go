@@ -0,0 +1,96 @@
+package notify
+
+import (
+	"encoding/json"
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/localstack/sresim/app-sresim/pkg/events"
+)
+
+// cloudEventsSource is the CloudEvents source attribute of every event.
+const cloudEventsSource = "sresim"
+
+// titles are the human-readable headlines of the Slack messages.
+var titles = map[string]string{
+	events.ScenarioStarted:    "Chaos scenario started",
+	events.ScenarioStopped:    "Chaos scenario stopped",
+	events.ScenarioAborted:    "Chaos scenario aborted",
+	events.ScenarioVerdict:    "Scenario verdict",
+	events.ScenarioDetected:   "Chaos scenario detected",
+	events.ExperimentFinished: "Experiment finished",
+	events.Kill:               "Kill switch engaged",
+}
+
+// slackMessage is the payload accepted by Slack incoming webhooks and the
+// many tools compatible with them.
+type slackMessage struct {
+	Text string `json:"text"`
+}
+
+// cloudEvent is a CloudEvents 1.0 event in structured JSON mode.
+type cloudEvent struct {
+	SpecVersion     string       `json:"specversion"`
+	ID              string       `json:"id"`
+	Source          string       `json:"source"`
+	Type            string       `json:"type"`
+	Subject         string       `json:"subject,omitempty"`
+	Time            time.Time    `json:"time"`
+	DataContentType string       `json:"datacontenttype"`
+	Data            events.Event `json:"data"`
+}
+
+// encode renders the event in the given format and returns the body and
+// its content type.
+func encode(format string, e events.Event) ([]byte, string, error) {
+	switch format {
+	case FormatSlack:
+		body, err := json.Marshal(slackMessage{Text: slackText(e)})
+		return body, "application/json", err
+	case FormatCloudEvents:
+		body, err := json.Marshal(cloudEvent{
+			SpecVersion:     "1.0",
+			ID:              e.ID,
+			Source:          cloudEventsSource,
+			Type:            "io.sresim." + e.Type,
+			Subject:         e.RunID,
+			Time:            e.Time,
+			DataContentType: "application/json",
+			Data:            e,
+		})
+		return body, "application/cloudevents+json", err
+	default:
+		body, err := json.Marshal(e)
+		return body, "application/json", err
+	}
+}
+
+// slackText summarises the event in Slack mrkdwn.
+func slackText(e events.Event) string {
+	title, ok := titles[e.Type]
+	if !ok {
+		title = e.Type
+	}
+	var b strings.Builder
+	fmt.Fprintf(&b, "*%s*", title)
+	if e.Scenario != "" {
+		fmt.Fprintf(&b, ": `%s`", e.Scenario)
+	}
+	if name, ok := e.Data["name"]; ok && e.Type == events.ExperimentFinished {
+		fmt.Fprintf(&b, ": %v", name)
+	}
+	if result, ok := e.Data["result"]; ok {
+		fmt.Fprintf(&b, " %v", result)
+	}
+	if status, ok := e.Data["status"]; ok {
+		fmt.Fprintf(&b, " %v", status)
+	}
+	if e.RunID != "" {
+		fmt.Fprintf(&b, " (run %s)", e.RunID)
+	}
+	if e.Message != "" {
+		fmt.Fprintf(&b, "\n%s", e.Message)
+	}
+	return b.String()
+}
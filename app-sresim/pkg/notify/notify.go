@@ -0,0 +1,255 @@
+// Package notify delivers sresim events to outbound webhooks.
+package notify
+
+import (
+	"bytes"
+	"context"
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"log/slog"
+	"net/http"
+	"net/url"
+	"os"
+	"sync"
+	"time"
+
+	"github.com/localstack/sresim/app-sresim/pkg/config"
+	"github.com/localstack/sresim/app-sresim/pkg/events"
+	"github.com/localstack/sresim/app-sresim/pkg/tracing"
+	"go.opentelemetry.io/otel/attribute"
+)
+
+// Payload formats.
+const (
+	FormatJSON        = "json"
+	FormatSlack       = "slack"
+	FormatCloudEvents = "cloudevents"
+)
+
+// Request headers set on every delivery.
+const (
+	SignatureHeader = "X-Sresim-Signature-256"
+	EventHeader     = "X-Sresim-Event"
+	DeliveryHeader  = "X-Sresim-Delivery"
+)
+
+// DefaultEvents are the event types sent to webhooks that do not list any.
+var DefaultEvents = []string{
+	events.ScenarioStarted,
+	events.ScenarioStopped,
+	events.ScenarioAborted,
+	events.ScenarioVerdict,
+	events.ScenarioDetected,
+	events.ExperimentFinished,
+}
+
+const (
+	defaultTimeout    = 5 * time.Second
+	defaultMaxRetries = 3
+	// queueSize is how many events a slow webhook may fall behind before
+	// further events for it are dropped.
+	queueSize = 100
+)
+
+// retryBackoff is the wait before the first retry; it doubles with every
+// further attempt.
+var retryBackoff = 500 * time.Millisecond
+
+// Sink is one configured webhook.
+type Sink struct {
+	name       string
+	url        string
+	format     string
+	secret     []byte
+	headers    map[string]string
+	filter     events.Filter
+	maxRetries int
+	client     *http.Client
+}
+
+// NewSink validates cfg and returns the webhook it describes.
+func NewSink(cfg config.Webhook) (*Sink, error) {
+	name := cfg.Name
+	if name == "" {
+		name = cfg.URL
+	}
+	u, err := url.Parse(cfg.URL)
+	if err != nil || (u.Scheme != "http" && u.Scheme != "https") || u.Host == "" {
+		return nil, fmt.Errorf("webhook %s: url must be an absolute http or https URL", name)
+	}
+	format := cfg.Format
+	switch format {
+	case "":
+		format = FormatJSON
+	case FormatJSON, FormatSlack, FormatCloudEvents:
+	default:
+		return nil, fmt.Errorf("webhook %s: unknown format %q", name, cfg.Format)
+	}
+	secret := cfg.Secret
+	if cfg.SecretEnv != "" {
+		if secret = os.Getenv(cfg.SecretEnv); secret == "" {
+			return nil, fmt.Errorf("webhook %s: environment variable %s is empty", name, cfg.SecretEnv)
+		}
+	}
+	types := cfg.Events
+	if len(types) == 0 {
+		types = DefaultEvents
+	}
+	timeout := cfg.Timeout.Std()
+	if timeout <= 0 {
+		timeout = defaultTimeout
+	}
+	maxRetries := cfg.MaxRetries
+	switch {
+	case maxRetries == 0:
+		maxRetries = defaultMaxRetries
+	case maxRetries < 0:
+		maxRetries = 0
+	}
+	return &Sink{
+		name:       name,
+		url:        cfg.URL,
+		format:     format,
+		secret:     []byte(secret),
+		headers:    cfg.Headers,
+		filter:     events.Filter{Types: types},
+		maxRetries: maxRetries,
+		client:     &http.Client{Timeout: timeout},
+	}, nil
+}
+
+// Name identifies the sink in logs.
+func (s *Sink) Name() string {
+	return s.name
+}
+
+// Wants reports whether the event passes the sink's event filter.
+func (s *Sink) Wants(e events.Event) bool {
+	return s.filter.Match(e)
+}
+
+// Deliver sends the event, retrying with exponential backoff on network
+// errors, 429 and 5xx responses.
+func (s *Sink) Deliver(ctx context.Context, e events.Event) error {
+	body, contentType, err := encode(s.format, e)
+	if err != nil {
+		return err
+	}
+	backoff := retryBackoff
+	for attempt := 0; ; attempt++ {
+		retry, err := s.send(ctx, e, body, contentType)
+		if err == nil {
+			return nil
+		}
+		if !retry || attempt >= s.maxRetries {
+			return err
+		}
+		select {
+		case <-ctx.Done():
+			return err
+		case <-time.After(backoff):
+		}
+		backoff *= 2
+	}
+}
+
+// send makes one delivery attempt and reports whether a failure is worth
+// retrying.
+func (s *Sink) send(ctx context.Context, e events.Event, body []byte, contentType string) (bool, error) {
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, s.url, bytes.NewReader(body))
+	if err != nil {
+		return false, err
+	}
+	for k, v := range s.headers {
+		req.Header.Set(k, v)
+	}
+	req.Header.Set("Content-Type", contentType)
+	req.Header.Set("User-Agent", "sresim-webhook")
+	req.Header.Set(EventHeader, e.Type)
+	req.Header.Set(DeliveryHeader, e.ID)
+	if len(s.secret) > 0 {
+		req.Header.Set(SignatureHeader, Sign(s.secret, body))
+	}
+
+	attrs := []attribute.KeyValue{tracing.WebhookKey.String(s.name), tracing.EventTypeKey.String(e.Type)}
+	if e.RunID != "" {
+		attrs = append(attrs, tracing.RunIDKey.String(e.RunID))
+	}
+	req, span := tracing.StartClient(req, "webhook "+s.name, attrs...)
+	resp, err := s.client.Do(req)
+	tracing.EndClient(span, resp, err)
+	if err != nil {
+		return true, err
+	}
+	resp.Body.Close()
+	if resp.StatusCode/100 == 2 {
+		return false, nil
+	}
+	retry := resp.StatusCode == http.StatusTooManyRequests || resp.StatusCode >= 500
+	return retry, fmt.Errorf("webhook responded with %s", resp.Status)
+}
+
+// Sign returns the signature header value of body: "sha256=" followed by
+// the hex-encoded HMAC-SHA256 of body under secret.
+func Sign(secret, body []byte) string {
+	mac := hmac.New(sha256.New, secret)
+	mac.Write(body)
+	return "sha256=" + hex.EncodeToString(mac.Sum(nil))
+}
+
+// Notifier fans events out to the configured sinks. Each sink has its own
+// queue so that a slow or unreachable webhook does not hold up the others.
+type Notifier struct {
+	sinks []*Sink
+}
+
+// New validates the webhook configuration and returns a notifier for it.
+func New(webhooks []config.Webhook) (*Notifier, error) {
+	n := &Notifier{}
+	for _, cfg := range webhooks {
+		sink, err := NewSink(cfg)
+		if err != nil {
+			return nil, err
+		}
+		n.sinks = append(n.sinks, sink)
+	}
+	return n, nil
+}
+
+// Run delivers the events received from ch until it is closed, then waits
+// for queued deliveries to finish. Cancelling ctx abandons pending retries.
+func (n *Notifier) Run(ctx context.Context, ch <-chan events.Event) {
+	var wg sync.WaitGroup
+	queues := make([]chan events.Event, len(n.sinks))
+	for i, sink := range n.sinks {
+		queues[i] = make(chan events.Event, queueSize)
+		wg.Add(1)
+		go func(sink *Sink, queue <-chan events.Event) {
+			defer wg.Done()
+			for e := range queue {
+				if err := sink.Deliver(ctx, e); err != nil {
+					slog.Error("Failed to deliver event", "webhook", sink.name, "event_type", e.Type, "event_id", e.ID, "error", err)
+				}
+			}
+		}(sink, queues[i])
+	}
+
+	for e := range ch {
+		for i, sink := range n.sinks {
+			if !sink.Wants(e) {
+				continue
+			}
+			select {
+			case queues[i] <- e:
+			default:
+				slog.Warn("Webhook queue full, dropping event", "webhook", sink.name, "event_type", e.Type, "event_id", e.ID)
+			}
+		}
+	}
+	for _, queue := range queues {
+		close(queue)
+	}
+	wg.Wait()
+}
@@ -0,0 +1,203 @@
+package notify
+
+import (
+	"context"
+	"encoding/json"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"go.opentelemetry.io/otel"
+	"go.opentelemetry.io/otel/propagation"
+	sdktrace "go.opentelemetry.io/otel/sdk/trace"
+
+	"github.com/localstack/sresim/app-sresim/pkg/config"
+	"github.com/localstack/sresim/app-sresim/pkg/events"
+)
+
+// delivery is a request received by the test webhook.
+type delivery struct {
+	header http.Header
+	body   []byte
+}
+
+// receiver is an httptest webhook that answers the first len(statuses)
+// requests with the given statuses and every further one with 204.
+type receiver struct {
+	*httptest.Server
+	mu         sync.Mutex
+	statuses   []int
+	deliveries []delivery
+}
+
+func newReceiver(t *testing.T, statuses ...int) *receiver {
+	rcv := &receiver{statuses: statuses}
+	rcv.Server = httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		body, _ := io.ReadAll(r.Body)
+		rcv.mu.Lock()
+		defer rcv.mu.Unlock()
+		rcv.deliveries = append(rcv.deliveries, delivery{header: r.Header, body: body})
+		status := http.StatusNoContent
+		if len(rcv.statuses) > 0 {
+			status, rcv.statuses = rcv.statuses[0], rcv.statuses[1:]
+		}
+		w.WriteHeader(status)
+	}))
+	t.Cleanup(rcv.Close)
+	return rcv
+}
+
+func (rcv *receiver) received() []delivery {
+	rcv.mu.Lock()
+	defer rcv.mu.Unlock()
+	return append([]delivery(nil), rcv.deliveries...)
+}
+
+func fastRetries(t *testing.T) {
+	t.Helper()
+	backoff := retryBackoff
+	retryBackoff = time.Millisecond
+	t.Cleanup(func() { retryBackoff = backoff })
+}
+
+func testEvent() events.Event {
+	return events.Event{
+		ID:       "evt-1",
+		Type:     events.ScenarioAborted,
+		Time:     time.Date(2026, 1, 2, 3, 4, 5, 0, time.UTC),
+		RunID:    "run-1",
+		Scenario: "memory_leak",
+		Message:  "max_rss_mb exceeded",
+	}
+}
+
+func TestDeliverSignedJSON(t *testing.T) {
+	rcv := newReceiver(t)
+	sink, err := NewSink(config.Webhook{URL: rcv.URL, Secret: "s3cret", Headers: map[string]string{"X-Team": "sre"}})
+	require.NoError(t, err)
+
+	require.NoError(t, sink.Deliver(context.Background(), testEvent()))
+	got := rcv.received()
+	require.Len(t, got, 1)
+	h := got[0].header
+	assert.Equal(t, "application/json", h.Get("Content-Type"))
+	assert.Equal(t, events.ScenarioAborted, h.Get(EventHeader))
+	assert.Equal(t, "evt-1", h.Get(DeliveryHeader))
+	assert.Equal(t, "sre", h.Get("X-Team"))
+	assert.Equal(t, Sign([]byte("s3cret"), got[0].body), h.Get(SignatureHeader))
+
+	var e events.Event
+	require.NoError(t, json.Unmarshal(got[0].body, &e))
+	assert.Equal(t, testEvent(), e)
+}
+
+func TestDeliverTraceContext(t *testing.T) {
+	prevProvider, prevPropagator := otel.GetTracerProvider(), otel.GetTextMapPropagator()
+	otel.SetTracerProvider(sdktrace.NewTracerProvider())
+	otel.SetTextMapPropagator(propagation.TraceContext{})
+	t.Cleanup(func() {
+		otel.SetTracerProvider(prevProvider)
+		otel.SetTextMapPropagator(prevPropagator)
+	})
+
+	rcv := newReceiver(t)
+	sink, err := NewSink(config.Webhook{URL: rcv.URL})
+	require.NoError(t, err)
+	require.NoError(t, sink.Deliver(context.Background(), testEvent()))
+	got := rcv.received()
+	require.Len(t, got, 1)
+	assert.NotEmpty(t, got[0].header.Get("traceparent"), "the receiver can continue the trace of the delivery")
+}
+
+func TestFormats(t *testing.T) {
+	rcv := newReceiver(t)
+	slack, err := NewSink(config.Webhook{URL: rcv.URL, Format: FormatSlack})
+	require.NoError(t, err)
+	ce, err := NewSink(config.Webhook{URL: rcv.URL, Format: FormatCloudEvents})
+	require.NoError(t, err)
+
+	require.NoError(t, slack.Deliver(context.Background(), testEvent()))
+	require.NoError(t, ce.Deliver(context.Background(), testEvent()))
+	got := rcv.received()
+	require.Len(t, got, 2)
+
+	assert.Empty(t, got[0].header.Get(SignatureHeader), "unsigned without a secret")
+	assert.JSONEq(t, `{"text":"*Chaos scenario aborted*: `+"`memory_leak`"+` (run run-1)\nmax_rss_mb exceeded"}`, string(got[0].body))
+
+	assert.Equal(t, "application/cloudevents+json", got[1].header.Get("Content-Type"))
+	var ev map[string]interface{}
+	require.NoError(t, json.Unmarshal(got[1].body, &ev))
+	assert.Equal(t, "1.0", ev["specversion"])
+	assert.Equal(t, "evt-1", ev["id"])
+	assert.Equal(t, "sresim", ev["source"])
+	assert.Equal(t, "io.sresim.scenario.aborted", ev["type"])
+	assert.Equal(t, "run-1", ev["subject"])
+	assert.Equal(t, "2026-01-02T03:04:05Z", ev["time"])
+	assert.Equal(t, "memory_leak", ev["data"].(map[string]interface{})["scenario"])
+}
+
+func TestDeliverRetries(t *testing.T) {
+	fastRetries(t)
+
+	rcv := newReceiver(t, http.StatusServiceUnavailable, http.StatusTooManyRequests)
+	sink, err := NewSink(config.Webhook{URL: rcv.URL})
+	require.NoError(t, err)
+	require.NoError(t, sink.Deliver(context.Background(), testEvent()))
+	assert.Len(t, rcv.received(), 3)
+
+	rcv = newReceiver(t, 500, 500, 500)
+	sink, err = NewSink(config.Webhook{URL: rcv.URL, MaxRetries: 2})
+	require.NoError(t, err)
+	assert.ErrorContains(t, sink.Deliver(context.Background(), testEvent()), "500")
+	assert.Len(t, rcv.received(), 3)
+
+	rcv = newReceiver(t, http.StatusBadRequest)
+	sink, err = NewSink(config.Webhook{URL: rcv.URL})
+	require.NoError(t, err)
+	assert.Error(t, sink.Deliver(context.Background(), testEvent()))
+	assert.Len(t, rcv.received(), 1, "client errors are not retried")
+}
+
+func TestNotifierFiltersPerSink(t *testing.T) {
+	fastRetries(t)
+	all := newReceiver(t)
+	breakers := newReceiver(t)
+	n, err := New([]config.Webhook{
+		{Name: "lifecycle", URL: all.URL},
+		{Name: "breakers", URL: breakers.URL, Events: []string{"breaker.*"}},
+	})
+	require.NoError(t, err)
+
+	ch := make(chan events.Event, 4)
+	ch <- testEvent()
+	ch <- events.Event{ID: "evt-2", Type: events.FaultInjected}
+	ch <- events.Event{ID: "evt-3", Type: events.BreakerStateChanged}
+	close(ch)
+	n.Run(context.Background(), ch)
+
+	got := all.received()
+	require.Len(t, got, 1)
+	assert.Equal(t, "evt-1", got[0].header.Get(DeliveryHeader))
+	got = breakers.received()
+	require.Len(t, got, 1)
+	assert.Equal(t, "evt-3", got[0].header.Get(DeliveryHeader))
+}
+
+func TestNewSinkValidation(t *testing.T) {
+	_, err := NewSink(config.Webhook{Name: "bad", URL: "ftp://example.com"})
+	assert.ErrorContains(t, err, "webhook bad")
+	_, err = NewSink(config.Webhook{URL: "http://example.com", Format: "xml"})
+	assert.ErrorContains(t, err, "unknown format")
+	_, err = NewSink(config.Webhook{URL: "http://example.com", SecretEnv: "SRESIM_TEST_UNSET_SECRET"})
+	assert.ErrorContains(t, err, "SRESIM_TEST_UNSET_SECRET")
+
+	t.Setenv("SRESIM_TEST_SECRET", "from-env")
+	sink, err := NewSink(config.Webhook{URL: "http://example.com", SecretEnv: "SRESIM_TEST_SECRET"})
+	require.NoError(t, err)
+	assert.Equal(t, []byte("from-env"), sink.secret)
+}
@@ -0,0 +1,55 @@
+// Package response provides the http.ResponseWriter wrapper the middleware
+// use to find out what a handler wrote.
+package response
+
+import "net/http"
+
+// Writer records the status code and size of the response written through
+// it.
+type Writer struct {
+	http.ResponseWriter
+	status      int
+	size        int
+	wroteHeader bool
+}
+
+// Wrap returns a Writer writing to w.
+func Wrap(w http.ResponseWriter) *Writer {
+	return &Writer{ResponseWriter: w, status: http.StatusOK}
+}
+
+func (w *Writer) WriteHeader(code int) {
+	w.status = code
+	w.wroteHeader = true
+	w.ResponseWriter.WriteHeader(code)
+}
+
+func (w *Writer) Write(b []byte) (int, error) {
+	w.wroteHeader = true
+	n, err := w.ResponseWriter.Write(b)
+	w.size += n
+	return n, err
+}
+
+// Unwrap lets http.ResponseController reach the underlying writer, e.g. to
+// flush event streams.
+func (w *Writer) Unwrap() http.ResponseWriter {
+	return w.ResponseWriter
+}
+
+// Status returns the status code of the response: 200 unless the handler
+// wrote another one.
+func (w *Writer) Status() int {
+	return w.status
+}
+
+// Size returns the number of body bytes written.
+func (w *Writer) Size() int {
+	return w.size
+}
+
+// WroteHeader reports whether the response is under way. Once it is, the
+// status code can no longer be changed.
+func (w *Writer) WroteHeader() bool {
+	return w.wroteHeader
+}
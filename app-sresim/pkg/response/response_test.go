@@ -0,0 +1,34 @@
+package response
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestWriter(t *testing.T) {
+	w := Wrap(httptest.NewRecorder())
+	assert.Equal(t, http.StatusOK, w.Status())
+	assert.False(t, w.WroteHeader())
+
+	w.WriteHeader(http.StatusTeapot)
+	w.Write([]byte("short and stout"))
+	assert.Equal(t, http.StatusTeapot, w.Status())
+	assert.Equal(t, 15, w.Size())
+	assert.True(t, w.WroteHeader())
+}
+
+func TestWriterBodyOnly(t *testing.T) {
+	w := Wrap(httptest.NewRecorder())
+	w.Write([]byte("ok"))
+	assert.Equal(t, http.StatusOK, w.Status())
+	assert.True(t, w.WroteHeader())
+}
+
+func TestWriterUnwrap(t *testing.T) {
+	rec := httptest.NewRecorder()
+	assert.NoError(t, http.NewResponseController(Wrap(rec)).Flush())
+	assert.True(t, rec.Flushed)
+}
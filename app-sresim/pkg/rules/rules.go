@@ -0,0 +1,323 @@
+package rules
+
+import (
+	"bytes"
+	"fmt"
+	"net/http"
+	"strconv"
+	"strings"
+
+	"github.com/localstack/sresim/app-sresim/pkg/config"
+	"github.com/localstack/sresim/app-sresim/pkg/metrics"
+	"github.com/localstack/sresim/app-sresim/pkg/simulator"
+	"github.com/localstack/sresim/app-sresim/pkg/slo"
+	"gopkg.in/yaml.v3"
+)
+
+// PrometheusRule is the Prometheus Operator custom resource holding rule
+// groups.
+type PrometheusRule struct {
+	APIVersion string   `yaml:"apiVersion"`
+	Kind       string   `yaml:"kind"`
+	Metadata   Metadata `yaml:"metadata"`
+	Spec       Spec     `yaml:"spec"`
+}
+
+// Metadata is the object metadata of the generated resource.
+type Metadata struct {
+	Name   string            `yaml:"name"`
+	Labels map[string]string `yaml:"labels,omitempty"`
+}
+
+// Spec holds the rule groups.
+type Spec struct {
+	Groups []Group `yaml:"groups"`
+}
+
+// Group is a Prometheus rule group.
+type Group struct {
+	Name  string `yaml:"name"`
+	Rules []Rule `yaml:"rules"`
+}
+
+// Rule is either a recording rule (Record set) or an alerting rule (Alert set).
+type Rule struct {
+	Record      string            `yaml:"record,omitempty"`
+	Alert       string            `yaml:"alert,omitempty"`
+	Expr        string            `yaml:"expr"`
+	For         string            `yaml:"for,omitempty"`
+	Labels      map[string]string `yaml:"labels,omitempty"`
+	Annotations map[string]string `yaml:"annotations,omitempty"`
+}
+
+// Recording rule names used by the scenario alerts.
+const (
+	errorRatioRecord   = "sresim:http_error_ratio:rate5m"
+	latencyP99Record   = "sresim:http_request_duration_seconds:p99_5m"
+	memoryGrowthRecord = "sresim:memory_usage_bytes:deriv15m"
+)
+
+// burnRateAlert is one half of the multi-window, multi-burn-rate alert
+// pattern: both the long and the short window must exceed the factor.
+type burnRateAlert struct {
+	name     string
+	long     string
+	short    string
+	factor   float64
+	forTime  string
+	severity string
+}
+
+var burnRateAlerts = []burnRateAlert{
+	// 2% of a 30-day budget in one hour.
+	{name: "SresimSLOFastBurn", long: "1h", short: "5m", factor: 14.4, forTime: "2m", severity: "page"},
+	// 5% of a 30-day budget in six hours.
+	{name: "SresimSLOSlowBurn", long: "6h", short: "30m", factor: 6, forTime: "15m", severity: "ticket"},
+}
+
+// signal is the alert that should fire while a scenario runs.
+type signal struct {
+	alert       string
+	expr        string
+	forTime     string
+	description string
+}
+
+// scenarioSignals maps each scenario to the symptom it is meant to produce.
+var scenarioSignals = map[string]signal{
+	"latency": {
+		alert:       "SresimHighLatency",
+		expr:        latencyP99Record + " > 0.5",
+		forTime:     "2m",
+		description: "p99 latency of {{ $labels.handler }} is {{ $value | humanizeDuration }}.",
+	},
+	"error_rate": {
+		alert:       "SresimHighErrorRate",
+		expr:        errorRatioRecord + " > 0.05",
+		forTime:     "2m",
+		description: "{{ $value | humanizePercentage }} of requests to {{ $labels.handler }} are failing.",
+	},
+	"resource_exhaustion": {
+		alert:       "SresimResourceExhaustion",
+		expr:        metrics.CPUUsageName + `{scenario_type="resource_exhaustion"} > 80`,
+		forTime:     "5m",
+		description: "CPU usage is {{ $value }}%.",
+	},
+	"circuit_breaker": {
+		alert:       "SresimCircuitOpen",
+		expr:        metrics.CircuitBreakerStateName + " == 1",
+		forTime:     "1m",
+		description: "The circuit breaker has been open for more than a minute.",
+	},
+	"rate_limit": {
+		alert:       "SresimRateLimited",
+		expr:        "rate(" + metrics.RateLimitHitsName + "[5m]) > 1",
+		forTime:     "5m",
+		description: "Requests are being rate limited at {{ $value }}/s.",
+	},
+	"network_partition": {
+		alert:       "SresimNetworkErrors",
+		expr:        "rate(" + metrics.NetworkErrorsName + "[5m]) > 0",
+		forTime:     "1m",
+		description: "Network errors are occurring at {{ $value }}/s.",
+	},
+	"memory_leak": {
+		alert:       "SresimMemoryGrowth",
+		expr:        memoryGrowthRecord + " > 1048576",
+		forTime:     "10m",
+		description: "Memory is growing by {{ $value | humanize1024 }}B/s.",
+	},
+	// Spikes come and go, so the alert looks at the peak of the last
+	// interval.
+	"cpu_spike": {
+		alert:       "SresimCPUSpike",
+		expr:        "max_over_time(" + metrics.CPUUsageName + `{scenario_type="cpu_spike"}[2m]) > 90`,
+		forTime:     "1m",
+		description: "CPU usage spiked to {{ $value }}%.",
+	},
+	"disk_io": {
+		alert:       "SresimDiskIOSaturation",
+		expr:        "rate(" + metrics.DiskIOName + "[5m]) > 52428800",
+		forTime:     "5m",
+		description: "Disk I/O is at {{ $value | humanize1024 }}B/s.",
+	},
+	"connection_pool_exhaustion": {
+		alert:       "SresimConnectionPoolExhausted",
+		expr:        "increase(" + metrics.ScenarioErrorsName + `{scenario_type="connection_pool_exhaustion"}[5m]) > 0`,
+		forTime:     "1m",
+		description: "Requests are waiting for a free connection.",
+	},
+	"cascading_failure": {
+		alert:       "SresimCascadingFailure",
+		expr:        "increase(" + metrics.ScenarioErrorsName + `{scenario_type="cascading_failure"}[5m]) > 0`,
+		forTime:     "1m",
+		description: "Failures are propagating across dependent services.",
+	},
+	"thundering_herd": {
+		alert:       "SresimThunderingHerd",
+		expr:        "sum(rate(" + metrics.RequestsTotalName + "[1m])) > 3 * sum(rate(" + metrics.RequestsTotalName + "[1h] offset 5m))",
+		forTime:     "1m",
+		description: "Request rate is more than three times the hourly baseline.",
+	},
+	// Readiness is only visible from outside the pod, through
+	// kube-state-metrics.
+	"readiness_flap": {
+		alert:       "SresimPodNotReady",
+		expr:        `max_over_time(kube_pod_status_ready{condition="false", pod=~"sresim-.*"}[5m]) == 1`,
+		forTime:     "1m",
+		description: "Pod {{ $labels.pod }} failed its readiness probe in the last 5 minutes.",
+	},
+	"log_storm": {
+		alert:       "SresimErrorLogStorm",
+		expr:        "sum(rate(" + metrics.LogRecordsName + `{level="error"}[5m])) > 10`,
+		forTime:     "2m",
+		description: "sresim logs {{ $value }} errors per second.",
+	},
+	// Synthetic traces only reach Prometheus as the span metrics the
+	// OpenTelemetry Collector's spanmetrics connector derives from them.
+	"synthetic_traces": {
+		alert:       "SresimServiceErrorSpans",
+		expr:        `sum by (service_name) (rate(traces_span_metrics_calls_total{status_code="STATUS_CODE_ERROR"}[5m])) > 0`,
+		forTime:     "2m",
+		description: "Service {{ $labels.service_name }} reports {{ $value }} failed spans per second.",
+	},
+}
+
+// Generate builds the PrometheusRule resource for the given SLOs and every
+// registered scenario.
+func Generate(slos []config.SLO) (*PrometheusRule, error) {
+	groups := []Group{recordingRules()}
+
+	sloGroup, err := sloRules(slos)
+	if err != nil {
+		return nil, err
+	}
+	if len(sloGroup.Rules) > 0 {
+		groups = append(groups, sloGroup)
+	}
+
+	scenarioGroup, err := scenarioRules()
+	if err != nil {
+		return nil, err
+	}
+	groups = append(groups, scenarioGroup)
+
+	return &PrometheusRule{
+		APIVersion: "monitoring.coreos.com/v1",
+		Kind:       "PrometheusRule",
+		Metadata: Metadata{
+			Name: "sresim",
+			// Matches the ServiceMonitor so the same Prometheus picks both up.
+			Labels: map[string]string{"release": "prometheus"},
+		},
+		Spec: Spec{Groups: groups},
+	}, nil
+}
+
+func recordingRules() Group {
+	return Group{
+		Name: "sresim.recording",
+		Rules: []Rule{
+			{
+				Record: errorRatioRecord,
+				Expr: fmt.Sprintf("sum by (handler) (rate(%s[5m])) / sum by (handler) (rate(%s[5m]))",
+					metrics.ErrorsTotalName, metrics.RequestsTotalName),
+			},
+			{
+				Record: latencyP99Record,
+				Expr: fmt.Sprintf("histogram_quantile(0.99, sum by (handler, le) (rate(%s_bucket[5m])))",
+					metrics.RequestDurationName),
+			},
+			{
+				Record: memoryGrowthRecord,
+				Expr:   fmt.Sprintf("deriv(%s[15m])", metrics.MemoryUsageName),
+			},
+		},
+	}
+}
+
+func sloRules(slos []config.SLO) (Group, error) {
+	// The tracker validates the definitions and fills in defaulted names, and
+	// its status lists exactly the SLIs that are exported as gauges.
+	tracker, err := slo.NewTracker(slos)
+	if err != nil {
+		return Group{}, err
+	}
+
+	group := Group{Name: "sresim.slo"}
+	for _, status := range tracker.Status() {
+		for _, ind := range status.Indicators {
+			for _, a := range burnRateAlerts {
+				selector := func(window string) string {
+					return fmt.Sprintf(`%s{slo=%q, sli=%q, window=%q}`, metrics.SLOBurnRateName, status.Name, ind.SLI, window)
+				}
+				factor := strconv.FormatFloat(a.factor, 'f', -1, 64)
+				group.Rules = append(group.Rules, Rule{
+					Alert:  a.name,
+					Expr:   fmt.Sprintf("%s > %s and %s > %s", selector(a.long), factor, selector(a.short), factor),
+					For:    a.forTime,
+					Labels: map[string]string{"severity": a.severity, "slo": status.Name, "sli": ind.SLI},
+					Annotations: map[string]string{
+						"summary": fmt.Sprintf("SLO %s (%s) is burning its error budget %sx too fast", status.Name, ind.SLI, factor),
+						"description": fmt.Sprintf("The %s SLI of %s has burned its error budget at more than %sx the sustainable rate over the last %s and %s.",
+							ind.SLI, status.Handler, factor, a.long, a.short),
+					},
+				})
+			}
+		}
+	}
+	return group, nil
+}
+
+func scenarioRules() (Group, error) {
+	group := Group{Name: "sresim.scenarios"}
+	for _, name := range simulator.ScenarioNames() {
+		sig, ok := scenarioSignals[name]
+		if !ok {
+			return Group{}, fmt.Errorf("no alert signal defined for scenario %q", name)
+		}
+		group.Rules = append(group.Rules, Rule{
+			Alert:  sig.alert,
+			Expr:   sig.expr,
+			For:    sig.forTime,
+			Labels: map[string]string{"severity": "warning", "scenario": name},
+			Annotations: map[string]string{
+				"summary":     fmt.Sprintf("Symptoms of the %s scenario detected", strings.ReplaceAll(name, "_", " ")),
+				"description": sig.description,
+			},
+		})
+	}
+	return group, nil
+}
+
+// Marshal renders the resource as YAML.
+func (pr *PrometheusRule) Marshal() ([]byte, error) {
+	var buf bytes.Buffer
+	enc := yaml.NewEncoder(&buf)
+	enc.SetIndent(2)
+	if err := enc.Encode(pr); err != nil {
+		return nil, err
+	}
+	if err := enc.Close(); err != nil {
+		return nil, err
+	}
+	return buf.Bytes(), nil
+}
+
+// Handler serves GET /rules with the rules for the given SLOs.
+func Handler(slos []config.SLO) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		pr, err := Generate(slos)
+		if err != nil {
+			http.Error(w, err.Error(), http.StatusInternalServerError)
+			return
+		}
+		out, err := pr.Marshal()
+		if err != nil {
+			http.Error(w, err.Error(), http.StatusInternalServerError)
+			return
+		}
+		w.Header().Set("Content-Type", "application/yaml")
+		w.Write(out)
+	}
+}
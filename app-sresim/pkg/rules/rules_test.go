@@ -0,0 +1,185 @@
+package rules
+
+import (
+	"flag"
+	"io"
+	"log/slog"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"regexp"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/localstack/sresim/app-sresim/pkg/config"
+	"github.com/localstack/sresim/app-sresim/pkg/logging"
+	"github.com/localstack/sresim/app-sresim/pkg/metrics"
+	"github.com/localstack/sresim/app-sresim/pkg/simulator"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+var update = flag.Bool("update", false, "rewrite the golden files in testdata")
+
+func testSLOs() []config.SLO {
+	return []config.SLO{
+		{
+			Name:         "simulate",
+			Handler:      "/simulate",
+			Availability: 0.99,
+			Latency: &config.LatencySLO{
+				Threshold:  config.Duration(300 * time.Millisecond),
+				Percentile: 0.99,
+			},
+		},
+		{Handler: "/health", Availability: 0.999},
+	}
+}
+
+func TestGenerateGolden(t *testing.T) {
+	pr, err := Generate(testSLOs())
+	require.NoError(t, err)
+	out, err := pr.Marshal()
+	require.NoError(t, err)
+
+	golden := filepath.Join("testdata", "prometheusrule.golden.yaml")
+	if *update {
+		require.NoError(t, os.WriteFile(golden, out, 0o644))
+	}
+	want, err := os.ReadFile(golden)
+	require.NoError(t, err)
+	assert.Equal(t, string(want), string(out), "run go test ./pkg/rules -update to refresh the golden file")
+}
+
+// The manifest shipped in k8s/ is generated from the default configuration.
+func TestShippedManifestUpToDate(t *testing.T) {
+	pr, err := Generate(config.Default().SLOs)
+	require.NoError(t, err)
+	out, err := pr.Marshal()
+	require.NoError(t, err)
+
+	manifest := filepath.Join("..", "..", "k8s", "prometheus-rule.yaml")
+	if *update {
+		require.NoError(t, os.WriteFile(manifest, out, 0o644))
+	}
+	want, err := os.ReadFile(manifest)
+	require.NoError(t, err)
+	assert.Equal(t, string(want), string(out), "run go test ./pkg/rules -update to regenerate k8s/prometheus-rule.yaml")
+}
+
+func TestGenerateRejectsInvalidSLO(t *testing.T) {
+	_, err := Generate([]config.SLO{{Name: "broken"}})
+	assert.Error(t, err)
+}
+
+func TestGenerateCoversEveryScenario(t *testing.T) {
+	pr, err := Generate(nil)
+	require.NoError(t, err)
+
+	var scenarioGroup *Group
+	for i := range pr.Spec.Groups {
+		if pr.Spec.Groups[i].Name == "sresim.scenarios" {
+			scenarioGroup = &pr.Spec.Groups[i]
+		}
+	}
+	require.NotNil(t, scenarioGroup)
+	assert.Len(t, scenarioGroup.Rules, len(scenarioSignals))
+}
+
+func TestHandler(t *testing.T) {
+	w := httptest.NewRecorder()
+	Handler(testSLOs())(w, httptest.NewRequest(http.MethodGet, "/rules", nil))
+
+	assert.Equal(t, http.StatusOK, w.Code)
+	assert.Equal(t, "application/yaml", w.Header().Get("Content-Type"))
+	assert.Contains(t, w.Body.String(), "kind: PrometheusRule")
+}
+
+// externalSignals are scenarios whose alerts read series exported by other
+// components: kube-state-metrics and the OpenTelemetry Collector.
+var externalSignals = map[string]bool{"readiness_flap": true, "synthetic_traces": true}
+
+// lightParameters keep the scenarios cheap while they run in the test.
+var lightParameters = map[string]map[string]interface{}{
+	"resource_exhaustion":        {"cpu_percentage": 90, "memory_percentage": 1},
+	"memory_leak":                {"leak_rate_mb_per_second": 1},
+	"cpu_spike":                  {"spike_percentage": 95, "duration_seconds": 1, "interval_seconds": 2},
+	"disk_io":                    {"io_operations_per_second": 10, "file_size_mb": 1},
+	"connection_pool_exhaustion": {"max_connections": 1},
+	"cascading_failure":          {"failure_chain_length": 1, "delay_between_failures_seconds": 0},
+	"thundering_herd":            {"concurrent_requests": 1},
+	"log_storm":                  {"lines_per_second": 100, "payload_bytes": 0},
+}
+
+// metricRef matches the names of the series sresim exports, and recordRef
+// the recording rules, which are expanded to the series they read.
+var (
+	metricRef = regexp.MustCompile(`\b(?:sresim|http|process)_[a-z0-9_]+`)
+	recordRef = regexp.MustCompile(`\bsresim:[a-z0-9_:]+`)
+)
+
+func TestSignalsUseExportedMetrics(t *testing.T) {
+	reg := metrics.NewRegistry()
+	logger := slog.Default()
+	slog.SetDefault(logging.New(io.Discard, slog.LevelInfo, reg))
+	t.Cleanup(func() { slog.SetDefault(logger) })
+
+	// The HTTP metrics appear with the first request, here a failed one.
+	mux := http.NewServeMux()
+	mux.HandleFunc("GET /simulate", func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusInternalServerError)
+	})
+	reg.Middleware(mux)(mux).ServeHTTP(httptest.NewRecorder(), httptest.NewRequest(http.MethodGet, "/simulate", nil))
+
+	sm := simulator.NewManager(reg)
+	t.Cleanup(func() { sm.KillAll("test cleanup") })
+	records := make(map[string]string)
+	for _, rule := range recordingRules().Rules {
+		records[rule.Record] = rule.Expr
+	}
+	wanted := make(map[string][]string)
+	for name, sig := range scenarioSignals {
+		if externalSignals[name] {
+			continue
+		}
+		_, err := sm.Start(name, lightParameters[name], nil)
+		require.NoError(t, err, name)
+		expr := recordRef.ReplaceAllStringFunc(sig.expr, func(record string) string {
+			require.Contains(t, records, record, "%s uses an unknown recording rule", name)
+			return records[record]
+		})
+		for _, metric := range metricRef.FindAllString(expr, -1) {
+			metric = strings.TrimSuffix(metric, "_bucket")
+			wanted[metric] = append(wanted[metric], name)
+		}
+	}
+
+	exported := func() map[string]bool {
+		families, err := reg.Gatherer().Gather()
+		require.NoError(t, err)
+		names := make(map[string]bool)
+		for _, mf := range families {
+			if len(mf.GetMetric()) > 0 {
+				names[mf.GetName()] = true
+			}
+		}
+		return names
+	}
+	// Some scenarios record their first sample after a tick.
+	assert.Eventually(t, func() bool {
+		names := exported()
+		for metric := range wanted {
+			if !names[metric] {
+				return false
+			}
+		}
+		return true
+	}, 10*time.Second, 100*time.Millisecond)
+
+	names := exported()
+	for metric, scenarios := range wanted {
+		assert.True(t, names[metric], "%s, used by the alerts of %v, is not exported", metric, scenarios)
+	}
+}
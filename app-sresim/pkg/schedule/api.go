@@ -0,0 +1,87 @@
+package schedule
+
+import (
+	"encoding/json"
+	"errors"
+	"io"
+	"net/http"
+
+	"github.com/localstack/sresim/app-sresim/pkg/auth"
+)
+
+// maxDefinitionSize bounds the schedule definitions accepted over HTTP.
+const maxDefinitionSize = 1 << 20
+
+// Handler serves /schedules. POST creates a schedule from a YAML or JSON
+// definition, GET lists schedules or returns the one named by ?id=, PUT
+// replaces the definition of ?id= and DELETE removes it.
+func (s *Scheduler) Handler(w http.ResponseWriter, r *http.Request) {
+	id := r.URL.Query().Get("id")
+	switch r.Method {
+	case http.MethodGet:
+		if id == "" {
+			writeJSON(w, http.StatusOK, s.List())
+			return
+		}
+		sched, ok := s.Get(id)
+		if !ok {
+			http.Error(w, "Schedule not found", http.StatusNotFound)
+			return
+		}
+		writeJSON(w, http.StatusOK, sched)
+	case http.MethodPost, http.MethodPut:
+		def, err := readDefinition(w, r)
+		if err != nil {
+			http.Error(w, "Invalid schedule: "+err.Error(), http.StatusBadRequest)
+			return
+		}
+		if !auth.CheckScenarios(w, r, def.Scenarios()...) {
+			return
+		}
+		if old, ok := s.Get(id); ok && r.Method == http.MethodPut && !auth.CheckScenarios(w, r, old.Scenarios()...) {
+			return
+		}
+		var sched Schedule
+		status := http.StatusCreated
+		if r.Method == http.MethodPost {
+			sched, err = s.Create(*def)
+		} else {
+			sched, err = s.Update(id, *def)
+			status = http.StatusOK
+		}
+		switch {
+		case errors.Is(err, ErrNotFound):
+			http.Error(w, "Schedule not found", http.StatusNotFound)
+		case err != nil:
+			http.Error(w, "Invalid schedule: "+err.Error(), http.StatusBadRequest)
+		default:
+			writeJSON(w, status, sched)
+		}
+	case http.MethodDelete:
+		if old, ok := s.Get(id); ok && !auth.CheckScenarios(w, r, old.Scenarios()...) {
+			return
+		}
+		if !s.Delete(id) {
+			http.Error(w, "Schedule not found", http.StatusNotFound)
+			return
+		}
+		w.WriteHeader(http.StatusNoContent)
+	default:
+		w.Header().Set("Allow", "GET, POST, PUT, DELETE")
+		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+	}
+}
+
+func readDefinition(w http.ResponseWriter, r *http.Request) (*Schedule, error) {
+	data, err := io.ReadAll(http.MaxBytesReader(w, r.Body, maxDefinitionSize))
+	if err != nil {
+		return nil, err
+	}
+	return Parse(data)
+}
+
+func writeJSON(w http.ResponseWriter, status int, v interface{}) {
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(status)
+	json.NewEncoder(w).Encode(v)
+}
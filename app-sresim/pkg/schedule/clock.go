@@ -0,0 +1,21 @@
+package schedule
+
+import "time"
+
+// Clock tells the scheduler the time and wakes it up. Tests substitute a
+// clock they advance by hand.
+type Clock interface {
+	Now() time.Time
+	After(d time.Duration) <-chan time.Time
+}
+
+// RealClock is the clock of the machine.
+type RealClock struct{}
+
+func (RealClock) Now() time.Time {
+	return time.Now()
+}
+
+func (RealClock) After(d time.Duration) <-chan time.Time {
+	return time.After(d)
+}
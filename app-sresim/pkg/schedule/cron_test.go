@@ -0,0 +1,79 @@
+package schedule
+
+import (
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestCronNext(t *testing.T) {
+	// 2024-03-25 is a Monday.
+	base := time.Date(2024, 3, 25, 9, 30, 0, 0, time.UTC)
+	for _, tc := range []struct {
+		expr string
+		from time.Time
+		want time.Time
+	}{
+		{"0 10 * * 1-5", base, time.Date(2024, 3, 25, 10, 0, 0, 0, time.UTC)},
+		// Strictly after: a match at the start time is not returned.
+		{"0 10 * * 1-5", time.Date(2024, 3, 25, 10, 0, 0, 0, time.UTC), time.Date(2024, 3, 26, 10, 0, 0, 0, time.UTC)},
+		{"0 10 * * MON-FRI", time.Date(2024, 3, 29, 11, 0, 0, 0, time.UTC), time.Date(2024, 4, 1, 10, 0, 0, 0, time.UTC)},
+		{"*/15 * * * *", base, time.Date(2024, 3, 25, 9, 45, 0, 0, time.UTC)},
+		{"0-30/10 * * * *", base.Add(time.Minute), time.Date(2024, 3, 25, 10, 0, 0, 0, time.UTC)},
+		{"@hourly", base, time.Date(2024, 3, 25, 10, 0, 0, 0, time.UTC)},
+		{"@monthly", base, time.Date(2024, 4, 1, 0, 0, 0, 0, time.UTC)},
+		{"0 0 * * 7", base, time.Date(2024, 3, 31, 0, 0, 0, 0, time.UTC)},
+		{"0 0 29 2 *", base, time.Date(2028, 2, 29, 0, 0, 0, 0, time.UTC)},
+		// Both day fields restricted: either matching is enough.
+		{"0 0 1 * 5", base, time.Date(2024, 3, 29, 0, 0, 0, 0, time.UTC)},
+		{"0 12 1,15 JAN,APR *", base, time.Date(2024, 4, 1, 12, 0, 0, 0, time.UTC)},
+	} {
+		c, err := ParseCron(tc.expr)
+		require.NoError(t, err, tc.expr)
+		assert.Equal(t, tc.want, c.Next(tc.from), tc.expr)
+	}
+}
+
+func TestCronNextTimezone(t *testing.T) {
+	berlin, err := time.LoadLocation("Europe/Berlin")
+	require.NoError(t, err)
+	c, err := ParseCron("0 10 * * *")
+	require.NoError(t, err)
+
+	next := c.Next(time.Date(2024, 3, 25, 8, 30, 0, 0, time.UTC).In(berlin))
+	assert.Equal(t, time.Date(2024, 3, 25, 10, 0, 0, 0, berlin), next)
+	assert.Equal(t, time.Date(2024, 3, 25, 9, 0, 0, 0, time.UTC), next.UTC())
+
+	// On 2024-03-31 clocks go forward and 02:00-03:00 does not exist.
+	c, err = ParseCron("30 2 * * *")
+	require.NoError(t, err)
+	next = c.Next(time.Date(2024, 3, 30, 12, 0, 0, 0, berlin))
+	assert.Equal(t, time.Date(2024, 4, 1, 2, 30, 0, 0, berlin), next)
+}
+
+func TestParseCronErrors(t *testing.T) {
+	for _, expr := range []string{
+		"",
+		"* * * *",
+		"60 * * * *",
+		"* 24 * * *",
+		"* * 0 * *",
+		"* * * 13 *",
+		"* * * * 8",
+		"5-1 * * * *",
+		"*/0 * * * *",
+		"* * * FOO *",
+		"@often",
+	} {
+		_, err := ParseCron(expr)
+		assert.Error(t, err, expr)
+	}
+}
+
+func TestCronNextNever(t *testing.T) {
+	c, err := ParseCron("0 0 31 2 *")
+	require.NoError(t, err)
+	assert.True(t, c.Next(time.Now()).IsZero())
+}
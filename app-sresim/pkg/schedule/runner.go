@@ -0,0 +1,67 @@
+package schedule
+
+import (
+	"github.com/localstack/sresim/app-sresim/pkg/config"
+	"github.com/localstack/sresim/app-sresim/pkg/experiment"
+	"github.com/localstack/sresim/app-sresim/pkg/simulator"
+)
+
+// Runner starts and stops what schedules run.
+type Runner interface {
+	StartScenario(name string, params map[string]interface{}, guardrails *config.Guardrails) (runID string, err error)
+	StartExperiment(exp experiment.Experiment) (id string, err error)
+	// Active reports whether the run or experiment of the occurrence is
+	// still going.
+	Active(occ Occurrence) bool
+	// Stop stops the run or experiment of the occurrence. It must not
+	// block on experiment rollbacks.
+	Stop(occ Occurrence)
+}
+
+// managers runs schedules on a scenario and an experiment manager.
+type managers struct {
+	scenarios   *simulator.ScenarioManager
+	experiments *experiment.Manager
+}
+
+// NewRunner returns a runner that starts and stops schedules on the given
+// scenario and experiment managers.
+func NewRunner(scenarios *simulator.ScenarioManager, experiments *experiment.Manager) Runner {
+	return managers{scenarios: scenarios, experiments: experiments}
+}
+
+func (m managers) StartScenario(name string, params map[string]interface{}, guardrails *config.Guardrails) (string, error) {
+	run, err := m.scenarios.Start(name, params, guardrails)
+	return run.ID, err
+}
+
+func (m managers) StartExperiment(exp experiment.Experiment) (string, error) {
+	e, err := m.experiments.Start(exp)
+	if err != nil {
+		return "", err
+	}
+	return e.ID, nil
+}
+
+func (m managers) Active(occ Occurrence) bool {
+	if occ.RunID != "" {
+		run, ok := m.scenarios.GetRun(occ.RunID)
+		return ok && (run.Status == simulator.RunPending || run.Status == simulator.RunRunning)
+	}
+	e, ok := m.experiments.Get(occ.ExperimentID)
+	if !ok {
+		return false
+	}
+	status := e.Status().Status
+	return status == experiment.StatusPending || status == experiment.StatusRunning
+}
+
+func (m managers) Stop(occ Occurrence) {
+	if occ.RunID != "" {
+		m.scenarios.StopRun(occ.RunID)
+		return
+	}
+	if e, ok := m.experiments.Get(occ.ExperimentID); ok {
+		go e.Stop()
+	}
+}
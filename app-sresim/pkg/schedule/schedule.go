@@ -0,0 +1,231 @@
+// Package schedule runs scenarios and experiments on cron schedules.
+package schedule
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"time"
+
+	"gopkg.in/yaml.v3"
+
+	"github.com/localstack/sresim/app-sresim/pkg/config"
+	"github.com/localstack/sresim/app-sresim/pkg/experiment"
+	"github.com/localstack/sresim/app-sresim/pkg/simulator"
+)
+
+// Occurrence outcomes.
+const (
+	OccurrenceStarted = "started"
+	OccurrenceSkipped = "skipped"
+	OccurrenceFailed  = "failed"
+)
+
+// Schedule runs a scenario for Duration, or an experiment, at every match
+// of Cron.
+type Schedule struct {
+	ID   string `json:"id"`
+	Name string `json:"name"`
+	Cron string `json:"cron"`
+	// Timezone is the IANA time zone Cron is evaluated in (default UTC).
+	Timezone   string                 `json:"timezone,omitempty"`
+	Scenario   string                 `json:"scenario,omitempty"`
+	Parameters map[string]interface{} `json:"parameters,omitempty"`
+	Guardrails *config.Guardrails     `json:"guardrails,omitempty"`
+	// Duration is how long each occurrence of the scenario runs.
+	Duration   config.Duration        `json:"duration,omitempty"`
+	Experiment *experiment.Experiment `json:"experiment,omitempty"`
+	Blackouts  []config.Blackout      `json:"blackouts,omitempty"`
+	Paused     bool                   `json:"paused,omitempty"`
+
+	CreatedAt time.Time  `json:"created_at"`
+	UpdatedAt time.Time  `json:"updated_at"`
+	NextRun   *time.Time `json:"next_run,omitempty"`
+	// LastRun is the outcome of the latest due time and Current the
+	// occurrence in progress, if any.
+	LastRun *Occurrence `json:"last_run,omitempty"`
+	Current *Occurrence `json:"current,omitempty"`
+}
+
+// Occurrence is the outcome of one due time of a schedule.
+type Occurrence struct {
+	ScheduledAt  time.Time `json:"scheduled_at"`
+	Status       string    `json:"status"`
+	Reason       string    `json:"reason,omitempty"`
+	RunID        string    `json:"run_id,omitempty"`
+	ExperimentID string    `json:"experiment_id,omitempty"`
+	// Until is when a scenario occurrence is stopped.
+	Until *time.Time `json:"until,omitempty"`
+	// EndedAt is set once the scheduler stopped the occurrence or saw it
+	// finish.
+	EndedAt *time.Time `json:"ended_at,omitempty"`
+}
+
+// Scenarios returns the scenarios the schedule runs, directly or in its
+// experiment.
+func (s Schedule) Scenarios() []string {
+	if s.Experiment != nil {
+		return s.Experiment.Scenarios()
+	}
+	return []string{s.Scenario}
+}
+
+// snapshot returns a copy of the schedule that does not share the mutable
+// run state with s.
+func (s Schedule) snapshot() Schedule {
+	if s.NextRun != nil {
+		next := *s.NextRun
+		s.NextRun = &next
+	}
+	if s.LastRun != nil {
+		last := *s.LastRun
+		s.LastRun = &last
+	}
+	if s.Current != nil {
+		current := *s.Current
+		s.Current = &current
+	}
+	return s
+}
+
+// Parse reads a schedule definition in YAML or JSON.
+func Parse(data []byte) (*Schedule, error) {
+	// As for experiments, YAML is re-encoded as JSON so that the JSON field
+	// names and duration parsing apply to both formats.
+	var doc interface{}
+	if err := yaml.Unmarshal(data, &doc); err != nil {
+		return nil, err
+	}
+	raw, err := json.Marshal(doc)
+	if err != nil {
+		return nil, err
+	}
+	dec := json.NewDecoder(bytes.NewReader(raw))
+	dec.DisallowUnknownFields()
+	var s Schedule
+	if err := dec.Decode(&s); err != nil {
+		return nil, err
+	}
+	return &s, nil
+}
+
+// compiled is a validated schedule ready for evaluation.
+type compiled struct {
+	cron      *Cron
+	loc       *time.Location
+	blackouts []window
+}
+
+// compile validates the definition part of s.
+func compile(s Schedule) (*compiled, error) {
+	if s.Name == "" {
+		return nil, errors.New("schedule name is required")
+	}
+	cron, err := ParseCron(s.Cron)
+	if err != nil {
+		return nil, err
+	}
+	loc, err := location(s.Timezone)
+	if err != nil {
+		return nil, err
+	}
+	switch {
+	case s.Scenario != "" && s.Experiment != nil:
+		return nil, errors.New("schedule needs either a scenario or an experiment, not both")
+	case s.Scenario != "":
+		if !isScenario(s.Scenario) {
+			return nil, fmt.Errorf("unknown scenario %q", s.Scenario)
+		}
+		if s.Duration <= 0 {
+			return nil, errors.New("a scheduled scenario needs a positive duration")
+		}
+	case s.Experiment != nil:
+		if err := s.Experiment.Validate(); err != nil {
+			return nil, err
+		}
+		if s.Duration != 0 || s.Parameters != nil || s.Guardrails != nil {
+			return nil, errors.New("duration, parameters and guardrails apply to scenarios; set them in the experiment steps")
+		}
+	default:
+		return nil, errors.New("schedule needs a scenario or an experiment")
+	}
+
+	c := &compiled{cron: cron, loc: loc}
+	for i, b := range s.Blackouts {
+		w, err := compileBlackout(b)
+		if err != nil {
+			return nil, fmt.Errorf("blackout %d: %w", i+1, err)
+		}
+		c.blackouts = append(c.blackouts, w)
+	}
+	return c, nil
+}
+
+func isScenario(name string) bool {
+	for _, n := range simulator.ScenarioNames() {
+		if n == name {
+			return true
+		}
+	}
+	return false
+}
+
+func location(tz string) (*time.Location, error) {
+	if tz == "" {
+		return time.UTC, nil
+	}
+	loc, err := time.LoadLocation(tz)
+	if err != nil {
+		return nil, fmt.Errorf("unknown timezone %q", tz)
+	}
+	return loc, nil
+}
+
+// window is a compiled blackout.
+type window struct {
+	name       string
+	start, end time.Time
+	cron       *Cron
+	loc        *time.Location
+	duration   time.Duration
+}
+
+func compileBlackout(b config.Blackout) (window, error) {
+	w := window{name: b.Name}
+	if b.Cron != "" {
+		if b.Start != nil || b.End != nil {
+			return w, errors.New("set either cron and duration or start and end")
+		}
+		var err error
+		if w.cron, err = ParseCron(b.Cron); err != nil {
+			return w, err
+		}
+		if w.loc, err = location(b.Timezone); err != nil {
+			return w, err
+		}
+		if w.duration = b.Duration.Std(); w.duration <= 0 {
+			return w, errors.New("a recurring blackout needs a positive duration")
+		}
+	} else {
+		if b.Start == nil || b.End == nil || !b.End.After(*b.Start) {
+			return w, errors.New("needs a cron expression and duration, or a start before its end")
+		}
+		w.start, w.end = *b.Start, *b.End
+	}
+	if w.name == "" {
+		w.name = "unnamed blackout"
+	}
+	return w, nil
+}
+
+// contains reports whether t falls within the blackout.
+func (w window) contains(t time.Time) bool {
+	if w.cron == nil {
+		return !t.Before(w.start) && t.Before(w.end)
+	}
+	// The window opening closest before t opened after t-duration if t is
+	// inside it.
+	opened := w.cron.Next(t.In(w.loc).Add(-w.duration))
+	return !opened.IsZero() && !opened.After(t)
+}
@@ -0,0 +1,352 @@
+package schedule
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log/slog"
+	"sort"
+	"sync"
+	"time"
+
+	"github.com/google/uuid"
+
+	"github.com/localstack/sresim/app-sresim/pkg/config"
+	"github.com/localstack/sresim/app-sresim/pkg/store"
+)
+
+// maxWait bounds how long the scheduler sleeps, so that changes made
+// between two due times are picked up promptly.
+const maxWait = time.Minute
+
+// ErrNotFound is returned for operations on unknown schedules.
+var ErrNotFound = errors.New("schedule not found")
+
+// Scheduler starts schedule occurrences when they are due and stops them
+// when their duration has elapsed or a blackout begins.
+type Scheduler struct {
+	clock  Clock
+	runner Runner
+
+	mu        sync.Mutex
+	entries   map[string]*entry
+	blackouts []window
+	store     *store.Store
+	wake      chan struct{}
+}
+
+type entry struct {
+	Schedule
+	*compiled
+}
+
+// New returns a scheduler without schedules.
+func New(clock Clock, runner Runner) *Scheduler {
+	return &Scheduler{
+		clock:   clock,
+		runner:  runner,
+		entries: make(map[string]*entry),
+		wake:    make(chan struct{}, 1),
+	}
+}
+
+// SetBlackouts sets the blackouts that apply to every schedule.
+func (s *Scheduler) SetBlackouts(blackouts []config.Blackout) error {
+	var windows []window
+	for i, b := range blackouts {
+		w, err := compileBlackout(b)
+		if err != nil {
+			return fmt.Errorf("blackout %d: %w", i+1, err)
+		}
+		windows = append(windows, w)
+	}
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.blackouts = windows
+	return nil
+}
+
+// SetStore persists schedules to st and restores the ones it holds.
+// Occurrences that were running before a restart are not resumed; the
+// restart interrupted their scenario runs.
+func (s *Scheduler) SetStore(st *store.Store) error {
+	now := s.clock.Now()
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.store = st
+	for _, r := range st.Query(store.Filter{Kind: store.KindSchedule}) {
+		var sched Schedule
+		if err := json.Unmarshal(r.Data, &sched); err != nil {
+			return fmt.Errorf("schedule %s: %w", r.ID, err)
+		}
+		c, err := compile(sched)
+		if err != nil {
+			slog.Warn("Skipping invalid schedule", "schedule_id", r.ID, "error", err)
+			continue
+		}
+		e := &entry{Schedule: sched, compiled: c}
+		if occ := e.Current; occ != nil {
+			occ.EndedAt = &now
+			occ.Reason = "interrupted by restart"
+			if e.LastRun != nil && e.LastRun.ScheduledAt.Equal(occ.ScheduledAt) {
+				e.LastRun = occ
+			}
+			e.Current = nil
+		}
+		e.plan(now)
+		s.entries[e.ID] = e
+		s.save(e)
+	}
+	return nil
+}
+
+// Create adds a schedule and returns it with its ID and next run filled in.
+func (s *Scheduler) Create(sched Schedule) (Schedule, error) {
+	c, err := compile(sched)
+	if err != nil {
+		return Schedule{}, err
+	}
+	now := s.clock.Now()
+	sched.ID = uuid.NewString()
+	sched.CreatedAt = now
+	sched.UpdatedAt = now
+	sched.LastRun = nil
+	sched.Current = nil
+	e := &entry{Schedule: sched, compiled: c}
+	e.plan(now)
+
+	s.mu.Lock()
+	s.entries[e.ID] = e
+	s.save(e)
+	created := e.snapshot()
+	s.mu.Unlock()
+	s.poke()
+	return created, nil
+}
+
+// Update replaces the definition of a schedule. An occurrence in progress
+// keeps running until its original end.
+func (s *Scheduler) Update(id string, sched Schedule) (Schedule, error) {
+	c, err := compile(sched)
+	if err != nil {
+		return Schedule{}, err
+	}
+	now := s.clock.Now()
+	s.mu.Lock()
+	defer s.poke()
+	defer s.mu.Unlock()
+	e, ok := s.entries[id]
+	if !ok {
+		return Schedule{}, ErrNotFound
+	}
+	sched.ID = id
+	sched.CreatedAt = e.CreatedAt
+	sched.UpdatedAt = now
+	sched.LastRun = e.LastRun
+	sched.Current = e.Current
+	e.Schedule = sched
+	e.compiled = c
+	e.plan(now)
+	s.save(e)
+	return e.snapshot(), nil
+}
+
+// Delete removes a schedule and stops its occurrence in progress. It
+// reports whether the schedule existed.
+func (s *Scheduler) Delete(id string) bool {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	e, ok := s.entries[id]
+	if !ok {
+		return false
+	}
+	if e.Current != nil {
+		s.runner.Stop(*e.Current)
+	}
+	delete(s.entries, id)
+	if s.store != nil {
+		if err := s.store.Delete(store.KindSchedule, id); err != nil {
+			slog.Error("Failed to delete schedule from history", "schedule_id", id, "error", err)
+		}
+	}
+	return true
+}
+
+// Get returns the schedule with the given ID.
+func (s *Scheduler) Get(id string) (Schedule, bool) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	e, ok := s.entries[id]
+	if !ok {
+		return Schedule{}, false
+	}
+	return e.snapshot(), true
+}
+
+// List returns every schedule, by name.
+func (s *Scheduler) List() []Schedule {
+	s.mu.Lock()
+	schedules := make([]Schedule, 0, len(s.entries))
+	for _, e := range s.entries {
+		schedules = append(schedules, e.snapshot())
+	}
+	s.mu.Unlock()
+	sort.Slice(schedules, func(i, j int) bool {
+		if schedules[i].Name == schedules[j].Name {
+			return schedules[i].ID < schedules[j].ID
+		}
+		return schedules[i].Name < schedules[j].Name
+	})
+	return schedules
+}
+
+// Run evaluates the schedules until ctx is cancelled.
+func (s *Scheduler) Run(ctx context.Context) {
+	for {
+		wait := s.Tick()
+		select {
+		case <-ctx.Done():
+			return
+		case <-s.wake:
+		case <-s.clock.After(wait):
+		}
+	}
+}
+
+// poke makes Run re-evaluate the schedules after a change.
+func (s *Scheduler) poke() {
+	select {
+	case s.wake <- struct{}{}:
+	default:
+	}
+}
+
+// Tick ends occurrences that are over, starts the ones that are due and
+// returns how long to wait until something is due next.
+func (s *Scheduler) Tick() time.Duration {
+	now := s.clock.Now()
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	next := now.Add(maxWait)
+	for _, e := range s.entries {
+		changed := s.settle(e, now)
+		if e.NextRun != nil && !now.Before(*e.NextRun) {
+			e.LastRun = s.occur(e, *e.NextRun, now)
+			e.plan(now)
+			changed = true
+		}
+		if changed {
+			s.save(e)
+		}
+		if e.NextRun != nil && e.NextRun.Before(next) {
+			next = *e.NextRun
+		}
+		if e.Current != nil && e.Current.Until != nil && e.Current.Until.Before(next) {
+			next = *e.Current.Until
+		}
+	}
+	return next.Sub(now)
+}
+
+// settle ends the occurrence in progress once it finished, its duration
+// has elapsed or a blackout began. It reports whether it changed anything.
+func (s *Scheduler) settle(e *entry, now time.Time) bool {
+	occ := e.Current
+	if occ == nil {
+		return false
+	}
+	switch {
+	case !s.runner.Active(*occ):
+		occ.Reason = "finished"
+	case occ.Until != nil && !now.Before(*occ.Until):
+		s.runner.Stop(*occ)
+		occ.Reason = "duration elapsed"
+	default:
+		name, blacked := s.blackout(e, now)
+		if !blacked {
+			return false
+		}
+		s.runner.Stop(*occ)
+		occ.Reason = "stopped by blackout " + name
+	}
+	occ.EndedAt = &now
+	e.Current = nil
+	return true
+}
+
+// occur handles the occurrence of e due at at.
+func (s *Scheduler) occur(e *entry, at, now time.Time) *Occurrence {
+	occ := &Occurrence{ScheduledAt: at, Status: OccurrenceSkipped}
+	if name, blacked := s.blackout(e, at); blacked {
+		occ.Reason = "blackout " + name
+		return occ
+	}
+	if e.Current != nil {
+		occ.Reason = "previous occurrence still running"
+		return occ
+	}
+
+	var err error
+	if e.Experiment != nil {
+		occ.ExperimentID, err = s.runner.StartExperiment(*e.Experiment)
+	} else {
+		until := at.Add(e.Duration.Std())
+		if !now.Before(until) {
+			occ.Reason = "missed"
+			return occ
+		}
+		occ.Until = &until
+		occ.RunID, err = s.runner.StartScenario(e.Scenario, e.Parameters, e.Guardrails)
+	}
+	if err != nil {
+		occ.Status = OccurrenceFailed
+		occ.Reason = err.Error()
+		occ.Until = nil
+		return occ
+	}
+	occ.Status = OccurrenceStarted
+	e.Current = occ
+	return occ
+}
+
+// blackout returns the name of the blackout t falls into, if any.
+func (s *Scheduler) blackout(e *entry, t time.Time) (string, bool) {
+	for _, windows := range [][]window{s.blackouts, e.blackouts} {
+		for _, w := range windows {
+			if w.contains(t) {
+				return w.name, true
+			}
+		}
+	}
+	return "", false
+}
+
+// plan sets the next run after now, or clears it while the schedule is
+// paused.
+func (e *entry) plan(now time.Time) {
+	e.NextRun = nil
+	if e.Paused {
+		return
+	}
+	if next := e.cron.Next(now.In(e.loc)); !next.IsZero() {
+		e.NextRun = &next
+	}
+}
+
+// save writes the schedule to the store, if one is set. The caller must
+// hold s.mu.
+func (s *Scheduler) save(e *entry) {
+	if s.store == nil {
+		return
+	}
+	status := "active"
+	if e.Paused {
+		status = "paused"
+	}
+	r := store.Record{Kind: store.KindSchedule, ID: e.ID, Time: e.CreatedAt, Scenario: e.Scenario, Status: status}
+	if err := s.store.Put(r, e.Schedule); err != nil {
+		slog.Error("Failed to record schedule", "schedule_id", e.ID, "error", err)
+	}
+}
@@ -0,0 +1,403 @@
+package schedule
+
+import (
+	"errors"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"path/filepath"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/localstack/sresim/app-sresim/pkg/config"
+	"github.com/localstack/sresim/app-sresim/pkg/experiment"
+	"github.com/localstack/sresim/app-sresim/pkg/store"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+type fakeClock struct {
+	mu  sync.Mutex
+	now time.Time
+}
+
+func (c *fakeClock) Now() time.Time {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	return c.now
+}
+
+func (c *fakeClock) After(time.Duration) <-chan time.Time {
+	return nil
+}
+
+func (c *fakeClock) Set(t time.Time) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.now = t
+}
+
+type fakeRunner struct {
+	mu     sync.Mutex
+	n      int
+	starts []string
+	stops  []string
+	active map[string]bool
+	err    error
+}
+
+func newFakeRunner() *fakeRunner {
+	return &fakeRunner{active: make(map[string]bool)}
+}
+
+func (r *fakeRunner) start(kind, name string) (string, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	if r.err != nil {
+		return "", r.err
+	}
+	r.n++
+	id := fmt.Sprintf("%s-%d", kind, r.n)
+	r.starts = append(r.starts, name)
+	r.active[id] = true
+	return id, nil
+}
+
+func (r *fakeRunner) StartScenario(name string, _ map[string]interface{}, _ *config.Guardrails) (string, error) {
+	return r.start("run", name)
+}
+
+func (r *fakeRunner) StartExperiment(exp experiment.Experiment) (string, error) {
+	return r.start("exp", exp.Name)
+}
+
+func (r *fakeRunner) Active(occ Occurrence) bool {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	return r.active[occ.RunID+occ.ExperimentID]
+}
+
+func (r *fakeRunner) Stop(occ Occurrence) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	id := occ.RunID + occ.ExperimentID
+	r.active[id] = false
+	r.stops = append(r.stops, id)
+}
+
+func (r *fakeRunner) finish(id string) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.active[id] = false
+}
+
+// at returns a time in the week of Monday 2024-03-25, in UTC.
+func at(day, hour, minute int) time.Time {
+	return time.Date(2024, 3, 25+day, hour, minute, 0, 0, time.UTC)
+}
+
+func testExperiment(t *testing.T) *experiment.Experiment {
+	exp, err := experiment.Parse([]byte("name: nightly\nsteps: [{wait: 1m}]"))
+	require.NoError(t, err)
+	return exp
+}
+
+func TestWeekdayScenario(t *testing.T) {
+	clock := &fakeClock{now: at(0, 9, 0)}
+	runner := newFakeRunner()
+	s := New(clock, runner)
+
+	sched, err := s.Create(Schedule{
+		Name:       "weekday latency",
+		Cron:       "0 10 * * 1-5",
+		Scenario:   "latency",
+		Parameters: map[string]interface{}{"delay_ms": 200},
+		Duration:   config.Duration(15 * time.Minute),
+	})
+	require.NoError(t, err)
+	require.NotEmpty(t, sched.ID)
+	assert.Equal(t, at(0, 10, 0), *sched.NextRun)
+
+	assert.Equal(t, maxWait, s.Tick())
+	assert.Empty(t, runner.starts)
+
+	clock.Set(at(0, 10, 0))
+	assert.Equal(t, maxWait, s.Tick())
+	sched, _ = s.Get(sched.ID)
+	require.NotNil(t, sched.Current)
+	assert.Equal(t, OccurrenceStarted, sched.Current.Status)
+	assert.Equal(t, "run-1", sched.Current.RunID)
+	assert.Equal(t, at(0, 10, 15), *sched.Current.Until)
+	assert.Equal(t, at(1, 10, 0), *sched.NextRun)
+
+	clock.Set(at(0, 10, 14))
+	assert.Equal(t, time.Minute, s.Tick())
+	clock.Set(at(0, 10, 15))
+	s.Tick()
+	assert.Equal(t, []string{"run-1"}, runner.stops)
+	sched, _ = s.Get(sched.ID)
+	assert.Nil(t, sched.Current)
+	assert.Equal(t, "duration elapsed", sched.LastRun.Reason)
+	assert.Equal(t, at(0, 10, 15), *sched.LastRun.EndedAt)
+
+	// An occurrence whose whole window passed while the scheduler was not
+	// looking is skipped rather than started late.
+	clock.Set(at(4, 10, 20))
+	s.Tick()
+	assert.Equal(t, []string{"latency"}, runner.starts)
+	sched, _ = s.Get(sched.ID)
+	assert.Equal(t, OccurrenceSkipped, sched.LastRun.Status)
+	assert.Equal(t, "missed", sched.LastRun.Reason)
+	assert.Equal(t, at(7, 10, 0), *sched.NextRun)
+}
+
+func TestOccurrenceStartedLate(t *testing.T) {
+	clock := &fakeClock{now: at(0, 9, 0)}
+	runner := newFakeRunner()
+	s := New(clock, runner)
+	sched, err := s.Create(Schedule{Name: "late", Cron: "0 10 * * *", Scenario: "latency", Duration: config.Duration(15 * time.Minute)})
+	require.NoError(t, err)
+
+	// A late start still ends at the end of the original window.
+	clock.Set(at(0, 10, 5))
+	s.Tick()
+	sched, _ = s.Get(sched.ID)
+	require.NotNil(t, sched.Current)
+	assert.Equal(t, at(0, 10, 0), sched.Current.ScheduledAt)
+	assert.Equal(t, at(0, 10, 15), *sched.Current.Until)
+}
+
+func TestBlackouts(t *testing.T) {
+	clock := &fakeClock{now: at(2, 9, 30)}
+	runner := newFakeRunner()
+	s := New(clock, runner)
+	require.NoError(t, s.SetBlackouts([]config.Blackout{
+		{Name: "release freeze", Cron: "0 10 * * WED", Duration: config.Duration(time.Hour)},
+	}))
+
+	start, end := at(2, 11, 2), at(2, 11, 30)
+	sched, err := s.Create(Schedule{
+		Name:       "hourly errors",
+		Cron:       "@hourly",
+		Scenario:   "error_rate",
+		Parameters: map[string]interface{}{"error_rate": 0.05},
+		Duration:   config.Duration(5 * time.Minute),
+		Blackouts:  []config.Blackout{{Name: "incident", Start: &start, End: &end}},
+	})
+	require.NoError(t, err)
+
+	clock.Set(at(2, 10, 0))
+	s.Tick()
+	sched, _ = s.Get(sched.ID)
+	assert.Equal(t, OccurrenceSkipped, sched.LastRun.Status)
+	assert.Equal(t, "blackout release freeze", sched.LastRun.Reason)
+	assert.Empty(t, runner.starts)
+
+	// The recurring blackout ends at 11:00.
+	clock.Set(at(2, 11, 0))
+	s.Tick()
+	sched, _ = s.Get(sched.ID)
+	require.NotNil(t, sched.Current)
+
+	// A blackout beginning while an occurrence runs stops it.
+	clock.Set(at(2, 11, 2))
+	s.Tick()
+	assert.Equal(t, []string{"run-1"}, runner.stops)
+	sched, _ = s.Get(sched.ID)
+	assert.Nil(t, sched.Current)
+	assert.Equal(t, "stopped by blackout incident", sched.LastRun.Reason)
+
+	clock.Set(at(2, 12, 0))
+	s.Tick()
+	assert.Len(t, runner.starts, 2)
+}
+
+func TestExperimentStillRunning(t *testing.T) {
+	clock := &fakeClock{now: at(0, 9, 59)}
+	runner := newFakeRunner()
+	s := New(clock, runner)
+	sched, err := s.Create(Schedule{Name: "nightly", Cron: "*/30 * * * *", Experiment: testExperiment(t)})
+	require.NoError(t, err)
+
+	clock.Set(at(0, 10, 0))
+	s.Tick()
+	sched, _ = s.Get(sched.ID)
+	require.NotNil(t, sched.Current)
+	assert.Equal(t, "exp-1", sched.Current.ExperimentID)
+
+	clock.Set(at(0, 10, 30))
+	s.Tick()
+	sched, _ = s.Get(sched.ID)
+	assert.Equal(t, "previous occurrence still running", sched.LastRun.Reason)
+	assert.Equal(t, at(0, 10, 0), sched.Current.ScheduledAt)
+
+	runner.finish("exp-1")
+	clock.Set(at(0, 10, 31))
+	s.Tick()
+	sched, _ = s.Get(sched.ID)
+	assert.Nil(t, sched.Current)
+	assert.Empty(t, runner.stops)
+
+	clock.Set(at(0, 11, 0))
+	s.Tick()
+	assert.Equal(t, []string{"nightly", "nightly"}, runner.starts)
+}
+
+func TestStartFailure(t *testing.T) {
+	clock := &fakeClock{now: at(0, 9, 59)}
+	runner := newFakeRunner()
+	runner.err = errors.New("scenario latency is already running")
+	s := New(clock, runner)
+	sched, err := s.Create(Schedule{Name: "busy", Cron: "0 10 * * *", Scenario: "latency", Duration: config.Duration(time.Minute)})
+	require.NoError(t, err)
+
+	clock.Set(at(0, 10, 0))
+	s.Tick()
+	sched, _ = s.Get(sched.ID)
+	assert.Nil(t, sched.Current)
+	assert.Equal(t, OccurrenceFailed, sched.LastRun.Status)
+	assert.Equal(t, "scenario latency is already running", sched.LastRun.Reason)
+}
+
+func TestPauseAndDelete(t *testing.T) {
+	clock := &fakeClock{now: at(0, 9, 59)}
+	runner := newFakeRunner()
+	s := New(clock, runner)
+	def := Schedule{Name: "paused", Cron: "0 10 * * *", Scenario: "latency", Duration: config.Duration(time.Hour)}
+	sched, err := s.Create(def)
+	require.NoError(t, err)
+
+	clock.Set(at(0, 10, 0))
+	s.Tick()
+
+	def.Paused = true
+	updated, err := s.Update(sched.ID, def)
+	require.NoError(t, err)
+	assert.Nil(t, updated.NextRun)
+	assert.Equal(t, sched.CreatedAt, updated.CreatedAt)
+	// Pausing does not stop the occurrence in progress.
+	require.NotNil(t, updated.Current)
+
+	clock.Set(at(0, 10, 30))
+	s.Tick()
+	assert.Empty(t, runner.stops)
+
+	_, err = s.Update("missing", def)
+	assert.ErrorIs(t, err, ErrNotFound)
+
+	// Deleting the schedule does.
+	assert.True(t, s.Delete(sched.ID))
+	assert.Equal(t, []string{"run-1"}, runner.stops)
+	assert.False(t, s.Delete(sched.ID))
+	assert.Empty(t, s.List())
+
+	_, err = s.Create(def)
+	require.NoError(t, err)
+	clock.Set(at(1, 10, 0))
+	s.Tick()
+	assert.Len(t, runner.starts, 1)
+}
+
+func TestPersistence(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "history.jsonl")
+	st, err := store.Open(path, 0)
+	require.NoError(t, err)
+
+	clock := &fakeClock{now: at(0, 9, 59)}
+	s := New(clock, newFakeRunner())
+	require.NoError(t, s.SetStore(st))
+	kept, err := s.Create(Schedule{Name: "kept", Cron: "0 10 * * *", Scenario: "latency", Duration: config.Duration(time.Hour)})
+	require.NoError(t, err)
+	deleted, err := s.Create(Schedule{Name: "deleted", Cron: "0 12 * * *", Scenario: "latency", Duration: config.Duration(time.Hour)})
+	require.NoError(t, err)
+	require.True(t, s.Delete(deleted.ID))
+	clock.Set(at(0, 10, 0))
+	s.Tick()
+	require.NoError(t, st.Close())
+
+	st, err = store.Open(path, 0)
+	require.NoError(t, err)
+	defer st.Close()
+	clock.Set(at(0, 10, 30))
+	restored := New(clock, newFakeRunner())
+	require.NoError(t, restored.SetStore(st))
+
+	schedules := restored.List()
+	require.Len(t, schedules, 1)
+	sched := schedules[0]
+	assert.Equal(t, kept.ID, sched.ID)
+	assert.Nil(t, sched.Current)
+	assert.Equal(t, "run-1", sched.LastRun.RunID)
+	assert.Equal(t, "interrupted by restart", sched.LastRun.Reason)
+	assert.Equal(t, at(1, 10, 0), *sched.NextRun)
+}
+
+func TestValidation(t *testing.T) {
+	s := New(&fakeClock{now: at(0, 0, 0)}, newFakeRunner())
+	exp := testExperiment(t)
+	minute := config.Duration(time.Minute)
+	for name, sched := range map[string]Schedule{
+		"missing name":      {Cron: "@daily", Scenario: "latency", Duration: minute},
+		"invalid cron":      {Name: "x", Cron: "0 25 * * *", Scenario: "latency", Duration: minute},
+		"unknown timezone":  {Name: "x", Cron: "@daily", Timezone: "Mars/Olympus", Scenario: "latency", Duration: minute},
+		"unknown scenario":  {Name: "x", Cron: "@daily", Scenario: "nope", Duration: minute},
+		"missing duration":  {Name: "x", Cron: "@daily", Scenario: "latency"},
+		"nothing to run":    {Name: "x", Cron: "@daily"},
+		"both":              {Name: "x", Cron: "@daily", Scenario: "latency", Duration: minute, Experiment: exp},
+		"experiment params": {Name: "x", Cron: "@daily", Experiment: exp, Duration: minute},
+		"invalid blackout":  {Name: "x", Cron: "@daily", Scenario: "latency", Duration: minute, Blackouts: []config.Blackout{{Cron: "@daily"}}},
+	} {
+		_, err := s.Create(sched)
+		assert.Error(t, err, name)
+	}
+	assert.Empty(t, s.List())
+
+	assert.Error(t, s.SetBlackouts([]config.Blackout{{Name: "open ended", Start: &time.Time{}}}))
+}
+
+func TestHandler(t *testing.T) {
+	s := New(&fakeClock{now: at(0, 8, 0)}, newFakeRunner())
+	do := func(method, target, body string) *httptest.ResponseRecorder {
+		rec := httptest.NewRecorder()
+		s.Handler(rec, httptest.NewRequest(method, target, strings.NewReader(body)))
+		return rec
+	}
+
+	rec := do(http.MethodPost, "/schedules", `
+name: weekday latency
+cron: "0 10 * * 1-5"
+timezone: Europe/Berlin
+scenario: latency
+parameters:
+  delay_ms: 200
+duration: 15m
+`)
+	require.Equal(t, http.StatusCreated, rec.Code, rec.Body.String())
+	assert.Contains(t, rec.Body.String(), `"next_run":"2024-03-25T10:00:00+01:00"`)
+	created, err := Parse(rec.Body.Bytes())
+	require.NoError(t, err)
+
+	rec = do(http.MethodGet, "/schedules", "")
+	assert.Equal(t, http.StatusOK, rec.Code)
+	assert.Contains(t, rec.Body.String(), created.ID)
+
+	rec = do(http.MethodPut, "/schedules?id="+created.ID, `{"name": "weekday latency", "cron": "0 11 * * 1-5", "scenario": "latency", "duration": "15m"}`)
+	assert.Equal(t, http.StatusOK, rec.Code, rec.Body.String())
+	rec = do(http.MethodGet, "/schedules?id="+created.ID, "")
+	assert.Equal(t, http.StatusOK, rec.Code)
+	assert.Contains(t, rec.Body.String(), `"cron":"0 11 * * 1-5"`)
+
+	assert.Equal(t, http.StatusBadRequest, do(http.MethodPost, "/schedules", "name: x\ncron: '@daily'\nscenaro: latency").Code)
+	assert.Equal(t, http.StatusBadRequest, do(http.MethodPost, "/schedules", "name: x\ncron: '@daily'\nscenario: nope\nduration: 1m").Code)
+	assert.Equal(t, http.StatusNotFound, do(http.MethodPut, "/schedules?id=missing", "name: x\ncron: '@daily'\nscenario: latency\nduration: 1m").Code)
+	assert.Equal(t, http.StatusNotFound, do(http.MethodGet, "/schedules?id=missing", "").Code)
+
+	assert.Equal(t, http.StatusNoContent, do(http.MethodDelete, "/schedules?id="+created.ID, "").Code)
+	assert.Equal(t, http.StatusNotFound, do(http.MethodDelete, "/schedules?id="+created.ID, "").Code)
+
+	rec = do(http.MethodPatch, "/schedules", "")
+	assert.Equal(t, http.StatusMethodNotAllowed, rec.Code)
+	assert.Equal(t, "GET, POST, PUT, DELETE", rec.Header().Get("Allow"))
+}
@@ -0,0 +1,173 @@
+package simulator
+
+import (
+	"encoding/json"
+	"net/http"
+	"time"
+
+	"github.com/localstack/sresim/app-sresim/pkg/events"
+)
+
+// detectionGrace is how long after a run ended an alert that starts firing
+// is still attributed to it, since alerts only fire once their "for"
+// duration has passed.
+const detectionGrace = 10 * time.Minute
+
+// maxAlertPayloadSize bounds the Alertmanager payloads accepted by /alerts.
+const maxAlertPayloadSize = 1 << 20
+
+// Labels that tie an alert to a scenario or run.
+const (
+	ScenarioLabel = "scenario"
+	RunIDLabel    = "run_id"
+)
+
+// Detection is the first alert that fired for a run.
+type Detection struct {
+	Alert       string            `json:"alert"`
+	Fingerprint string            `json:"fingerprint,omitempty"`
+	Labels      map[string]string `json:"labels,omitempty"`
+	FiredAt     time.Time         `json:"fired_at"`
+	// TimeToDetect is the time from the start of the run until the alert
+	// fired.
+	TimeToDetect float64 `json:"time_to_detect_seconds"`
+}
+
+// Detect attributes an alert that started firing at firedAt to the runs
+// it detected and returns them. An alert labelled with a run_id or
+// scenario only matches runs with that ID or scenario; any other alert
+// matches every run that was active when it fired. Only the first alert
+// of a run counts.
+func (sm *ScenarioManager) Detect(alert, fingerprint string, labels map[string]string, firedAt time.Time) []Run {
+	sm.mu.Lock()
+	var detected []Run
+	for _, run := range sm.runs {
+		if run.Detection != nil || !detects(run, labels, firedAt) {
+			continue
+		}
+		ttd := firedAt.Sub(*run.StartedAt)
+		run.Detection = &Detection{
+			Alert:        alert,
+			Fingerprint:  fingerprint,
+			Labels:       labels,
+			FiredAt:      firedAt,
+			TimeToDetect: ttd.Seconds(),
+		}
+		sm.save(run)
+		sm.metrics.ObserveTimeToDetect(run.Scenario, ttd)
+		detected = append(detected, *run)
+	}
+	sm.mu.Unlock()
+
+	for _, run := range detected {
+		events.Publish(events.Event{
+			Type:     events.ScenarioDetected,
+			RunID:    run.ID,
+			Scenario: run.Scenario,
+			Message:  alert + " fired after " + time.Duration(run.Detection.TimeToDetect*float64(time.Second)).Round(time.Second).String(),
+			Data: map[string]interface{}{
+				"alert":                  alert,
+				"time_to_detect_seconds": run.Detection.TimeToDetect,
+			},
+		})
+	}
+	return detected
+}
+
+// detects reports whether an alert with the given labels that fired at
+// firedAt can have been caused by run.
+func detects(run *Run, labels map[string]string, firedAt time.Time) bool {
+	if run.StartedAt == nil || firedAt.Before(*run.StartedAt) {
+		return false
+	}
+	if run.EndedAt != nil && firedAt.After(run.EndedAt.Add(detectionGrace)) {
+		return false
+	}
+	if id, ok := labels[RunIDLabel]; ok && id != run.ID {
+		return false
+	}
+	if scenario, ok := labels[ScenarioLabel]; ok && scenario != run.Scenario {
+		return false
+	}
+	return true
+}
+
+// AlertmanagerPayload is the body of an Alertmanager webhook notification.
+type AlertmanagerPayload struct {
+	Version     string            `json:"version"`
+	GroupKey    string            `json:"groupKey"`
+	Status      string            `json:"status"`
+	Receiver    string            `json:"receiver"`
+	GroupLabels map[string]string `json:"groupLabels"`
+	ExternalURL string            `json:"externalURL"`
+	Alerts      []Alert           `json:"alerts"`
+}
+
+// Alert is one alert of an Alertmanager notification.
+type Alert struct {
+	Status       string            `json:"status"`
+	Labels       map[string]string `json:"labels"`
+	Annotations  map[string]string `json:"annotations"`
+	StartsAt     time.Time         `json:"startsAt"`
+	EndsAt       time.Time         `json:"endsAt"`
+	GeneratorURL string            `json:"generatorURL"`
+	Fingerprint  string            `json:"fingerprint"`
+}
+
+// AlertCorrelation ties a received alert to a run it detected.
+type AlertCorrelation struct {
+	Alert        string  `json:"alert"`
+	Fingerprint  string  `json:"fingerprint,omitempty"`
+	RunID        string  `json:"run_id"`
+	Scenario     string  `json:"scenario"`
+	TimeToDetect float64 `json:"time_to_detect_seconds"`
+}
+
+// AlertsResponse is the reply to an Alertmanager notification.
+type AlertsResponse struct {
+	Received   int                `json:"received"`
+	Correlated []AlertCorrelation `json:"correlated"`
+}
+
+// AlertsHandler receives Alertmanager webhook notifications and records the
+// firing alerts as detections of the scenario runs that caused them.
+func (sm *ScenarioManager) AlertsHandler(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodPost {
+		w.Header().Set("Allow", "POST")
+		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+	var payload AlertmanagerPayload
+	if err := json.NewDecoder(http.MaxBytesReader(w, r.Body, maxAlertPayloadSize)).Decode(&payload); err != nil {
+		http.Error(w, "Invalid Alertmanager payload: "+err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	response := AlertsResponse{Received: len(payload.Alerts), Correlated: []AlertCorrelation{}}
+	for _, alert := range payload.Alerts {
+		status := alert.Status
+		if status == "" {
+			status = payload.Status
+		}
+		if status != "firing" {
+			continue
+		}
+		firedAt := alert.StartsAt
+		if firedAt.IsZero() {
+			firedAt = time.Now()
+		}
+		name := alert.Labels["alertname"]
+		for _, run := range sm.Detect(name, alert.Fingerprint, alert.Labels, firedAt) {
+			response.Correlated = append(response.Correlated, AlertCorrelation{
+				Alert:        name,
+				Fingerprint:  alert.Fingerprint,
+				RunID:        run.ID,
+				Scenario:     run.Scenario,
+				TimeToDetect: run.Detection.TimeToDetect,
+			})
+		}
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(response)
+}
@@ -0,0 +1,89 @@
+package simulator
+
+import (
+	"bytes"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func postAlerts(t *testing.T, sm *ScenarioManager, payload AlertmanagerPayload) AlertsResponse {
+	t.Helper()
+	body, err := json.Marshal(payload)
+	require.NoError(t, err)
+	rec := httptest.NewRecorder()
+	sm.AlertsHandler(rec, httptest.NewRequest(http.MethodPost, "/alerts", bytes.NewReader(body)))
+	require.Equal(t, http.StatusOK, rec.Code, rec.Body.String())
+	var resp AlertsResponse
+	require.NoError(t, json.NewDecoder(rec.Body).Decode(&resp))
+	return resp
+}
+
+func TestDetectAlerts(t *testing.T) {
+	sm := newTestManager()
+	latency, err := sm.Start("latency", nil, nil)
+	require.NoError(t, err)
+	defer sm.StopScenario("latency")
+	errorRun, err := sm.Start("error_rate", nil, nil)
+	require.NoError(t, err)
+	defer sm.StopScenario("error_rate")
+	started := *latency.StartedAt
+
+	resp := postAlerts(t, sm, AlertmanagerPayload{Status: "firing", Alerts: []Alert{
+		// Fired before the run started, so it cannot have been caused by it.
+		{Labels: map[string]string{"alertname": "Old"}, StartsAt: started.Add(-time.Minute)},
+		{Status: "resolved", Labels: map[string]string{"alertname": "Resolved", "scenario": "latency"}, StartsAt: started.Add(time.Second)},
+		{Labels: map[string]string{"alertname": "HighLatency", "scenario": "latency"}, StartsAt: started.Add(42 * time.Second), Fingerprint: "abc"},
+	}})
+	assert.Equal(t, 3, resp.Received)
+	require.Len(t, resp.Correlated, 1)
+	assert.Equal(t, AlertCorrelation{Alert: "HighLatency", Fingerprint: "abc", RunID: latency.ID, Scenario: "latency", TimeToDetect: 42}, resp.Correlated[0])
+
+	run, _ := sm.GetRun(latency.ID)
+	require.NotNil(t, run.Detection)
+	assert.Equal(t, "HighLatency", run.Detection.Alert)
+	assert.Equal(t, 42.0, run.Detection.TimeToDetect)
+	run, _ = sm.GetRun(errorRun.ID)
+	assert.Nil(t, run.Detection, "the scenario label restricts the alert to latency runs")
+
+	// An alert without scenario labels detects every active run, but runs
+	// keep their first detection.
+	resp = postAlerts(t, sm, AlertmanagerPayload{Status: "firing", Alerts: []Alert{
+		{Labels: map[string]string{"alertname": "SLOBurn", "slo": "simulate"}, StartsAt: time.Now()},
+	}})
+	require.Len(t, resp.Correlated, 1)
+	assert.Equal(t, errorRun.ID, resp.Correlated[0].RunID)
+	run, _ = sm.GetRun(latency.ID)
+	assert.Equal(t, "HighLatency", run.Detection.Alert)
+}
+
+func TestDetectWindow(t *testing.T) {
+	sm := newTestManager()
+	run, err := sm.Start("latency", nil, nil)
+	require.NoError(t, err)
+	sm.StopScenario("latency")
+	run, _ = sm.GetRun(run.ID)
+	require.NotNil(t, run.EndedAt)
+
+	assert.Empty(t, sm.Detect("Late", "", nil, run.EndedAt.Add(detectionGrace+time.Second)))
+	assert.Empty(t, sm.Detect("OtherRun", "", map[string]string{RunIDLabel: "other"}, run.EndedAt.Add(time.Minute)))
+	detected := sm.Detect("Lagging", "", map[string]string{RunIDLabel: run.ID}, run.EndedAt.Add(time.Minute))
+	require.Len(t, detected, 1)
+	assert.Equal(t, "Lagging", detected[0].Detection.Alert)
+}
+
+func TestAlertsHandlerRejectsInvalidPayloads(t *testing.T) {
+	sm := newTestManager()
+	rec := httptest.NewRecorder()
+	sm.AlertsHandler(rec, httptest.NewRequest(http.MethodPost, "/alerts", bytes.NewReader([]byte("{"))))
+	assert.Equal(t, http.StatusBadRequest, rec.Code)
+
+	rec = httptest.NewRecorder()
+	sm.AlertsHandler(rec, httptest.NewRequest(http.MethodGet, "/alerts", nil))
+	assert.Equal(t, http.StatusMethodNotAllowed, rec.Code)
+}
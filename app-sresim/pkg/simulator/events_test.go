@@ -0,0 +1,117 @@
+package simulator
+
+import (
+	"context"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/localstack/sresim/app-sresim/pkg/events"
+	"github.com/localstack/sresim/app-sresim/pkg/health"
+)
+
+// nextEvent returns the next event of the given type from ch.
+func nextEvent(t *testing.T, ch <-chan events.Event, eventType string) events.Event {
+	t.Helper()
+	timeout := time.After(5 * time.Second)
+	for {
+		select {
+		case e := <-ch:
+			if e.Type == eventType {
+				return e
+			}
+		case <-timeout:
+			t.Fatalf("no %s event", eventType)
+		}
+	}
+}
+
+func TestCircuitBreakerEvents(t *testing.T) {
+	interval := breakerCallInterval
+	breakerCallInterval = 5 * time.Millisecond
+	t.Cleanup(func() { breakerCallInterval = interval })
+
+	ch, cancel := events.GetBus().Subscribe(64)
+	defer cancel()
+	sm := newTestManager()
+	run, err := sm.Start("circuit_breaker", map[string]interface{}{"threshold": 3, "timeout": 0}, nil)
+	require.NoError(t, err)
+	defer sm.StopScenario("circuit_breaker")
+
+	opened := nextEvent(t, ch, events.BreakerStateChanged)
+	assert.Equal(t, run.ID, opened.RunID)
+	assert.Equal(t, "circuit_breaker", opened.Scenario)
+	assert.Equal(t, breakerClosed, opened.Data["from"])
+	assert.Equal(t, breakerOpen, opened.Data["to"])
+	assert.Equal(t, 3, opened.Data["failures"])
+
+	halfOpen := nextEvent(t, ch, events.BreakerStateChanged)
+	assert.Equal(t, breakerHalfOpen, halfOpen.Data["to"])
+	reopened := nextEvent(t, ch, events.BreakerStateChanged)
+	assert.Equal(t, breakerHalfOpen, reopened.Data["from"])
+	assert.Equal(t, breakerOpen, reopened.Data["to"])
+}
+
+func TestRateLimitBurstEvents(t *testing.T) {
+	window := rateLimitWindow
+	rateLimitWindow = 5 * time.Millisecond
+	t.Cleanup(func() { rateLimitWindow = window })
+
+	ch, cancel := events.GetBus().Subscribe(64)
+	defer cancel()
+	sm := newTestManager()
+	run, err := sm.Start("rate_limit", map[string]interface{}{"requests_per_second": 1000}, nil)
+	require.NoError(t, err)
+	defer sm.StopScenario("rate_limit")
+
+	burst := nextEvent(t, ch, events.RateLimitBurst)
+	assert.Equal(t, run.ID, burst.RunID)
+	assert.Equal(t, 5, burst.Data["limit"])
+	assert.Greater(t, burst.Data["rejected"], 0)
+}
+
+func TestReadinessFlap(t *testing.T) {
+	unit := readinessFlapUnit
+	readinessFlapUnit = 20 * time.Millisecond
+	t.Cleanup(func() { readinessFlapUnit = unit })
+
+	failing := func() bool {
+		for _, reason := range health.GetChecker().Status(context.Background()).Reasons {
+			if strings.Contains(reason, "readiness_flap") {
+				return true
+			}
+		}
+		return false
+	}
+
+	ch, cancel := events.GetBus().Subscribe(64)
+	defer cancel()
+	sm := newTestManager()
+	run, err := sm.Start("readiness_flap", map[string]interface{}{"down_seconds": 1, "up_seconds": 1}, nil)
+	require.NoError(t, err)
+	assert.True(t, failing())
+	assert.Equal(t, []string{"readiness_flap"}, sm.ActiveScenarios())
+
+	down := nextEvent(t, ch, events.ReadinessChanged)
+	assert.Equal(t, run.ID, down.RunID)
+	assert.Equal(t, false, down.Data["ready"])
+	assert.Equal(t, true, nextEvent(t, ch, events.ReadinessChanged).Data["ready"])
+	assert.Equal(t, false, nextEvent(t, ch, events.ReadinessChanged).Data["ready"])
+
+	sm.StopScenario("readiness_flap")
+	assert.Eventually(t, func() bool { return !failing() }, time.Second, 5*time.Millisecond)
+
+	// Without an up period readiness fails until the scenario stops.
+	_, err = sm.Start("readiness_flap", map[string]interface{}{"down_seconds": 1, "up_seconds": 0}, nil)
+	require.NoError(t, err)
+	time.Sleep(3 * readinessFlapUnit)
+	assert.True(t, failing())
+	sm.StopScenario("readiness_flap")
+	assert.Eventually(t, func() bool { return !failing() }, time.Second, 5*time.Millisecond)
+
+	_, err = sm.Start("readiness_flap", map[string]interface{}{"down_seconds": 0}, nil)
+	assert.Error(t, err)
+}
@@ -0,0 +1,147 @@
+package simulator
+
+import (
+	"bufio"
+	"bytes"
+	"fmt"
+	"log/slog"
+	"os"
+	"runtime"
+	"strconv"
+	"time"
+
+	"github.com/localstack/sresim/app-sresim/pkg/config"
+	"github.com/localstack/sresim/app-sresim/pkg/slo"
+	"github.com/localstack/sresim/app-sresim/pkg/steadystate"
+)
+
+// guardInterval is how often the guardrails of a running scenario are
+// checked.
+var guardInterval = time.Second
+
+// minGuardRequests is the number of requests that must have been served
+// during a run before its error rate is compared with the guardrail, so a
+// single early failure does not abort it.
+const minGuardRequests = 20
+
+// defaultBurnRateWindow is the burn rate window checked when a guardrail
+// does not name one.
+const defaultBurnRateWindow = "5m"
+
+// processRSS returns the resident memory of the process in bytes.
+var processRSS = readRSS
+
+func validateGuardrails(g config.Guardrails) error {
+	if g.MaxDuration < 0 || g.MaxRSSMB < 0 || g.MaxBurnRate < 0 {
+		return fmt.Errorf("guardrails must not be negative")
+	}
+	if g.MaxErrorRate < 0 || g.MaxErrorRate > 1 {
+		return fmt.Errorf("guardrail max_error_rate must be between 0 and 1")
+	}
+	if g.BurnRateWindow != "" {
+		for _, w := range slo.Windows {
+			if w.Name == g.BurnRateWindow {
+				return nil
+			}
+		}
+		return fmt.Errorf("unknown burn rate window %q", g.BurnRateWindow)
+	}
+	return nil
+}
+
+// guard checks the guardrails of a running scenario until the run ends,
+// aborting it at the first one that is exceeded.
+func (sm *ScenarioManager) guard(run *Run, g config.Guardrails) {
+	started := sm.snapshot(run).StartedAt
+	baseRequests, baseErrors, _ := steadystate.Counts(sm.metrics.Gatherer())
+
+	ticker := time.NewTicker(guardInterval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-run.done:
+			return
+		case <-ticker.C:
+		}
+
+		reason := sm.violation(g, *started, baseRequests, baseErrors)
+		if reason != "" && sm.Abort(run.ID, reason) {
+			slog.Warn("Aborted run", "scenario", run.Scenario, "run_id", run.ID, "reason", reason)
+			return
+		}
+	}
+}
+
+// violation returns a description of the first exceeded guardrail, or ""
+// if the run is within all of them.
+func (sm *ScenarioManager) violation(g config.Guardrails, started time.Time, baseRequests, baseErrors uint64) string {
+	if g.MaxDuration > 0 {
+		if elapsed := time.Since(started); elapsed >= g.MaxDuration.Std() {
+			return fmt.Sprintf("exceeded max duration of %s", g.MaxDuration)
+		}
+	}
+
+	if g.MaxRSSMB > 0 {
+		if rss := processRSS(); rss > uint64(g.MaxRSSMB)<<20 {
+			return fmt.Sprintf("resident memory of %d MiB exceeds %d MiB", rss>>20, g.MaxRSSMB)
+		}
+	}
+
+	if g.MaxErrorRate > 0 {
+		requests, errors, err := steadystate.Counts(sm.metrics.Gatherer())
+		if err == nil && requests-baseRequests >= minGuardRequests {
+			rate := float64(errors-baseErrors) / float64(requests-baseRequests)
+			if rate > g.MaxErrorRate {
+				return fmt.Sprintf("error rate of %.3f exceeds %.3f", rate, g.MaxErrorRate)
+			}
+		}
+	}
+
+	if g.MaxBurnRate > 0 {
+		sm.mu.RLock()
+		tracker := sm.sloTracker
+		sm.mu.RUnlock()
+		if tracker != nil {
+			window := g.BurnRateWindow
+			if window == "" {
+				window = defaultBurnRateWindow
+			}
+			for _, status := range tracker.Status() {
+				if g.SLO != "" && status.Name != g.SLO {
+					continue
+				}
+				for _, ind := range status.Indicators {
+					if rate := ind.BurnRates[window]; rate > g.MaxBurnRate {
+						return fmt.Sprintf("SLO %s (%s) is burning its error budget at %.1fx over %s, above %.1fx",
+							status.Name, ind.SLI, rate, window, g.MaxBurnRate)
+					}
+				}
+			}
+		}
+	}
+	return ""
+}
+
+// ResidentMemory returns the resident memory of the sresim process in bytes.
+func ResidentMemory() uint64 {
+	return processRSS()
+}
+
+// readRSS reads the resident set size from /proc, falling back to the
+// memory obtained by the Go runtime where /proc is not available.
+func readRSS() uint64 {
+	if data, err := os.ReadFile("/proc/self/status"); err == nil {
+		scanner := bufio.NewScanner(bytes.NewReader(data))
+		for scanner.Scan() {
+			fields := bytes.Fields(scanner.Bytes())
+			if len(fields) >= 2 && string(fields[0]) == "VmRSS:" {
+				if kb, err := strconv.ParseUint(string(fields[1]), 10, 64); err == nil {
+					return kb << 10
+				}
+			}
+		}
+	}
+	var m runtime.MemStats
+	runtime.ReadMemStats(&m)
+	return m.Sys
+}
@@ -0,0 +1,140 @@
+package simulator
+
+import (
+	"runtime"
+	"testing"
+	"time"
+
+	"github.com/localstack/sresim/app-sresim/pkg/config"
+	"github.com/localstack/sresim/app-sresim/pkg/events"
+	"github.com/localstack/sresim/app-sresim/pkg/slo"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func fastGuard(t *testing.T) {
+	t.Helper()
+	interval := guardInterval
+	guardInterval = 10 * time.Millisecond
+	t.Cleanup(func() { guardInterval = interval })
+}
+
+func waitStatus(t *testing.T, sm *ScenarioManager, runID, status string) Run {
+	t.Helper()
+	var run Run
+	require.Eventually(t, func() bool {
+		run, _ = sm.GetRun(runID)
+		return run.Status == status
+	}, 5*time.Second, 10*time.Millisecond)
+	return run
+}
+
+func TestGuardrailMaxDuration(t *testing.T) {
+	fastGuard(t)
+	sm := newTestManager()
+	sm.SetGuardrails(config.Guardrails{MaxRSSMB: 1 << 20})
+
+	aborted, cancel := events.GetBus().Subscribe(10)
+	defer cancel()
+
+	run, err := sm.Start("latency", nil, &config.Guardrails{MaxDuration: config.Duration(50 * time.Millisecond)})
+	require.NoError(t, err)
+	require.NotNil(t, run.Guardrails)
+	assert.Equal(t, int64(1<<20), run.Guardrails.MaxRSSMB, "unset guardrails fall back to the defaults")
+
+	run = waitStatus(t, sm, run.ID, RunAborted)
+	assert.Contains(t, run.AbortReason, "max duration")
+	assert.False(t, sm.IsScenarioActive("latency"))
+
+	for e := range aborted {
+		if e.Type == events.ScenarioAborted && e.RunID == run.ID {
+			assert.Equal(t, run.AbortReason, e.Message)
+			break
+		}
+	}
+}
+
+func TestGuardrailMaxRSS(t *testing.T) {
+	fastGuard(t)
+	rss := processRSS
+	processRSS = func() uint64 { return 2 << 30 }
+	defer func() { processRSS = rss }()
+
+	sm := newTestManager()
+	run, err := sm.Start("latency", nil, &config.Guardrails{MaxRSSMB: 1024})
+	require.NoError(t, err)
+
+	run = waitStatus(t, sm, run.ID, RunAborted)
+	assert.Equal(t, "resident memory of 2048 MiB exceeds 1024 MiB", run.AbortReason)
+}
+
+func TestGuardrailBurnRate(t *testing.T) {
+	fastGuard(t)
+	tracker, err := slo.NewTracker([]config.SLO{{Name: "api", Handler: "/api", Availability: 0.99}})
+	require.NoError(t, err)
+
+	sm := newTestManager()
+	sm.SetSLOTracker(tracker)
+	run, err := sm.Start("latency", nil, &config.Guardrails{MaxBurnRate: 10, SLO: "api"})
+	require.NoError(t, err)
+
+	time.Sleep(50 * time.Millisecond)
+	current, _ := sm.GetRun(run.ID)
+	assert.Equal(t, RunRunning, current.Status, "no traffic, no burn")
+
+	// Half of the requests failing burns a 1% budget 50 times too fast.
+	for i := 0; i < 10; i++ {
+		tracker.Observe("/api", 200, time.Millisecond)
+		tracker.Observe("/api", 500, time.Millisecond)
+	}
+	run = waitStatus(t, sm, run.ID, RunAborted)
+	assert.Contains(t, run.AbortReason, "SLO api (availability)")
+}
+
+func TestGuardrailValidation(t *testing.T) {
+	sm := newTestManager()
+	_, err := sm.Start("latency", nil, &config.Guardrails{MaxErrorRate: 2})
+	assert.Error(t, err)
+	_, err = sm.Start("latency", nil, &config.Guardrails{MaxBurnRate: 10, BurnRateWindow: "2m"})
+	assert.Error(t, err)
+	_, err = sm.Start("latency", nil, &config.Guardrails{MaxDuration: config.Duration(-time.Second)})
+	assert.Error(t, err)
+	assert.False(t, sm.IsScenarioActive("latency"))
+}
+
+func TestKillAll(t *testing.T) {
+	sm := newTestManager()
+	first, err := sm.Start("latency", nil, nil)
+	require.NoError(t, err)
+	second, err := sm.Start("rate_limit", nil, nil)
+	require.NoError(t, err)
+	assert.Nil(t, first.Guardrails, "no guardrails configured")
+
+	aborted := sm.KillAll("kill switch")
+	assert.ElementsMatch(t, []string{first.ID, second.ID}, aborted)
+
+	for _, id := range aborted {
+		run, _ := sm.GetRun(id)
+		assert.Equal(t, RunAborted, run.Status)
+		assert.Equal(t, "kill switch", run.AbortReason)
+	}
+	assert.False(t, sm.IsScenarioActive("latency"))
+	assert.False(t, sm.IsScenarioActive("rate_limit"))
+	assert.Empty(t, sm.KillAll("kill switch"))
+}
+
+func TestKillAllStopsCPUSpike(t *testing.T) {
+	sm := newTestManager()
+	before := runtime.NumGoroutine()
+	_, err := sm.Start("cpu_spike", map[string]interface{}{"duration_seconds": 60}, nil)
+	require.NoError(t, err)
+	require.Greater(t, runtime.NumGoroutine(), before, "the spike has started")
+
+	// Polled by hand, as assert.Eventually runs the condition in a goroutine.
+	sm.KillAll("kill switch")
+	deadline := time.Now().Add(5 * time.Second)
+	for runtime.NumGoroutine() > before && time.Now().Before(deadline) {
+		time.Sleep(10 * time.Millisecond)
+	}
+	assert.LessOrEqual(t, runtime.NumGoroutine(), before, "the workers stop in the middle of the spike")
+}
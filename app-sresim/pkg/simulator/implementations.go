@@ -1,31 +1,275 @@
 package simulator
 
 import (
+	"context"
+	"errors"
+	"fmt"
+	"log/slog"
 	"math/rand"
 	"runtime"
+	"sort"
+	"strings"
 	"sync"
 	"time"
+
+	"github.com/localstack/sresim/app-sresim/pkg/config"
+	"github.com/localstack/sresim/app-sresim/pkg/events"
+	"github.com/localstack/sresim/app-sresim/pkg/health"
+	"github.com/localstack/sresim/app-sresim/pkg/loadgen"
+	"github.com/localstack/sresim/app-sresim/pkg/metrics"
+	"github.com/localstack/sresim/app-sresim/pkg/slo"
+	"github.com/localstack/sresim/app-sresim/pkg/store"
+	"github.com/localstack/sresim/app-sresim/pkg/tracegen"
+	"github.com/localstack/sresim/app-sresim/pkg/tracing"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/trace"
 )
 
+// ErrScenarioActive is returned when starting a scenario that is already running.
+var ErrScenarioActive = errors.New("scenario is already running")
+
 type ScenarioManager struct {
 	activeScenarios map[string]bool
 	stopChannels    map[string]chan struct{}
+	loads           map[string]*loadgen.Run
+	runs            map[string]*Run
+	current         map[string]*Run
+	guardrails      config.Guardrails
+	sloTracker      *slo.Tracker
+	store           *store.Store
+	metrics         *metrics.Registry
 	mu              sync.RWMutex
 }
 
-var manager = &ScenarioManager{
-	activeScenarios: make(map[string]bool),
-	stopChannels:    make(map[string]chan struct{}),
+// NewManager returns a scenario manager that records scenario metrics in m
+// and reads the request metrics its guardrails and hypotheses check from it.
+func NewManager(m *metrics.Registry) *ScenarioManager {
+	return &ScenarioManager{
+		activeScenarios: make(map[string]bool),
+		stopChannels:    make(map[string]chan struct{}),
+		loads:           make(map[string]*loadgen.Run),
+		runs:            make(map[string]*Run),
+		current:         make(map[string]*Run),
+		metrics:         m,
+	}
 }
 
-// StartLatencySimulation simulates high network latency
-func (sm *ScenarioManager) StartLatencySimulation(delayMs int) {
+// SetGuardrails sets the guardrails applied to runs that do not override
+// them.
+func (sm *ScenarioManager) SetGuardrails(g config.Guardrails) {
+	sm.mu.Lock()
+	defer sm.mu.Unlock()
+	sm.guardrails = g
+}
+
+// SetSLOTracker sets the tracker burn rate guardrails are checked against.
+func (sm *ScenarioManager) SetSLOTracker(t *slo.Tracker) {
 	sm.mu.Lock()
-	sm.activeScenarios["latency"] = true
+	defer sm.mu.Unlock()
+	sm.sloTracker = t
+}
+
+// Start launches the named scenario and returns its run record. Parameters
+// missing from params are taken from the scenario's defaults, and guardrails
+// left unset from the manager's defaults.
+func (sm *ScenarioManager) Start(scenarioName string, params map[string]interface{}, guardrails *config.Guardrails) (Run, error) {
+	run, start, err := sm.prepare(scenarioName, params, guardrails)
+	if err != nil {
+		return Run{}, err
+	}
+	sm.launch(run, start)
+	return sm.snapshot(run), nil
+}
+
+// prepare validates the parameters and reserves the scenario for a new run
+// without starting it yet. The returned function injects the fault and
+// removes it again once its stop channel is closed.
+func (sm *ScenarioManager) prepare(scenarioName string, params map[string]interface{}, guardrails *config.Guardrails) (*Run, func(stopCh chan struct{}), error) {
+	scenario, exists := scenarios[scenarioName]
+	if !exists {
+		return nil, nil, fmt.Errorf("unknown scenario %q", scenarioName)
+	}
+
+	p := newParams(scenario.Parameters, params)
+	var start func(stopCh chan struct{})
+	switch scenarioName {
+	case "latency":
+		delayMs := p.int("delay_ms")
+		start = func(stopCh chan struct{}) { sm.StartLatencySimulation(stopCh, delayMs) }
+	case "error_rate":
+		errorPercentage := p.int("error_percentage")
+		start = func(stopCh chan struct{}) { sm.StartErrorRateSimulation(stopCh, errorPercentage) }
+	case "resource_exhaustion":
+		cpuPercentage := p.int("cpu_percentage")
+		memoryPercentage := p.int("memory_percentage")
+		start = func(stopCh chan struct{}) {
+			sm.StartResourceExhaustionSimulation(stopCh, cpuPercentage, memoryPercentage)
+		}
+	case "circuit_breaker":
+		threshold := p.int("threshold")
+		timeout := p.int("timeout")
+		start = func(stopCh chan struct{}) { sm.StartCircuitBreakerSimulation(stopCh, threshold, timeout) }
+	case "rate_limit":
+		requestsPerSecond := p.positive("requests_per_second")
+		start = func(stopCh chan struct{}) { sm.StartRateLimitSimulation(stopCh, requestsPerSecond) }
+	case "network_partition":
+		duration := p.int("partition_duration")
+		start = func(stopCh chan struct{}) { sm.StartNetworkPartitionSimulation(stopCh, duration) }
+	case "memory_leak":
+		leakRate := p.positive("leak_rate_mb_per_second")
+		duration := p.int("duration_seconds")
+		start = func(stopCh chan struct{}) { sm.StartMemoryLeakSimulation(stopCh, leakRate, duration) }
+	case "cpu_spike":
+		spikePercentage := p.int("spike_percentage")
+		duration := p.int("duration_seconds")
+		interval := p.int("interval_seconds")
+		start = func(stopCh chan struct{}) { sm.StartCPUSpikeSimulation(stopCh, spikePercentage, duration, interval) }
+	case "disk_io":
+		opsPerSecond := p.positive("io_operations_per_second")
+		fileSize := p.positive("file_size_mb")
+		start = func(stopCh chan struct{}) { sm.StartDiskIOSimulation(stopCh, opsPerSecond, fileSize) }
+	case "connection_pool_exhaustion":
+		maxConnections := p.int("max_connections")
+		holdTime := p.int("hold_time_seconds")
+		start = func(stopCh chan struct{}) {
+			sm.StartConnectionPoolExhaustionSimulation(stopCh, maxConnections, holdTime)
+		}
+	case "cascading_failure":
+		chainLength := p.int("failure_chain_length")
+		delay := p.int("delay_between_failures_seconds")
+		start = func(stopCh chan struct{}) { sm.StartCascadingFailureSimulation(stopCh, chainLength, delay) }
+	case "thundering_herd":
+		concurrentRequests := p.int("concurrent_requests")
+		cacheMissPercentage := p.int("cache_miss_percentage")
+		start = func(stopCh chan struct{}) {
+			sm.StartThunderingHerdSimulation(stopCh, concurrentRequests, cacheMissPercentage)
+		}
+	case "readiness_flap":
+		down := p.positive("down_seconds")
+		up := p.int("up_seconds")
+		start = func(stopCh chan struct{}) { sm.StartReadinessFlapSimulation(stopCh, down, up) }
+	case "log_storm":
+		linesPerSecond := p.positive("lines_per_second")
+		stackTracePercentage := p.int("stack_trace_percentage")
+		payloadBytes := p.int("payload_bytes")
+		if p.err == nil && (stackTracePercentage < 0 || stackTracePercentage > 100) {
+			p.err = fmt.Errorf("parameter %q must be between 0 and 100, got %d", "stack_trace_percentage", stackTracePercentage)
+		}
+		if p.err == nil && payloadBytes < 0 {
+			p.err = fmt.Errorf("parameter %q must not be negative, got %d", "payload_bytes", payloadBytes)
+		}
+		start = func(stopCh chan struct{}) {
+			sm.StartLogStormSimulation(stopCh, linesPerSecond, stackTracePercentage, payloadBytes)
+		}
+	case "synthetic_traces":
+		opts := tracegen.Options{
+			TracesPerSecond: p.positive("traces_per_second"),
+			FanOut:          p.int("fan_out"),
+			NPlusOne:        p.int("n_plus_one"),
+			SlowPercentage:  p.int("slow_percentage"),
+			ErrorPercentage: p.int("error_percentage"),
+			GapPercentage:   p.int("gap_percentage"),
+			Culprit:         p.string("culprit"),
+		}
+		if p.err != nil {
+			break
+		}
+		gen, err := tracegen.New(tracegen.Shop, opts, tracing.ServiceTracer, rand.Int63())
+		if err != nil {
+			p.err = err
+			break
+		}
+		// The run record tells instructors which service to look for.
+		p.values["culprit"] = gen.Culprit()
+		start = func(stopCh chan struct{}) { sm.StartSyntheticTracesSimulation(stopCh, gen) }
+	}
+	if p.err != nil {
+		return nil, nil, p.err
+	}
+	var g config.Guardrails
+	if guardrails != nil {
+		g = *guardrails
+	}
+	if err := validateGuardrails(g); err != nil {
+		return nil, nil, err
+	}
+
+	sm.mu.Lock()
+	defer sm.mu.Unlock()
+	if sm.current[scenarioName] != nil || sm.activeScenarios[scenarioName] {
+		return nil, nil, ErrScenarioActive
+	}
+	run := newRun(scenarioName, p.values)
+	if g = g.Merge(sm.guardrails); !g.IsZero() {
+		run.Guardrails = &g
+	}
+	sm.runs[run.ID] = run
+	sm.current[scenarioName] = run
+	sm.save(run)
+	return run, start, nil
+}
+
+// launch injects the fault of a prepared run. It returns false if the run
+// was stopped before it got the chance to start.
+func (sm *ScenarioManager) launch(run *Run, start func(stopCh chan struct{})) bool {
+	sm.mu.Lock()
+	if sm.current[run.Scenario] != run {
+		sm.mu.Unlock()
+		return false
+	}
+	// The stop channel is registered together with the running status, so a
+	// stop that comes in while the fault is being injected still tears it
+	// down rather than leaving it behind for a run that already ended.
 	stopCh := make(chan struct{})
-	sm.stopChannels["latency"] = stopCh
+	sm.activeScenarios[run.Scenario] = true
+	sm.stopChannels[run.Scenario] = stopCh
+	now := time.Now()
+	run.StartedAt = &now
+	run.Status = RunRunning
+	run.span = tracing.StartRun(run.Scenario, run.ID, run.Parameters)
+	sm.save(run)
+	sm.metrics.Scenario(run.Scenario).SetActive(true)
+	events.Publish(events.Event{Type: events.ScenarioStarted, RunID: run.ID, Scenario: run.Scenario})
 	sm.mu.Unlock()
 
+	start(stopCh)
+	if run.Guardrails != nil {
+		go sm.guard(run, *run.Guardrails)
+	}
+	return true
+}
+
+// publish publishes an event about the current run of a scenario.
+func (sm *ScenarioManager) publish(scenarioName, eventType, message string, data map[string]interface{}) {
+	e := events.Event{Type: eventType, Scenario: scenarioName, Message: message, Data: data}
+	sm.mu.RLock()
+	if run := sm.current[scenarioName]; run != nil {
+		e.RunID = run.ID
+		if run.span != nil {
+			run.span.AddEvent(eventType, trace.WithAttributes(attribute.String("message", message)))
+		}
+	}
+	sm.mu.RUnlock()
+	events.Publish(e)
+}
+
+// AttachLoad ties a load generation run to a scenario run so that stopping
+// the scenario also stops the traffic driving it.
+func (sm *ScenarioManager) AttachLoad(runID string, load *loadgen.Run) {
+	sm.mu.Lock()
+	defer sm.mu.Unlock()
+	run, ok := sm.runs[runID]
+	if !ok {
+		return
+	}
+	run.LoadRunID = load.ID
+	load.SetScenarios(run.Scenario)
+	sm.loads[run.Scenario] = load
+	sm.save(run)
+}
+
+// StartLatencySimulation simulates high network latency
+func (sm *ScenarioManager) StartLatencySimulation(stopCh chan struct{}, delayMs int) {
 	go func() {
 		for {
 			select {
@@ -39,13 +283,7 @@ func (sm *ScenarioManager) StartLatencySimulation(delayMs int) {
 }
 
 // StartErrorRateSimulation simulates high error rate
-func (sm *ScenarioManager) StartErrorRateSimulation(errorPercentage int) {
-	sm.mu.Lock()
-	sm.activeScenarios["error_rate"] = true
-	stopCh := make(chan struct{})
-	sm.stopChannels["error_rate"] = stopCh
-	sm.mu.Unlock()
-
+func (sm *ScenarioManager) StartErrorRateSimulation(stopCh chan struct{}, errorPercentage int) {
 	go func() {
 		for {
 			select {
@@ -62,11 +300,9 @@ func (sm *ScenarioManager) StartErrorRateSimulation(errorPercentage int) {
 }
 
 // StartResourceExhaustionSimulation simulates CPU and memory exhaustion
-func (sm *ScenarioManager) StartResourceExhaustionSimulation(cpuPercentage, memoryPercentage int) {
+func (sm *ScenarioManager) StartResourceExhaustionSimulation(stopCh chan struct{}, cpuPercentage, memoryPercentage int) {
 	sm.mu.Lock()
-	sm.activeScenarios["resource_exhaustion"] = true
-	stopCh := make(chan struct{})
-	sm.stopChannels["resource_exhaustion"] = stopCh
+	m := sm.metrics.Scenario("resource_exhaustion")
 	sm.mu.Unlock()
 
 	go func() {
@@ -77,6 +313,10 @@ func (sm *ScenarioManager) StartResourceExhaustionSimulation(cpuPercentage, memo
 		for i := 0; i < len(memory); i += 4096 {
 			memory[i] = 1
 		}
+		m.UpdateMemoryUsage(int64(memorySize))
+		m.UpdateCPUUsage(float64(cpuPercentage))
+		defer m.UpdateMemoryUsage(0)
+		defer m.UpdateCPUUsage(0)
 
 		// CPU intensive loop
 		for {
@@ -93,81 +333,166 @@ func (sm *ScenarioManager) StartResourceExhaustionSimulation(cpuPercentage, memo
 	}()
 }
 
-// StartCircuitBreakerSimulation simulates circuit breaker pattern
-func (sm *ScenarioManager) StartCircuitBreakerSimulation(threshold int, timeoutSeconds int) {
+// Circuit breaker states, numbered as in the sresim_circuit_breaker_state
+// gauge.
+const (
+	breakerClosed   = "closed"
+	breakerOpen     = "open"
+	breakerHalfOpen = "half-open"
+)
+
+var breakerStateCodes = map[string]int{breakerClosed: 0, breakerOpen: 1, breakerHalfOpen: 2}
+
+// breakerCallInterval is how often the simulated client calls the failing
+// dependency behind the circuit breaker.
+var breakerCallInterval = 100 * time.Millisecond
+
+// StartCircuitBreakerSimulation simulates a circuit breaker in front of a
+// dependency that keeps failing: it opens after threshold failures, lets a
+// probe through after timeoutSeconds and opens again when the probe fails.
+func (sm *ScenarioManager) StartCircuitBreakerSimulation(stopCh chan struct{}, threshold int, timeoutSeconds int) {
 	sm.mu.Lock()
-	sm.activeScenarios["circuit_breaker"] = true
-	stopCh := make(chan struct{})
-	sm.stopChannels["circuit_breaker"] = stopCh
+	m := sm.metrics.Scenario("circuit_breaker")
 	sm.mu.Unlock()
 
 	go func() {
+		timeout := time.Duration(timeoutSeconds) * time.Second
+		state := breakerClosed
 		failures := 0
-		lastFailure := time.Now()
+		var openedAt time.Time
+		setState := func(next string) {
+			sm.publish("circuit_breaker", events.BreakerStateChanged, state+" -> "+next, map[string]interface{}{
+				"from":     state,
+				"to":       next,
+				"failures": failures,
+			})
+			state = next
+			m.UpdateCircuitBreakerState(breakerStateCodes[state])
+		}
+		m.UpdateCircuitBreakerState(breakerStateCodes[state])
+		defer m.UpdateCircuitBreakerState(breakerStateCodes[breakerClosed])
 
+		ticker := time.NewTicker(breakerCallInterval)
+		defer ticker.Stop()
 		for {
 			select {
 			case <-stopCh:
 				return
-			default:
-				if time.Since(lastFailure).Seconds() > float64(timeoutSeconds) {
-					failures = 0
-				}
-				if failures >= threshold {
-					time.Sleep(time.Second)
+			case <-ticker.C:
+				switch state {
+				case breakerClosed:
+					failures++
+					m.RecordCircuitBreakerFailure()
+					if failures >= threshold {
+						openedAt = time.Now()
+						setState(breakerOpen)
+					}
+				case breakerOpen:
+					if time.Since(openedAt) >= timeout {
+						setState(breakerHalfOpen)
+					}
+				case breakerHalfOpen:
+					// The dependency is still down, so the probe fails.
+					failures++
+					m.RecordCircuitBreakerFailure()
+					openedAt = time.Now()
+					setState(breakerOpen)
 				}
 			}
 		}
 	}()
 }
 
-// StartRateLimitSimulation simulates rate limiting
-func (sm *ScenarioManager) StartRateLimitSimulation(requestsPerSecond int) {
+// rateLimitWindow is the period over which the rate limit simulation
+// compares simulated traffic with the limit.
+var rateLimitWindow = time.Second
+
+// StartRateLimitSimulation simulates a rate limiter in front of traffic
+// that fluctuates between half and twice the limit. A rate_limit.burst
+// event is published whenever requests start being rejected.
+func (sm *ScenarioManager) StartRateLimitSimulation(stopCh chan struct{}, requestsPerSecond int) {
 	sm.mu.Lock()
-	sm.activeScenarios["rate_limit"] = true
-	stopCh := make(chan struct{})
-	sm.stopChannels["rate_limit"] = stopCh
+	m := sm.metrics.Scenario("rate_limit")
 	sm.mu.Unlock()
 
 	go func() {
-		interval := time.Second / time.Duration(requestsPerSecond)
+		m.UpdateRateLimit(float64(requestsPerSecond))
+		limit := requestsPerSecond * int(rateLimitWindow) / int(time.Second)
+		if limit < 1 {
+			limit = 1
+		}
+		inBurst := false
+
+		ticker := time.NewTicker(rateLimitWindow)
+		defer ticker.Stop()
 		for {
 			select {
 			case <-stopCh:
 				return
-			default:
-				time.Sleep(interval)
+			case <-ticker.C:
+				offered := limit/2 + rand.Intn(limit*2-limit/2+1)
+				rejected := offered - limit
+				if rejected <= 0 {
+					inBurst = false
+					continue
+				}
+				for i := 0; i < rejected; i++ {
+					m.RecordRateLimitHit()
+				}
+				if !inBurst {
+					sm.publish("rate_limit", events.RateLimitBurst, fmt.Sprintf("%d of %d requests rejected", rejected, offered), map[string]interface{}{
+						"limit":    limit,
+						"offered":  offered,
+						"rejected": rejected,
+					})
+				}
+				inBurst = true
 			}
 		}
 	}()
 }
 
-// StartNetworkPartitionSimulation simulates network partition
-func (sm *ScenarioManager) StartNetworkPartitionSimulation(durationSeconds int) {
+// networkPartitionCallInterval is how often calls across the simulated
+// partition fail.
+var networkPartitionCallInterval = time.Second
+
+// StartNetworkPartitionSimulation simulates network partition: calls to the
+// other side fail until the partition heals after durationSeconds.
+func (sm *ScenarioManager) StartNetworkPartitionSimulation(stopCh chan struct{}, durationSeconds int) {
 	sm.mu.Lock()
-	sm.activeScenarios["network_partition"] = true
-	stopCh := make(chan struct{})
-	sm.stopChannels["network_partition"] = stopCh
+	m := sm.metrics.Scenario("network_partition")
 	sm.mu.Unlock()
 
 	go func() {
-		time.Sleep(time.Duration(durationSeconds) * time.Second)
-		sm.StopScenario("network_partition")
+		healed := time.After(time.Duration(durationSeconds) * time.Second)
+		ticker := time.NewTicker(networkPartitionCallInterval)
+		defer ticker.Stop()
+		m.RecordNetworkError("partition")
+		for {
+			select {
+			case <-stopCh:
+				return
+			case <-healed:
+				sm.StopScenario("network_partition")
+				return
+			case <-ticker.C:
+				m.RecordNetworkError("partition")
+			}
+		}
 	}()
 }
 
 // StartMemoryLeakSimulation simulates memory leak
-func (sm *ScenarioManager) StartMemoryLeakSimulation(leakRateMB int, durationSeconds int) {
+func (sm *ScenarioManager) StartMemoryLeakSimulation(stopCh chan struct{}, leakRateMB int, durationSeconds int) {
 	sm.mu.Lock()
-	sm.activeScenarios["memory_leak"] = true
-	stopCh := make(chan struct{})
-	sm.stopChannels["memory_leak"] = stopCh
+	m := sm.metrics.Scenario("memory_leak")
 	sm.mu.Unlock()
 
 	go func() {
 		leakInterval := time.Second / time.Duration(leakRateMB)
 		leakSize := 1024 * 1024 // 1MB
 		leakedMemory := make([][]byte, 0)
+		defer m.UpdateMemoryUsage(0)
 
 		for {
 			select {
@@ -178,49 +503,69 @@ func (sm *ScenarioManager) StartMemoryLeakSimulation(leakRateMB int, durationSec
 				return
 			default:
 				leakedMemory = append(leakedMemory, make([]byte, leakSize))
+				m.UpdateMemoryUsage(int64(len(leakedMemory) * leakSize))
 				time.Sleep(leakInterval)
 			}
 		}
 	}()
 }
 
-// StartCPUSpikeSimulation simulates CPU spikes
-func (sm *ScenarioManager) StartCPUSpikeSimulation(spikePercentage int, durationSeconds int, intervalSeconds int) {
+// StartCPUSpikeSimulation simulates CPU spikes: every intervalSeconds, one
+// worker per CPU burns CPU for durationSeconds. The workers stop with the
+// scenario, also in the middle of a spike.
+func (sm *ScenarioManager) StartCPUSpikeSimulation(stopCh chan struct{}, spikePercentage int, durationSeconds int, intervalSeconds int) {
 	sm.mu.Lock()
-	sm.activeScenarios["cpu_spike"] = true
-	stopCh := make(chan struct{})
-	sm.stopChannels["cpu_spike"] = stopCh
+	m := sm.metrics.Scenario("cpu_spike")
 	sm.mu.Unlock()
 
 	go func() {
+		defer m.UpdateCPUUsage(0)
 		for {
-			select {
-			case <-stopCh:
-				return
-			default:
-				// Create CPU spike
-				for i := 0; i < runtime.NumCPU(); i++ {
-					go func() {
-						for {
+			// Create CPU spike
+			m.UpdateCPUUsage(float64(spikePercentage))
+			spikeEnd := time.After(time.Duration(durationSeconds) * time.Second)
+			spikeDone := make(chan struct{})
+			for i := 0; i < runtime.NumCPU(); i++ {
+				go func() {
+					for {
+						select {
+						case <-stopCh:
+							return
+						case <-spikeDone:
+							return
+						default:
 							if rand.Float64()*100 < float64(spikePercentage) {
 								runtime.Gosched()
 							}
 						}
-					}()
-				}
-				time.Sleep(time.Duration(durationSeconds) * time.Second)
-				time.Sleep(time.Duration(intervalSeconds-durationSeconds) * time.Second)
+					}
+				}()
+			}
+			select {
+			case <-stopCh:
+				return
+			case <-spikeEnd:
+				close(spikeDone)
+				m.UpdateCPUUsage(0)
+			}
+
+			select {
+			case <-stopCh:
+				return
+			case <-time.After(time.Duration(intervalSeconds-durationSeconds) * time.Second):
 			}
 		}
 	}()
 }
 
-// StartDiskIOSimulation simulates disk I/O saturation
-func (sm *ScenarioManager) StartDiskIOSimulation(opsPerSecond int, fileSizeMB int) {
+// diskIOBlockSize is how much each simulated disk I/O operation reads.
+const diskIOBlockSize = 64 * 1024
+
+// StartDiskIOSimulation simulates disk I/O saturation: opsPerSecond reads
+// of diskIOBlockSize at random offsets of a fileSizeMB file.
+func (sm *ScenarioManager) StartDiskIOSimulation(stopCh chan struct{}, opsPerSecond int, fileSizeMB int) {
 	sm.mu.Lock()
-	sm.activeScenarios["disk_io"] = true
-	stopCh := make(chan struct{})
-	sm.stopChannels["disk_io"] = stopCh
+	m := sm.metrics.Scenario("disk_io")
 	sm.mu.Unlock()
 
 	go func() {
@@ -234,9 +579,10 @@ func (sm *ScenarioManager) StartDiskIOSimulation(opsPerSecond int, fileSizeMB in
 				return
 			default:
 				// Perform random I/O operations
-				offset := rand.Int63n(int64(len(data)))
-				length := rand.Intn(4096) + 1
-				_ = data[offset : offset+int64(length)]
+				length := min(diskIOBlockSize, len(data))
+				offset := rand.Intn(len(data) - length + 1)
+				_ = data[offset : offset+length]
+				m.RecordDiskIO(int64(length), "read")
 				time.Sleep(interval)
 			}
 		}
@@ -244,11 +590,9 @@ func (sm *ScenarioManager) StartDiskIOSimulation(opsPerSecond int, fileSizeMB in
 }
 
 // StartConnectionPoolExhaustionSimulation simulates connection pool exhaustion
-func (sm *ScenarioManager) StartConnectionPoolExhaustionSimulation(maxConnections int, holdTimeSeconds int) {
+func (sm *ScenarioManager) StartConnectionPoolExhaustionSimulation(stopCh chan struct{}, maxConnections int, holdTimeSeconds int) {
 	sm.mu.Lock()
-	sm.activeScenarios["connection_pool_exhaustion"] = true
-	stopCh := make(chan struct{})
-	sm.stopChannels["connection_pool_exhaustion"] = stopCh
+	m := sm.metrics.Scenario("connection_pool_exhaustion")
 	sm.mu.Unlock()
 
 	go func() {
@@ -268,6 +612,7 @@ func (sm *ScenarioManager) StartConnectionPoolExhaustionSimulation(maxConnection
 						time.Sleep(time.Duration(holdTimeSeconds) * time.Second)
 					default:
 						// Connection pool is full
+						m.RecordError("pool_exhausted")
 						time.Sleep(time.Millisecond * 100)
 					}
 				}
@@ -277,11 +622,9 @@ func (sm *ScenarioManager) StartConnectionPoolExhaustionSimulation(maxConnection
 }
 
 // StartCascadingFailureSimulation simulates cascading failures
-func (sm *ScenarioManager) StartCascadingFailureSimulation(chainLength int, delaySeconds int) {
+func (sm *ScenarioManager) StartCascadingFailureSimulation(stopCh chan struct{}, chainLength int, delaySeconds int) {
 	sm.mu.Lock()
-	sm.activeScenarios["cascading_failure"] = true
-	stopCh := make(chan struct{})
-	sm.stopChannels["cascading_failure"] = stopCh
+	m := sm.metrics.Scenario("cascading_failure")
 	sm.mu.Unlock()
 
 	go func() {
@@ -291,6 +634,7 @@ func (sm *ScenarioManager) StartCascadingFailureSimulation(chainLength int, dela
 				return
 			default:
 				// Simulate service failure
+				m.RecordError("dependency_failure")
 				time.Sleep(time.Duration(delaySeconds) * time.Second)
 				// Trigger cascading effect
 				runtime.Gosched()
@@ -301,13 +645,7 @@ func (sm *ScenarioManager) StartCascadingFailureSimulation(chainLength int, dela
 }
 
 // StartThunderingHerdSimulation simulates thundering herd problem
-func (sm *ScenarioManager) StartThunderingHerdSimulation(concurrentRequests int, cacheMissPercentage int) {
-	sm.mu.Lock()
-	sm.activeScenarios["thundering_herd"] = true
-	stopCh := make(chan struct{})
-	sm.stopChannels["thundering_herd"] = stopCh
-	sm.mu.Unlock()
-
+func (sm *ScenarioManager) StartThunderingHerdSimulation(stopCh chan struct{}, concurrentRequests int, cacheMissPercentage int) {
 	go func() {
 		var wg sync.WaitGroup
 		for {
@@ -332,16 +670,216 @@ func (sm *ScenarioManager) StartThunderingHerdSimulation(concurrentRequests int,
 	}()
 }
 
+// logStormTick is how often the log_storm scenario catches up with its
+// rate.
+var logStormTick = 10 * time.Millisecond
+
+// StartLogStormSimulation writes linesPerSecond error records to the log,
+// stackTracePercentage of them with a stack trace, each padded with
+// payloadBytes of data. Writing blocks when the log pipeline applies
+// backpressure, which slows down the storm and every other log writer.
+func (sm *ScenarioManager) StartLogStormSimulation(stopCh chan struct{}, linesPerSecond, stackTracePercentage, payloadBytes int) {
+	sm.mu.Lock()
+	var runID string
+	if run := sm.current["log_storm"]; run != nil {
+		runID = run.ID
+	}
+	sm.mu.Unlock()
+
+	payload := strings.Repeat("x", payloadBytes)
+	go func() {
+		ticker := time.NewTicker(logStormTick)
+		defer ticker.Stop()
+		started := time.Now()
+		written := 0
+		for {
+			select {
+			case <-stopCh:
+				return
+			case now := <-ticker.C:
+				due := int(float64(linesPerSecond) * now.Sub(started).Seconds())
+				for ; written < due; written++ {
+					attrs := []slog.Attr{
+						slog.String("scenario", "log_storm"),
+						slog.String("run_id", runID),
+						slog.Int("order_id", 100000+written),
+						slog.String("error", "context deadline exceeded"),
+						slog.String("payload", payload),
+					}
+					if rand.Intn(100) < stackTracePercentage {
+						attrs = append(attrs, slog.String("stack", fakeStackTrace(written)))
+					}
+					slog.LogAttrs(context.Background(), slog.LevelError, "failed to process order", attrs...)
+				}
+			}
+		}
+	}()
+}
+
+// fakeStackTrace returns a goroutine dump like the one of a panicking
+// handler.
+func fakeStackTrace(n int) string {
+	return fmt.Sprintf(`goroutine %d [running]:
+github.com/example/shop/internal/orders.(*Service).Process(0xc000132000, {0x1a2b3c0, 0xc0001a4000}, 0x%x)
+	/app/internal/orders/service.go:118 +0x1d4
+github.com/example/shop/internal/orders.(*Handler).ServeHTTP(0xc00011e0f0, {0x1a2c1e0, 0xc0002a8000}, 0xc0002b2000)
+	/app/internal/orders/handler.go:52 +0x2f1
+net/http.serverHandler.ServeHTTP({0xc0001b6000?}, {0x1a2c1e0?, 0xc0002a8000?}, 0x6?)
+	/usr/local/go/src/net/http/server.go:3142 +0x8e
+net/http.(*conn).serve(0xc0001c8000, {0x1a2d0f8, 0xc0001b4000})
+	/usr/local/go/src/net/http/server.go:2044 +0x5e8
+created by net/http.(*Server).Serve in goroutine 1
+	/usr/local/go/src/net/http/server.go:3290 +0x4b4`, 100+n%900, 100000+n)
+}
+
+// StartSyntheticTracesSimulation emits the traces of gen until the
+// scenario is stopped.
+func (sm *ScenarioManager) StartSyntheticTracesSimulation(stopCh chan struct{}, gen *tracegen.Generator) {
+	go gen.Run(stopCh)
+}
+
+// readinessFlapUnit is the unit of the readiness_flap periods.
+var readinessFlapUnit = time.Second
+
+// StartReadinessFlapSimulation fails the readiness probe for downSeconds
+// and lets it pass for upSeconds, over and over, so that Kubernetes keeps
+// removing the pod from its Service endpoints and adding it back. With
+// upSeconds of zero readiness fails until the scenario is stopped.
+func (sm *ScenarioManager) StartReadinessFlapSimulation(stopCh chan struct{}, downSeconds, upSeconds int) {
+	restore := health.GetChecker().FailReadiness("scenario readiness_flap is failing readiness")
+	sm.publish("readiness_flap", events.ReadinessChanged, "not ready", map[string]interface{}{"ready": false})
+	go func() {
+		down := time.NewTimer(time.Duration(downSeconds) * readinessFlapUnit)
+		defer down.Stop()
+		for {
+			select {
+			case <-stopCh:
+				restore()
+				return
+			case <-down.C:
+			}
+			if upSeconds <= 0 {
+				<-stopCh
+				restore()
+				return
+			}
+			restore()
+			sm.publish("readiness_flap", events.ReadinessChanged, "ready", map[string]interface{}{"ready": true})
+			select {
+			case <-stopCh:
+				return
+			case <-time.After(time.Duration(upSeconds) * readinessFlapUnit):
+			}
+			restore = health.GetChecker().FailReadiness("scenario readiness_flap is failing readiness")
+			sm.publish("readiness_flap", events.ReadinessChanged, "not ready", map[string]interface{}{"ready": false})
+			down.Reset(time.Duration(downSeconds) * readinessFlapUnit)
+		}
+	}()
+}
+
 // StopScenario stops a running simulation scenario
 func (sm *ScenarioManager) StopScenario(scenarioName string) {
-	sm.mu.Lock()
-	defer sm.mu.Unlock()
+	sm.stopScenario(scenarioName, RunStopped, "")
+}
 
+// stopScenario stops the scenario and finishes its current run with the
+// given status and abort reason.
+func (sm *ScenarioManager) stopScenario(scenarioName, status, reason string) {
+	sm.mu.Lock()
 	if stopCh, exists := sm.stopChannels[scenarioName]; exists {
 		close(stopCh)
 		delete(sm.stopChannels, scenarioName)
 		delete(sm.activeScenarios, scenarioName)
 	}
+	load := sm.loads[scenarioName]
+	delete(sm.loads, scenarioName)
+	run := sm.current[scenarioName]
+	delete(sm.current, scenarioName)
+	if run != nil {
+		run.AbortReason = reason
+		sm.finish(run, status)
+	}
+	sm.mu.Unlock()
+
+	if load != nil {
+		load.Stop()
+	}
+}
+
+// StopRun stops the scenario of the given run if that run is still the
+// current one for its scenario.
+func (sm *ScenarioManager) StopRun(runID string) {
+	sm.mu.RLock()
+	run := sm.runs[runID]
+	current := run != nil && sm.current[run.Scenario] == run
+	sm.mu.RUnlock()
+	if current {
+		sm.StopScenario(run.Scenario)
+	}
+}
+
+// Abort stops the scenario of the given run if that run is still the current
+// one for its scenario, and records why. It reports whether the run was
+// aborted.
+func (sm *ScenarioManager) Abort(runID, reason string) bool {
+	sm.mu.RLock()
+	run := sm.runs[runID]
+	current := run != nil && sm.current[run.Scenario] == run
+	sm.mu.RUnlock()
+	if !current {
+		return false
+	}
+	sm.stopScenario(run.Scenario, RunAborted, reason)
+	return true
+}
+
+// KillAll aborts every active scenario and returns the IDs of the runs that
+// were aborted.
+func (sm *ScenarioManager) KillAll(reason string) []string {
+	sm.mu.RLock()
+	var runIDs []string
+	names := make(map[string]bool)
+	for name, run := range sm.current {
+		names[name] = true
+		runIDs = append(runIDs, run.ID)
+	}
+	sm.mu.RUnlock()
+
+	for name := range names {
+		sm.stopScenario(name, RunAborted, reason)
+	}
+	sort.Strings(runIDs)
+	return runIDs
+}
+
+// ActiveRunIDs returns the IDs of the runs whose faults are currently
+// injected, sorted.
+func (sm *ScenarioManager) ActiveRunIDs() []string {
+	sm.mu.RLock()
+	var runIDs []string
+	for _, run := range sm.current {
+		if run.Status == RunRunning {
+			runIDs = append(runIDs, run.ID)
+		}
+	}
+	sm.mu.RUnlock()
+	sort.Strings(runIDs)
+	return runIDs
+}
+
+// ActiveScenarios returns the names of the scenarios currently injecting
+// faults, sorted.
+func (sm *ScenarioManager) ActiveScenarios() []string {
+	sm.mu.RLock()
+	names := make([]string, 0, len(sm.activeScenarios))
+	for name, active := range sm.activeScenarios {
+		if active {
+			names = append(names, name)
+		}
+	}
+	sm.mu.RUnlock()
+	sort.Strings(names)
+	return names
 }
 
 // IsScenarioActive checks if a scenario is currently running
@@ -350,8 +888,3 @@ func (sm *ScenarioManager) IsScenarioActive(scenarioName string) bool {
 	defer sm.mu.RUnlock()
 	return sm.activeScenarios[scenarioName]
 }
-
-// GetManager returns the singleton scenario manager
-func GetManager() *ScenarioManager {
-	return manager
-}
@@ -0,0 +1,294 @@
+package simulator
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"log/slog"
+	"net/http"
+	"sort"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/localstack/sresim/app-sresim/pkg/config"
+	"github.com/localstack/sresim/app-sresim/pkg/events"
+	"github.com/localstack/sresim/app-sresim/pkg/steadystate"
+	"github.com/localstack/sresim/app-sresim/pkg/store"
+	"github.com/localstack/sresim/app-sresim/pkg/tracing"
+	"go.opentelemetry.io/otel/codes"
+	"go.opentelemetry.io/otel/trace"
+)
+
+// Run states.
+const (
+	// RunPending is a run whose fault has not been injected yet, e.g. while
+	// the steady state is verified beforehand.
+	RunPending = "pending"
+	RunRunning = "running"
+	RunStopped = "stopped"
+	// RunSkipped is a run whose fault was never injected because the steady
+	// state did not hold beforehand.
+	RunSkipped = "skipped"
+	// RunAborted is a run stopped by a guardrail or the kill switch.
+	RunAborted = "aborted"
+	// RunInterrupted is a run that was still active when sresim restarted.
+	RunInterrupted = "interrupted"
+)
+
+// Run is the record of one execution of a scenario.
+type Run struct {
+	ID         string                 `json:"id"`
+	Scenario   string                 `json:"scenario"`
+	Parameters map[string]interface{} `json:"parameters"`
+	Status     string                 `json:"status"`
+	CreatedAt  time.Time              `json:"created_at"`
+	StartedAt  *time.Time             `json:"started_at,omitempty"`
+	EndedAt    *time.Time             `json:"ended_at,omitempty"`
+	LoadRunID  string                 `json:"load_run_id,omitempty"`
+	Guardrails *config.Guardrails     `json:"guardrails,omitempty"`
+	// AbortReason says which guardrail stopped an aborted run.
+	AbortReason string               `json:"abort_reason,omitempty"`
+	Verdict     *steadystate.Verdict `json:"verdict,omitempty"`
+	// Detection is the first alert that fired for the run, if any.
+	Detection *Detection `json:"detection,omitempty"`
+
+	done chan struct{}
+	// span traces the run from the injection of its fault until it ends.
+	span trace.Span
+}
+
+func newRun(scenarioName string, params map[string]interface{}) *Run {
+	return &Run{
+		ID:         uuid.NewString(),
+		Scenario:   scenarioName,
+		Parameters: params,
+		Status:     RunPending,
+		CreatedAt:  time.Now(),
+		done:       make(chan struct{}),
+	}
+}
+
+// finish records the end of a run. The caller must hold sm.mu.
+func (sm *ScenarioManager) finish(run *Run, status string) {
+	if run.EndedAt != nil {
+		return
+	}
+	now := time.Now()
+	run.EndedAt = &now
+	run.Status = status
+	if run.StartedAt == nil {
+		// A run stopped before its fault was injected never ran at all.
+		run.Status = RunSkipped
+	} else {
+		m := sm.metrics.Scenario(run.Scenario)
+		m.SetActive(false)
+		m.RecordDuration(now.Sub(*run.StartedAt))
+	}
+	switch run.Status {
+	case RunAborted:
+		events.Publish(events.Event{Type: events.ScenarioAborted, RunID: run.ID, Scenario: run.Scenario, Message: run.AbortReason})
+	case RunStopped:
+		events.Publish(events.Event{Type: events.ScenarioStopped, RunID: run.ID, Scenario: run.Scenario})
+	}
+	if run.span != nil {
+		run.span.SetAttributes(tracing.RunStatusKey.String(run.Status))
+		if run.AbortReason != "" {
+			run.span.SetAttributes(tracing.AbortReasonKey.String(run.AbortReason))
+		}
+		if run.Status == RunAborted {
+			run.span.SetStatus(codes.Error, run.AbortReason)
+		}
+		run.span.End(trace.WithTimestamp(now))
+	}
+	sm.save(run)
+	close(run.done)
+}
+
+// save writes the run to the store, if one is set. The caller must hold
+// sm.mu.
+func (sm *ScenarioManager) save(run *Run) {
+	if sm.store == nil {
+		return
+	}
+	record := store.Record{Kind: store.KindRun, ID: run.ID, Time: run.CreatedAt, Scenario: run.Scenario, Status: run.Status}
+	if err := sm.store.Put(record, run); err != nil {
+		slog.Error("Failed to save run", "run_id", run.ID, "error", err)
+	}
+}
+
+// SetStore persists runs to st from now on and loads the runs recorded
+// there. Runs that were still pending or running belong to a previous
+// process whose faults died with it; they are marked as interrupted.
+func (sm *ScenarioManager) SetStore(st *store.Store) error {
+	sm.mu.Lock()
+	defer sm.mu.Unlock()
+	sm.store = st
+	for _, record := range st.Query(store.Filter{Kind: store.KindRun}) {
+		run := &Run{}
+		if err := json.Unmarshal(record.Data, run); err != nil {
+			return fmt.Errorf("run %s: %w", record.ID, err)
+		}
+		run.done = make(chan struct{})
+		close(run.done)
+		if run.Status == RunPending || run.Status == RunRunning {
+			now := time.Now()
+			run.Status = RunInterrupted
+			run.EndedAt = &now
+			if run.Verdict != nil && run.Verdict.Result == steadystate.VerdictPending {
+				run.Verdict.Result = steadystate.VerdictFailed
+				run.Verdict.Reason = "run was interrupted by a restart"
+			}
+			sm.save(run)
+		}
+		sm.runs[run.ID] = run
+	}
+	return nil
+}
+
+// snapshot returns a copy of the run that is safe to read without the lock.
+func (sm *ScenarioManager) snapshot(run *Run) Run {
+	sm.mu.RLock()
+	defer sm.mu.RUnlock()
+	return *run
+}
+
+func (sm *ScenarioManager) setVerdict(run *Run, verdict steadystate.Verdict) {
+	sm.mu.Lock()
+	defer sm.mu.Unlock()
+	run.Verdict = &verdict
+	sm.save(run)
+}
+
+// GetRun returns the run record with the given ID.
+func (sm *ScenarioManager) GetRun(runID string) (Run, bool) {
+	sm.mu.RLock()
+	defer sm.mu.RUnlock()
+	run, ok := sm.runs[runID]
+	if !ok {
+		return Run{}, false
+	}
+	return *run, true
+}
+
+// Runs returns every run record, most recent first.
+func (sm *ScenarioManager) Runs() []Run {
+	sm.mu.RLock()
+	runs := make([]Run, 0, len(sm.runs))
+	for _, run := range sm.runs {
+		runs = append(runs, *run)
+	}
+	sm.mu.RUnlock()
+	sort.Slice(runs, func(i, j int) bool {
+		return runs[i].CreatedAt.After(runs[j].CreatedAt)
+	})
+	return runs
+}
+
+// StartVerified runs the scenario as an experiment: the hypothesis is
+// checked before the fault is injected, during the last window of the fault
+// and again after the scenario has been stopped. The verdict is recorded on
+// the returned run as the phases complete.
+func (sm *ScenarioManager) StartVerified(scenarioName string, params map[string]interface{}, guardrails *config.Guardrails, hypothesis steadystate.Hypothesis, duration time.Duration) (Run, error) {
+	if err := hypothesis.Validate(); err != nil {
+		return Run{}, err
+	}
+	if duration < hypothesis.WindowDuration() {
+		return Run{}, fmt.Errorf("duration %s is shorter than the hypothesis window %s", duration, hypothesis.WindowDuration())
+	}
+	run, start, err := sm.prepare(scenarioName, params, guardrails)
+	if err != nil {
+		return Run{}, err
+	}
+	sm.setVerdict(run, steadystate.Verdict{Result: steadystate.VerdictPending})
+	go sm.verify(run, start, hypothesis, duration)
+	return sm.snapshot(run), nil
+}
+
+func (sm *ScenarioManager) verify(run *Run, start func(stopCh chan struct{}), hypothesis steadystate.Hypothesis, duration time.Duration) {
+	ctx := context.Background()
+	verdict := steadystate.Verdict{Result: steadystate.VerdictPending}
+	conclude := func(result, reason string) {
+		verdict.Result = result
+		verdict.Reason = reason
+		sm.setVerdict(run, verdict)
+		events.Publish(events.Event{
+			Type:     events.ScenarioVerdict,
+			RunID:    run.ID,
+			Scenario: run.Scenario,
+			Message:  reason,
+			Data:     map[string]interface{}{"result": result},
+		})
+	}
+
+	before := hypothesis.Check(ctx, sm.metrics.Gatherer(), steadystate.PhaseBefore)
+	verdict.Checks = append(verdict.Checks, before)
+	sm.setVerdict(run, verdict)
+	if !before.Passed {
+		sm.mu.Lock()
+		if sm.current[run.Scenario] == run {
+			delete(sm.current, run.Scenario)
+		}
+		sm.finish(run, RunSkipped)
+		sm.mu.Unlock()
+		conclude(steadystate.VerdictFailed, "steady state was not met before the fault was injected")
+		return
+	}
+
+	if !sm.launch(run, start) {
+		conclude(steadystate.VerdictFailed, "run was stopped before the fault was injected")
+		return
+	}
+
+	// Measure during the last window of the fault so it has had time to
+	// take effect.
+	select {
+	case <-time.After(duration - hypothesis.WindowDuration()):
+	case <-run.done:
+	}
+	switch ended := sm.snapshot(run); ended.Status {
+	case RunAborted:
+		// The fault is already rolled back; only recovery is left to verify.
+		after := hypothesis.Check(ctx, sm.metrics.Gatherer(), steadystate.PhaseAfter)
+		verdict.Checks = append(verdict.Checks, after)
+		conclude(steadystate.VerdictFailed, "run was aborted: "+ended.AbortReason)
+		return
+	case RunStopped:
+		// Without the fault, a check now would say nothing about it.
+		after := hypothesis.Check(ctx, sm.metrics.Gatherer(), steadystate.PhaseAfter)
+		verdict.Checks = append(verdict.Checks, after)
+		conclude(steadystate.VerdictInconclusive, "run was stopped before the fault was verified")
+		return
+	}
+	during := hypothesis.Check(ctx, sm.metrics.Gatherer(), steadystate.PhaseDuring)
+	verdict.Checks = append(verdict.Checks, during)
+	sm.setVerdict(run, verdict)
+
+	// Roll back the fault before verifying that the system recovered.
+	sm.StopRun(run.ID)
+	after := hypothesis.Check(ctx, sm.metrics.Gatherer(), steadystate.PhaseAfter)
+	verdict.Checks = append(verdict.Checks, after)
+
+	switch {
+	case !during.Passed:
+		conclude(steadystate.VerdictFailed, "steady state deviated during the fault")
+	case !after.Passed:
+		conclude(steadystate.VerdictFailed, "steady state did not recover after rollback")
+	default:
+		conclude(steadystate.VerdictPassed, "")
+	}
+}
+
+// RunsHandler returns all run records, or a single one when ?id= is set.
+func (sm *ScenarioManager) RunsHandler(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Content-Type", "application/json")
+	if id := r.URL.Query().Get("id"); id != "" {
+		run, ok := sm.GetRun(id)
+		if !ok {
+			http.Error(w, "Run not found", http.StatusNotFound)
+			return
+		}
+		json.NewEncoder(w).Encode(run)
+		return
+	}
+	json.NewEncoder(w).Encode(sm.Runs())
+}
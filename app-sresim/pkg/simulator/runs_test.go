@@ -0,0 +1,358 @@
+package simulator
+
+import (
+	"context"
+	"log/slog"
+	"net/http"
+	"net/http/httptest"
+	"path/filepath"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/localstack/sresim/app-sresim/pkg/config"
+	"github.com/localstack/sresim/app-sresim/pkg/loadgen"
+	"github.com/localstack/sresim/app-sresim/pkg/metrics"
+	"github.com/localstack/sresim/app-sresim/pkg/steadystate"
+	"github.com/localstack/sresim/app-sresim/pkg/store"
+	"github.com/localstack/sresim/app-sresim/pkg/tracing"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"go.opentelemetry.io/otel"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/codes"
+	sdktrace "go.opentelemetry.io/otel/sdk/trace"
+	"go.opentelemetry.io/otel/sdk/trace/tracetest"
+)
+
+func newTestManager() *ScenarioManager {
+	return NewManager(metrics.NewRegistry())
+}
+
+func waitVerdict(t *testing.T, sm *ScenarioManager, runID string) Run {
+	t.Helper()
+	var run Run
+	require.Eventually(t, func() bool {
+		run, _ = sm.GetRun(runID)
+		return run.Verdict != nil && run.Verdict.Result != steadystate.VerdictPending
+	}, 5*time.Second, 10*time.Millisecond)
+	return run
+}
+
+func TestRunLifecycle(t *testing.T) {
+	sm := newTestManager()
+
+	run, err := sm.Start("latency", map[string]interface{}{"delay_ms": 10}, nil)
+	require.NoError(t, err)
+	assert.Equal(t, RunRunning, run.Status)
+	assert.Equal(t, 10, run.Parameters["delay_ms"])
+
+	_, err = sm.Start("latency", nil, nil)
+	assert.ErrorIs(t, err, ErrScenarioActive)
+
+	sm.StopRun(run.ID)
+	stopped, ok := sm.GetRun(run.ID)
+	require.True(t, ok)
+	assert.Equal(t, RunStopped, stopped.Status)
+	assert.NotNil(t, stopped.EndedAt)
+	assert.False(t, sm.IsScenarioActive("latency"))
+
+	_, err = sm.Start("no_such_scenario", nil, nil)
+	assert.Error(t, err)
+}
+
+func TestStartKillAllRace(t *testing.T) {
+	sm := newTestManager()
+
+	done := make(chan struct{})
+	var wg sync.WaitGroup
+	wg.Add(1)
+	go func() {
+		defer wg.Done()
+		for {
+			select {
+			case <-done:
+				return
+			default:
+				sm.KillAll("test")
+			}
+		}
+	}()
+	for i := 0; i < 500; i++ {
+		_, _ = sm.Start("latency", map[string]interface{}{"delay_ms": 1}, nil)
+	}
+	close(done)
+	wg.Wait()
+	t.Cleanup(func() { sm.KillAll("test") })
+
+	// No fault may outlive the run it was started for.
+	sm.mu.RLock()
+	defer sm.mu.RUnlock()
+	for name := range sm.stopChannels {
+		run := sm.current[name]
+		if assert.NotNil(t, run, "%s has a stop channel but no run", name) {
+			assert.Equal(t, RunRunning, run.Status)
+		}
+	}
+	for name := range sm.activeScenarios {
+		assert.NotNil(t, sm.current[name], "%s is active but has no run", name)
+	}
+	for _, run := range sm.runs {
+		if run != sm.current[run.Scenario] {
+			assert.NotNil(t, run.EndedAt, "run %s was left running", run.ID)
+		}
+	}
+}
+
+func TestRunSpan(t *testing.T) {
+	exporter := tracetest.NewInMemoryExporter()
+	prev := otel.GetTracerProvider()
+	otel.SetTracerProvider(sdktrace.NewTracerProvider(sdktrace.WithSyncer(exporter)))
+	t.Cleanup(func() { otel.SetTracerProvider(prev) })
+	sm := newTestManager()
+
+	run, err := sm.Start("latency", map[string]interface{}{"delay_ms": 10}, nil)
+	require.NoError(t, err)
+	assert.Equal(t, []string{run.ID}, sm.ActiveRunIDs())
+	sm.publish("latency", "latency.test", "still slow", nil)
+	assert.Empty(t, exporter.GetSpans(), "the span lasts as long as the run")
+
+	require.True(t, sm.Abort(run.ID, "max duration exceeded"))
+	assert.Empty(t, sm.ActiveRunIDs())
+
+	spans := exporter.GetSpans()
+	require.Len(t, spans, 1)
+	span := spans[0]
+	assert.Equal(t, "scenario latency", span.Name)
+	assert.Equal(t, codes.Error, span.Status.Code)
+	assert.Equal(t, "max duration exceeded", span.Status.Description)
+	attrs := make(map[attribute.Key]string)
+	for _, kv := range span.Attributes {
+		attrs[kv.Key] = kv.Value.Emit()
+	}
+	assert.Equal(t, run.ID, attrs[tracing.RunIDKey])
+	assert.Equal(t, RunAborted, attrs[tracing.RunStatusKey])
+	assert.Equal(t, "10", attrs[tracing.ParameterPrefix+"delay_ms"])
+	require.Len(t, span.Events, 1)
+	assert.Equal(t, "latency.test", span.Events[0].Name)
+}
+
+func TestSyntheticTraces(t *testing.T) {
+	exporter := tracetest.NewInMemoryExporter()
+	prev := otel.GetTracerProvider()
+	otel.SetTracerProvider(sdktrace.NewTracerProvider(sdktrace.WithSyncer(exporter)))
+	t.Cleanup(func() { otel.SetTracerProvider(prev) })
+	sm := newTestManager()
+
+	_, err := sm.Start("synthetic_traces", map[string]interface{}{"culprit": "billing"}, nil)
+	assert.ErrorContains(t, err, `unknown service "billing"`)
+	_, err = sm.Start("synthetic_traces", map[string]interface{}{"culprit": 3.0}, nil)
+	assert.ErrorContains(t, err, "must be a string")
+
+	run, err := sm.Start("synthetic_traces", map[string]interface{}{"traces_per_second": 100.0}, nil)
+	require.NoError(t, err)
+	assert.NotEmpty(t, run.Parameters["culprit"], "a culprit is picked and recorded")
+	require.Eventually(t, func() bool {
+		return len(exporter.GetSpans()) > 20
+	}, 5*time.Second, 10*time.Millisecond)
+	sm.StopRun(run.ID)
+}
+
+// recordingHandler keeps the records logged with it.
+type recordingHandler struct {
+	mu      sync.Mutex
+	records []slog.Record
+}
+
+func (h *recordingHandler) Enabled(context.Context, slog.Level) bool { return true }
+func (h *recordingHandler) WithAttrs([]slog.Attr) slog.Handler       { return h }
+func (h *recordingHandler) WithGroup(string) slog.Handler            { return h }
+
+func (h *recordingHandler) Handle(_ context.Context, r slog.Record) error {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	h.records = append(h.records, r)
+	return nil
+}
+
+func (h *recordingHandler) Records() []slog.Record {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	return append([]slog.Record(nil), h.records...)
+}
+
+func TestLogStorm(t *testing.T) {
+	logs := &recordingHandler{}
+	prev := slog.Default()
+	slog.SetDefault(slog.New(logs))
+	t.Cleanup(func() { slog.SetDefault(prev) })
+	sm := newTestManager()
+
+	_, err := sm.Start("log_storm", map[string]interface{}{"stack_trace_percentage": 101.0}, nil)
+	assert.ErrorContains(t, err, "between 0 and 100")
+	_, err = sm.Start("log_storm", map[string]interface{}{"payload_bytes": -1.0}, nil)
+	assert.ErrorContains(t, err, "must not be negative")
+
+	run, err := sm.Start("log_storm", map[string]interface{}{
+		"lines_per_second": 1000.0, "stack_trace_percentage": 100.0, "payload_bytes": 16.0,
+	}, nil)
+	require.NoError(t, err)
+	require.Eventually(t, func() bool {
+		return len(logs.Records()) >= 50
+	}, 5*time.Second, 10*time.Millisecond)
+	sm.StopRun(run.ID)
+
+	attrs := make(map[string]string)
+	storms := 0
+	for _, r := range logs.Records() {
+		if r.Message != "failed to process order" {
+			continue
+		}
+		storms++
+		assert.Equal(t, slog.LevelError, r.Level)
+		r.Attrs(func(a slog.Attr) bool {
+			attrs[a.Key] = a.Value.String()
+			return true
+		})
+	}
+	assert.GreaterOrEqual(t, storms, 50)
+	assert.Equal(t, run.ID, attrs["run_id"], "the lines carry the run ID")
+	assert.Len(t, attrs["payload"], 16)
+	assert.Contains(t, attrs["stack"], "goroutine ")
+}
+
+func TestStartVerified(t *testing.T) {
+	healthy := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {}))
+	defer healthy.Close()
+	broken := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusServiceUnavailable)
+	}))
+	defer broken.Close()
+
+	hypothesis := func(target string) steadystate.Hypothesis {
+		return steadystate.Hypothesis{
+			Window: config.Duration(50 * time.Millisecond),
+			Load:   &loadgen.Config{Target: target, Rate: 100},
+			Probes: []steadystate.Probe{
+				{Type: steadystate.ProbeErrorRate, Source: steadystate.SourceLoadgen, MaxErrorRate: 0.01},
+			},
+		}
+	}
+
+	t.Run("passes when steady throughout", func(t *testing.T) {
+		sm := newTestManager()
+		run, err := sm.StartVerified("latency", nil, nil, hypothesis(healthy.URL), 100*time.Millisecond)
+		require.NoError(t, err)
+		assert.Equal(t, RunPending, run.Status)
+
+		run = waitVerdict(t, sm, run.ID)
+		assert.Equal(t, steadystate.VerdictPassed, run.Verdict.Result)
+		require.Len(t, run.Verdict.Checks, 3)
+		assert.Equal(t, steadystate.PhaseBefore, run.Verdict.Checks[0].Phase)
+		assert.Equal(t, steadystate.PhaseDuring, run.Verdict.Checks[1].Phase)
+		assert.Equal(t, steadystate.PhaseAfter, run.Verdict.Checks[2].Phase)
+		assert.Equal(t, RunStopped, run.Status)
+		assert.False(t, sm.IsScenarioActive("latency"))
+	})
+
+	t.Run("skips the fault when not steady beforehand", func(t *testing.T) {
+		sm := newTestManager()
+		run, err := sm.StartVerified("latency", nil, nil, hypothesis(broken.URL), 100*time.Millisecond)
+		require.NoError(t, err)
+
+		run = waitVerdict(t, sm, run.ID)
+		assert.Equal(t, steadystate.VerdictFailed, run.Verdict.Result)
+		assert.Len(t, run.Verdict.Checks, 1)
+		assert.Equal(t, RunSkipped, run.Status)
+		assert.Nil(t, run.StartedAt)
+	})
+
+	t.Run("is inconclusive when stopped by hand", func(t *testing.T) {
+		sm := newTestManager()
+		run, err := sm.StartVerified("latency", nil, nil, hypothesis(healthy.URL), time.Minute)
+		require.NoError(t, err)
+		require.Eventually(t, func() bool {
+			run, _ = sm.GetRun(run.ID)
+			return run.Status == RunRunning
+		}, 5*time.Second, 10*time.Millisecond)
+		sm.StopRun(run.ID)
+
+		run = waitVerdict(t, sm, run.ID)
+		assert.Equal(t, steadystate.VerdictInconclusive, run.Verdict.Result)
+		require.Len(t, run.Verdict.Checks, 2)
+		assert.Equal(t, steadystate.PhaseBefore, run.Verdict.Checks[0].Phase)
+		assert.Equal(t, steadystate.PhaseAfter, run.Verdict.Checks[1].Phase, "nothing is checked during a fault that is gone")
+	})
+
+	t.Run("rejects a duration shorter than the window", func(t *testing.T) {
+		sm := newTestManager()
+		_, err := sm.StartVerified("latency", nil, nil, hypothesis(healthy.URL), time.Millisecond)
+		assert.Error(t, err)
+	})
+}
+
+func TestRunHistory(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "history.jsonl")
+	st, err := store.Open(path, 0)
+	require.NoError(t, err)
+
+	sm := newTestManager()
+	require.NoError(t, sm.SetStore(st))
+	finished, err := sm.Start("latency", nil, nil)
+	require.NoError(t, err)
+	sm.StopRun(finished.ID)
+	active, err := sm.Start("disk_io", nil, nil)
+	require.NoError(t, err)
+	defer sm.StopRun(active.ID)
+
+	record, ok := st.Get(store.KindRun, active.ID)
+	require.True(t, ok)
+	assert.Equal(t, RunRunning, record.Status)
+	require.NoError(t, st.Close())
+
+	// A new process finds the active run in the history.
+	st, err = store.Open(path, 0)
+	require.NoError(t, err)
+	defer st.Close()
+	restarted := newTestManager()
+	require.NoError(t, restarted.SetStore(st))
+
+	run, ok := restarted.GetRun(active.ID)
+	require.True(t, ok)
+	assert.Equal(t, RunInterrupted, run.Status)
+	assert.NotNil(t, run.EndedAt)
+	run, ok = restarted.GetRun(finished.ID)
+	require.True(t, ok)
+	assert.Equal(t, RunStopped, run.Status)
+
+	record, _ = st.Get(store.KindRun, active.ID)
+	assert.Equal(t, RunInterrupted, record.Status)
+	assert.False(t, restarted.IsScenarioActive("disk_io"))
+}
+
+func TestRequestedScenario(t *testing.T) {
+	var got []string
+	mux := http.NewServeMux()
+	record := func(w http.ResponseWriter, r *http.Request) { got = append(got, requestedScenario(r)) }
+	mux.HandleFunc("POST /scenarios/run", record)
+	mux.HandleFunc("POST /scenarios/{name}/run", record)
+
+	mux.ServeHTTP(httptest.NewRecorder(), httptest.NewRequest(http.MethodPost, "/scenarios/latency/run", nil))
+	mux.ServeHTTP(httptest.NewRecorder(), httptest.NewRequest(http.MethodPost, "/scenarios/run?scenario=disk_io", nil))
+	mux.ServeHTTP(httptest.NewRecorder(), httptest.NewRequest(http.MethodPost, "/scenarios/cpu_spike/run?scenario=disk_io", nil))
+
+	assert.Equal(t, []string{"latency", "disk_io", "cpu_spike"}, got, "the path takes precedence over the query string")
+}
+
+func TestRunHandlerValidatesLoadFirst(t *testing.T) {
+	sm := newTestManager()
+	t.Cleanup(func() { sm.KillAll("test") })
+
+	body := strings.NewReader(`{"load": {"rate": 1000000}}`)
+	rec := httptest.NewRecorder()
+	sm.RunHandler(rec, httptest.NewRequest(http.MethodPost, "/scenarios/run?scenario=latency", body))
+
+	assert.Equal(t, http.StatusBadRequest, rec.Code)
+	assert.Empty(t, sm.Runs(), "no fault is injected for a run with invalid load")
+}
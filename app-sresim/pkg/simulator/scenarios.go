@@ -2,8 +2,17 @@ package simulator
 
 import (
 	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
 	"net/http"
+	"sort"
 	"time"
+
+	"github.com/localstack/sresim/app-sresim/pkg/auth"
+	"github.com/localstack/sresim/app-sresim/pkg/config"
+	"github.com/localstack/sresim/app-sresim/pkg/loadgen"
+	"github.com/localstack/sresim/app-sresim/pkg/steadystate"
 )
 
 type Scenario struct {
@@ -17,6 +26,8 @@ type ScenarioResponse struct {
 	Message    string                 `json:"message"`
 	Timestamp  time.Time              `json:"timestamp"`
 	Parameters map[string]interface{} `json:"parameters"`
+	RunID      string                 `json:"run_id,omitempty"`
+	LoadRunID  string                 `json:"load_run_id,omitempty"`
 }
 
 var scenarios = map[string]Scenario{
@@ -113,6 +124,106 @@ var scenarios = map[string]Scenario{
 			"cache_miss_percentage": 80,
 		},
 	},
+	"readiness_flap": {
+		Name:        "Readiness Flap",
+		Description: "Fails the readiness probe on purpose, permanently or in cycles",
+		Parameters: map[string]interface{}{
+			"down_seconds": 20,
+			"up_seconds":   40,
+		},
+	},
+	"log_storm": {
+		Name:        "Log Storm",
+		Description: "Floods the log with error records and stack traces",
+		Parameters: map[string]interface{}{
+			"lines_per_second":       200,
+			"stack_trace_percentage": 20,
+			"payload_bytes":          256,
+		},
+	},
+	"synthetic_traces": {
+		Name:        "Synthetic Traces",
+		Description: "Emits the traces of a simulated online shop with slow and failing spans in one culprit service",
+		Parameters: map[string]interface{}{
+			"traces_per_second": 5,
+			"fan_out":           4,
+			"n_plus_one":        10,
+			"slow_percentage":   10,
+			"error_percentage":  5,
+			"gap_percentage":    2,
+			"culprit":           "",
+		},
+	},
+}
+
+// ScenarioNames returns the names of all registered scenarios in sorted order.
+func ScenarioNames() []string {
+	names := make([]string, 0, len(scenarios))
+	for name := range scenarios {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names
+}
+
+// mergeParameters overlays overrides on a copy of defaults.
+func mergeParameters(defaults, overrides map[string]interface{}) map[string]interface{} {
+	merged := make(map[string]interface{}, len(defaults))
+	for k, v := range defaults {
+		merged[k] = v
+	}
+	for k, v := range overrides {
+		merged[k] = v
+	}
+	return merged
+}
+
+// params reads numeric scenario parameters, which arrive as ints from the
+// catalog and as float64 from decoded JSON. The first conversion error is
+// kept in err so callers can read every parameter before checking.
+type params struct {
+	values map[string]interface{}
+	err    error
+}
+
+func newParams(defaults, overrides map[string]interface{}) *params {
+	return &params{values: mergeParameters(defaults, overrides)}
+}
+
+func (p *params) int(key string) int {
+	switch v := p.values[key].(type) {
+	case int:
+		return v
+	case float64:
+		return int(v)
+	case json.Number:
+		n, err := v.Int64()
+		if err == nil {
+			return int(n)
+		}
+	}
+	if p.err == nil {
+		p.err = fmt.Errorf("parameter %q must be a number, got %v", key, p.values[key])
+	}
+	return 0
+}
+
+// string reads a string parameter.
+func (p *params) string(key string) string {
+	v, ok := p.values[key].(string)
+	if !ok && p.err == nil {
+		p.err = fmt.Errorf("parameter %q must be a string, got %v", key, p.values[key])
+	}
+	return v
+}
+
+// positive is like int but also rejects values that would be used as divisors.
+func (p *params) positive(key string) int {
+	v := p.int(key)
+	if v <= 0 && p.err == nil {
+		p.err = fmt.Errorf("parameter %q must be positive, got %d", key, v)
+	}
+	return v
 }
 
 // ListScenarios returns all available simulation scenarios
@@ -121,89 +232,122 @@ func ListScenarios(w http.ResponseWriter, r *http.Request) {
 	json.NewEncoder(w).Encode(scenarios)
 }
 
-// RunScenario executes a specific simulation scenario
-func RunScenario(w http.ResponseWriter, r *http.Request) {
-	scenarioName := r.URL.Query().Get("scenario")
-	scenario, exists := scenarios[scenarioName]
+// RunRequest is the optional JSON body of a run request. Parameters override
+// the scenario defaults and Load starts a load generation run alongside the
+// scenario. When Hypothesis is set the scenario runs as an experiment for
+// Duration and the run record carries the steady-state verdict. Guardrails
+// override the configured limits at which the run is aborted.
+type RunRequest struct {
+	Parameters map[string]interface{}  `json:"parameters,omitempty"`
+	Guardrails *config.Guardrails      `json:"guardrails,omitempty"`
+	Load       *loadgen.Config         `json:"load,omitempty"`
+	Hypothesis *steadystate.Hypothesis `json:"hypothesis,omitempty"`
+	Duration   config.Duration         `json:"duration,omitempty"`
+}
+
+// defaultExperimentDuration is how long a verified run keeps its fault
+// injected when the request does not say.
+const defaultExperimentDuration = time.Minute
+
+// requestedScenario returns the scenario a request is for: the {name} path
+// parameter of POST /scenarios/{name}/run and /stop, or the scenario query
+// parameter of the older /scenarios/run and /stop routes.
+func requestedScenario(r *http.Request) string {
+	if name := r.PathValue("name"); name != "" {
+		return name
+	}
+	return r.URL.Query().Get("scenario")
+}
+
+// RunHandler executes a specific simulation scenario
+func (sm *ScenarioManager) RunHandler(w http.ResponseWriter, r *http.Request) {
+	scenarioName := requestedScenario(r)
+	_, exists := scenarios[scenarioName]
 	if !exists {
 		http.Error(w, "Scenario not found", http.StatusNotFound)
 		return
 	}
+	if !auth.CheckScenarios(w, r, scenarioName) {
+		return
+	}
 
-	// Get the scenario manager
-	manager := GetManager()
-
-	// Start the appropriate scenario based on the name
-	switch scenarioName {
-	case "latency":
-		delayMs := int(scenario.Parameters["delay_ms"].(float64))
-		manager.StartLatencySimulation(delayMs)
-	case "error_rate":
-		errorPercentage := int(scenario.Parameters["error_percentage"].(float64))
-		manager.StartErrorRateSimulation(errorPercentage)
-	case "resource_exhaustion":
-		cpuPercentage := int(scenario.Parameters["cpu_percentage"].(float64))
-		memoryPercentage := int(scenario.Parameters["memory_percentage"].(float64))
-		manager.StartResourceExhaustionSimulation(cpuPercentage, memoryPercentage)
-	case "circuit_breaker":
-		threshold := int(scenario.Parameters["threshold"].(float64))
-		timeout := int(scenario.Parameters["timeout"].(float64))
-		manager.StartCircuitBreakerSimulation(threshold, timeout)
-	case "rate_limit":
-		requestsPerSecond := int(scenario.Parameters["requests_per_second"].(float64))
-		manager.StartRateLimitSimulation(requestsPerSecond)
-	case "network_partition":
-		duration := int(scenario.Parameters["partition_duration"].(float64))
-		manager.StartNetworkPartitionSimulation(duration)
-	case "memory_leak":
-		leakRate := int(scenario.Parameters["leak_rate_mb_per_second"].(float64))
-		duration := int(scenario.Parameters["duration_seconds"].(float64))
-		manager.StartMemoryLeakSimulation(leakRate, duration)
-	case "cpu_spike":
-		spikePercentage := int(scenario.Parameters["spike_percentage"].(float64))
-		duration := int(scenario.Parameters["duration_seconds"].(float64))
-		interval := int(scenario.Parameters["interval_seconds"].(float64))
-		manager.StartCPUSpikeSimulation(spikePercentage, duration, interval)
-	case "disk_io":
-		opsPerSecond := int(scenario.Parameters["io_operations_per_second"].(float64))
-		fileSize := int(scenario.Parameters["file_size_mb"].(float64))
-		manager.StartDiskIOSimulation(opsPerSecond, fileSize)
-	case "connection_pool_exhaustion":
-		maxConnections := int(scenario.Parameters["max_connections"].(float64))
-		holdTime := int(scenario.Parameters["hold_time_seconds"].(float64))
-		manager.StartConnectionPoolExhaustionSimulation(maxConnections, holdTime)
-	case "cascading_failure":
-		chainLength := int(scenario.Parameters["failure_chain_length"].(float64))
-		delay := int(scenario.Parameters["delay_between_failures_seconds"].(float64))
-		manager.StartCascadingFailureSimulation(chainLength, delay)
-	case "thundering_herd":
-		concurrentRequests := int(scenario.Parameters["concurrent_requests"].(float64))
-		cacheMissPercentage := int(scenario.Parameters["cache_miss_percentage"].(float64))
-		manager.StartThunderingHerdSimulation(concurrentRequests, cacheMissPercentage)
+	var req RunRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil && err != io.EOF {
+		http.Error(w, "Invalid request body: "+err.Error(), http.StatusBadRequest)
+		return
+	}
+	if req.Hypothesis != nil && req.Load != nil {
+		http.Error(w, "load cannot be combined with a hypothesis; set hypothesis.load to drive traffic during checks", http.StatusBadRequest)
+		return
+	}
+	if req.Load != nil {
+		load := req.Load.WithDefaults()
+		if err := load.Validate(); err != nil {
+			http.Error(w, "Invalid load configuration: "+err.Error(), http.StatusBadRequest)
+			return
+		}
+		req.Load = &load
+	}
+
+	var run Run
+	var err error
+	if req.Hypothesis != nil {
+		duration := req.Duration.Std()
+		if duration == 0 {
+			duration = defaultExperimentDuration
+		}
+		run, err = sm.StartVerified(scenarioName, req.Parameters, req.Guardrails, *req.Hypothesis, duration)
+	} else {
+		run, err = sm.Start(scenarioName, req.Parameters, req.Guardrails)
+	}
+	if err != nil {
+		status := http.StatusBadRequest
+		if errors.Is(err, ErrScenarioActive) {
+			status = http.StatusConflict
+		}
+		http.Error(w, err.Error(), status)
+		return
 	}
 
 	response := ScenarioResponse{
-		Status:     "running",
+		Status:     run.Status,
 		Message:    "Scenario started",
 		Timestamp:  time.Now(),
-		Parameters: scenario.Parameters,
+		Parameters: run.Parameters,
+		RunID:      run.ID,
+	}
+	if req.Hypothesis != nil {
+		response.Message = "Experiment started; poll the run for its verdict"
+	}
+
+	if req.Load != nil {
+		load, err := loadgen.GetManager().Start(*req.Load)
+		if err != nil {
+			sm.StopScenario(scenarioName)
+			http.Error(w, "Invalid load configuration: "+err.Error(), http.StatusBadRequest)
+			return
+		}
+		sm.AttachLoad(run.ID, load)
+		response.LoadRunID = load.ID
 	}
 
 	w.Header().Set("Content-Type", "application/json")
 	json.NewEncoder(w).Encode(response)
 }
 
-// StopScenario stops a running simulation scenario
-func StopScenario(w http.ResponseWriter, r *http.Request) {
-	scenarioName := r.URL.Query().Get("scenario")
+// StopHandler stops a running simulation scenario
+func (sm *ScenarioManager) StopHandler(w http.ResponseWriter, r *http.Request) {
+	scenarioName := requestedScenario(r)
 	_, exists := scenarios[scenarioName]
 	if !exists {
 		http.Error(w, "Scenario not found", http.StatusNotFound)
 		return
 	}
+	if !auth.CheckScenarios(w, r, scenarioName) {
+		return
+	}
 
-	manager := GetManager()
-	manager.StopScenario(scenarioName)
+	sm.StopScenario(scenarioName)
 
 	response := ScenarioResponse{
 		Status:    "stopped",
@@ -0,0 +1,316 @@
+package slo
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"sync"
+	"time"
+
+	"github.com/localstack/sresim/app-sresim/pkg/config"
+	"github.com/localstack/sresim/app-sresim/pkg/metrics"
+)
+
+// SLI types.
+const (
+	SLIAvailability = "availability"
+	SLILatency      = "latency"
+)
+
+// bucketWidth is the resolution of the sliding windows. It has to be well
+// below the shortest burn-rate window (5m) to keep that window accurate.
+const bucketWidth = 10 * time.Second
+
+// Window is a burn-rate lookback window.
+type Window struct {
+	Name     string
+	Duration time.Duration
+}
+
+// Windows are the lookback windows burn rates are computed for. They pair up
+// into the standard multi-window alerts: 1h/5m for fast burn and 6h/30m for
+// slow burn.
+var Windows = []Window{
+	{Name: "5m", Duration: 5 * time.Minute},
+	{Name: "30m", Duration: 30 * time.Minute},
+	{Name: "1h", Duration: time.Hour},
+	{Name: "6h", Duration: 6 * time.Hour},
+}
+
+// retention is how much history the sliding windows keep.
+const retention = 6 * time.Hour
+
+// counts is the number of events and of bad events per SLI.
+type counts struct {
+	total           int64
+	badAvailability int64
+	badLatency      int64
+}
+
+func (c *counts) add(other counts) {
+	c.total += other.total
+	c.badAvailability += other.badAvailability
+	c.badLatency += other.badLatency
+}
+
+func (c counts) bad(sli string) int64 {
+	if sli == SLILatency {
+		return c.badLatency
+	}
+	return c.badAvailability
+}
+
+type bucket struct {
+	slot int64
+	counts
+}
+
+// objective tracks the events for a single configured SLO.
+type objective struct {
+	cfg     config.SLO
+	buckets []bucket
+	total   counts
+}
+
+func (o *objective) slis() []string {
+	var slis []string
+	if o.cfg.Availability > 0 {
+		slis = append(slis, SLIAvailability)
+	}
+	if o.cfg.Latency != nil {
+		slis = append(slis, SLILatency)
+	}
+	return slis
+}
+
+func (o *objective) target(sli string) float64 {
+	if sli == SLILatency {
+		return o.cfg.Latency.Percentile
+	}
+	return o.cfg.Availability
+}
+
+func (o *objective) record(now time.Time, event counts) {
+	slot := now.UnixNano() / int64(bucketWidth)
+	b := &o.buckets[slot%int64(len(o.buckets))]
+	if b.slot != slot {
+		*b = bucket{slot: slot}
+	}
+	b.add(event)
+	o.total.add(event)
+}
+
+func (o *objective) window(now time.Time, d time.Duration) counts {
+	var sum counts
+	current := now.UnixNano() / int64(bucketWidth)
+	oldest := current - int64(d/bucketWidth) + 1
+	for _, b := range o.buckets {
+		if b.slot >= oldest && b.slot <= current {
+			sum.add(b.counts)
+		}
+	}
+	return sum
+}
+
+// Tracker computes SLIs, error budgets and burn rates from observed requests.
+type Tracker struct {
+	mu         sync.Mutex
+	objectives []*objective
+	since      time.Time
+	now        func() time.Time
+}
+
+// NewTracker validates the SLO definitions and returns a tracker for them.
+func NewTracker(slos []config.SLO) (*Tracker, error) {
+	t := &Tracker{now: time.Now}
+	t.since = t.now()
+	names := make(map[string]bool)
+	for _, cfg := range slos {
+		if err := validate(&cfg); err != nil {
+			return nil, err
+		}
+		if names[cfg.Name] {
+			return nil, fmt.Errorf("duplicate SLO name %q", cfg.Name)
+		}
+		names[cfg.Name] = true
+		t.objectives = append(t.objectives, &objective{
+			cfg:     cfg,
+			buckets: make([]bucket, retention/bucketWidth),
+		})
+	}
+	return t, nil
+}
+
+func validate(cfg *config.SLO) error {
+	if cfg.Handler == "" {
+		return errors.New("SLO handler is required")
+	}
+	if cfg.Name == "" {
+		cfg.Name = cfg.Handler
+	}
+	if cfg.Availability < 0 || cfg.Availability >= 1 {
+		return fmt.Errorf("SLO %q: availability must be between 0 and 1", cfg.Name)
+	}
+	if cfg.Latency != nil {
+		if cfg.Latency.Threshold <= 0 {
+			return fmt.Errorf("SLO %q: latency threshold must be positive", cfg.Name)
+		}
+		if cfg.Latency.Percentile <= 0 || cfg.Latency.Percentile >= 1 {
+			return fmt.Errorf("SLO %q: latency percentile must be between 0 and 1", cfg.Name)
+		}
+	}
+	if cfg.Availability == 0 && cfg.Latency == nil {
+		return fmt.Errorf("SLO %q: at least one of availability or latency is required", cfg.Name)
+	}
+	return nil
+}
+
+// Observe records a request. It has the signature of metrics.RequestObserver
+// so the tracker can be fed by the HTTP metrics middleware.
+func (t *Tracker) Observe(handler string, status int, duration time.Duration) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	now := t.now()
+	for _, o := range t.objectives {
+		if o.cfg.Handler != handler {
+			continue
+		}
+		event := counts{total: 1}
+		if status >= 500 {
+			event.badAvailability = 1
+		}
+		if o.cfg.Latency != nil && duration > o.cfg.Latency.Threshold.Std() {
+			event.badLatency = 1
+		}
+		o.record(now, event)
+	}
+}
+
+// Indicator is the state of one SLI of an SLO.
+type Indicator struct {
+	SLI       string  `json:"sli"`
+	Objective float64 `json:"objective"`
+	Good      int64   `json:"good"`
+	Total     int64   `json:"total"`
+	// Ratio is the share of good events since the budget period started.
+	Ratio float64 `json:"ratio"`
+	// ErrorBudgetRemaining is the share of the budget left; it goes negative
+	// once the budget is overspent.
+	ErrorBudgetRemaining float64            `json:"error_budget_remaining"`
+	BurnRates            map[string]float64 `json:"burn_rates"`
+}
+
+// BudgetBurnedSince returns the share of the error budget consumed between
+// an earlier snapshot of the same SLI and this one, which is how much budget
+// a scenario run cost.
+func (i Indicator) BudgetBurnedSince(earlier Indicator) float64 {
+	return earlier.ErrorBudgetRemaining - i.ErrorBudgetRemaining
+}
+
+// Status is the state of an SLO.
+type Status struct {
+	Name       string      `json:"name"`
+	Handler    string      `json:"handler"`
+	Since      time.Time   `json:"since"`
+	Indicators []Indicator `json:"indicators"`
+}
+
+// Indicator returns the named SLI, if the SLO defines it.
+func (s Status) Indicator(sli string) (Indicator, bool) {
+	for _, ind := range s.Indicators {
+		if ind.SLI == sli {
+			return ind, true
+		}
+	}
+	return Indicator{}, false
+}
+
+// Status returns the current state of every SLO.
+func (t *Tracker) Status() []Status {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	now := t.now()
+	statuses := make([]Status, 0, len(t.objectives))
+	for _, o := range t.objectives {
+		status := Status{Name: o.cfg.Name, Handler: o.cfg.Handler, Since: t.since}
+		windows := make(map[string]counts, len(Windows))
+		for _, w := range Windows {
+			windows[w.Name] = o.window(now, w.Duration)
+		}
+		for _, sli := range o.slis() {
+			target := o.target(sli)
+			ind := Indicator{
+				SLI:                  sli,
+				Objective:            target,
+				Total:                o.total.total,
+				Good:                 o.total.total - o.total.bad(sli),
+				Ratio:                1,
+				ErrorBudgetRemaining: 1,
+				BurnRates:            make(map[string]float64, len(Windows)),
+			}
+			if ind.Total > 0 {
+				ind.Ratio = float64(ind.Good) / float64(ind.Total)
+				ind.ErrorBudgetRemaining = 1 - (1-ind.Ratio)/(1-target)
+			}
+			for name, c := range windows {
+				ind.BurnRates[name] = burnRate(c, sli, target)
+			}
+			status.Indicators = append(status.Indicators, ind)
+		}
+		statuses = append(statuses, status)
+	}
+	return statuses
+}
+
+// burnRate is the observed error ratio divided by the ratio the objective
+// allows; 1 means the budget would be used up exactly at the end of the period.
+func burnRate(c counts, sli string, target float64) float64 {
+	if c.total == 0 {
+		return 0
+	}
+	return (float64(c.bad(sli)) / float64(c.total)) / (1 - target)
+}
+
+// Reset starts a new error budget period.
+func (t *Tracker) Reset() {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	t.since = t.now()
+	for _, o := range t.objectives {
+		o.total = counts{}
+	}
+}
+
+// Export publishes the current state as Prometheus gauges in m.
+func (t *Tracker) Export(m *metrics.Registry) {
+	for _, status := range t.Status() {
+		for _, ind := range status.Indicators {
+			m.UpdateSLO(status.Name, ind.SLI, ind.Objective, ind.Ratio, ind.ErrorBudgetRemaining)
+			for window, rate := range ind.BurnRates {
+				m.UpdateSLOBurnRate(status.Name, ind.SLI, window, rate)
+			}
+		}
+	}
+}
+
+// Run exports the gauges to m every interval until ctx is cancelled.
+func (t *Tracker) Run(ctx context.Context, m *metrics.Registry, interval time.Duration) {
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+	for {
+		t.Export(m)
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		}
+	}
+}
+
+// StatusHandler serves GET /slo.
+func (t *Tracker) StatusHandler(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(t.Status())
+}
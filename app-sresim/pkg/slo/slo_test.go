@@ -0,0 +1,120 @@
+package slo
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/localstack/sresim/app-sresim/pkg/config"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func newTestTracker(t *testing.T, now *time.Time) *Tracker {
+	tracker, err := NewTracker([]config.SLO{{
+		Name:         "simulate",
+		Handler:      "/simulate",
+		Availability: 0.99,
+		Latency: &config.LatencySLO{
+			Threshold:  config.Duration(300 * time.Millisecond),
+			Percentile: 0.9,
+		},
+	}})
+	require.NoError(t, err)
+	tracker.now = func() time.Time { return *now }
+	return tracker
+}
+
+func TestNewTrackerValidation(t *testing.T) {
+	_, err := NewTracker([]config.SLO{{Name: "no-handler", Availability: 0.99}})
+	assert.Error(t, err)
+
+	_, err = NewTracker([]config.SLO{{Handler: "/x", Availability: 1}})
+	assert.Error(t, err)
+
+	_, err = NewTracker([]config.SLO{{Handler: "/x"}})
+	assert.Error(t, err)
+
+	_, err = NewTracker([]config.SLO{{Handler: "/x", Availability: 0.9}, {Handler: "/x", Availability: 0.99}})
+	assert.Error(t, err, "names default to the handler and must be unique")
+}
+
+func TestTrackerBudgetAndBurnRate(t *testing.T) {
+	now := time.Unix(1700000000, 0)
+	tracker := newTestTracker(t, &now)
+
+	// 100 requests: 2 server errors, 5 slower than the threshold.
+	for i := 0; i < 100; i++ {
+		status, latency := http.StatusOK, 50*time.Millisecond
+		if i < 2 {
+			status = http.StatusInternalServerError
+		}
+		if i >= 95 {
+			latency = time.Second
+		}
+		tracker.Observe("/simulate", status, latency)
+	}
+	tracker.Observe("/other", http.StatusInternalServerError, time.Second)
+
+	status := tracker.Status()
+	require.Len(t, status, 1)
+
+	availability, ok := status[0].Indicator(SLIAvailability)
+	require.True(t, ok)
+	assert.Equal(t, int64(100), availability.Total)
+	assert.Equal(t, int64(98), availability.Good)
+	assert.InDelta(t, 0.98, availability.Ratio, 1e-9)
+	// 2% errors against a 1% budget: budget overspent by 100%.
+	assert.InDelta(t, -1, availability.ErrorBudgetRemaining, 1e-9)
+	assert.InDelta(t, 2, availability.BurnRates["5m"], 1e-9)
+	assert.InDelta(t, 2, availability.BurnRates["6h"], 1e-9)
+
+	latency, ok := status[0].Indicator(SLILatency)
+	require.True(t, ok)
+	assert.Equal(t, int64(95), latency.Good)
+	assert.InDelta(t, 0.5, latency.ErrorBudgetRemaining, 1e-9)
+	assert.InDelta(t, 0.5, latency.BurnRates["1h"], 1e-9)
+}
+
+func TestTrackerWindowsExpire(t *testing.T) {
+	now := time.Unix(1700000000, 0)
+	tracker := newTestTracker(t, &now)
+
+	tracker.Observe("/simulate", http.StatusInternalServerError, 0)
+	now = now.Add(10 * time.Minute)
+	tracker.Observe("/simulate", http.StatusOK, 0)
+
+	availability, _ := tracker.Status()[0].Indicator(SLIAvailability)
+	assert.Equal(t, float64(0), availability.BurnRates["5m"], "the error is older than 5m")
+	assert.InDelta(t, 50, availability.BurnRates["30m"], 1e-9)
+	assert.Equal(t, int64(2), availability.Total)
+}
+
+func TestBudgetBurnedSince(t *testing.T) {
+	now := time.Unix(1700000000, 0)
+	tracker := newTestTracker(t, &now)
+	for i := 0; i < 1000; i++ {
+		tracker.Observe("/simulate", http.StatusOK, 0)
+	}
+	before, _ := tracker.Status()[0].Indicator(SLIAvailability)
+
+	for i := 0; i < 5; i++ {
+		tracker.Observe("/simulate", http.StatusServiceUnavailable, 0)
+	}
+	after, _ := tracker.Status()[0].Indicator(SLIAvailability)
+
+	assert.InDelta(t, 0.5, after.BudgetBurnedSince(before), 0.01)
+}
+
+func TestStatusHandler(t *testing.T) {
+	now := time.Unix(1700000000, 0)
+	tracker := newTestTracker(t, &now)
+
+	w := httptest.NewRecorder()
+	tracker.StatusHandler(w, httptest.NewRequest(http.MethodGet, "/slo", nil))
+
+	assert.Equal(t, http.StatusOK, w.Code)
+	assert.Contains(t, w.Body.String(), `"name":"simulate"`)
+	assert.Contains(t, w.Body.String(), `"burn_rates"`)
+}
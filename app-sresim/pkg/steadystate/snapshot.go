@@ -0,0 +1,126 @@
+package steadystate
+
+import (
+	"math"
+	"sort"
+	"strconv"
+
+	"github.com/localstack/sresim/app-sresim/pkg/metrics"
+	"github.com/prometheus/client_golang/prometheus"
+)
+
+// handlerHistogram is the request latency histogram of one handler summed
+// over methods and statuses, plus the number of 5xx responses.
+type handlerHistogram struct {
+	count   uint64
+	errors  uint64
+	buckets map[float64]uint64 // cumulative count per upper bound
+}
+
+// snapshotDelta maps handlers to their histograms.
+type snapshotDelta map[string]*handlerHistogram
+
+// snapshot reads the HTTP request histogram from g.
+func snapshot(g prometheus.Gatherer) (snapshotDelta, error) {
+	families, err := g.Gather()
+	if err != nil {
+		return nil, err
+	}
+	snap := make(snapshotDelta)
+	for _, mf := range families {
+		if mf.GetName() != metrics.RequestDurationName {
+			continue
+		}
+		for _, m := range mf.GetMetric() {
+			var handler string
+			var status int
+			for _, label := range m.GetLabel() {
+				switch label.GetName() {
+				case "handler":
+					handler = label.GetValue()
+				case "status":
+					status, _ = strconv.Atoi(label.GetValue())
+				}
+			}
+			h := snap[handler]
+			if h == nil {
+				h = &handlerHistogram{buckets: make(map[float64]uint64)}
+				snap[handler] = h
+			}
+			hist := m.GetHistogram()
+			h.count += hist.GetSampleCount()
+			if status >= 500 {
+				h.errors += hist.GetSampleCount()
+			}
+			for _, b := range hist.GetBucket() {
+				h.buckets[b.GetUpperBound()] += b.GetCumulativeCount()
+			}
+		}
+	}
+	return snap, nil
+}
+
+// since returns the observations recorded between earlier and s.
+func (s snapshotDelta) since(earlier snapshotDelta) snapshotDelta {
+	delta := make(snapshotDelta, len(s))
+	for handler, h := range s {
+		d := &handlerHistogram{count: h.count, errors: h.errors, buckets: make(map[float64]uint64, len(h.buckets))}
+		for bound, c := range h.buckets {
+			d.buckets[bound] = c
+		}
+		if prev, ok := earlier[handler]; ok {
+			d.count -= prev.count
+			d.errors -= prev.errors
+			for bound, c := range prev.buckets {
+				d.buckets[bound] -= c
+			}
+		}
+		delta[handler] = d
+	}
+	return delta
+}
+
+// quantile estimates the q-quantile in seconds by linear interpolation
+// within the bucket that contains it, the same way PromQL's
+// histogram_quantile does.
+func (h *handlerHistogram) quantile(q float64) float64 {
+	bounds := make([]float64, 0, len(h.buckets))
+	for bound := range h.buckets {
+		bounds = append(bounds, bound)
+	}
+	sort.Float64s(bounds)
+
+	rank := q * float64(h.count)
+	var lower float64
+	var below uint64
+	for _, upper := range bounds {
+		cumulative := h.buckets[upper]
+		if float64(cumulative) >= rank {
+			if cumulative == below {
+				return upper
+			}
+			return lower + (upper-lower)*(rank-float64(below))/float64(cumulative-below)
+		}
+		lower, below = upper, cumulative
+	}
+	// The quantile falls into the implicit +Inf bucket; like PromQL, report
+	// the highest finite bound.
+	if len(bounds) == 0 {
+		return math.NaN()
+	}
+	return lower
+}
+
+// Counts returns the number of requests and of 5xx responses recorded in g
+// so far across all handlers.
+func Counts(g prometheus.Gatherer) (requests, errors uint64, err error) {
+	snap, err := snapshot(g)
+	if err != nil {
+		return 0, 0, err
+	}
+	for _, h := range snap {
+		requests += h.count
+		errors += h.errors
+	}
+	return requests, errors, nil
+}
@@ -0,0 +1,273 @@
+package steadystate
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"time"
+
+	"github.com/localstack/sresim/app-sresim/pkg/config"
+	"github.com/localstack/sresim/app-sresim/pkg/loadgen"
+	"github.com/prometheus/client_golang/prometheus"
+)
+
+// Probe types.
+const (
+	ProbeLatency   = "latency"
+	ProbeErrorRate = "error_rate"
+)
+
+// Probe sources.
+const (
+	// SourceMetrics measures the traffic recorded by sresim's own HTTP metrics.
+	SourceMetrics = "metrics"
+	// SourceLoadgen measures the hypothesis' load generation run.
+	SourceLoadgen = "loadgen"
+)
+
+// Check phases, in the order an experiment runs them.
+const (
+	PhaseBefore = "before"
+	PhaseDuring = "during"
+	PhaseAfter  = "after"
+)
+
+// DefaultWindow is how long a check measures when the hypothesis sets none.
+const DefaultWindow = 10 * time.Second
+
+// Probe is a single tolerance of the hypothesis, e.g. "p99 of /simulate is
+// below 300ms" or "error rate of /simulate is below 1%".
+type Probe struct {
+	Name   string `json:"name"`
+	Type   string `json:"type"`
+	Source string `json:"source,omitempty"`
+	// Handler selects the requests measured from metrics.
+	Handler string `json:"handler,omitempty"`
+	// Percentile and MaxLatency define a latency probe.
+	Percentile float64         `json:"percentile,omitempty"`
+	MaxLatency config.Duration `json:"max_latency,omitempty"`
+	// MaxErrorRate defines an error rate probe, as a ratio (0.01 = 1%).
+	MaxErrorRate float64 `json:"max_error_rate,omitempty"`
+}
+
+// Hypothesis describes the steady state of the system. Every probe must be
+// within tolerance for the system to be considered steady.
+type Hypothesis struct {
+	Title string `json:"title"`
+	// Window is how long each check observes the system.
+	Window config.Duration `json:"window,omitempty"`
+	// Load, if set, is generated for the duration of every check so there is
+	// traffic to measure.
+	Load   *loadgen.Config `json:"load,omitempty"`
+	Probes []Probe         `json:"probes"`
+}
+
+// WindowDuration returns the measurement window of a check.
+func (h Hypothesis) WindowDuration() time.Duration {
+	if h.Window <= 0 {
+		return DefaultWindow
+	}
+	return h.Window.Std()
+}
+
+// loadConfig returns the load generated during a check, which lasts the
+// window.
+func (h Hypothesis) loadConfig() loadgen.Config {
+	cfg := *h.Load
+	cfg.Duration = config.Duration(h.WindowDuration())
+	cfg.Stages = nil
+	return cfg.WithDefaults()
+}
+
+// Validate checks that every probe and the load, if any, are well formed.
+func (h Hypothesis) Validate() error {
+	if len(h.Probes) == 0 {
+		return errors.New("hypothesis needs at least one probe")
+	}
+	if h.Load != nil {
+		if err := h.loadConfig().Validate(); err != nil {
+			return fmt.Errorf("load: %w", err)
+		}
+	}
+	for i, p := range h.Probes {
+		name := p.Name
+		if name == "" {
+			name = fmt.Sprintf("probe %d", i)
+		}
+		switch p.source() {
+		case SourceMetrics:
+			if p.Handler == "" {
+				return fmt.Errorf("%s: handler is required for metrics probes", name)
+			}
+		case SourceLoadgen:
+			if h.Load == nil {
+				return fmt.Errorf("%s: loadgen probes need the hypothesis to define load", name)
+			}
+		default:
+			return fmt.Errorf("%s: unknown source %q", name, p.Source)
+		}
+		switch p.Type {
+		case ProbeLatency:
+			if p.MaxLatency <= 0 {
+				return fmt.Errorf("%s: max_latency must be positive", name)
+			}
+			if p.Percentile < 0 || p.Percentile >= 1 {
+				return fmt.Errorf("%s: percentile must be between 0 and 1", name)
+			}
+		case ProbeErrorRate:
+			if p.MaxErrorRate < 0 || p.MaxErrorRate > 1 {
+				return fmt.Errorf("%s: max_error_rate must be between 0 and 1", name)
+			}
+		default:
+			return fmt.Errorf("%s: unknown type %q (want %q or %q)", name, p.Type, ProbeLatency, ProbeErrorRate)
+		}
+	}
+	return nil
+}
+
+func (p Probe) source() string {
+	if p.Source == "" {
+		return SourceMetrics
+	}
+	return p.Source
+}
+
+func (p Probe) percentile() float64 {
+	if p.Percentile == 0 {
+		return 0.99
+	}
+	return p.Percentile
+}
+
+// ProbeResult is the measured value of a probe.
+type ProbeResult struct {
+	Name   string  `json:"name"`
+	Type   string  `json:"type"`
+	Value  float64 `json:"value"`
+	Limit  float64 `json:"limit"`
+	Unit   string  `json:"unit"`
+	Passed bool    `json:"passed"`
+	Error  string  `json:"error,omitempty"`
+}
+
+// Check is the result of evaluating the hypothesis once.
+type Check struct {
+	Phase  string        `json:"phase"`
+	At     time.Time     `json:"at"`
+	Passed bool          `json:"passed"`
+	Probes []ProbeResult `json:"probes"`
+}
+
+// Check observes the system for the hypothesis window and evaluates every
+// probe against what was measured. Metrics probes read the HTTP request
+// histogram from g.
+func (h Hypothesis) Check(ctx context.Context, g prometheus.Gatherer, phase string) Check {
+	check := Check{Phase: phase, At: time.Now(), Passed: true}
+	window := h.WindowDuration()
+
+	start, startErr := snapshot(g)
+	var load *loadgen.Result
+	var loadErr error
+	if h.Load != nil {
+		var result loadgen.Result
+		result, loadErr = loadgen.Execute(ctx, h.loadConfig())
+		load = &result
+	} else {
+		select {
+		case <-ctx.Done():
+		case <-time.After(window):
+		}
+	}
+	end, endErr := snapshot(g)
+	metricsErr := errors.Join(startErr, endErr)
+
+	for i, p := range h.Probes {
+		result := ProbeResult{Name: p.Name, Type: p.Type}
+		if result.Name == "" {
+			result.Name = fmt.Sprintf("probe %d", i)
+		}
+		var err error
+		switch {
+		case ctx.Err() != nil:
+			err = ctx.Err()
+		case p.source() == SourceLoadgen && loadErr != nil:
+			err = loadErr
+		case p.source() == SourceLoadgen:
+			err = evaluateLoad(p, load, &result)
+		case metricsErr != nil:
+			err = metricsErr
+		default:
+			err = evaluateMetrics(p, end.since(start), &result)
+		}
+		if err != nil {
+			result.Error = err.Error()
+			result.Passed = false
+		}
+		check.Passed = check.Passed && result.Passed
+		check.Probes = append(check.Probes, result)
+	}
+	return check
+}
+
+func evaluateLoad(p Probe, load *loadgen.Result, result *ProbeResult) error {
+	if load.Requests == 0 {
+		return errors.New("load generation produced no requests")
+	}
+	switch p.Type {
+	case ProbeLatency:
+		result.Value = millis(load.Percentile(p.percentile()))
+		result.Limit = millis(p.MaxLatency.Std())
+		result.Unit = "ms"
+	case ProbeErrorRate:
+		result.Value = load.ErrorRate()
+		result.Limit = p.MaxErrorRate
+		result.Unit = "ratio"
+	}
+	result.Passed = result.Value <= result.Limit
+	return nil
+}
+
+func evaluateMetrics(p Probe, window snapshotDelta, result *ProbeResult) error {
+	h, ok := window[p.Handler]
+	if !ok || h.count == 0 {
+		return fmt.Errorf("no requests to %s observed during the check", p.Handler)
+	}
+	switch p.Type {
+	case ProbeLatency:
+		result.Value = h.quantile(p.percentile()) * 1000
+		result.Limit = millis(p.MaxLatency.Std())
+		result.Unit = "ms"
+	case ProbeErrorRate:
+		result.Value = float64(h.errors) / float64(h.count)
+		result.Limit = p.MaxErrorRate
+		result.Unit = "ratio"
+	}
+	result.Passed = result.Value <= result.Limit
+	return nil
+}
+
+func millis(d time.Duration) float64 {
+	return float64(d) / float64(time.Millisecond)
+}
+
+// Verdict results.
+const (
+	VerdictPending = "pending"
+	VerdictPassed  = "passed"
+	VerdictFailed  = "failed"
+	// VerdictInconclusive is the result of a run that was stopped by hand
+	// before the hypothesis could be checked under the fault.
+	VerdictInconclusive = "inconclusive"
+)
+
+// Verdict is the outcome of verifying a hypothesis around a fault.
+type Verdict struct {
+	Result string  `json:"result"`
+	Reason string  `json:"reason,omitempty"`
+	Checks []Check `json:"checks"`
+}
+
+// Passed reports whether the verdict is a pass.
+func (v Verdict) Passed() bool {
+	return v.Result == VerdictPassed
+}
@@ -0,0 +1,108 @@
+package steadystate
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/localstack/sresim/app-sresim/pkg/config"
+	"github.com/localstack/sresim/app-sresim/pkg/loadgen"
+	"github.com/localstack/sresim/app-sresim/pkg/metrics"
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestValidate(t *testing.T) {
+	valid := Hypothesis{Probes: []Probe{
+		{Type: ProbeLatency, Handler: "/simulate", Percentile: 0.99, MaxLatency: config.Duration(300 * time.Millisecond)},
+		{Type: ProbeErrorRate, Handler: "/simulate", MaxErrorRate: 0.01},
+	}}
+	assert.NoError(t, valid.Validate())
+
+	assert.Error(t, Hypothesis{}.Validate(), "no probes")
+	assert.Error(t, Hypothesis{Probes: []Probe{{Type: ProbeErrorRate}}}.Validate(), "metrics probe without handler")
+	assert.Error(t, Hypothesis{Probes: []Probe{{Type: ProbeLatency, Handler: "/x"}}}.Validate(), "latency probe without limit")
+	assert.Error(t, Hypothesis{Probes: []Probe{{Type: "throughput", Handler: "/x"}}}.Validate(), "unknown type")
+	assert.Error(t, Hypothesis{Probes: []Probe{{Type: ProbeErrorRate, Source: SourceLoadgen}}}.Validate(), "loadgen probe without load")
+	assert.Error(t, Hypothesis{Load: &loadgen.Config{Model: "poisson"}, Probes: valid.Probes}.Validate(), "invalid load")
+	assert.NoError(t, Hypothesis{Load: &loadgen.Config{Rate: 20}, Probes: valid.Probes}.Validate())
+}
+
+func TestQuantile(t *testing.T) {
+	h := &handlerHistogram{
+		count:   100,
+		buckets: map[float64]uint64{0.1: 50, 0.5: 90, 1: 100},
+	}
+	assert.InDelta(t, 0.1, h.quantile(0.5), 1e-9)
+	assert.InDelta(t, 0.3, h.quantile(0.7), 1e-9)
+	assert.InDelta(t, 0.95, h.quantile(0.99), 1e-9)
+}
+
+func TestCheckFromMetrics(t *testing.T) {
+	registry := prometheus.NewRegistry()
+	hist := prometheus.NewHistogramVec(prometheus.HistogramOpts{
+		Name:    metrics.RequestDurationName,
+		Buckets: prometheus.DefBuckets,
+	}, []string{"handler", "method", "status"})
+	registry.MustRegister(hist)
+
+	// Traffic before the check must not count towards it.
+	hist.WithLabelValues("/simulate", "GET", "500").Observe(5)
+
+	h := Hypothesis{
+		Window: config.Duration(50 * time.Millisecond),
+		Probes: []Probe{
+			{Name: "p99", Type: ProbeLatency, Handler: "/simulate", MaxLatency: config.Duration(300 * time.Millisecond)},
+			{Name: "errors", Type: ProbeErrorRate, Handler: "/simulate", MaxErrorRate: 0.01},
+			{Name: "idle", Type: ProbeErrorRate, Handler: "/idle", MaxErrorRate: 0.01},
+		},
+	}
+	go func() {
+		time.Sleep(10 * time.Millisecond)
+		for i := 0; i < 99; i++ {
+			hist.WithLabelValues("/simulate", "GET", "200").Observe(0.02)
+		}
+		hist.WithLabelValues("/simulate", "GET", "503").Observe(0.02)
+	}()
+
+	check := h.Check(context.Background(), registry, PhaseBefore)
+	require.Len(t, check.Probes, 3)
+	assert.False(t, check.Passed)
+
+	assert.True(t, check.Probes[0].Passed)
+	assert.Less(t, check.Probes[0].Value, 25.0)
+	assert.Equal(t, "ms", check.Probes[0].Unit)
+
+	assert.True(t, check.Probes[1].Passed, "1%% errors is within a 1%% tolerance")
+	assert.InDelta(t, 0.01, check.Probes[1].Value, 1e-9)
+
+	assert.False(t, check.Probes[2].Passed, "a handler without traffic cannot be verified")
+	assert.NotEmpty(t, check.Probes[2].Error)
+}
+
+func TestCheckFromLoadgen(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusInternalServerError)
+	}))
+	defer server.Close()
+
+	h := Hypothesis{
+		Window: config.Duration(100 * time.Millisecond),
+		Load:   &loadgen.Config{Target: server.URL, Rate: 50},
+		Probes: []Probe{
+			{Type: ProbeErrorRate, Source: SourceLoadgen, MaxErrorRate: 0.01},
+			{Type: ProbeLatency, Source: SourceLoadgen, MaxLatency: config.Duration(time.Second)},
+		},
+	}
+	require.NoError(t, h.Validate())
+
+	check := h.Check(context.Background(), prometheus.NewRegistry(), PhaseDuring)
+	assert.Equal(t, PhaseDuring, check.Phase)
+	assert.False(t, check.Passed)
+	assert.False(t, check.Probes[0].Passed)
+	assert.Equal(t, 1.0, check.Probes[0].Value)
+	assert.True(t, check.Probes[1].Passed)
+}
@@ -0,0 +1,60 @@
+package store
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"strconv"
+	"time"
+)
+
+// defaultHistoryLimit is the number of records /history returns by default.
+const defaultHistoryLimit = 100
+
+// HistoryHandler serves GET /history. Records can be filtered with ?kind=,
+// ?scenario=, ?status=, ?since= and ?until= (RFC 3339 timestamps, or a
+// duration such as 24h meaning that long ago) and capped with ?limit=.
+func (s *Store) HistoryHandler(w http.ResponseWriter, r *http.Request) {
+	q := r.URL.Query()
+	f := Filter{
+		Kind:     q.Get("kind"),
+		Scenario: q.Get("scenario"),
+		Status:   q.Get("status"),
+		Limit:    defaultHistoryLimit,
+	}
+	var err error
+	if f.Since, err = parseTime(q.Get("since")); err != nil {
+		http.Error(w, "Invalid since: "+err.Error(), http.StatusBadRequest)
+		return
+	}
+	if f.Until, err = parseTime(q.Get("until")); err != nil {
+		http.Error(w, "Invalid until: "+err.Error(), http.StatusBadRequest)
+		return
+	}
+	if limit := q.Get("limit"); limit != "" {
+		if f.Limit, err = strconv.Atoi(limit); err != nil || f.Limit < 0 {
+			http.Error(w, "Invalid limit", http.StatusBadRequest)
+			return
+		}
+	}
+
+	records := s.Query(f)
+	if records == nil {
+		records = []Record{}
+	}
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(records)
+}
+
+func parseTime(value string) (time.Time, error) {
+	if value == "" {
+		return time.Time{}, nil
+	}
+	if t, err := time.Parse(time.RFC3339, value); err == nil {
+		return t, nil
+	}
+	if d, err := time.ParseDuration(value); err == nil {
+		return time.Now().Add(-d), nil
+	}
+	return time.Time{}, fmt.Errorf("%q is neither an RFC 3339 timestamp nor a duration", value)
+}
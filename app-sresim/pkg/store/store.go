@@ -0,0 +1,369 @@
+package store
+
+import (
+	"bufio"
+	"context"
+	"encoding/json"
+	"fmt"
+	"log/slog"
+	"os"
+	"path/filepath"
+	"sort"
+	"sync"
+	"time"
+
+	"github.com/localstack/sresim/app-sresim/pkg/events"
+)
+
+// Record kinds.
+const (
+	KindRun        = "run"
+	KindExperiment = "experiment"
+	KindAudit      = "audit"
+	KindSchedule   = "schedule"
+)
+
+// Record is one entry of the history. Writing a record with the kind and ID
+// of an existing one replaces it.
+type Record struct {
+	Kind     string          `json:"kind"`
+	ID       string          `json:"id"`
+	Time     time.Time       `json:"time"`
+	Scenario string          `json:"scenario,omitempty"`
+	Status   string          `json:"status,omitempty"`
+	Data     json.RawMessage `json:"data"`
+	// Deleted marks a tombstone written by Delete. Tombstones are dropped
+	// when the log is compacted.
+	Deleted bool `json:"deleted,omitempty"`
+}
+
+type key struct {
+	kind string
+	id   string
+}
+
+// DefaultMaxAuditRecords is how many audit records are kept when Open is
+// not given a limit.
+const DefaultMaxAuditRecords = 10000
+
+// minCompactLines is how many lines have to be appended to the log before
+// it is compacted while open.
+const minCompactLines = 1000
+
+// Store is an append-only JSONL log of records. Every write is appended to
+// the file and the latest version of each record is kept in memory. An empty
+// path keeps the history in memory only.
+type Store struct {
+	mu      sync.RWMutex
+	path    string
+	file    *os.File
+	records map[key]Record
+	// audit holds the keys of the audit records, oldest first. Only the
+	// most recent maxAudit of them are kept.
+	audit    []key
+	maxAudit int
+	// appended counts the lines written since the log was last compacted.
+	appended int
+}
+
+// Open loads the log at path, creating it if needed, and compacts it so that
+// it holds only the latest version of each record and the most recent
+// maxAudit audit records (DefaultMaxAuditRecords if maxAudit is 0).
+func Open(path string, maxAudit int) (*Store, error) {
+	if maxAudit <= 0 {
+		maxAudit = DefaultMaxAuditRecords
+	}
+	s := &Store{path: path, records: make(map[key]Record), maxAudit: maxAudit}
+	if path == "" {
+		return s, nil
+	}
+	if err := s.load(path); err != nil {
+		return nil, err
+	}
+	if err := s.reopen(); err != nil {
+		return nil, err
+	}
+	return s, nil
+}
+
+// reopen compacts the log and opens it for appending. The caller must hold
+// s.mu or own s exclusively.
+func (s *Store) reopen() error {
+	if err := s.compact(s.path); err != nil {
+		return err
+	}
+	f, err := os.OpenFile(s.path, os.O_APPEND|os.O_CREATE|os.O_WRONLY, 0o644)
+	if err != nil {
+		return err
+	}
+	if s.file != nil {
+		s.file.Close()
+	}
+	s.file = f
+	s.appended = 0
+	return nil
+}
+
+func (s *Store) load(path string) error {
+	f, err := os.Open(path)
+	if os.IsNotExist(err) {
+		return nil
+	}
+	if err != nil {
+		return err
+	}
+	defer f.Close()
+
+	scanner := bufio.NewScanner(f)
+	scanner.Buffer(make([]byte, 0, 64*1024), 16*1024*1024)
+	for scanner.Scan() {
+		var r Record
+		if err := json.Unmarshal(scanner.Bytes(), &r); err != nil {
+			// A write cut short by a crash leaves a partial last line; the
+			// records before it are still good.
+			continue
+		}
+		if r.Deleted {
+			delete(s.records, key{r.Kind, r.ID})
+			continue
+		}
+		s.records[key{r.Kind, r.ID}] = r
+	}
+	if err := scanner.Err(); err != nil {
+		return fmt.Errorf("read %s: %w", path, err)
+	}
+	for _, r := range s.sorted() {
+		if r.Kind == KindAudit {
+			s.audit = append(s.audit, key{r.Kind, r.ID})
+		}
+	}
+	s.trimAudit()
+	return nil
+}
+
+// trimAudit drops the oldest audit records beyond s.maxAudit. The caller
+// must hold s.mu.
+func (s *Store) trimAudit() {
+	for len(s.audit) > s.maxAudit {
+		delete(s.records, s.audit[0])
+		s.audit = s.audit[1:]
+	}
+}
+
+// compact rewrites the log with one line per record.
+func (s *Store) compact(path string) error {
+	if err := os.MkdirAll(filepath.Dir(path), 0o755); err != nil {
+		return err
+	}
+	tmp, err := os.CreateTemp(filepath.Dir(path), filepath.Base(path)+".*")
+	if err != nil {
+		return err
+	}
+	defer os.Remove(tmp.Name())
+
+	w := bufio.NewWriter(tmp)
+	enc := json.NewEncoder(w)
+	for _, r := range s.sorted() {
+		if err := enc.Encode(r); err != nil {
+			tmp.Close()
+			return err
+		}
+	}
+	if err := w.Flush(); err != nil {
+		tmp.Close()
+		return err
+	}
+	if err := tmp.Close(); err != nil {
+		return err
+	}
+	return os.Rename(tmp.Name(), path)
+}
+
+// sorted returns every record, oldest first.
+func (s *Store) sorted() []Record {
+	records := make([]Record, 0, len(s.records))
+	for _, r := range s.records {
+		records = append(records, r)
+	}
+	sort.Slice(records, func(i, j int) bool { return before(records[i], records[j]) })
+	return records
+}
+
+// before orders records by time and then by ID.
+func before(a, b Record) bool {
+	if a.Time.Equal(b.Time) {
+		return a.ID < b.ID
+	}
+	return a.Time.Before(b.Time)
+}
+
+// Put writes r with v, encoded as JSON, as its data.
+func (s *Store) Put(r Record, v interface{}) error {
+	data, err := json.Marshal(v)
+	if err != nil {
+		return err
+	}
+	r.Data = data
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	k := key{r.Kind, r.ID}
+	if _, exists := s.records[k]; !exists && r.Kind == KindAudit {
+		s.audit = append(s.audit, k)
+	}
+	s.records[k] = r
+	s.trimAudit()
+	return s.append(r)
+}
+
+// Delete removes the record of the given kind and ID.
+func (s *Store) Delete(kind, id string) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	k := key{kind, id}
+	delete(s.records, k)
+	if kind == KindAudit {
+		for i, a := range s.audit {
+			if a == k {
+				s.audit = append(s.audit[:i], s.audit[i+1:]...)
+				break
+			}
+		}
+	}
+	return s.append(Record{Kind: kind, ID: id, Time: time.Now(), Deleted: true})
+}
+
+// append writes r to the log file, if there is one, and compacts the log
+// once it holds more superseded lines than records. The caller must hold
+// s.mu.
+func (s *Store) append(r Record) error {
+	if s.file == nil {
+		return nil
+	}
+	line, err := json.Marshal(r)
+	if err != nil {
+		return err
+	}
+	if _, err := s.file.Write(append(line, '\n')); err != nil {
+		return err
+	}
+	s.appended++
+	if s.appended < minCompactLines || s.appended < len(s.records) {
+		return nil
+	}
+	// The record is written either way; a failed compaction is retried
+	// with the next one.
+	if err := s.reopen(); err != nil {
+		slog.Warn("Failed to compact history", "path", s.path, "error", err)
+	}
+	return nil
+}
+
+// Check fails when the log file can no longer be written to, e.g. because
+// it was deleted or its volume went away. An in-memory store is always
+// healthy.
+func (s *Store) Check(ctx context.Context) error {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	if s.file == nil {
+		return nil
+	}
+	open, err := s.file.Stat()
+	if err != nil {
+		return err
+	}
+	onDisk, err := os.Stat(s.path)
+	if err != nil {
+		return err
+	}
+	if !os.SameFile(open, onDisk) {
+		return fmt.Errorf("%s was replaced", s.path)
+	}
+	return nil
+}
+
+// Get returns the record of the given kind and ID.
+func (s *Store) Get(kind, id string) (Record, bool) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	r, ok := s.records[key{kind, id}]
+	return r, ok
+}
+
+// Filter selects records. Zero fields match everything.
+type Filter struct {
+	Kind     string
+	Scenario string
+	Status   string
+	Since    time.Time
+	Until    time.Time
+	// Limit caps the number of records returned, most recent first.
+	Limit int
+}
+
+func (f Filter) match(r Record) bool {
+	return (f.Kind == "" || r.Kind == f.Kind) &&
+		(f.Scenario == "" || r.Scenario == f.Scenario) &&
+		(f.Status == "" || r.Status == f.Status) &&
+		(f.Since.IsZero() || !r.Time.Before(f.Since)) &&
+		(f.Until.IsZero() || r.Time.Before(f.Until))
+}
+
+// Query returns the records matching f, most recent first.
+func (s *Store) Query(f Filter) []Record {
+	var matched []Record
+	s.mu.RLock()
+	for _, r := range s.records {
+		if f.match(r) {
+			matched = append(matched, r)
+		}
+	}
+	s.mu.RUnlock()
+
+	sort.Slice(matched, func(i, j int) bool { return before(matched[j], matched[i]) })
+	if f.Limit > 0 && len(matched) > f.Limit {
+		matched = matched[:f.Limit]
+	}
+	return matched
+}
+
+// auditedEvents are the event types RecordEvents keeps: the lifecycle of
+// runs and experiments and what operators did. High-rate events such as
+// sampled faults and breaker transitions are only streamed.
+var auditedEvents = map[string]bool{
+	events.ScenarioStarted:    true,
+	events.ScenarioStopped:    true,
+	events.ScenarioAborted:    true,
+	events.ScenarioVerdict:    true,
+	events.ScenarioDetected:   true,
+	events.ExperimentFinished: true,
+	events.Kill:               true,
+	events.ConfigReloaded:     true,
+}
+
+// RecordEvents writes the lifecycle and audit events received from ch as
+// audit records until ch is closed.
+func (s *Store) RecordEvents(ch <-chan events.Event) {
+	for e := range ch {
+		if !auditedEvents[e.Type] {
+			continue
+		}
+		s.Put(Record{Kind: KindAudit, ID: e.ID, Time: e.Time, Scenario: e.Scenario, Status: e.Type}, e)
+	}
+}
+
+// Close flushes the log file to disk and closes it. Records written
+// afterwards are kept in memory only.
+func (s *Store) Close() error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if s.file == nil {
+		return nil
+	}
+	err := s.file.Sync()
+	if cerr := s.file.Close(); err == nil {
+		err = cerr
+	}
+	s.file = nil
+	return err
+}
@@ -0,0 +1,224 @@
+package store
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/localstack/sresim/app-sresim/pkg/events"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+type payload struct {
+	Value string `json:"value"`
+}
+
+func TestPutAndQuery(t *testing.T) {
+	s, err := Open("", 0)
+	require.NoError(t, err)
+
+	base := time.Date(2024, 3, 25, 12, 0, 0, 0, time.UTC)
+	require.NoError(t, s.Put(Record{Kind: KindRun, ID: "a", Time: base, Scenario: "latency", Status: "running"}, payload{"a1"}))
+	require.NoError(t, s.Put(Record{Kind: KindRun, ID: "b", Time: base.Add(time.Hour), Scenario: "cpu_spike", Status: "stopped"}, payload{"b"}))
+	require.NoError(t, s.Put(Record{Kind: KindExperiment, ID: "c", Time: base.Add(2 * time.Hour), Status: "passed"}, payload{"c"}))
+	// Writing the same kind and ID again replaces the record.
+	require.NoError(t, s.Put(Record{Kind: KindRun, ID: "a", Time: base, Scenario: "latency", Status: "stopped"}, payload{"a2"}))
+
+	ids := func(records []Record) []string {
+		var ids []string
+		for _, r := range records {
+			ids = append(ids, r.ID)
+		}
+		return ids
+	}
+	assert.Equal(t, []string{"c", "b", "a"}, ids(s.Query(Filter{})))
+	assert.Equal(t, []string{"b", "a"}, ids(s.Query(Filter{Kind: KindRun})))
+	assert.Equal(t, []string{"a"}, ids(s.Query(Filter{Scenario: "latency"})))
+	assert.Equal(t, []string{"b", "a"}, ids(s.Query(Filter{Status: "stopped"})))
+	assert.Equal(t, []string{"c", "b"}, ids(s.Query(Filter{Since: base.Add(time.Hour)})))
+	assert.Equal(t, []string{"a"}, ids(s.Query(Filter{Until: base.Add(time.Hour)})))
+	assert.Equal(t, []string{"c"}, ids(s.Query(Filter{Limit: 1})))
+
+	r, ok := s.Get(KindRun, "a")
+	require.True(t, ok)
+	assert.JSONEq(t, `{"value": "a2"}`, string(r.Data))
+}
+
+func TestPersistence(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "history", "history.jsonl")
+	s, err := Open(path, 0)
+	require.NoError(t, err)
+	now := time.Now().UTC().Truncate(time.Second)
+	for i := 0; i < 3; i++ {
+		require.NoError(t, s.Put(Record{Kind: KindRun, ID: "run", Time: now, Status: "running"}, payload{"v"}))
+	}
+	require.NoError(t, s.Put(Record{Kind: KindAudit, ID: "event", Time: now}, payload{"e"}))
+	require.NoError(t, s.Close())
+
+	// Simulate a write cut short by a crash.
+	f, err := os.OpenFile(path, os.O_APPEND|os.O_WRONLY, 0)
+	require.NoError(t, err)
+	_, err = f.WriteString(`{"kind":"run","id":"trunc`)
+	require.NoError(t, err)
+	require.NoError(t, f.Close())
+
+	s, err = Open(path, 0)
+	require.NoError(t, err)
+	defer s.Close()
+	assert.Len(t, s.Query(Filter{}), 2)
+	r, ok := s.Get(KindRun, "run")
+	require.True(t, ok)
+	assert.True(t, now.Equal(r.Time))
+
+	data, err := os.ReadFile(path)
+	require.NoError(t, err)
+	assert.Equal(t, 2, strings.Count(string(data), "\n"), "the log is compacted on open")
+}
+
+func TestDelete(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "history.jsonl")
+	s, err := Open(path, 0)
+	require.NoError(t, err)
+	require.NoError(t, s.Put(Record{Kind: KindSchedule, ID: "a", Time: time.Now()}, payload{"a"}))
+	require.NoError(t, s.Put(Record{Kind: KindSchedule, ID: "b", Time: time.Now()}, payload{"b"}))
+	require.NoError(t, s.Delete(KindSchedule, "a"))
+	_, ok := s.Get(KindSchedule, "a")
+	assert.False(t, ok)
+	require.NoError(t, s.Close())
+
+	s, err = Open(path, 0)
+	require.NoError(t, err)
+	defer s.Close()
+	records := s.Query(Filter{Kind: KindSchedule})
+	require.Len(t, records, 1)
+	assert.Equal(t, "b", records[0].ID)
+
+	data, err := os.ReadFile(path)
+	require.NoError(t, err)
+	assert.NotContains(t, string(data), "deleted", "tombstones are compacted away")
+}
+
+func TestRecordEvents(t *testing.T) {
+	s, err := Open("", 0)
+	require.NoError(t, err)
+
+	ch := make(chan events.Event, 2)
+	ch <- events.Event{ID: "e1", Type: events.Kill, Time: time.Now()}
+	ch <- events.Event{ID: "e2", Type: events.FaultInjected, Time: time.Now()}
+	close(ch)
+	s.RecordEvents(ch)
+
+	records := s.Query(Filter{Kind: KindAudit})
+	require.Len(t, records, 1)
+	assert.Equal(t, events.Kill, records[0].Status)
+}
+
+func TestAuditRetention(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "history.jsonl")
+	s, err := Open(path, 3)
+	require.NoError(t, err)
+	base := time.Now().UTC().Truncate(time.Second)
+	require.NoError(t, s.Put(Record{Kind: KindRun, ID: "run", Time: base}, payload{}))
+	for i := 0; i < 5; i++ {
+		require.NoError(t, s.Put(Record{Kind: KindAudit, ID: fmt.Sprint(i), Time: base.Add(time.Duration(i) * time.Second)}, payload{}))
+	}
+
+	ids := func() []string {
+		var ids []string
+		for _, r := range s.Query(Filter{Kind: KindAudit}) {
+			ids = append(ids, r.ID)
+		}
+		return ids
+	}
+	assert.Equal(t, []string{"4", "3", "2"}, ids(), "only the most recent audit records are kept")
+	_, ok := s.Get(KindRun, "run")
+	assert.True(t, ok, "other records are kept")
+	require.NoError(t, s.Close())
+
+	// A lower limit is applied to the log on open.
+	s, err = Open(path, 2)
+	require.NoError(t, err)
+	defer s.Close()
+	assert.Equal(t, []string{"4", "3"}, ids())
+	data, err := os.ReadFile(path)
+	require.NoError(t, err)
+	assert.Equal(t, 3, strings.Count(string(data), "\n"))
+}
+
+func TestCompactWhileOpen(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "history.jsonl")
+	s, err := Open(path, 0)
+	require.NoError(t, err)
+	defer s.Close()
+	for i := 0; i < 2*minCompactLines; i++ {
+		require.NoError(t, s.Put(Record{Kind: KindRun, ID: fmt.Sprint(i % 10), Time: time.Now()}, payload{}))
+	}
+
+	data, err := os.ReadFile(path)
+	require.NoError(t, err)
+	assert.Less(t, strings.Count(string(data), "\n"), minCompactLines, "superseded lines are compacted away")
+	assert.NoError(t, s.Check(context.Background()), "writes go to the compacted log")
+	assert.Len(t, s.Query(Filter{}), 10)
+}
+
+func TestHistoryHandler(t *testing.T) {
+	s, err := Open("", 0)
+	require.NoError(t, err)
+	now := time.Now()
+	s.Put(Record{Kind: KindRun, ID: "old", Time: now.Add(-48 * time.Hour), Scenario: "latency"}, payload{})
+	s.Put(Record{Kind: KindRun, ID: "new", Time: now.Add(-time.Hour), Scenario: "latency"}, payload{})
+	s.Put(Record{Kind: KindRun, ID: "other", Time: now, Scenario: "disk_io"}, payload{})
+
+	get := func(query string) (int, []Record) {
+		w := httptest.NewRecorder()
+		s.HistoryHandler(w, httptest.NewRequest(http.MethodGet, "/history?"+query, nil))
+		var records []Record
+		if w.Code == http.StatusOK {
+			require.NoError(t, json.Unmarshal(w.Body.Bytes(), &records))
+		}
+		return w.Code, records
+	}
+
+	code, records := get("scenario=latency&since=24h")
+	assert.Equal(t, http.StatusOK, code)
+	require.Len(t, records, 1)
+	assert.Equal(t, "new", records[0].ID)
+
+	code, records = get("until=" + now.Add(-time.Minute).Format(time.RFC3339) + "&limit=1")
+	assert.Equal(t, http.StatusOK, code)
+	require.Len(t, records, 1)
+	assert.Equal(t, "new", records[0].ID)
+
+	code, records = get("kind=experiment")
+	assert.Equal(t, http.StatusOK, code)
+	assert.NotNil(t, records)
+	assert.Empty(t, records)
+
+	code, _ = get("since=yesterday")
+	assert.Equal(t, http.StatusBadRequest, code)
+	code, _ = get("limit=-1")
+	assert.Equal(t, http.StatusBadRequest, code)
+}
+
+func TestCheck(t *testing.T) {
+	mem, err := Open("", 0)
+	require.NoError(t, err)
+	assert.NoError(t, mem.Check(context.Background()))
+
+	path := filepath.Join(t.TempDir(), "history.jsonl")
+	s, err := Open(path, 0)
+	require.NoError(t, err)
+	defer s.Close()
+	assert.NoError(t, s.Check(context.Background()))
+
+	require.NoError(t, os.Remove(path))
+	assert.Error(t, s.Check(context.Background()))
+}
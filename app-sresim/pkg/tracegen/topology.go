@@ -0,0 +1,119 @@
+package tracegen
+
+import (
+	"sort"
+	"time"
+
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/trace"
+)
+
+// Call patterns of an operation calling another one.
+const (
+	// CallOnce calls the operation once.
+	CallOnce = iota
+	// CallFanOut calls FanOut instances of the operation concurrently.
+	CallFanOut
+	// CallPerItem calls the operation once per item, one after the other:
+	// the N+1 query pattern. Without N+1 queries it is called once for all
+	// items.
+	CallPerItem
+)
+
+// Operation is one kind of span of the simulated system.
+type Operation struct {
+	Service string
+	Name    string
+	Kind    trace.SpanKind
+	// Latency is the typical time the operation spends on its own,
+	// excluding its calls.
+	Latency    time.Duration
+	Attributes []attribute.KeyValue
+	// Error is the exception the operation fails with.
+	Error string
+	Calls []Call
+}
+
+// Call is a call from one operation to another.
+type Call struct {
+	To      *Operation
+	Pattern int
+}
+
+// Shop is the simulated system: an online shop whose checkout request
+// passes through a gateway to a dozen services, caches and databases.
+var Shop = func() *Operation {
+	redis := &Operation{
+		Service: "cart-cache", Name: "GET", Kind: trace.SpanKindClient, Latency: time.Millisecond,
+		Attributes: []attribute.KeyValue{attribute.String("db.system", "redis"), attribute.String("db.statement", "GET cart:{id}")},
+		Error:      "redis: connection pool timeout",
+	}
+	prices := &Operation{
+		Service: "catalog-db", Name: "SELECT prices", Kind: trace.SpanKindClient, Latency: 2 * time.Millisecond,
+		Attributes: []attribute.KeyValue{attribute.String("db.system", "postgresql"), attribute.String("db.statement", "SELECT price FROM prices WHERE product_id = $1")},
+		Error:      "pq: canceling statement due to statement timeout",
+	}
+	search := &Operation{
+		Service: "search", Name: "Search/Query", Kind: trace.SpanKindServer, Latency: 15 * time.Millisecond,
+		Attributes: []attribute.KeyValue{attribute.String("rpc.system", "grpc")},
+		Error:      "search shard unavailable",
+	}
+	orders := &Operation{
+		Service: "orders-db", Name: "INSERT orders", Kind: trace.SpanKindClient, Latency: 4 * time.Millisecond,
+		Attributes: []attribute.KeyValue{attribute.String("db.system", "postgresql"), attribute.String("db.statement", "INSERT INTO orders (customer_id, total) VALUES ($1, $2)")},
+		Error:      "pq: deadlock detected",
+	}
+	return &Operation{
+		Service: "frontend", Name: "POST /checkout", Kind: trace.SpanKindServer, Latency: 5 * time.Millisecond,
+		Attributes: []attribute.KeyValue{attribute.String("http.request.method", "POST"), attribute.String("http.route", "/checkout")},
+		Error:      "upstream request failed",
+		Calls: []Call{{To: &Operation{
+			Service: "api-gateway", Name: "POST /api/checkout", Kind: trace.SpanKindServer, Latency: 2 * time.Millisecond,
+			Attributes: []attribute.KeyValue{attribute.String("http.request.method", "POST"), attribute.String("http.route", "/api/checkout")},
+			Error:      "no healthy upstream",
+			Calls: []Call{
+				{To: &Operation{Service: "auth", Name: "Auth/Verify", Kind: trace.SpanKindServer, Latency: 3 * time.Millisecond, Error: "token verification failed: key set unavailable"}},
+				{To: &Operation{
+					Service: "cart", Name: "Cart/Get", Kind: trace.SpanKindServer, Latency: 4 * time.Millisecond, Error: "cart service overloaded",
+					Calls: []Call{{To: redis}},
+				}},
+				{To: &Operation{
+					Service: "catalog", Name: "Catalog/GetProducts", Kind: trace.SpanKindServer, Latency: 6 * time.Millisecond, Error: "failed to load product prices",
+					Calls: []Call{{To: prices, Pattern: CallPerItem}},
+				}},
+				{To: &Operation{
+					Service: "recommendation", Name: "Recommendation/List", Kind: trace.SpanKindServer, Latency: 8 * time.Millisecond, Error: "recommendation model not loaded",
+					Calls: []Call{{To: search, Pattern: CallFanOut}},
+				}},
+				{To: &Operation{
+					Service: "checkout", Name: "Checkout/PlaceOrder", Kind: trace.SpanKindServer, Latency: 10 * time.Millisecond, Error: "order could not be placed",
+					Calls: []Call{
+						{To: &Operation{Service: "payment", Name: "Payment/Charge", Kind: trace.SpanKindServer, Latency: 40 * time.Millisecond, Error: "payment provider returned 503"}},
+						{To: &Operation{Service: "inventory", Name: "Inventory/Reserve", Kind: trace.SpanKindServer, Latency: 7 * time.Millisecond, Error: "insufficient stock: lock wait timeout"}},
+						{To: orders},
+					},
+				}},
+			},
+		}}},
+	}
+}()
+
+// Services returns the names of the services of the system rooted at op,
+// sorted.
+func Services(op *Operation) []string {
+	seen := make(map[string]bool)
+	var walk func(*Operation)
+	walk = func(op *Operation) {
+		seen[op.Service] = true
+		for _, c := range op.Calls {
+			walk(c.To)
+		}
+	}
+	walk(op)
+	names := make([]string, 0, len(seen))
+	for name := range seen {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names
+}
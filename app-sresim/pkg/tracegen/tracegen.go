@@ -0,0 +1,223 @@
+// Package tracegen generates the traces of a simulated distributed system,
+// with anomalies injected into one culprit service, for practising
+// trace-based debugging.
+package tracegen
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"math/rand"
+	"time"
+
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/codes"
+	"go.opentelemetry.io/otel/trace"
+)
+
+// Options shape the generated traces. Percentages are of traces, except
+// GapPercentage, which is of spans.
+type Options struct {
+	TracesPerSecond int
+	// FanOut is the number of concurrent calls of fan-out calls.
+	FanOut int
+	// NPlusOne is the number of queries of per-item calls, made one after
+	// the other. Zero makes a single batched query instead.
+	NPlusOne int
+	// SlowPercentage of traces have the spans of the culprit take 10 to 20
+	// times longer than usual.
+	SlowPercentage int
+	// ErrorPercentage of traces fail at a span of the culprit. The error
+	// propagates to its callers, which skip their remaining calls.
+	ErrorPercentage int
+	// GapPercentage of spans are never reported, leaving their children
+	// pointing at a parent that is missing from the trace.
+	GapPercentage int
+	// Culprit is the service the slow and failing spans belong to. Empty
+	// picks one at random.
+	Culprit string
+}
+
+// Validate checks the options against the system rooted at root.
+func (o Options) Validate(root *Operation) error {
+	if o.TracesPerSecond <= 0 {
+		return errors.New("traces per second must be positive")
+	}
+	if o.FanOut < 1 {
+		return errors.New("fan-out must be at least 1")
+	}
+	if o.NPlusOne < 0 {
+		return errors.New("N+1 queries must not be negative")
+	}
+	for _, p := range []int{o.SlowPercentage, o.ErrorPercentage, o.GapPercentage} {
+		if p < 0 || p > 100 {
+			return fmt.Errorf("percentage %d is not between 0 and 100", p)
+		}
+	}
+	if o.Culprit != "" {
+		for _, s := range Services(root) {
+			if s == o.Culprit {
+				return nil
+			}
+		}
+		return fmt.Errorf("unknown service %q", o.Culprit)
+	}
+	return nil
+}
+
+// Generator emits the traces of a simulated system. It is not safe for
+// concurrent use.
+type Generator struct {
+	root   *Operation
+	opts   Options
+	tracer func(service string) trace.Tracer
+	rng    *rand.Rand
+}
+
+// New returns a generator of traces of the system rooted at root. tracer
+// returns the tracer that reports the spans of a service, so that every
+// service of the simulated system shows up as a service of its own.
+func New(root *Operation, opts Options, tracer func(service string) trace.Tracer, seed int64) (*Generator, error) {
+	if err := opts.Validate(root); err != nil {
+		return nil, err
+	}
+	g := &Generator{root: root, opts: opts, tracer: tracer, rng: rand.New(rand.NewSource(seed))}
+	if g.opts.Culprit == "" {
+		// The entry point is where every anomaly shows; a service behind
+		// it makes for a search.
+		var candidates []string
+		for _, s := range Services(root) {
+			if s != root.Service {
+				candidates = append(candidates, s)
+			}
+		}
+		g.opts.Culprit = candidates[g.rng.Intn(len(candidates))]
+	}
+	return g, nil
+}
+
+// Culprit returns the service the anomalies are injected into.
+func (g *Generator) Culprit() string {
+	return g.opts.Culprit
+}
+
+// Run emits TracesPerSecond traces a second until stop is closed.
+func (g *Generator) Run(stop <-chan struct{}) {
+	ticker := time.NewTicker(time.Second / time.Duration(g.opts.TracesPerSecond))
+	defer ticker.Stop()
+	for {
+		select {
+		case <-stop:
+			return
+		case now := <-ticker.C:
+			g.Emit(now)
+		}
+	}
+}
+
+// traceState holds the anomalies of the trace being emitted.
+type traceState struct {
+	slow bool
+	// fail is cleared once a span of the culprit failed.
+	fail bool
+}
+
+// Emit emits one trace that starts at start and returns its ID. The spans
+// carry simulated timestamps, so the whole trace is emitted at once.
+func (g *Generator) Emit(start time.Time) trace.TraceID {
+	t := &traceState{slow: g.roll(g.opts.SlowPercentage), fail: g.roll(g.opts.ErrorPercentage)}
+	ctx, span := g.start(context.Background(), g.root, start, trace.WithNewRoot())
+	g.finish(ctx, span, g.root, start, t)
+	return span.SpanContext().TraceID()
+}
+
+func (g *Generator) start(ctx context.Context, op *Operation, at time.Time, opts ...trace.SpanStartOption) (context.Context, trace.Span) {
+	opts = append(opts, trace.WithTimestamp(at), trace.WithSpanKind(op.Kind), trace.WithAttributes(op.Attributes...))
+	return g.tracer(op.Service).Start(ctx, op.Name, opts...)
+}
+
+// emit emits the span of op and its calls, and returns when the operation
+// ended and whether it failed.
+func (g *Generator) emit(ctx context.Context, op *Operation, at time.Time, t *traceState, attrs ...attribute.KeyValue) (time.Time, bool) {
+	ctx, span := g.start(ctx, op, at, trace.WithAttributes(attrs...))
+	return g.finish(ctx, span, op, at, t)
+}
+
+func (g *Generator) finish(ctx context.Context, span trace.Span, op *Operation, start time.Time, t *traceState) (time.Time, bool) {
+	culprit := op.Service == g.opts.Culprit
+	own := g.jitter(op.Latency)
+	if culprit && t.slow {
+		own *= time.Duration(10 + g.rng.Intn(11))
+	}
+
+	// Half of the operation's own work happens before its calls.
+	now := start.Add(own / 2)
+	failed := culprit && t.fail
+	if failed {
+		t.fail = false
+		span.RecordError(errors.New(op.Error), trace.WithTimestamp(now))
+	} else {
+		now, failed = g.calls(ctx, op, now, t)
+	}
+	end := now.Add(own - own/2)
+
+	if failed {
+		span.SetStatus(codes.Error, op.Error)
+	}
+	if op != g.root && g.roll(g.opts.GapPercentage) {
+		// A span that is never ended is never exported.
+		return end, failed
+	}
+	span.End(trace.WithTimestamp(end))
+	return end, failed
+}
+
+// calls emits the calls of op, starting at now, and returns when the last
+// one ended and whether one failed. Callers give up after a failed call.
+func (g *Generator) calls(ctx context.Context, op *Operation, now time.Time, t *traceState) (time.Time, bool) {
+	for _, c := range op.Calls {
+		switch c.Pattern {
+		case CallFanOut:
+			latest, failed := now, false
+			for i := 0; i < g.opts.FanOut; i++ {
+				end, f := g.emit(ctx, c.To, now, t, attribute.Int("sresim.synthetic.shard", i))
+				if end.After(latest) {
+					latest = end
+				}
+				failed = failed || f
+			}
+			if now = latest; failed {
+				return now, true
+			}
+		case CallPerItem:
+			if g.opts.NPlusOne == 0 {
+				end, failed := g.emit(ctx, c.To, now, t)
+				if now = end; failed {
+					return now, true
+				}
+				continue
+			}
+			for i := 0; i < g.opts.NPlusOne; i++ {
+				end, failed := g.emit(ctx, c.To, now, t, attribute.Int("sresim.synthetic.item", i))
+				if now = end; failed {
+					return now, true
+				}
+			}
+		default:
+			end, failed := g.emit(ctx, c.To, now, t)
+			if now = end; failed {
+				return now, true
+			}
+		}
+	}
+	return now, false
+}
+
+// jitter returns d varied by up to 50% either way.
+func (g *Generator) jitter(d time.Duration) time.Duration {
+	return d/2 + time.Duration(g.rng.Int63n(int64(d)+1))
+}
+
+func (g *Generator) roll(percentage int) bool {
+	return g.rng.Intn(100) < percentage
+}
@@ -0,0 +1,166 @@
+package tracegen
+
+import (
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"go.opentelemetry.io/otel/codes"
+	"go.opentelemetry.io/otel/sdk/resource"
+	sdktrace "go.opentelemetry.io/otel/sdk/trace"
+	"go.opentelemetry.io/otel/sdk/trace/tracetest"
+	semconv "go.opentelemetry.io/otel/semconv/v1.26.0"
+	"go.opentelemetry.io/otel/trace"
+)
+
+// generate emits one trace with opts and returns its spans.
+func generate(t *testing.T, opts Options) tracetest.SpanStubs {
+	t.Helper()
+	exporter := tracetest.NewInMemoryExporter()
+	providers := make(map[string]*sdktrace.TracerProvider)
+	tracer := func(service string) trace.Tracer {
+		p, ok := providers[service]
+		if !ok {
+			p = sdktrace.NewTracerProvider(
+				sdktrace.WithSyncer(exporter),
+				sdktrace.WithResource(resource.NewSchemaless(semconv.ServiceName(service))),
+			)
+			providers[service] = p
+		}
+		return p.Tracer("test")
+	}
+
+	if opts.TracesPerSecond == 0 {
+		opts.TracesPerSecond = 1
+	}
+	if opts.FanOut == 0 {
+		opts.FanOut = 3
+	}
+	g, err := New(Shop, opts, tracer, 1)
+	require.NoError(t, err)
+	id := g.Emit(time.Now())
+
+	spans := exporter.GetSpans()
+	for _, s := range spans {
+		require.Equal(t, id, s.SpanContext.TraceID(), "all spans belong to the emitted trace")
+	}
+	return spans
+}
+
+func service(s tracetest.SpanStub) string {
+	v, _ := s.Resource.Set().Value(semconv.ServiceNameKey)
+	return v.AsString()
+}
+
+func count(spans tracetest.SpanStubs, svc string) int {
+	n := 0
+	for _, s := range spans {
+		if service(s) == svc {
+			n++
+		}
+	}
+	return n
+}
+
+func TestEmit(t *testing.T) {
+	spans := generate(t, Options{FanOut: 3, NPlusOne: 5, Culprit: "payment"})
+
+	// 11 services called once, 3 search shards and 5 price queries.
+	require.Len(t, spans, 19)
+	assert.Equal(t, 3, count(spans, "search"))
+	assert.Equal(t, 5, count(spans, "catalog-db"))
+
+	ids := make(map[trace.SpanID]tracetest.SpanStub)
+	for _, s := range spans {
+		ids[s.SpanContext.SpanID()] = s
+	}
+	roots := 0
+	for _, s := range spans {
+		assert.Equal(t, codes.Unset, s.Status.Code)
+		assert.False(t, s.EndTime.Before(s.StartTime))
+		if !s.Parent.IsValid() {
+			roots++
+			assert.Equal(t, "frontend", service(s))
+			continue
+		}
+		parent, ok := ids[s.Parent.SpanID()]
+		require.True(t, ok, "no gaps were asked for")
+		assert.False(t, s.StartTime.Before(parent.StartTime), "%s starts within its parent", s.Name)
+		assert.False(t, s.EndTime.After(parent.EndTime), "%s ends within its parent", s.Name)
+	}
+	assert.Equal(t, 1, roots)
+}
+
+func TestBatchedQuery(t *testing.T) {
+	spans := generate(t, Options{NPlusOne: 0, Culprit: "payment"})
+	assert.Equal(t, 1, count(spans, "catalog-db"))
+}
+
+func TestErrorSpans(t *testing.T) {
+	spans := generate(t, Options{NPlusOne: 1, ErrorPercentage: 100, Culprit: "payment"})
+
+	failed := make(map[string]bool)
+	var exceptions []string
+	for _, s := range spans {
+		if s.Status.Code == codes.Error {
+			failed[service(s)] = true
+		}
+		for _, e := range s.Events {
+			if e.Name == "exception" {
+				exceptions = append(exceptions, service(s))
+			}
+		}
+	}
+	assert.Equal(t, map[string]bool{"payment": true, "checkout": true, "api-gateway": true, "frontend": true}, failed,
+		"the error propagates to the callers of the culprit")
+	assert.Equal(t, []string{"payment"}, exceptions, "only the culprit records the exception")
+	assert.Zero(t, count(spans, "inventory"), "checkout gives up after the failed payment")
+	assert.Zero(t, count(spans, "orders-db"))
+}
+
+func TestSlowSpans(t *testing.T) {
+	spans := generate(t, Options{NPlusOne: 1, SlowPercentage: 100, Culprit: "auth"})
+
+	for _, s := range spans {
+		if service(s) == "auth" {
+			// auth usually takes 1.5 to 4.5ms.
+			assert.GreaterOrEqual(t, s.EndTime.Sub(s.StartTime), 15*time.Millisecond)
+		}
+	}
+}
+
+func TestGaps(t *testing.T) {
+	spans := generate(t, Options{NPlusOne: 10, FanOut: 10, GapPercentage: 30, Culprit: "payment"})
+
+	ids := make(map[trace.SpanID]bool)
+	for _, s := range spans {
+		ids[s.SpanContext.SpanID()] = true
+	}
+	orphans := 0
+	for _, s := range spans {
+		if s.Parent.IsValid() && !ids[s.Parent.SpanID()] {
+			orphans++
+		}
+	}
+	assert.Less(t, len(spans), 31, "some spans are missing")
+	assert.Greater(t, orphans, 0, "some spans point at a missing parent")
+}
+
+func TestNew(t *testing.T) {
+	g, err := New(Shop, Options{TracesPerSecond: 1, FanOut: 1}, nil, 7)
+	require.NoError(t, err)
+	assert.Contains(t, Services(Shop), g.Culprit())
+	assert.NotEqual(t, "frontend", g.Culprit(), "the entry point is never the culprit")
+
+	for _, opts := range []Options{
+		{TracesPerSecond: 0, FanOut: 1},
+		{TracesPerSecond: 1, FanOut: 0},
+		{TracesPerSecond: 1, FanOut: 1, NPlusOne: -1},
+		{TracesPerSecond: 1, FanOut: 1, ErrorPercentage: 101},
+		{TracesPerSecond: 1, FanOut: 1, Culprit: "billing"},
+	} {
+		_, err := New(Shop, opts, nil, 1)
+		assert.Error(t, err, "%+v", opts)
+	}
+}
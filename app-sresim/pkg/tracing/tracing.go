@@ -0,0 +1,266 @@
+// Package tracing sets up OpenTelemetry tracing: a server span for every
+// request, with the faults injected into it, and a span for every scenario
+// run.
+package tracing
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"os"
+	"sort"
+	"strconv"
+	"sync"
+
+	"go.opentelemetry.io/otel"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/codes"
+	"go.opentelemetry.io/otel/exporters/otlp/otlptrace/otlptracehttp"
+	"go.opentelemetry.io/otel/propagation"
+	"go.opentelemetry.io/otel/sdk/resource"
+	sdktrace "go.opentelemetry.io/otel/sdk/trace"
+	semconv "go.opentelemetry.io/otel/semconv/v1.26.0"
+	"go.opentelemetry.io/otel/trace"
+
+	"github.com/localstack/sresim/app-sresim/pkg/config"
+	"github.com/localstack/sresim/app-sresim/pkg/metrics"
+	"github.com/localstack/sresim/app-sresim/pkg/response"
+	"github.com/localstack/sresim/app-sresim/pkg/version"
+)
+
+// instrumentationName names the tracer of all sresim spans.
+const instrumentationName = "github.com/localstack/sresim/app-sresim"
+
+// Attributes sresim adds to spans.
+const (
+	FaultTypeKey    = attribute.Key("sresim.fault.type")
+	FaultDelayKey   = attribute.Key("sresim.fault.delay_ms")
+	ScenarioKey     = attribute.Key("sresim.scenario")
+	RunIDKey        = attribute.Key("sresim.scenario.run_id")
+	RunIDsKey       = attribute.Key("sresim.scenario.run_ids")
+	RunStatusKey    = attribute.Key("sresim.scenario.run_status")
+	AbortReasonKey  = attribute.Key("sresim.scenario.abort_reason")
+	ParameterPrefix = "sresim.scenario.parameter."
+	WebhookKey      = attribute.Key("sresim.webhook")
+	EventTypeKey    = attribute.Key("sresim.event.type")
+	// SyntheticKey marks the services simulated by the synthetic_traces
+	// scenario.
+	SyntheticKey = attribute.Key("sresim.synthetic")
+)
+
+// FaultInjectedEvent is the span event recorded for every fault injected
+// into a request.
+const FaultInjectedEvent = "fault.injected"
+
+// Init installs a tracer provider that exports spans over OTLP/HTTP when
+// tracing is enabled in cfg or by ENABLE_TRACING=true. The collector
+// endpoint is cfg.Endpoint, or else taken from the standard
+// OTEL_EXPORTER_OTLP_ENDPOINT variables. The returned function flushes the
+// pending spans; it does nothing while tracing is off.
+func Init(ctx context.Context, cfg config.Tracing) (shutdown func(context.Context) error, err error) {
+	otel.SetTextMapPropagator(propagation.NewCompositeTextMapPropagator(propagation.TraceContext{}, propagation.Baggage{}))
+	if enabled, _ := strconv.ParseBool(os.Getenv("ENABLE_TRACING")); !cfg.Enabled && !enabled {
+		return func(context.Context) error { return nil }, nil
+	}
+
+	var opts []otlptracehttp.Option
+	if cfg.Endpoint != "" {
+		opts = append(opts, otlptracehttp.WithEndpointURL(cfg.Endpoint))
+	}
+	exporter, err := otlptracehttp.New(ctx, opts...)
+	if err != nil {
+		return nil, err
+	}
+	res, err := resource.New(ctx,
+		resource.WithFromEnv(),
+		resource.WithTelemetrySDK(),
+		resource.WithAttributes(semconv.ServiceName("sresim"), semconv.ServiceVersion(version.Get().Version)),
+	)
+	if err != nil {
+		return nil, err
+	}
+
+	sampler := sdktrace.AlwaysSample()
+	if cfg.SampleRatio > 0 && cfg.SampleRatio < 1 {
+		sampler = sdktrace.TraceIDRatioBased(cfg.SampleRatio)
+	}
+	provider := sdktrace.NewTracerProvider(
+		sdktrace.WithBatcher(exporter),
+		sdktrace.WithResource(res),
+		sdktrace.WithSampler(sdktrace.ParentBased(sampler)),
+	)
+	otel.SetTracerProvider(provider)
+
+	services.Lock()
+	services.exporter = sharedExporter{exporter}
+	services.Unlock()
+	return func(ctx context.Context) error {
+		services.Lock()
+		defer services.Unlock()
+		for _, p := range services.providers {
+			p.Shutdown(ctx)
+		}
+		services.providers = nil
+		services.exporter = nil
+		return provider.Shutdown(ctx)
+	}, nil
+}
+
+// services holds the tracer providers of the services simulated by the
+// synthetic_traces scenario. They share the exporter of the sresim
+// provider but report their spans as coming from other services.
+var services struct {
+	sync.Mutex
+	exporter  sdktrace.SpanExporter
+	providers map[string]*sdktrace.TracerProvider
+}
+
+// sharedExporter leaves shutting down the exporter to the sresim provider.
+type sharedExporter struct {
+	sdktrace.SpanExporter
+}
+
+func (sharedExporter) Shutdown(context.Context) error { return nil }
+
+// ServiceTracer returns a tracer whose spans are reported as coming from
+// the named service rather than sresim. While traces are not exported it
+// returns the tracer of sresim spans.
+func ServiceTracer(service string) trace.Tracer {
+	services.Lock()
+	defer services.Unlock()
+	if services.exporter == nil {
+		return Tracer()
+	}
+	p, ok := services.providers[service]
+	if !ok {
+		p = sdktrace.NewTracerProvider(
+			sdktrace.WithBatcher(services.exporter),
+			sdktrace.WithResource(resource.NewSchemaless(semconv.ServiceName(service), SyntheticKey.Bool(true))),
+		)
+		if services.providers == nil {
+			services.providers = make(map[string]*sdktrace.TracerProvider)
+		}
+		services.providers[service] = p
+	}
+	return p.Tracer(instrumentationName)
+}
+
+// Tracer returns the tracer of sresim spans. It is looked up on every call,
+// so that spans go to whichever provider is installed, e.g. one with an
+// in-memory exporter in tests.
+func Tracer() trace.Tracer {
+	return otel.Tracer(instrumentationName, trace.WithInstrumentationVersion(version.Get().Version))
+}
+
+// Middleware returns middleware that starts a server span for every
+// request. Spans are named after the method and the pattern of the route the
+// request matches in routes, e.g. GET /experiments/{id}/report, or after the
+// method alone if it matches none. The middleware continues the trace of the
+// caller if the request carries W3C trace context, and must be the
+// outermost one so that the faults injected by the inner ones end up in the
+// span.
+func Middleware(routes metrics.Router) func(http.Handler) http.Handler {
+	return func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			name := r.Method
+			attrs := []attribute.KeyValue{
+				semconv.HTTPRequestMethodKey.String(r.Method),
+				semconv.URLPath(r.URL.Path),
+				semconv.UserAgentOriginal(r.UserAgent()),
+			}
+			if route := metrics.Route(routes, r); route != metrics.Other {
+				name += " " + route
+				attrs = append(attrs, semconv.HTTPRoute(route))
+			}
+			ctx := otel.GetTextMapPropagator().Extract(r.Context(), propagation.HeaderCarrier(r.Header))
+			ctx, span := Tracer().Start(ctx, name, trace.WithSpanKind(trace.SpanKindServer), trace.WithAttributes(attrs...))
+			defer span.End()
+
+			rw := response.Wrap(w)
+			defer func() {
+				// Mark the span of a request whose handler panicked as failed and
+				// let the panic go on. Ending the span records it as an exception.
+				if p := recover(); p != nil {
+					if !rw.WroteHeader() {
+						span.SetAttributes(semconv.HTTPResponseStatusCode(http.StatusInternalServerError))
+					}
+					span.SetStatus(codes.Error, "panic")
+					panic(p)
+				}
+			}()
+			next.ServeHTTP(rw, r.WithContext(ctx))
+
+			span.SetAttributes(semconv.HTTPResponseStatusCode(rw.Status()))
+			if rw.Status() >= 500 {
+				span.SetStatus(codes.Error, http.StatusText(rw.Status()))
+			}
+		})
+	}
+}
+
+// RecordFault records a fault injected into the request traced by ctx as
+// an event of its span, and sets the fault attributes on the span so that
+// affected requests can be searched for.
+func RecordFault(ctx context.Context, fault string, attrs ...attribute.KeyValue) {
+	span := trace.SpanFromContext(ctx)
+	attrs = append([]attribute.KeyValue{FaultTypeKey.String(fault)}, attrs...)
+	span.AddEvent(FaultInjectedEvent, trace.WithAttributes(attrs...))
+	span.SetAttributes(attrs...)
+}
+
+// StartRun starts the span of a scenario run. It is a root span that lasts
+// as long as the run.
+func StartRun(scenario, runID string, params map[string]interface{}) trace.Span {
+	attrs := []attribute.KeyValue{ScenarioKey.String(scenario), RunIDKey.String(runID)}
+	keys := make([]string, 0, len(params))
+	for k := range params {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+	for _, k := range keys {
+		attrs = append(attrs, attribute.String(ParameterPrefix+k, fmt.Sprint(params[k])))
+	}
+	_, span := Tracer().Start(context.Background(), "scenario "+scenario,
+		trace.WithNewRoot(),
+		trace.WithSpanKind(trace.SpanKindInternal),
+		trace.WithAttributes(attrs...),
+	)
+	return span
+}
+
+// Inject adds the trace context of ctx to the headers of an outgoing
+// request.
+func Inject(ctx context.Context, header http.Header) {
+	otel.GetTextMapPropagator().Inject(ctx, propagation.HeaderCarrier(header))
+}
+
+// StartClient starts a client span for an outgoing request, e.g. of load
+// generation or a webhook delivery, and adds its trace context to the
+// request, so that the server continues the trace. The span is a child of
+// the span of the request's context, if any. End it with EndClient.
+func StartClient(req *http.Request, name string, attrs ...attribute.KeyValue) (*http.Request, trace.Span) {
+	attrs = append([]attribute.KeyValue{
+		semconv.HTTPRequestMethodKey.String(req.Method),
+		semconv.URLFull(req.URL.String()),
+	}, attrs...)
+	ctx, span := Tracer().Start(req.Context(), name, trace.WithSpanKind(trace.SpanKindClient), trace.WithAttributes(attrs...))
+	req = req.WithContext(ctx)
+	Inject(ctx, req.Header)
+	return req, span
+}
+
+// EndClient records the outcome of the request of a client span started by
+// StartClient and ends the span.
+func EndClient(span trace.Span, resp *http.Response, err error) {
+	switch {
+	case err != nil:
+		span.RecordError(err)
+		span.SetStatus(codes.Error, err.Error())
+	case resp.StatusCode >= 400:
+		span.SetAttributes(semconv.HTTPResponseStatusCode(resp.StatusCode))
+		span.SetStatus(codes.Error, resp.Status)
+	default:
+		span.SetAttributes(semconv.HTTPResponseStatusCode(resp.StatusCode))
+	}
+	span.End()
+}
@@ -0,0 +1,167 @@
+package tracing
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"go.opentelemetry.io/otel"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/codes"
+	"go.opentelemetry.io/otel/propagation"
+	sdktrace "go.opentelemetry.io/otel/sdk/trace"
+	"go.opentelemetry.io/otel/sdk/trace/tracetest"
+	"go.opentelemetry.io/otel/trace"
+)
+
+// recordSpans installs a tracer provider that keeps finished spans in
+// memory.
+func recordSpans(t *testing.T) *tracetest.InMemoryExporter {
+	t.Helper()
+	exporter := tracetest.NewInMemoryExporter()
+	provider := sdktrace.NewTracerProvider(sdktrace.WithSyncer(exporter))
+	prevProvider, prevPropagator := otel.GetTracerProvider(), otel.GetTextMapPropagator()
+	otel.SetTracerProvider(provider)
+	otel.SetTextMapPropagator(propagation.TraceContext{})
+	t.Cleanup(func() {
+		otel.SetTracerProvider(prevProvider)
+		otel.SetTextMapPropagator(prevPropagator)
+	})
+	return exporter
+}
+
+func attributes(kvs []attribute.KeyValue) map[attribute.Key]attribute.Value {
+	m := make(map[attribute.Key]attribute.Value, len(kvs))
+	for _, kv := range kvs {
+		m[kv.Key] = kv.Value
+	}
+	return m
+}
+
+// routed serves h at pattern behind Middleware.
+func routed(pattern string, h http.HandlerFunc) http.Handler {
+	mux := http.NewServeMux()
+	mux.Handle(pattern, h)
+	return Middleware(mux)(mux)
+}
+
+func TestMiddleware(t *testing.T) {
+	exporter := recordSpans(t)
+	handler := routed("GET /experiments/{id}/report", func(w http.ResponseWriter, r *http.Request) {
+		RecordFault(r.Context(), "delay", FaultDelayKey.Int64(250))
+		http.Error(w, "Simulated failure", http.StatusInternalServerError)
+	})
+
+	req := httptest.NewRequest(http.MethodGet, "/experiments/abc123/report", nil)
+	req.Header.Set("traceparent", "00-4bf92f3577b34da6a3ce929d0e0e4736-00f067aa0ba902b7-01")
+	handler.ServeHTTP(httptest.NewRecorder(), req)
+
+	spans := exporter.GetSpans()
+	require.Len(t, spans, 1)
+	span := spans[0]
+	assert.Equal(t, "GET /experiments/{id}/report", span.Name, "spans are named after the route, not the path")
+	assert.Equal(t, trace.SpanKindServer, span.SpanKind)
+	assert.Equal(t, "4bf92f3577b34da6a3ce929d0e0e4736", span.SpanContext.TraceID().String(), "the trace of the caller is continued")
+	assert.Equal(t, "00f067aa0ba902b7", span.Parent.SpanID().String())
+	assert.Equal(t, codes.Error, span.Status.Code)
+
+	attrs := attributes(span.Attributes)
+	assert.Equal(t, "/experiments/{id}/report", attrs["http.route"].AsString())
+	assert.Equal(t, "/experiments/abc123/report", attrs["url.path"].AsString())
+	assert.Equal(t, int64(500), attrs["http.response.status_code"].AsInt64())
+	assert.Equal(t, "delay", attrs[FaultTypeKey].AsString())
+	assert.Equal(t, int64(250), attrs[FaultDelayKey].AsInt64())
+
+	require.Len(t, span.Events, 1)
+	assert.Equal(t, FaultInjectedEvent, span.Events[0].Name)
+	assert.Equal(t, "delay", attributes(span.Events[0].Attributes)[FaultTypeKey].AsString())
+}
+
+func TestMiddlewareWithoutTraceContext(t *testing.T) {
+	exporter := recordSpans(t)
+	handler := routed("GET /simulate", func(w http.ResponseWriter, r *http.Request) {})
+
+	handler.ServeHTTP(httptest.NewRecorder(), httptest.NewRequest(http.MethodPost, "/scenarios/run", nil))
+
+	spans := exporter.GetSpans()
+	require.Len(t, spans, 1)
+	assert.Equal(t, "POST", spans[0].Name, "requests that match no route are named after the method")
+	assert.False(t, spans[0].Parent.IsValid(), "a request without trace context starts a new trace")
+	assert.Equal(t, codes.Unset, spans[0].Status.Code, "4xx responses are not span errors")
+	assert.Equal(t, int64(404), attributes(spans[0].Attributes)["http.response.status_code"].AsInt64())
+}
+
+func TestMiddlewarePanic(t *testing.T) {
+	exporter := recordSpans(t)
+	handler := routed("/simulate", func(w http.ResponseWriter, r *http.Request) {
+		panic("boom")
+	})
+
+	assert.PanicsWithValue(t, "boom", func() {
+		handler.ServeHTTP(httptest.NewRecorder(), httptest.NewRequest(http.MethodGet, "/simulate", nil))
+	}, "the panic is left to the recovery middleware")
+
+	spans := exporter.GetSpans()
+	require.Len(t, spans, 1)
+	assert.Equal(t, codes.Error, spans[0].Status.Code)
+	assert.Equal(t, int64(500), attributes(spans[0].Attributes)["http.response.status_code"].AsInt64())
+	require.Len(t, spans[0].Events, 1)
+	assert.Equal(t, "exception", spans[0].Events[0].Name)
+	assert.Equal(t, "boom", attributes(spans[0].Events[0].Attributes)["exception.message"].AsString())
+}
+
+func TestStartRun(t *testing.T) {
+	exporter := recordSpans(t)
+
+	span := StartRun("latency", "run-1", map[string]interface{}{"delay_ms": 200})
+	span.End()
+
+	spans := exporter.GetSpans()
+	require.Len(t, spans, 1)
+	assert.Equal(t, "scenario latency", spans[0].Name)
+	assert.False(t, spans[0].Parent.IsValid())
+	attrs := attributes(spans[0].Attributes)
+	assert.Equal(t, "latency", attrs[ScenarioKey].AsString())
+	assert.Equal(t, "run-1", attrs[RunIDKey].AsString())
+	assert.Equal(t, "200", attrs[ParameterPrefix+"delay_ms"].AsString())
+}
+
+func TestInject(t *testing.T) {
+	recordSpans(t)
+	ctx, span := Tracer().Start(httptest.NewRequest(http.MethodGet, "/", nil).Context(), "client")
+	defer span.End()
+
+	header := http.Header{}
+	Inject(ctx, header)
+	assert.Contains(t, header.Get("traceparent"), span.SpanContext().TraceID().String())
+}
+
+func TestStartClient(t *testing.T) {
+	exporter := recordSpans(t)
+	var traceparent string
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		traceparent = r.Header.Get("traceparent")
+		w.WriteHeader(http.StatusServiceUnavailable)
+	}))
+	defer server.Close()
+
+	req, err := http.NewRequest(http.MethodGet, server.URL+"/simulate", nil)
+	require.NoError(t, err)
+	req, span := StartClient(req, "loadgen GET", RunIDKey.String("run-1"))
+	resp, err := http.DefaultClient.Do(req)
+	require.NoError(t, err)
+	resp.Body.Close()
+	EndClient(span, resp, err)
+
+	spans := exporter.GetSpans()
+	require.Len(t, spans, 1)
+	assert.Equal(t, "loadgen GET", spans[0].Name)
+	assert.Equal(t, trace.SpanKindClient, spans[0].SpanKind)
+	assert.Equal(t, codes.Error, spans[0].Status.Code)
+	assert.Contains(t, traceparent, spans[0].SpanContext.SpanID().String(), "the server continues the client span")
+	attrs := attributes(spans[0].Attributes)
+	assert.Equal(t, int64(503), attrs["http.response.status_code"].AsInt64())
+	assert.Equal(t, "run-1", attrs[RunIDKey].AsString())
+}
@@ -0,0 +1,84 @@
+// Package version describes the build of the running binary.
+package version
+
+import (
+	"encoding/json"
+	"net/http"
+	"runtime"
+	"runtime/debug"
+)
+
+// Set at build time with
+//
+//	-ldflags "-X github.com/localstack/sresim/app-sresim/pkg/version.Version=v1.2.3 ..."
+//
+// Commit and BuildDate fall back to the VCS information Go embeds when
+// building from a git checkout.
+var (
+	Version   = ""
+	Commit    = ""
+	BuildDate = ""
+)
+
+// Info identifies the build of sresim.
+type Info struct {
+	Version   string `json:"version"`
+	Commit    string `json:"commit"`
+	BuildDate string `json:"build_date"`
+	GoVersion string `json:"go_version"`
+	// Modified is set when the binary was built from a checkout with
+	// uncommitted changes.
+	Modified bool `json:"modified,omitempty"`
+}
+
+// Get returns the build information of the running binary. Unknown fields
+// are "unknown", and the version is "dev" for untagged builds.
+func Get() Info {
+	info := Info{Version: Version, Commit: Commit, BuildDate: BuildDate, GoVersion: runtime.Version()}
+	if bi, ok := debug.ReadBuildInfo(); ok {
+		fromBuildInfo(&info, bi)
+	}
+	if info.Version == "" {
+		info.Version = "dev"
+	}
+	if info.Commit == "" {
+		info.Commit = "unknown"
+	}
+	if info.BuildDate == "" {
+		info.BuildDate = "unknown"
+	}
+	return info
+}
+
+// fromBuildInfo fills the fields not set through ldflags.
+func fromBuildInfo(info *Info, bi *debug.BuildInfo) {
+	// Installed with go install module@version.
+	if info.Version == "" && bi.Main.Version != "" && bi.Main.Version != "(devel)" {
+		info.Version = bi.Main.Version
+	}
+	for _, s := range bi.Settings {
+		switch s.Key {
+		case "vcs.revision":
+			if info.Commit == "" {
+				info.Commit = s.Value
+			}
+		case "vcs.time":
+			if info.BuildDate == "" {
+				info.BuildDate = s.Value
+			}
+		case "vcs.modified":
+			info.Modified = s.Value == "true"
+		}
+	}
+}
+
+// Handler serves GET /version.
+func Handler(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet {
+		w.Header().Set("Allow", "GET")
+		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(Get())
+}
@@ -0,0 +1,57 @@
+package version
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"runtime"
+	"runtime/debug"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestFromBuildInfo(t *testing.T) {
+	bi := &debug.BuildInfo{
+		Main: debug.Module{Version: "v1.4.0"},
+		Settings: []debug.BuildSetting{
+			{Key: "vcs.revision", Value: "0a1b2c3"},
+			{Key: "vcs.time", Value: "2024-03-25T12:00:00Z"},
+			{Key: "vcs.modified", Value: "true"},
+		},
+	}
+
+	info := Info{}
+	fromBuildInfo(&info, bi)
+	assert.Equal(t, Info{Version: "v1.4.0", Commit: "0a1b2c3", BuildDate: "2024-03-25T12:00:00Z", Modified: true}, info)
+
+	// Values injected with ldflags win.
+	info = Info{Version: "v1.5.0-rc.1", Commit: "fffffff", BuildDate: "2024-04-01T00:00:00Z"}
+	fromBuildInfo(&info, bi)
+	assert.Equal(t, "v1.5.0-rc.1", info.Version)
+	assert.Equal(t, "fffffff", info.Commit)
+	assert.Equal(t, "2024-04-01T00:00:00Z", info.BuildDate)
+
+	info = Info{}
+	fromBuildInfo(&info, &debug.BuildInfo{Main: debug.Module{Version: "(devel)"}})
+	assert.Empty(t, info.Version)
+}
+
+func TestHandler(t *testing.T) {
+	t.Cleanup(func() { Version, Commit, BuildDate = "", "", "" })
+	Version, Commit, BuildDate = "v1.4.0", "0a1b2c3", "2024-03-25T12:00:00Z"
+
+	rec := httptest.NewRecorder()
+	Handler(rec, httptest.NewRequest(http.MethodGet, "/version", nil))
+	require.Equal(t, http.StatusOK, rec.Code)
+	var info Info
+	require.NoError(t, json.Unmarshal(rec.Body.Bytes(), &info))
+	assert.Equal(t, "v1.4.0", info.Version)
+	assert.Equal(t, "0a1b2c3", info.Commit)
+	assert.Equal(t, runtime.Version(), info.GoVersion)
+
+	rec = httptest.NewRecorder()
+	Handler(rec, httptest.NewRequest(http.MethodPost, "/version", nil))
+	assert.Equal(t, http.StatusMethodNotAllowed, rec.Code)
+}